@@ -0,0 +1,59 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const DCAPath = "/trader/dca"
+
+type DCARequest struct {
+	ExchangeName string
+
+	ProductID string
+
+	// Amount is the value in quote currency and Size is the size in base
+	// currency to buy every interval. Only one of them must be set.
+	Amount decimal.Decimal
+	Size   decimal.Decimal
+
+	Interval time.Duration
+
+	// Ceiling when non-zero, defers the due buy while the ticker price is at or
+	// above the ceiling price.
+	Ceiling decimal.Decimal
+
+	// SkipFundsCheck when true, creates the job without checking the
+	// available balance on the exchange.
+	SkipFundsCheck bool
+}
+
+type DCAResponse struct {
+	UID string
+}
+
+func (r *DCARequest) Check() error {
+	if len(r.ExchangeName) == 0 {
+		return fmt.Errorf("exchange name cannot be empty")
+	}
+	if len(r.ProductID) == 0 {
+		return fmt.Errorf("product id cannot be empty")
+	}
+	if r.Amount.IsNegative() || r.Size.IsNegative() {
+		return fmt.Errorf("amount or size cannot be negative")
+	}
+	if r.Amount.IsZero() == r.Size.IsZero() {
+		return fmt.Errorf("exactly one of amount or size must be set")
+	}
+	if r.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if r.Ceiling.IsNegative() {
+		return fmt.Errorf("ceiling price cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package api
+
+import "github.com/bvk/tradebot/gobs"
+
+const ExchangeBalancesPath = "/exchange/balances"
+
+type ExchangeBalancesRequest struct {
+	ExchangeName string
+}
+
+type ExchangeBalancesResponse struct {
+	Error string
+
+	Accounts []*gobs.Account
+}
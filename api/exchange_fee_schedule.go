@@ -0,0 +1,17 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package api
+
+import "github.com/bvk/tradebot/gobs"
+
+const ExchangeFeeSchedulePath = "/exchange/fee-schedule"
+
+type ExchangeFeeScheduleRequest struct {
+	ExchangeName string
+}
+
+type ExchangeFeeScheduleResponse struct {
+	Error string
+
+	FeeSchedule *gobs.FeeSchedule
+}
@@ -2,7 +2,10 @@
 
 package api
 
-import "github.com/bvk/tradebot/gobs"
+import (
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+)
 
 const ExchangeGetProductPath = "/exchange/get-product"
 
@@ -15,4 +18,8 @@ type ExchangeGetProductResponse struct {
 	Error string
 
 	Product *gobs.Product
+
+	// BestBidAsk holds the best bid and ask from the order book when the
+	// product is being watched by the server.
+	BestBidAsk *exchange.BidAsk
 }
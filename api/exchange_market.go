@@ -0,0 +1,53 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package api
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+const ExchangeMarketPath = "/exchange/market"
+
+type ExchangeMarketRequest struct {
+	ExchangeName string
+	ProductID    string
+
+	// ClientOrderID is optional. A random id is used when it is empty. Retries
+	// with the same client order id do not create duplicate orders.
+	ClientOrderID string
+
+	Side string
+
+	// Exactly one of the Size or Funds must be non-zero. Size is in the base
+	// currency and Funds is in the quote currency.
+	Size  decimal.Decimal
+	Funds decimal.Decimal
+}
+
+type ExchangeMarketResponse struct {
+	Error string
+
+	ClientOrderID string
+	OrderID       string
+}
+
+func (r *ExchangeMarketRequest) Check() error {
+	if len(r.ExchangeName) == 0 {
+		return fmt.Errorf("exchange name cannot be empty")
+	}
+	if len(r.ProductID) == 0 {
+		return fmt.Errorf("product id cannot be empty")
+	}
+	if r.Side != "BUY" && r.Side != "SELL" {
+		return fmt.Errorf("side must be one of BUY or SELL")
+	}
+	if r.Size.IsZero() == r.Funds.IsZero() {
+		return fmt.Errorf("exactly one of size or funds must be non-zero")
+	}
+	if r.Size.IsNegative() || r.Funds.IsNegative() {
+		return fmt.Errorf("size or funds cannot be negative")
+	}
+	return nil
+}
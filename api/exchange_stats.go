@@ -0,0 +1,39 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package api
+
+import "time"
+
+const ExchangeStatsPath = "/exchange/stats"
+
+type ExchangeStatsRequest struct {
+	ExchangeName string
+}
+
+// ExchangeThrottle describes the current state of a REST api request budget.
+type ExchangeThrottle struct {
+	Name string
+
+	Limit float64
+	Burst int
+
+	Tokens float64
+
+	NumRequests  int64
+	NumThrottled int64
+
+	// BackoffUntil is non-zero when requests are paused because of the
+	// throttling responses from the exchange.
+	BackoffUntil time.Time
+}
+
+type ExchangeStatsResponse struct {
+	Error string
+
+	// NumGaps is the number of websocket message gaps found and NumResyncs is
+	// the number of order resyncs performed because of them.
+	NumGaps    int64
+	NumResyncs int64
+
+	Throttles []*ExchangeThrottle
+}
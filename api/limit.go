@@ -16,6 +16,10 @@ type LimitRequest struct {
 	ProductID string
 
 	Point *point.Point
+
+	// SkipFundsCheck when true, creates the job without checking the
+	// available balance on the exchange.
+	SkipFundsCheck bool
 }
 
 type LimitResponse struct {
@@ -17,6 +17,10 @@ type LoopRequest struct {
 
 	Buy  *point.Point
 	Sell *point.Point
+
+	// SkipFundsCheck when true, creates the job without checking the
+	// available balance on the exchange.
+	SkipFundsCheck bool
 }
 
 type LoopResponse struct {
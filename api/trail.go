@@ -0,0 +1,69 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package api
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+const TrailPath = "/trader/trail"
+
+type TrailRequest struct {
+	ExchangeName string
+
+	ProductID string
+
+	Size decimal.Decimal
+
+	// TrailAmount is the retrace amount in quote currency and TrailPct is the
+	// retrace percentage from the high price. Only one of them must be set.
+	TrailAmount decimal.Decimal
+	TrailPct    decimal.Decimal
+
+	// OrderType is one of MARKET or LIMIT. LimitOffset is the offset below the
+	// stop price for the LIMIT orders.
+	OrderType   string
+	LimitOffset decimal.Decimal
+
+	// ActivationPrice when non-zero, delays the trail till the ticker price
+	// reaches the activation price.
+	ActivationPrice decimal.Decimal
+
+	// SkipFundsCheck when true, creates the job without checking the
+	// available balance on the exchange.
+	SkipFundsCheck bool
+}
+
+type TrailResponse struct {
+	UID string
+}
+
+func (r *TrailRequest) Check() error {
+	if len(r.ExchangeName) == 0 {
+		return fmt.Errorf("exchange name cannot be empty")
+	}
+	if len(r.ProductID) == 0 {
+		return fmt.Errorf("product id cannot be empty")
+	}
+	if !r.Size.IsPositive() {
+		return fmt.Errorf("size must be positive")
+	}
+	if r.TrailAmount.IsNegative() || r.TrailPct.IsNegative() {
+		return fmt.Errorf("trail amount or percentage cannot be negative")
+	}
+	if r.TrailAmount.IsZero() == r.TrailPct.IsZero() {
+		return fmt.Errorf("exactly one of trail amount or percentage must be set")
+	}
+	if r.OrderType != "MARKET" && r.OrderType != "LIMIT" {
+		return fmt.Errorf("order type must be one of MARKET or LIMIT")
+	}
+	if r.LimitOffset.IsNegative() {
+		return fmt.Errorf("limit offset cannot be negative")
+	}
+	if r.ActivationPrice.IsNegative() {
+		return fmt.Errorf("activation price cannot be negative")
+	}
+	return nil
+}
@@ -16,6 +16,10 @@ type WallRequest struct {
 	ProductID string
 
 	Pairs []*point.Pair
+
+	// SkipFundsCheck when true, creates the job without checking the
+	// available balance on the exchange.
+	SkipFundsCheck bool
 }
 
 type WallResponse struct {
@@ -0,0 +1,160 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+// Package backtest replays historical candles through trader jobs on a
+// simulated product to estimate their performance.
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bvk/tradebot/clock"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/paper"
+	"github.com/bvk/tradebot/timerange"
+	"github.com/bvk/tradebot/trader"
+	"github.com/bvkgo/kv/kvmemdb"
+)
+
+// CandleScanner is the interface to read historical candles for a product,
+// which is implemented by the coinbase.Datastore.
+type CandleScanner interface {
+	ScanCandles(ctx context.Context, productID string, begin, end time.Time, fn func(*gobs.Candle) error) error
+}
+
+type Options struct {
+	// FeePct is the fee percentage charged by the simulated exchange. Zero
+	// value is replaced with the default fee percentage.
+	FeePct float64
+
+	// NoFees when true, simulates an exchange without any fees and FeePct is
+	// ignored.
+	NoFees bool
+}
+
+func (v *Options) setDefaults() {
+	if v.NoFees {
+		v.FeePct = 0
+	} else if v.FeePct == 0 {
+		v.FeePct = 0.25
+	}
+}
+
+// Run replays the candles for the trader's product between the begin and end
+// times through the trader job on a simulated product. Trader job is stopped
+// after all candles are replayed. Trader state is saved to a temporary,
+// in-memory database, so the input trader must not be used for real trading
+// after the backtest.
+//
+// Returns the time range covered by the replayed candles, which can be passed
+// to the trader's Status method.
+func Run(ctx context.Context, t trader.Trader, product *gobs.Product, candles CandleScanner, begin, end time.Time, opts *Options) (*timerange.Range, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	opts.setDefaults()
+
+	if product.ProductID != t.ProductID() {
+		return nil, fmt.Errorf("product %q doesn't match the trader product %q: %w", product.ProductID, t.ProductID(), os.ErrInvalid)
+	}
+
+	// Simulated clock starts at the begin time and is moved forward to the
+	// timestamp of each replayed ticker.
+	clk := clock.NewSimulated(begin)
+
+	popts := &paper.Options{
+		FeePct:       opts.FeePct,
+		NoFees:       opts.NoFees,
+		ExchangeName: t.ExchangeName(),
+		SyncTickers:  true,
+		Clock:        clk,
+	}
+	ex := paper.New(nil, popts)
+	defer ex.Close()
+
+	p, err := ex.AddProduct(product)
+	if err != nil {
+		return nil, fmt.Errorf("could not create simulated product: %w", err)
+	}
+
+	rt := &trader.Runtime{
+		Database:  kvmemdb.New(),
+		Product:   p,
+		Messenger: logMessenger{},
+		Clock:     clk,
+	}
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// Trader job is declared as a ticker receiver, so that tickers are not
+	// replayed while the job is between its steps.
+	removeReceiver := p.AddSyncReceiver()
+	doneCh := make(chan error, 1)
+	go func() {
+		err := t.Run(runCtx, rt)
+		removeReceiver()
+		doneCh <- err
+	}()
+
+	var period timerange.Range
+	replay := func(c *gobs.Candle) error {
+		if period.Begin.IsZero() {
+			period.Begin = c.StartTime.Time
+		}
+		period.End = c.StartTime.Time.Add(c.Duration)
+
+		for _, ticker := range candleTickers(c) {
+			clk.Set(ticker.Timestamp.Time)
+			p.HandleTicker(ticker)
+		}
+		return runCtx.Err()
+	}
+	if err := candles.ScanCandles(ctx, product.ProductID, begin, end, replay); err != nil {
+		cancel(err)
+		<-doneCh
+		return nil, fmt.Errorf("could not replay the candles: %w", err)
+	}
+
+	cancel(errDone)
+	if err := <-doneCh; err != nil && !errors.Is(err, errDone) {
+		return nil, fmt.Errorf("trader job has failed: %w", err)
+	}
+	if period.Begin.IsZero() {
+		return nil, fmt.Errorf("no candles found for product %q in the time range: %w", product.ProductID, os.ErrNotExist)
+	}
+	return &period, nil
+}
+
+var errDone = errors.New("backtest is complete")
+
+// candleTickers approximates the price movement within a candle as four
+// tickers spread evenly over the candle duration. Price is assumed to visit
+// the low before the high for the bullish candles and the high before the low
+// for the bearish candles.
+func candleTickers(c *gobs.Candle) []*exchange.Ticker {
+	second, third := c.High, c.Low
+	if c.Close.GreaterThanOrEqual(c.Open) {
+		second, third = c.Low, c.High
+	}
+
+	step := c.Duration / 4
+	start := c.StartTime.Time
+	return []*exchange.Ticker{
+		{Timestamp: exchange.RemoteTime{Time: start}, Price: c.Open},
+		{Timestamp: exchange.RemoteTime{Time: start.Add(step)}, Price: second},
+		{Timestamp: exchange.RemoteTime{Time: start.Add(2 * step)}, Price: third},
+		{Timestamp: exchange.RemoteTime{Time: start.Add(3 * step)}, Price: c.Close},
+	}
+}
+
+type logMessenger struct{}
+
+func (logMessenger) SendMessage(ctx context.Context, at time.Time, msgfmt string, args ...interface{}) {
+	log.Printf("backtest: "+msgfmt, args...)
+}
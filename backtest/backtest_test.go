@@ -0,0 +1,77 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package backtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/looper"
+	"github.com/bvk/tradebot/point"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type testCandles []*gobs.Candle
+
+func (vs testCandles) ScanCandles(ctx context.Context, productID string, begin, end time.Time, fn func(*gobs.Candle) error) error {
+	for _, v := range vs {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestLooperBacktest(t *testing.T) {
+	ctx := context.Background()
+
+	// Prices move by one unit every minute and oscillate between 90 and 110.
+	var candles testCandles
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	price := decimal.NewFromInt(110)
+	step := decimal.NewFromInt(1)
+	for i := 0; i < 200; i++ {
+		if (i/20)%2 == 0 {
+			step = decimal.NewFromInt(-1)
+		} else {
+			step = decimal.NewFromInt(1)
+		}
+		open, close := price, price.Add(step)
+		candles = append(candles, &gobs.Candle{
+			StartTime: gobs.RemoteTime{Time: start.Add(time.Duration(i) * time.Minute)},
+			Duration:  time.Minute,
+			Low:       decimal.Min(open, close),
+			High:      decimal.Max(open, close),
+			Open:      open,
+			Close:     close,
+		})
+		price = close
+	}
+
+	buy := &point.Point{Size: decimal.NewFromInt(1), Price: decimal.NewFromInt(95), Cancel: decimal.NewFromInt(100)}
+	sell := &point.Point{Size: decimal.NewFromInt(1), Price: decimal.NewFromInt(105), Cancel: decimal.NewFromInt(100)}
+	loop, err := looper.New(uuid.New().String(), "paper", "BTC-USD", buy, sell)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	product := &gobs.Product{ProductID: "BTC-USD"}
+	period, err := Run(ctx, loop, product, candles, time.Time{}, time.Time{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := loop.Status(period)
+	t.Logf("buys %d sells %d profit %s fees %s", status.NumBuys, status.NumSells, status.Profit(), status.Fees())
+	// Price range is crossed five times in both directions.
+	if status.NumBuys != 5 || status.NumSells != 5 {
+		t.Fatalf("want 5 buys and 5 sells, got %d buys and %d sells", status.NumBuys, status.NumSells)
+	}
+	// Each buy-sell pair makes 10 minus fees of 0.25% at both buy and sell.
+	if want := decimal.RequireFromString("47.5"); !status.Profit().Equal(want) {
+		t.Fatalf("want profit %s, got %s", want, status.Profit())
+	}
+}
@@ -0,0 +1,590 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+// Package binancetest implements an in-process fake of the binance spot REST
+// service for the tests.
+//
+// Fake server keeps all symbols, balances, orders and trades in memory. Open
+// limit orders are matched against the ticker prices scripted by the tests
+// using the Tick method. Signed requests must be signed with the Key and
+// Secret credentials.
+package binancetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bvk/tradebot/binance/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/shopspring/decimal"
+)
+
+// Key and Secret are the only API credentials accepted by the fake server.
+const (
+	Key    = "test-key"
+	Secret = "test-secret"
+)
+
+type Server struct {
+	httpServer *httptest.Server
+
+	mu sync.Mutex
+
+	// symbolMap and priceMap are indexed by the binance symbol names.
+	symbolMap map[string]*internal.Symbol
+	priceMap  map[string]decimal.Decimal
+
+	balanceMap map[string]*internal.Balance
+
+	rates internal.CommissionRates
+
+	lastOrderID int64
+	lastTradeID int64
+
+	// orderMap holds all orders indexed by the order id.
+	orderMap map[int64]*internal.Order
+
+	trades []*internal.Trade
+
+	numRequests int
+
+	// rejectStatus and retryAfter, when set, reject the next request with the
+	// status code and the Retry-After header.
+	rejectStatus int
+	retryAfter   time.Duration
+}
+
+// NewServer creates and starts a fake binance server on the loopback
+// interface. Caller must close the server when it is no longer necessary.
+func NewServer() *Server {
+	s := &Server{
+		symbolMap:  make(map[string]*internal.Symbol),
+		priceMap:   make(map[string]decimal.Decimal),
+		balanceMap: make(map[string]*internal.Balance),
+		orderMap:   make(map[int64]*internal.Order),
+		rates: internal.CommissionRates{
+			Maker: exchange.NullDecimal{Decimal: decimal.RequireFromString("0.001")},
+			Taker: exchange.NullDecimal{Decimal: decimal.RequireFromString("0.001")},
+		},
+	}
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// URL returns the base URL for the REST service.
+func (s *Server) URL() string {
+	return s.httpServer.URL
+}
+
+// AddProduct adds a new spot symbol with the given initial ticker price.
+// Product id must be in the BASE-QUOTE form, eg: "BTC-USDT".
+func (s *Server) AddProduct(productID string, price decimal.Decimal) {
+	base, quote, _ := strings.Cut(productID, "-")
+	symbol := &internal.Symbol{
+		Symbol:     base + quote,
+		Status:     "TRADING",
+		BaseAsset:  base,
+		QuoteAsset: quote,
+		OrderTypes: []string{"LIMIT", "LIMIT_MAKER", "MARKET"},
+		Filters: []*internal.Filter{
+			{
+				FilterType: "PRICE_FILTER",
+				MinPrice:   exchange.NullDecimal{Decimal: decimal.RequireFromString("0.01")},
+				MaxPrice:   exchange.NullDecimal{Decimal: decimal.NewFromInt(1000000)},
+				TickSize:   exchange.NullDecimal{Decimal: decimal.RequireFromString("0.01")},
+			},
+			{
+				FilterType: "LOT_SIZE",
+				MinQty:     exchange.NullDecimal{Decimal: decimal.RequireFromString("0.00001")},
+				MaxQty:     exchange.NullDecimal{Decimal: decimal.NewFromInt(9000)},
+				StepSize:   exchange.NullDecimal{Decimal: decimal.RequireFromString("0.00001")},
+			},
+			{
+				FilterType:  "NOTIONAL",
+				MinNotional: exchange.NullDecimal{Decimal: decimal.NewFromInt(5)},
+				MaxNotional: exchange.NullDecimal{Decimal: decimal.NewFromInt(9000000)},
+			},
+		},
+	}
+	s.mu.Lock()
+	s.symbolMap[symbol.Symbol] = symbol
+	s.priceMap[symbol.Symbol] = price
+	s.mu.Unlock()
+}
+
+// SetBalance sets the free balance for the asset.
+func (s *Server) SetBalance(asset string, free decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.balanceLocked(asset).Free.Decimal = free
+}
+
+// SetFeeRates sets the maker and taker commission rates, eg: 0.001 for 0.1%.
+func (s *Server) SetFeeRates(maker, taker decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rates.Maker.Decimal = maker
+	s.rates.Taker.Decimal = taker
+}
+
+// Orders returns a copy of all orders known to the server.
+func (s *Server) Orders() []*gobs.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var orders []*gobs.Order
+	for _, v := range s.orderMap {
+		order := &gobs.Order{
+			ServerOrderID: v.Symbol + "-" + strconv.FormatInt(v.OrderID, 10),
+			ClientOrderID: v.ClientOrderID,
+			CreateTime:    gobs.RemoteTime{Time: time.UnixMilli(v.Time)},
+			FinishTime:    gobs.RemoteTime{Time: time.UnixMilli(v.UpdateTime)},
+			Side:          v.Side,
+			Status:        v.Status,
+			FilledSize:    v.ExecutedQty.Decimal,
+			Done:          v.Status != "NEW" && v.Status != "PARTIALLY_FILLED",
+		}
+		if !v.ExecutedQty.Decimal.IsZero() {
+			order.FilledPrice = v.CummulativeQuoteQty.Decimal.Div(v.ExecutedQty.Decimal)
+		}
+		orders = append(orders, order)
+	}
+	return orders
+}
+
+// Tick updates the ticker price for the product and fills the open limit
+// orders as necessary.
+func (s *Server) Tick(productID string, price decimal.Decimal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol := strings.ReplaceAll(productID, "-", "")
+	if _, ok := s.symbolMap[symbol]; !ok {
+		return &internal.Error{StatusCode: http.StatusBadRequest, Code: -1121, Message: "Invalid symbol."}
+	}
+	s.priceMap[symbol] = price
+
+	for _, order := range s.orderMap {
+		if order.Symbol != symbol || order.Status != "NEW" {
+			continue
+		}
+		if crosses(order.Side, order.Price.Decimal, price) {
+			s.fillLocked(order, order.Price.Decimal, order.OrigQty.Decimal, s.rates.Maker.Decimal, true /* maker */)
+		}
+	}
+	return nil
+}
+
+func (s *Server) balanceLocked(asset string) *internal.Balance {
+	b, ok := s.balanceMap[asset]
+	if !ok {
+		b = &internal.Balance{Asset: asset}
+		s.balanceMap[asset] = b
+	}
+	return b
+}
+
+// crosses returns true if an order at the limit price on the given side is
+// executable at the ticker price.
+func crosses(side string, limit, price decimal.Decimal) bool {
+	if side == "BUY" {
+		return price.LessThanOrEqual(limit)
+	}
+	return price.GreaterThanOrEqual(limit)
+}
+
+// fillLocked fills the order completely at the given price in a single
+// trade. Commission is always charged in the quote asset.
+func (s *Server) fillLocked(order *internal.Order, price, size, rate decimal.Decimal, maker bool) {
+	now := time.Now().UnixMilli()
+	value := size.Mul(price)
+	fee := value.Mul(rate)
+
+	order.ExecutedQty.Decimal = size
+	order.CummulativeQuoteQty.Decimal = value
+	order.Status = "FILLED"
+	order.UpdateTime = now
+
+	s.lastTradeID++
+	symbol := s.symbolMap[order.Symbol]
+	s.trades = append(s.trades, &internal.Trade{
+		Symbol:          order.Symbol,
+		ID:              s.lastTradeID,
+		OrderID:         order.OrderID,
+		Price:           exchange.NullDecimal{Decimal: price},
+		Qty:             exchange.NullDecimal{Decimal: size},
+		QuoteQty:        exchange.NullDecimal{Decimal: value},
+		Commission:      exchange.NullDecimal{Decimal: fee},
+		CommissionAsset: symbol.QuoteAsset,
+		Time:            now,
+		IsBuyer:         order.Side == "BUY",
+		IsMaker:         maker,
+	})
+
+	base := s.balanceLocked(symbol.BaseAsset)
+	quote := s.balanceLocked(symbol.QuoteAsset)
+	if order.Side == "BUY" {
+		base.Free.Decimal = base.Free.Decimal.Add(size)
+		quote.Free.Decimal = quote.Free.Decimal.Sub(value.Add(fee))
+	} else {
+		base.Free.Decimal = base.Free.Decimal.Sub(size)
+		quote.Free.Decimal = quote.Free.Decimal.Add(value.Sub(fee))
+	}
+}
+
+// RejectNext makes the server reject the next request with the given status
+// code (eg: 429 or 418) and the Retry-After header.
+func (s *Server) RejectNext(status int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rejectStatus, s.retryAfter = status, retryAfter
+}
+
+// NumRequests returns the number of requests received by the server.
+func (s *Server) NumRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.numRequests
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	s.mu.Lock()
+	s.numRequests++
+	status, retryAfter := s.rejectStatus, s.retryAfter
+	s.rejectStatus = 0
+	s.mu.Unlock()
+
+	if status != 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		writeError(w, status, -1003, "Too many requests.")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v3/time":
+		writeJSON(w, &internal.ServerTime{ServerTime: time.Now().UnixMilli()})
+		return
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v3/exchangeInfo":
+		s.getExchangeInfo(w, r)
+		return
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v3/ticker/price":
+		s.getTickerPrice(w, values)
+		return
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v3/ticker/bookTicker":
+		s.getBookTicker(w, values)
+		return
+	}
+
+	if !isSigned(r) {
+		writeError(w, http.StatusUnauthorized, -1022, "Signature for this request is not valid.")
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v3/order":
+		s.createOrder(w, values)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v3/order":
+		s.getOrder(w, values)
+	case r.Method == http.MethodDelete && r.URL.Path == "/api/v3/order":
+		s.cancelOrder(w, values)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v3/openOrders":
+		s.listOpenOrders(w, values)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v3/myTrades":
+		s.listTrades(w, values)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v3/account":
+		s.getAccount(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// isSigned returns true if the request has the api key header and a valid
+// signature for the query parameters.
+func isSigned(r *http.Request) bool {
+	if r.Header.Get("X-MBX-APIKEY") != Key {
+		return false
+	}
+	query, signature, ok := strings.Cut(r.URL.RawQuery, "&signature=")
+	if !ok || !strings.Contains(query, "timestamp=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(Secret))
+	mac.Write([]byte(query))
+	return hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil))))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeError(w http.ResponseWriter, status, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&internal.Error{Code: code, Message: msg})
+}
+
+func (s *Server) getExchangeInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := &internal.ExchangeInfo{
+		Timezone:   "UTC",
+		ServerTime: time.Now().UnixMilli(),
+	}
+	for _, v := range s.symbolMap {
+		resp.Symbols = append(resp.Symbols, v)
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) getTickerPrice(w http.ResponseWriter, values url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol := values.Get("symbol")
+	price, ok := s.priceMap[symbol]
+	if !ok {
+		writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+	writeJSON(w, &internal.TickerPrice{
+		Symbol: symbol,
+		Price:  exchange.NullDecimal{Decimal: price},
+	})
+}
+
+// getBookTicker returns the ticker price as the best bid with the best ask
+// one tick above it.
+func (s *Server) getBookTicker(w http.ResponseWriter, values url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol := values.Get("symbol")
+	price, ok := s.priceMap[symbol]
+	if !ok {
+		writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+	tick := s.symbolMap[symbol].Filter("PRICE_FILTER").TickSize
+	writeJSON(w, &internal.BookTicker{
+		Symbol:   symbol,
+		BidPrice: exchange.NullDecimal{Decimal: price},
+		BidQty:   exchange.NullDecimal{Decimal: decimal.NewFromInt(1)},
+		AskPrice: exchange.NullDecimal{Decimal: price.Add(tick.Decimal)},
+		AskQty:   exchange.NullDecimal{Decimal: decimal.NewFromInt(1)},
+	})
+}
+
+func (s *Server) createOrder(w http.ResponseWriter, values url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol, ok := s.symbolMap[values.Get("symbol")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+	side := values.Get("side")
+	if side != "BUY" && side != "SELL" {
+		writeError(w, http.StatusBadRequest, -1128, "Invalid side.")
+		return
+	}
+
+	clientOrderID := values.Get("newClientOrderId")
+	for _, v := range s.orderMap {
+		if v.ClientOrderID == clientOrderID && v.Status == "NEW" {
+			writeError(w, http.StatusBadRequest, internal.ErrCodeNewOrderRejected, "Duplicate order sent.")
+			return
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	s.lastOrderID++
+	order := &internal.Order{
+		Symbol:        symbol.Symbol,
+		OrderID:       s.lastOrderID,
+		ClientOrderID: clientOrderID,
+		Side:          side,
+		Type:          values.Get("type"),
+		TimeInForce:   values.Get("timeInForce"),
+		Status:        "NEW",
+		Time:          now,
+		TransactTime:  now,
+		UpdateTime:    now,
+	}
+	price := s.priceMap[symbol.Symbol]
+
+	parse := func(name string) (decimal.Decimal, bool) {
+		v, err := decimal.NewFromString(values.Get(name))
+		return v, err == nil && v.IsPositive()
+	}
+
+	switch order.Type {
+	case "MARKET":
+		size, ok := parse("quantity")
+		if !ok {
+			funds, ok := parse("quoteOrderQty")
+			if !ok {
+				writeError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'quantity' was not sent.")
+				return
+			}
+			step := symbol.Filter("LOT_SIZE").StepSize.Decimal
+			size = funds.Div(price).Truncate(int32(-step.Exponent()))
+		}
+		s.orderMap[order.OrderID] = order
+		order.OrigQty.Decimal = size
+		s.fillLocked(order, price, size, s.rates.Taker.Decimal, false /* maker */)
+
+	case "LIMIT", "LIMIT_MAKER":
+		limit, ok1 := parse("price")
+		size, ok2 := parse("quantity")
+		if !ok1 || !ok2 {
+			writeError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'price' or 'quantity' was not sent.")
+			return
+		}
+		if lot := symbol.Filter("LOT_SIZE"); size.LessThan(lot.MinQty.Decimal) || size.GreaterThan(lot.MaxQty.Decimal) {
+			writeError(w, http.StatusBadRequest, -1013, "Filter failure: LOT_SIZE")
+			return
+		}
+		cross := crosses(side, limit, price)
+		if cross && order.Type == "LIMIT_MAKER" {
+			writeError(w, http.StatusBadRequest, internal.ErrCodeNewOrderRejected, "Order would immediately match and take.")
+			return
+		}
+		order.Price.Decimal = limit
+		order.OrigQty.Decimal = size
+		s.orderMap[order.OrderID] = order
+		switch {
+		case cross:
+			s.fillLocked(order, price, size, s.rates.Taker.Decimal, false /* maker */)
+		case order.TimeInForce == "IOC" || order.TimeInForce == "FOK":
+			order.Status = "EXPIRED"
+		}
+
+	default:
+		writeError(w, http.StatusBadRequest, -1116, "Invalid orderType.")
+		return
+	}
+	writeJSON(w, order)
+}
+
+func (s *Server) findOrderLocked(values url.Values) *internal.Order {
+	symbol := values.Get("symbol")
+	if v := values.Get("orderId"); len(v) > 0 {
+		id, _ := strconv.ParseInt(v, 10, 64)
+		if order, ok := s.orderMap[id]; ok && order.Symbol == symbol {
+			return order
+		}
+		return nil
+	}
+	cid := values.Get("origClientOrderId")
+	var found *internal.Order
+	for _, order := range s.orderMap {
+		if order.Symbol == symbol && order.ClientOrderID == cid {
+			if found == nil || order.OrderID > found.OrderID {
+				found = order
+			}
+		}
+	}
+	return found
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, values url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order := s.findOrderLocked(values)
+	if order == nil {
+		writeError(w, http.StatusBadRequest, internal.ErrCodeNoSuchOrder, "Order does not exist.")
+		return
+	}
+	// Query responses don't include the transaction time.
+	resp := *order
+	resp.TransactTime = 0
+	writeJSON(w, &resp)
+}
+
+func (s *Server) listOpenOrders(w http.ResponseWriter, values url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol := values.Get("symbol")
+	resp := []*internal.Order{}
+	for _, v := range s.orderMap {
+		if v.Symbol != symbol || (v.Status != "NEW" && v.Status != "PARTIALLY_FILLED") {
+			continue
+		}
+		order := *v
+		order.TransactTime = 0
+		resp = append(resp, &order)
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) cancelOrder(w http.ResponseWriter, values url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order := s.findOrderLocked(values)
+	if order == nil || order.Status != "NEW" {
+		writeError(w, http.StatusBadRequest, internal.ErrCodeCancelRejected, "Unknown order sent.")
+		return
+	}
+	order.Status = "CANCELED"
+	order.UpdateTime = time.Now().UnixMilli()
+	writeJSON(w, order)
+}
+
+func (s *Server) listTrades(w http.ResponseWriter, values url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol := values.Get("symbol")
+	orderID, _ := strconv.ParseInt(values.Get("orderId"), 10, 64)
+	trades := []*internal.Trade{}
+	for _, t := range s.trades {
+		if t.Symbol == symbol && (orderID == 0 || t.OrderID == orderID) {
+			trades = append(trades, t)
+		}
+	}
+	writeJSON(w, trades)
+}
+
+func (s *Server) getAccount(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := &internal.Account{
+		CommissionRates: s.rates,
+		CanTrade:        true,
+		UpdateTime:      time.Now().UnixMilli(),
+		Type:            "SPOT",
+	}
+	for _, b := range s.balanceMap {
+		v := *b
+		resp.Balances = append(resp.Balances, &v)
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,423 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+// Package binance implements the exchange interfaces for the binance spot
+// exchange. Binance product ids are in the BASE-QUOTE form (eg: BTC-USDT) to
+// match the other exchanges and are mapped to the binance symbols (eg:
+// BTCUSDT) internally.
+//
+// Exchange uses only the REST api. Ticker prices and the best bid and ask of
+// the opened products and the open orders are polled periodically. All
+// requests are sent within a client-side request weight budget.
+package binance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bvk/tradebot/binance/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/syncmap"
+	"github.com/shopspring/decimal"
+)
+
+var doneStatuses = []string{"FILLED", "CANCELLED", "EXPIRED", "FAILED"}
+
+type Exchange struct {
+	opts Options
+
+	client *internal.Client
+
+	// symbolMap holds the trading rules for the supported products indexed by
+	// the product id.
+	symbolMap map[string]*internal.Symbol
+
+	// productIDMap maps the binance symbols to the product ids.
+	productIDMap map[string]string
+
+	productMap syncmap.Map[string, *Product]
+
+	// openMap holds the last known state of the open orders, which are polled
+	// periodically for the order updates.
+	openMap syncmap.Map[exchange.OrderID, *exchange.Order]
+
+	feesMu sync.Mutex
+	fees   *gobs.FeeSchedule
+}
+
+var _ exchange.Exchange = &Exchange{}
+
+// New creates a client for binance spot exchange.
+func New(ctx context.Context, key, secret string, opts *Options) (_ *Exchange, status error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	opts.setDefaults()
+
+	copts := &internal.Options{
+		RestURL:           opts.RestURL,
+		HttpClientTimeout: opts.HttpClientTimeout,
+		RetryCount:        opts.RetryCount,
+		MaxTimeAdjustment: opts.MaxTimeAdjustment,
+
+		MaxWeightPerMinute: opts.MaxWeightPerMinute,
+	}
+	client, err := internal.New(ctx, key, secret, copts)
+	if err != nil {
+		return nil, fmt.Errorf("could not create binance client: %w", err)
+	}
+	defer func() {
+		if status != nil {
+			client.Close()
+		}
+	}()
+
+	info, err := client.GetExchangeInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch binance exchange info: %w", err)
+	}
+	symbolMap := make(map[string]*internal.Symbol)
+	productIDMap := make(map[string]string)
+	for _, s := range info.Symbols {
+		if !slices.Contains(opts.QuoteCurrencies, s.QuoteAsset) {
+			continue
+		}
+		pid := s.BaseAsset + "-" + s.QuoteAsset
+		symbolMap[pid] = s
+		productIDMap[s.Symbol] = pid
+	}
+
+	ex := &Exchange{
+		opts:         *opts,
+		client:       client,
+		symbolMap:    symbolMap,
+		productIDMap: productIDMap,
+	}
+	client.Go(ex.goPollOrders)
+	return ex, nil
+}
+
+func (ex *Exchange) Close() error {
+	ex.client.Close()
+	return nil
+}
+
+func (ex *Exchange) ExchangeName() string {
+	return "binance"
+}
+
+func (ex *Exchange) IsDone(status string) bool {
+	return slices.Contains(doneStatuses, status)
+}
+
+// formatOrderID returns the order id for a binance order. Binance order ids
+// are unique only within a symbol, so symbol is also included in the order
+// id.
+func formatOrderID(symbol string, id int64) exchange.OrderID {
+	return exchange.OrderID(fmt.Sprintf("%s-%d", symbol, id))
+}
+
+func parseOrderID(id exchange.OrderID) (symbol string, orderID int64, err error) {
+	symbol, num, ok := strings.Cut(string(id), "-")
+	if !ok {
+		return "", 0, fmt.Errorf("invalid binance order id %q: %w", id, os.ErrInvalid)
+	}
+	v, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid binance order id %q: %w", id, os.ErrInvalid)
+	}
+	return symbol, v, nil
+}
+
+// orderStatus maps the binance order status to the order status used by the
+// exchange clients.
+func orderStatus(status string) string {
+	switch status {
+	case "NEW", "PARTIALLY_FILLED", "PENDING_CANCEL":
+		return "OPEN"
+	case "FILLED":
+		return "FILLED"
+	case "CANCELED":
+		return "CANCELLED"
+	case "EXPIRED", "EXPIRED_IN_MATCH":
+		return "EXPIRED"
+	case "REJECTED":
+		return "FAILED"
+	}
+	return status
+}
+
+// exchangeOrder converts a binance order into an exchange order. Fee for the
+// completed orders with non-zero filled size is fetched from the order's
+// trades.
+func (ex *Exchange) exchangeOrder(ctx context.Context, v *internal.Order) (*exchange.Order, error) {
+	created := v.Time
+	if created == 0 {
+		created = v.TransactTime
+	}
+	order := &exchange.Order{
+		OrderID:       formatOrderID(v.Symbol, v.OrderID),
+		ClientOrderID: v.ClientOrderID,
+		Side:          v.Side,
+		CreateTime:    exchange.RemoteTime{Time: time.UnixMilli(created)},
+		FilledSize:    v.ExecutedQty.Decimal,
+		Status:        orderStatus(v.Status),
+	}
+	if !v.ExecutedQty.Decimal.IsZero() {
+		order.FilledPrice = v.CummulativeQuoteQty.Decimal.Div(v.ExecutedQty.Decimal)
+	}
+	order.Done = slices.Contains(doneStatuses, order.Status)
+	if order.Done {
+		if v.UpdateTime != 0 {
+			order.FinishTime = exchange.RemoteTime{Time: time.UnixMilli(v.UpdateTime)}
+		}
+		if order.Status != "FILLED" {
+			order.DoneReason = v.Status
+		}
+		if !order.FilledSize.IsZero() {
+			fee, err := ex.orderFee(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+			order.Fee = fee
+		}
+	}
+	return order, nil
+}
+
+// orderFee returns the total fee paid for an order in the quote currency.
+// Fees paid in other assets (eg: BNB) are not included.
+func (ex *Exchange) orderFee(ctx context.Context, v *internal.Order) (decimal.Decimal, error) {
+	pid, ok := ex.productIDMap[v.Symbol]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("symbol %q is not supported: %w", v.Symbol, os.ErrNotExist)
+	}
+	symbol := ex.symbolMap[pid]
+
+	trades, err := ex.client.ListTrades(ctx, v.Symbol, v.OrderID)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("could not list trades for order %d: %w", v.OrderID, err)
+	}
+	var fee decimal.Decimal
+	for _, t := range trades {
+		switch t.CommissionAsset {
+		case symbol.QuoteAsset:
+			fee = fee.Add(t.Commission.Decimal)
+		case symbol.BaseAsset:
+			fee = fee.Add(t.Commission.Decimal.Mul(t.Price.Decimal))
+		default:
+			log.Printf("binance: fee for order %d trade %d is paid in %s (ignored)", v.OrderID, t.ID, t.CommissionAsset)
+		}
+	}
+	return fee, nil
+}
+
+// dispatchOrder sends the order update to the product if the order has
+// changed since the last update. Open orders are tracked for the updates
+// till they are complete. Orders are always fetched through the REST api, so
+// the latest order state replaces the old state.
+func (ex *Exchange) dispatchOrder(productID string, order *exchange.Order) {
+	if order.Done {
+		ex.openMap.Delete(order.OrderID)
+	} else {
+		if old, ok := ex.openMap.Load(order.OrderID); ok && exchange.Equal(old, order) {
+			return
+		}
+		ex.openMap.Store(order.OrderID, order)
+	}
+
+	if p, ok := ex.productMap.Load(productID); ok {
+		p.handleOrder(order)
+	}
+}
+
+// goPollOrders periodically refreshes the open orders. Open orders are
+// listed with a single request per symbol and only the orders that are no
+// longer open are fetched individually to find their final state.
+func (ex *Exchange) goPollOrders(ctx context.Context) {
+	ticker := time.NewTicker(ex.opts.PollOrdersInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		symbolMap := make(map[string][]exchange.OrderID)
+		ex.openMap.Range(func(id exchange.OrderID, _ *exchange.Order) bool {
+			if symbol, _, err := parseOrderID(id); err == nil {
+				symbolMap[symbol] = append(symbolMap[symbol], id)
+			}
+			return true
+		})
+		for symbol, ids := range symbolMap {
+			if err := ex.pollOpenOrders(ctx, symbol, ids); err != nil && ctx.Err() == nil {
+				log.Printf("could not refresh binance open orders for %s (will retry): %v", symbol, err)
+			}
+		}
+	}
+}
+
+// pollOpenOrders dispatches the updates for the open orders of a symbol and
+// fetches the final state of the tracked orders that are no longer open.
+func (ex *Exchange) pollOpenOrders(ctx context.Context, symbol string, ids []exchange.OrderID) error {
+	opens, err := ex.client.ListOpenOrders(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	openIDs := make(map[exchange.OrderID]bool)
+	for _, v := range opens {
+		order, err := ex.exchangeOrder(ctx, v)
+		if err != nil {
+			return err
+		}
+		openIDs[order.OrderID] = true
+		if _, ok := ex.openMap.Load(order.OrderID); ok {
+			ex.dispatchOrder(ex.productIDMap[symbol], order)
+		}
+	}
+	for _, id := range ids {
+		if openIDs[id] {
+			continue
+		}
+		if _, err := ex.GetOrder(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOrder returns the current state of an order. Orders that are not yet
+// complete are tracked for the updates afterwards.
+func (ex *Exchange) GetOrder(ctx context.Context, id exchange.OrderID) (*exchange.Order, error) {
+	symbol, orderID, err := parseOrderID(id)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ex.client.GetOrder(ctx, symbol, orderID)
+	if err != nil {
+		var e *internal.Error
+		if errors.As(err, &e) && e.Code == internal.ErrCodeNoSuchOrder {
+			return nil, fmt.Errorf("order %s is not found: %w", id, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("could not fetch order %s: %w", id, err)
+	}
+	order, err := ex.exchangeOrder(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	ex.dispatchOrder(ex.productIDMap[v.Symbol], order)
+	return order, nil
+}
+
+func (ex *Exchange) GetProduct(ctx context.Context, productID string) (*gobs.Product, error) {
+	symbol, ok := ex.symbolMap[productID]
+	if !ok {
+		return nil, fmt.Errorf("product %q is not found: %w", productID, os.ErrNotExist)
+	}
+	ticker, err := ex.client.GetTickerPrice(ctx, symbol.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch product %q price: %w", productID, err)
+	}
+	product := productData(productID, symbol)
+	product.Price = ticker.Price.Decimal
+	return product, nil
+}
+
+// productData returns the product information from the symbol's trading
+// rules.
+func productData(productID string, s *internal.Symbol) *gobs.Product {
+	status := strings.ToLower(s.Status)
+	if s.Status == "TRADING" {
+		status = "online"
+	}
+	v := &gobs.Product{
+		ProductID: productID,
+		Status:    status,
+
+		BaseName:          s.BaseAsset,
+		BaseCurrencyID:    s.BaseAsset,
+		BaseDisplaySymbol: s.BaseAsset,
+
+		QuoteName:          s.QuoteAsset,
+		QuoteCurrencyID:    s.QuoteAsset,
+		QuoteDisplaySymbol: s.QuoteAsset,
+	}
+	if f := s.Filter("LOT_SIZE"); f != nil {
+		v.BaseMinSize = f.MinQty.Decimal
+		v.BaseMaxSize = f.MaxQty.Decimal
+		v.BaseIncrement = f.StepSize.Decimal
+	}
+	if f := s.Filter("PRICE_FILTER"); f != nil {
+		v.QuoteIncrement = f.TickSize.Decimal
+	}
+	if f := s.Filter("NOTIONAL"); f != nil {
+		v.QuoteMinSize = f.MinNotional.Decimal
+		v.QuoteMaxSize = f.MaxNotional.Decimal
+	}
+	return v
+}
+
+func (ex *Exchange) Balances(ctx context.Context) ([]*gobs.Account, error) {
+	resp, err := ex.client.GetAccount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch account information: %w", err)
+	}
+	now := time.Now()
+	var accounts []*gobs.Account
+	for _, v := range resp.Balances {
+		if v.Free.Decimal.IsZero() && v.Locked.Decimal.IsZero() {
+			continue
+		}
+		accounts = append(accounts, &gobs.Account{
+			Timestamp:  now,
+			Name:       v.Asset,
+			CurrencyID: v.Asset,
+			Available:  v.Free.Decimal,
+			Hold:       v.Locked.Decimal,
+		})
+	}
+	return accounts, nil
+}
+
+// feeScheduleMaxAge is the duration after which the fee schedule is
+// refreshed from the exchange.
+const feeScheduleMaxAge = 24 * time.Hour
+
+// FeeSchedule returns the account's commission rates. Fee schedule is
+// refreshed from the exchange once a day.
+func (ex *Exchange) FeeSchedule(ctx context.Context) (*gobs.FeeSchedule, error) {
+	ex.feesMu.Lock()
+	defer ex.feesMu.Unlock()
+
+	if ex.fees != nil && time.Since(ex.fees.Timestamp) < feeScheduleMaxAge {
+		return ex.fees, nil
+	}
+
+	resp, err := ex.client.GetAccount(ctx)
+	if err != nil {
+		if ex.fees != nil {
+			log.Printf("could not refresh fee schedule (using the old value): %v", err)
+			return ex.fees, nil
+		}
+		return nil, fmt.Errorf("could not fetch account information: %w", err)
+	}
+	d100 := decimal.NewFromInt(100)
+	ex.fees = &gobs.FeeSchedule{
+		Timestamp:   time.Now(),
+		TierName:    resp.Type,
+		MakerFeePct: resp.CommissionRates.Maker.Decimal.Mul(d100),
+		TakerFeePct: resp.CommissionRates.Taker.Decimal.Mul(d100),
+	}
+	return ex.fees, nil
+}
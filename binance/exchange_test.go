@@ -0,0 +1,255 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package binance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/binance/binancetest"
+	"github.com/bvk/tradebot/binance/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvkgo/kv/kvmemdb"
+	"github.com/shopspring/decimal"
+)
+
+func newTestOptions(srv *binancetest.Server) *Options {
+	return &Options{
+		RestURL:            srv.URL(),
+		PollTickerInterval: 10 * time.Millisecond,
+		PollOrdersInterval: 10 * time.Millisecond,
+	}
+}
+
+func newTestExchange(ctx context.Context, t *testing.T, srv *binancetest.Server) *Exchange {
+	ex, err := New(ctx, binancetest.Key, binancetest.Secret, newTestOptions(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ex
+}
+
+func waitForStatus(t *testing.T, ch <-chan *exchange.Order, id exchange.OrderID, status string) *exchange.Order {
+	timeoutCh := time.After(5 * time.Second)
+	for {
+		select {
+		case order := <-ch:
+			if order.OrderID == id && order.Status == status {
+				return order
+			}
+		case <-timeoutCh:
+			t.Fatalf("timed out waiting for order %s to reach %s status", id, status)
+		}
+	}
+}
+
+func TestFakeServer(t *testing.T) {
+	ctx := context.Background()
+
+	srv := binancetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BTC-USDT", decimal.NewFromInt(100))
+	srv.SetBalance("USDT", decimal.NewFromInt(10000))
+
+	ex := newTestExchange(ctx, t, srv)
+	defer ex.Close()
+
+	p, err := ex.OpenProduct(ctx, "BTC-USDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if ba := p.BestBidAsk(); ba == nil || !ba.BidPrice.Equal(decimal.NewFromInt(100)) {
+		t.Fatalf("want best bid at 100, got %v", ba)
+	}
+
+	orderCh, stopOrders := p.OrderUpdatesCh()
+	defer stopOrders()
+
+	// Limit buy below the ticker price must stay open till the price drops.
+	size, price := decimal.NewFromInt(2), decimal.NewFromInt(90)
+	id, err := p.LimitBuy(ctx, "buy-1", size, price, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, orderCh, id, "OPEN")
+
+	// Duplicate client order id must return the same order.
+	if dup, err := p.LimitBuy(ctx, "buy-1", size, price, nil); err != nil || dup != id {
+		t.Fatalf("want the same order id %s for duplicate client order id, got %s (%v)", id, dup, err)
+	}
+
+	if err := srv.Tick("BTC-USDT", decimal.NewFromInt(89)); err != nil {
+		t.Fatal(err)
+	}
+	filled := waitForStatus(t, orderCh, id, "FILLED")
+	if !filled.Done || !filled.FilledSize.Equal(size) || !filled.FilledPrice.Equal(price) {
+		t.Fatalf("unexpected filled order %v", filled)
+	}
+	if want := size.Mul(price).Mul(decimal.RequireFromString("0.001")); !filled.Fee.Equal(want) {
+		t.Fatalf("want fee %s, got %s", want, filled.Fee)
+	}
+
+	// Post-only orders that would match immediately must be rejected.
+	if _, err := p.LimitSell(ctx, "sell-1", size, decimal.NewFromInt(80), &exchange.LimitOptions{PostOnly: true}); !errors.Is(err, exchange.ErrPostOnlyRejected) {
+		t.Fatalf("want ErrPostOnlyRejected, got %v", err)
+	}
+
+	// Cancelled orders must be reported as done.
+	sellID, err := p.LimitSell(ctx, "sell-2", size, decimal.NewFromInt(120), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Cancel(ctx, sellID); err != nil {
+		t.Fatal(err)
+	}
+	if order := waitForStatus(t, orderCh, sellID, "CANCELLED"); !order.Done {
+		t.Fatalf("want cancelled order to be done")
+	}
+	if err := p.Edit(ctx, sellID, size, price); !errors.Is(err, exchange.ErrEditRejected) {
+		t.Fatalf("want ErrEditRejected, got %v", err)
+	}
+
+	// Market orders fill immediately at the ticker price.
+	mid, err := p.MarketBuyFunds(ctx, "market-1", decimal.NewFromInt(89))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order := waitForStatus(t, orderCh, mid, "FILLED"); !order.FilledSize.Equal(decimal.NewFromInt(1)) {
+		t.Fatalf("want market order size 1, got %s", order.FilledSize)
+	}
+
+	accounts, err := ex.Balances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range accounts {
+		if a.CurrencyID == "BTC" && !a.Available.Equal(decimal.NewFromInt(3)) {
+			t.Fatalf("want 3 BTC available, got %s", a.Available)
+		}
+	}
+	fees, err := ex.FeeSchedule(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fees.MakerFeePct.Equal(decimal.RequireFromString("0.1")) {
+		t.Fatalf("want maker fee 0.1%%, got %s", fees.MakerFeePct)
+	}
+}
+
+func TestTickers(t *testing.T) {
+	ctx := context.Background()
+
+	srv := binancetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("ETH-USDT", decimal.NewFromInt(100))
+
+	ex := newTestExchange(ctx, t, srv)
+	defer ex.Close()
+
+	p, err := ex.OpenProduct(ctx, "ETH-USDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	tickerCh, stopTickers := p.TickerCh()
+	defer stopTickers()
+
+	if err := srv.Tick("ETH-USDT", decimal.NewFromInt(105)); err != nil {
+		t.Fatal(err)
+	}
+	timeoutCh := time.After(5 * time.Second)
+	for {
+		select {
+		case ticker := <-tickerCh:
+			if ticker.Price.Equal(decimal.NewFromInt(105)) {
+				return
+			}
+		case <-timeoutCh:
+			t.Fatalf("timed out waiting for the polled ticker")
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+
+	srv := binancetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BTC-USDT", decimal.NewFromInt(100))
+
+	creds := &exchange.Credentials{Key: binancetest.Key, Secret: binancetest.Secret}
+	opts := &exchange.Options{ExchangeOptions: newTestOptions(srv)}
+	ex, err := exchange.New(ctx, "Binance", kvmemdb.New(), creds, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ex.Close()
+
+	if name := ex.ExchangeName(); name != "binance" {
+		t.Fatalf("want binance exchange, got %q", name)
+	}
+	product, err := ex.GetProduct(ctx, "BTC-USDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !product.Price.Equal(decimal.NewFromInt(100)) || !product.BaseMinSize.Equal(decimal.RequireFromString("0.00001")) {
+		t.Fatalf("unexpected product data %#v", product)
+	}
+
+	// Wrong credentials must be rejected by the server.
+	bad := &exchange.Credentials{Key: binancetest.Key, Secret: "wrong-secret"}
+	bex, err := exchange.New(ctx, "binance", kvmemdb.New(), bad, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bex.Close()
+	if _, err := bex.Balances(ctx); err == nil {
+		t.Fatalf("want balances to fail with wrong credentials")
+	}
+
+	if _, err := exchange.New(ctx, "no-such-exchange", kvmemdb.New(), creds, opts); err == nil {
+		t.Fatalf("want unregistered exchange to fail")
+	}
+
+	// Coinbase specific parameters must not be accepted.
+	popts := &exchange.Options{ExchangeOptions: newTestOptions(srv), Params: map[string]string{"record-tickers": "true"}}
+	if _, err := exchange.New(ctx, "binance", kvmemdb.New(), creds, popts); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want unsupported parameters to fail with os.ErrInvalid, got %v", err)
+	}
+}
+
+func TestRateLimitPause(t *testing.T) {
+	ctx := context.Background()
+
+	srv := binancetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BTC-USDT", decimal.NewFromInt(100))
+
+	ex := newTestExchange(ctx, t, srv)
+	defer ex.Close()
+
+	// Rejected request must not be retried and all later requests must fail
+	// without reaching the server till the Retry-After duration.
+	srv.RejectNext(http.StatusTooManyRequests, time.Minute)
+	nreqs := srv.NumRequests()
+	if _, err := ex.GetProduct(ctx, "BTC-USDT"); !errors.Is(err, internal.ErrPaused) {
+		t.Fatalf("want ErrPaused for the rejected request, got %v", err)
+	}
+	if _, err := ex.GetProduct(ctx, "BTC-USDT"); !errors.Is(err, internal.ErrPaused) {
+		t.Fatalf("want ErrPaused while requests are paused, got %v", err)
+	}
+	if n := srv.NumRequests() - nreqs; n != 1 {
+		t.Fatalf("want exactly one request to the server, got %d", n)
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bvk/tradebot/ctxutil"
+	"github.com/bvk/tradebot/exchange"
+	"golang.org/x/time/rate"
+)
+
+const (
+	minRetryBackoff = 100 * time.Millisecond
+	maxRetryBackoff = 10 * time.Second
+
+	// defaultPauseDuration is the pause after 429 or 418 responses without a
+	// Retry-After header.
+	defaultPauseDuration = time.Minute
+)
+
+// ErrPaused is returned for the requests that are not sent because binance
+// has asked to stop sending requests with 429 or 418 status.
+var ErrPaused = errors.New("binance requests are paused")
+
+type Client struct {
+	cg ctxutil.CloseGroup
+
+	opts Options
+
+	key    string
+	secret []byte
+
+	client *http.Client
+
+	// weights is the client-side budget for the binance request weights, which
+	// keeps the requests within the per-minute weight limit of the IP address.
+	weights *rate.Limiter
+
+	mu sync.Mutex
+
+	// pausedUntil is the time before which no requests are sent. It is set when
+	// binance rejects requests with 429 or 418 status or when the used weight
+	// reported by the server reaches the budget.
+	pausedUntil time.Time
+
+	// timeAdjustment is the difference between the local time and the server
+	// time, which must be subtracted from the local time before it can be used
+	// as a timestamp in the signed requests.
+	timeAdjustment atomic.Int64
+}
+
+// New creates a client for binance spot exchange.
+func New(ctx context.Context, key, secret string, opts *Options) (*Client, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	opts.setDefaults()
+
+	c := &Client{
+		opts:   *opts,
+		key:    key,
+		secret: []byte(secret),
+		client: &http.Client{
+			Timeout: opts.HttpClientTimeout,
+		},
+		weights: rate.NewLimiter(rate.Limit(float64(opts.MaxWeightPerMinute)/60), max(opts.MaxWeightPerMinute/10, maxRequestWeight)),
+	}
+
+	adjustment, err := c.findTimeAdjustment(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get binance server time: %w", err)
+	}
+	if adjustment.Abs() > opts.MaxTimeAdjustment {
+		return nil, fmt.Errorf("local time is out-of-sync by large amount with the server time")
+	}
+	c.timeAdjustment.Store(int64(adjustment))
+
+	c.cg.Go(c.goFindTimeAdjustment)
+	return c, nil
+}
+
+// Close shuts down the binance client.
+func (c *Client) Close() error {
+	c.cg.Close()
+	return nil
+}
+
+// Go runs the function in a background goroutine, which is waited for when
+// the client is closed.
+func (c *Client) Go(f func(ctx context.Context)) {
+	c.cg.Go(f)
+}
+
+// AfterDurationFunc runs the function after the given duration unless the
+// client is closed by that time.
+func (c *Client) AfterDurationFunc(d time.Duration, f func(context.Context)) {
+	c.cg.AfterDurationFunc(d, f)
+}
+
+func (c *Client) Now() exchange.RemoteTime {
+	return exchange.RemoteTime{Time: time.Now().Add(time.Duration(-c.timeAdjustment.Load()))}
+}
+
+func (c *Client) goFindTimeAdjustment(ctx context.Context) {
+	for ctxutil.Sleep(ctx, c.opts.SyncTimeInterval); ctx.Err() == nil; ctxutil.Sleep(ctx, c.opts.SyncTimeInterval) {
+		if diff, err := c.findTimeAdjustment(ctx); err == nil {
+			c.timeAdjustment.Store(int64(diff))
+		}
+	}
+}
+
+func (c *Client) findTimeAdjustment(ctx context.Context) (time.Duration, error) {
+	var st ServerTime
+	start := time.Now()
+	if err := c.do(ctx, http.MethodGet, "/api/v3/time", 1, nil, false /* signed */, &st); err != nil {
+		return 0, err
+	}
+	latency := time.Since(start)
+
+	ltime := start.Add(latency / 2)
+	stime := time.UnixMilli(st.ServerTime)
+	return ltime.Sub(stime), nil
+}
+
+func (c *Client) sign(message string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// pause stops sending the requests till the given time.
+func (c *Client) pause(until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if until.After(c.pausedUntil) {
+		c.pausedUntil = until
+	}
+}
+
+// wait blocks till a request with the given weight can be sent within the
+// weight budget. It fails immediately if requests are paused.
+func (c *Client) wait(ctx context.Context, weight int) error {
+	c.mu.Lock()
+	until := c.pausedUntil
+	c.mu.Unlock()
+
+	if time.Now().Before(until) {
+		return fmt.Errorf("requests are paused till %s: %w", until.Format(time.RFC3339), ErrPaused)
+	}
+	return c.weights.WaitN(ctx, weight)
+}
+
+// checkUsedWeight pauses the requests till the next minute when the request
+// weight used by the IP address, as reported by the server, reaches the
+// budget. Weight used by the other clients from the same IP address is also
+// included in the server's count.
+func (c *Client) checkUsedWeight(header http.Header) {
+	used, err := strconv.Atoi(header.Get("X-MBX-USED-WEIGHT-1M"))
+	if err != nil || used < c.opts.MaxWeightPerMinute {
+		return
+	}
+	until := c.Now().Truncate(time.Minute).Add(time.Minute).Add(time.Duration(c.timeAdjustment.Load()))
+	log.Printf("warning: binance request weight %d has reached the budget %d (pausing requests till %s)", used, c.opts.MaxWeightPerMinute, until.Format(time.RFC3339))
+	c.pause(until)
+}
+
+// do sends a request with the parameters in the url query string and decodes
+// the json response into the result. Signed requests carry the timestamp and
+// the HMAC signature of the parameters. Requests wait for the weight budget
+// before they are sent; GET requests are retried on network and server
+// errors. Requests rejected with 429 or 418 status are never retried, because
+// binance bans the IP address for repeated violations, and all requests are
+// paused for the duration given in the Retry-After header.
+func (c *Client) do(ctx context.Context, method, path string, weight int, values url.Values, signed bool, result any) error {
+	idempotent := method == http.MethodGet
+	for retry := 0; ; retry++ {
+		if err := c.wait(ctx, weight); err != nil {
+			return err
+		}
+
+		params := make(url.Values)
+		for k, vs := range values {
+			params[k] = vs
+		}
+		query := params.Encode()
+		if signed {
+			params.Set("timestamp", strconv.FormatInt(c.Now().UnixMilli(), 10))
+			params.Set("recvWindow", strconv.FormatInt(c.opts.RecvWindow.Milliseconds(), 10))
+			query = params.Encode()
+			query += "&signature=" + c.sign(query)
+		}
+
+		u := c.opts.RestURL + path
+		if len(query) > 0 {
+			u += "?" + query
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u, nil)
+		if err != nil {
+			return err
+		}
+		if signed {
+			req.Header.Set("X-MBX-APIKEY", c.key)
+		}
+
+		backoff := min(minRetryBackoff<<retry, maxRetryBackoff)
+		canRetry := retry < c.opts.RetryCount && ctx.Err() == nil
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			if !idempotent || !canRetry {
+				return err
+			}
+			log.Printf("warning: %s request to %s has failed (retrying): %v", method, path, err)
+			ctxutil.Sleep(ctx, backoff)
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("could not read response body: %w", err)
+		}
+
+		c.checkUsedWeight(resp.Header)
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot {
+			d := defaultPauseDuration
+			if v, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && v > 0 {
+				d = time.Duration(v) * time.Second
+			}
+			until := time.Now().Add(d)
+			c.pause(until)
+			log.Printf("warning: %s request to %s returned with status code %d (pausing requests till %s)", method, path, resp.StatusCode, until.Format(time.RFC3339))
+			return fmt.Errorf("http %s to %s returned status %d: %w", method, path, resp.StatusCode, ErrPaused)
+		}
+		if resp.StatusCode >= http.StatusInternalServerError && idempotent && canRetry {
+			log.Printf("warning: %s request to %s returned with status code %d (retrying)", method, path, resp.StatusCode)
+			ctxutil.Sleep(ctx, backoff)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			e := &Error{StatusCode: resp.StatusCode}
+			if err := json.Unmarshal(body, e); err != nil || len(e.Message) == 0 {
+				e.Message = string(body)
+			}
+			return e
+		}
+
+		if result != nil {
+			if err := json.Unmarshal(body, result); err != nil {
+				return fmt.Errorf("could not decode %s response from %s: %w", method, path, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *Client) GetExchangeInfo(ctx context.Context) (*ExchangeInfo, error) {
+	resp := new(ExchangeInfo)
+	if err := c.do(ctx, http.MethodGet, "/api/v3/exchangeInfo", 20, nil, false /* signed */, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) GetTickerPrice(ctx context.Context, symbol string) (*TickerPrice, error) {
+	values := make(url.Values)
+	values.Set("symbol", symbol)
+	resp := new(TickerPrice)
+	if err := c.do(ctx, http.MethodGet, "/api/v3/ticker/price", 2, values, false /* signed */, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) GetBookTicker(ctx context.Context, symbol string) (*BookTicker, error) {
+	values := make(url.Values)
+	values.Set("symbol", symbol)
+	resp := new(BookTicker)
+	if err := c.do(ctx, http.MethodGet, "/api/v3/ticker/bookTicker", 2, values, false /* signed */, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CreateOrder creates a new order with the given order parameters.
+func (c *Client) CreateOrder(ctx context.Context, values url.Values) (*Order, error) {
+	values.Set("newOrderRespType", "RESULT")
+	resp := new(Order)
+	if err := c.do(ctx, http.MethodPost, "/api/v3/order", 1, values, true /* signed */, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) GetOrder(ctx context.Context, symbol string, orderID int64) (*Order, error) {
+	values := make(url.Values)
+	values.Set("symbol", symbol)
+	values.Set("orderId", strconv.FormatInt(orderID, 10))
+	resp := new(Order)
+	if err := c.do(ctx, http.MethodGet, "/api/v3/order", 4, values, true /* signed */, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) GetOrderByClientID(ctx context.Context, symbol, clientOrderID string) (*Order, error) {
+	values := make(url.Values)
+	values.Set("symbol", symbol)
+	values.Set("origClientOrderId", clientOrderID)
+	resp := new(Order)
+	if err := c.do(ctx, http.MethodGet, "/api/v3/order", 4, values, true /* signed */, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) CancelOrder(ctx context.Context, symbol string, orderID int64) (*Order, error) {
+	values := make(url.Values)
+	values.Set("symbol", symbol)
+	values.Set("orderId", strconv.FormatInt(orderID, 10))
+	resp := new(Order)
+	if err := c.do(ctx, http.MethodDelete, "/api/v3/order", 1, values, true /* signed */, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ListOpenOrders returns all open orders for a symbol.
+func (c *Client) ListOpenOrders(ctx context.Context, symbol string) ([]*Order, error) {
+	values := make(url.Values)
+	values.Set("symbol", symbol)
+	var resp []*Order
+	if err := c.do(ctx, http.MethodGet, "/api/v3/openOrders", 6, values, true /* signed */, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ListTrades returns the trades for an order.
+func (c *Client) ListTrades(ctx context.Context, symbol string, orderID int64) ([]*Trade, error) {
+	values := make(url.Values)
+	values.Set("symbol", symbol)
+	values.Set("orderId", strconv.FormatInt(orderID, 10))
+	var resp []*Trade
+	if err := c.do(ctx, http.MethodGet, "/api/v3/myTrades", 5, values, true /* signed */, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) GetAccount(ctx context.Context) (*Account, error) {
+	values := make(url.Values)
+	values.Set("omitZeroBalances", "true")
+	resp := new(Account)
+	if err := c.do(ctx, http.MethodGet, "/api/v3/account", 20, values, true /* signed */, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package internal
+
+import "time"
+
+var RestURL = "https://api.binance.com"
+
+// maxRequestWeight is the largest weight of the requests sent by the client.
+const maxRequestWeight = 20
+
+type Options struct {
+	// RestURL is the base URL for the REST service endpoints. Tests can use
+	// the "http" URL scheme to talk with local servers.
+	RestURL string
+
+	// Timeout to use for the HTTP requests.
+	HttpClientTimeout time.Duration
+
+	// RecvWindow is the max duration after the request timestamp for which
+	// the signed requests are accepted by the server.
+	RecvWindow time.Duration
+
+	// Max limit for time difference between local time and the server times.
+	MaxTimeAdjustment time.Duration
+
+	// Periodic timeout interval to recalculate time difference between local
+	// time and the exchange time.
+	SyncTimeInterval time.Duration
+
+	// RetryCount is the max number of retries for the failed idempotent
+	// requests.
+	RetryCount int
+
+	// MaxWeightPerMinute is the request weight budget per minute. Binance
+	// allows 6000 weight per minute for an IP address, so default budget
+	// leaves room for the other clients from the same IP address.
+	MaxWeightPerMinute int
+}
+
+func (v *Options) setDefaults() {
+	if v.RestURL == "" {
+		v.RestURL = RestURL
+	}
+	if v.HttpClientTimeout == 0 {
+		v.HttpClientTimeout = 5 * time.Second
+	}
+	if v.RecvWindow == 0 {
+		v.RecvWindow = 5 * time.Second
+	}
+	if v.MaxTimeAdjustment == 0 {
+		v.MaxTimeAdjustment = time.Minute
+	}
+	if v.SyncTimeInterval == 0 {
+		v.SyncTimeInterval = 30 * time.Minute
+	}
+	if v.RetryCount == 0 {
+		v.RetryCount = 3
+	}
+	if v.MaxWeightPerMinute == 0 {
+		v.MaxWeightPerMinute = 3000
+	}
+	if v.MaxWeightPerMinute < maxRequestWeight {
+		v.MaxWeightPerMinute = maxRequestWeight
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package internal
+
+import (
+	"fmt"
+
+	"github.com/bvk/tradebot/exchange"
+)
+
+// Error is the error response from the binance REST api.
+type Error struct {
+	StatusCode int `json:"-"`
+
+	Code    int    `json:"code"`
+	Message string `json:"msg"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("binance api returned status %d with error code %d: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Binance error codes used by the clients.
+const (
+	// ErrCodeNewOrderRejected is returned when a new order is rejected, eg:
+	// for duplicate client order ids or for the post-only orders that would
+	// match immediately.
+	ErrCodeNewOrderRejected = -2010
+
+	// ErrCodeCancelRejected is returned when an order cannot be cancelled.
+	ErrCodeCancelRejected = -2011
+
+	// ErrCodeNoSuchOrder is returned when an order is not found.
+	ErrCodeNoSuchOrder = -2013
+)
+
+type ServerTime struct {
+	ServerTime int64 `json:"serverTime"`
+}
+
+type ExchangeInfo struct {
+	Timezone   string    `json:"timezone"`
+	ServerTime int64     `json:"serverTime"`
+	Symbols    []*Symbol `json:"symbols"`
+}
+
+type Symbol struct {
+	Symbol     string `json:"symbol"`
+	Status     string `json:"status"`
+	BaseAsset  string `json:"baseAsset"`
+	QuoteAsset string `json:"quoteAsset"`
+
+	OrderTypes []string `json:"orderTypes"`
+
+	Filters []*Filter `json:"filters"`
+}
+
+// Filter holds the trading rules for a symbol. Only the fields relevant to
+// the filter type are set.
+type Filter struct {
+	FilterType string `json:"filterType"`
+
+	// PRICE_FILTER fields.
+	MinPrice exchange.NullDecimal `json:"minPrice,omitempty"`
+	MaxPrice exchange.NullDecimal `json:"maxPrice,omitempty"`
+	TickSize exchange.NullDecimal `json:"tickSize,omitempty"`
+
+	// LOT_SIZE fields.
+	MinQty   exchange.NullDecimal `json:"minQty,omitempty"`
+	MaxQty   exchange.NullDecimal `json:"maxQty,omitempty"`
+	StepSize exchange.NullDecimal `json:"stepSize,omitempty"`
+
+	// NOTIONAL fields.
+	MinNotional exchange.NullDecimal `json:"minNotional,omitempty"`
+	MaxNotional exchange.NullDecimal `json:"maxNotional,omitempty"`
+}
+
+// Filter returns the filter with the given type or nil.
+func (s *Symbol) Filter(filterType string) *Filter {
+	for _, f := range s.Filters {
+		if f.FilterType == filterType {
+			return f
+		}
+	}
+	return nil
+}
+
+type TickerPrice struct {
+	Symbol string               `json:"symbol"`
+	Price  exchange.NullDecimal `json:"price"`
+}
+
+type BookTicker struct {
+	Symbol   string               `json:"symbol"`
+	BidPrice exchange.NullDecimal `json:"bidPrice"`
+	BidQty   exchange.NullDecimal `json:"bidQty"`
+	AskPrice exchange.NullDecimal `json:"askPrice"`
+	AskQty   exchange.NullDecimal `json:"askQty"`
+}
+
+// Order is the order status response from the order create, query and
+// cancel operations. TransactTime is set only in the create and cancel
+// responses and Time is set only in the query responses.
+type Order struct {
+	Symbol        string `json:"symbol"`
+	OrderID       int64  `json:"orderId"`
+	ClientOrderID string `json:"clientOrderId"`
+
+	Price               exchange.NullDecimal `json:"price"`
+	OrigQty             exchange.NullDecimal `json:"origQty"`
+	ExecutedQty         exchange.NullDecimal `json:"executedQty"`
+	CummulativeQuoteQty exchange.NullDecimal `json:"cummulativeQuoteQty"`
+
+	Status      string `json:"status"`
+	TimeInForce string `json:"timeInForce"`
+	Type        string `json:"type"`
+	Side        string `json:"side"`
+
+	Time         int64 `json:"time,omitempty"`
+	TransactTime int64 `json:"transactTime,omitempty"`
+	UpdateTime   int64 `json:"updateTime,omitempty"`
+}
+
+type Trade struct {
+	Symbol  string `json:"symbol"`
+	ID      int64  `json:"id"`
+	OrderID int64  `json:"orderId"`
+
+	Price           exchange.NullDecimal `json:"price"`
+	Qty             exchange.NullDecimal `json:"qty"`
+	QuoteQty        exchange.NullDecimal `json:"quoteQty"`
+	Commission      exchange.NullDecimal `json:"commission"`
+	CommissionAsset string               `json:"commissionAsset"`
+
+	Time    int64 `json:"time"`
+	IsBuyer bool  `json:"isBuyer"`
+	IsMaker bool  `json:"isMaker"`
+}
+
+type Balance struct {
+	Asset  string               `json:"asset"`
+	Free   exchange.NullDecimal `json:"free"`
+	Locked exchange.NullDecimal `json:"locked"`
+}
+
+type CommissionRates struct {
+	Maker exchange.NullDecimal `json:"maker"`
+	Taker exchange.NullDecimal `json:"taker"`
+}
+
+type Account struct {
+	CommissionRates CommissionRates `json:"commissionRates"`
+
+	CanTrade   bool   `json:"canTrade"`
+	UpdateTime int64  `json:"updateTime"`
+	Type       string `json:"accountType"`
+
+	Balances []*Balance `json:"balances"`
+}
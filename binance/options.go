@@ -0,0 +1,66 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package binance
+
+import (
+	"time"
+
+	"github.com/bvk/tradebot/binance/internal"
+)
+
+var RestURL = internal.RestURL
+
+type Options struct {
+	// RestURL is the base URL for the REST service endpoints.
+	RestURL string
+
+	// Timeout to use for the HTTP requests.
+	HttpClientTimeout time.Duration
+
+	// RetryCount indicates number of times to retry the failed requests.
+	RetryCount int
+
+	// Max limit for time difference between local time and the server times.
+	MaxTimeAdjustment time.Duration
+
+	// PollTickerInterval is the interval to poll the ticker price and the best
+	// bid and ask for the opened products.
+	PollTickerInterval time.Duration
+
+	// PollOrdersInterval is the interval to poll the open orders for the
+	// order updates. Open orders are polled with a single request for every
+	// product with open orders.
+	PollOrdersInterval time.Duration
+
+	// MaxWeightPerMinute is the client-side budget for the binance request
+	// weights per minute.
+	MaxWeightPerMinute int
+
+	// QuoteCurrencies holds the quote currencies for the supported products.
+	// Products with other quote currencies are ignored.
+	QuoteCurrencies []string
+}
+
+func (v *Options) setDefaults() {
+	if v.RestURL == "" {
+		v.RestURL = RestURL
+	}
+	if v.HttpClientTimeout == 0 {
+		v.HttpClientTimeout = 5 * time.Second
+	}
+	if v.RetryCount == 0 {
+		v.RetryCount = 3
+	}
+	if v.MaxTimeAdjustment == 0 {
+		v.MaxTimeAdjustment = time.Minute
+	}
+	if v.PollTickerInterval == 0 {
+		v.PollTickerInterval = 2 * time.Second
+	}
+	if v.PollOrdersInterval == 0 {
+		v.PollOrdersInterval = 2 * time.Second
+	}
+	if len(v.QuoteCurrencies) == 0 {
+		v.QuoteCurrencies = []string{"USDT", "USDC"}
+	}
+}
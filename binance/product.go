@@ -0,0 +1,319 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package binance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bvk/tradebot/binance/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvkgo/topic"
+	"github.com/shopspring/decimal"
+)
+
+type Product struct {
+	client *internal.Client
+
+	exchange *Exchange
+
+	symbol *internal.Symbol
+
+	productData *gobs.Product
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+
+	mu sync.Mutex
+
+	lastBidAsk *exchange.BidAsk
+
+	prodTickerTopic *topic.Topic[*exchange.Ticker]
+	prodOrderTopic  *topic.Topic[*exchange.Order]
+}
+
+var _ exchange.Product = &Product{}
+
+func (ex *Exchange) OpenProduct(ctx context.Context, pid string) (exchange.Product, error) {
+	if p, ok := ex.productMap.Load(pid); ok {
+		return p, nil
+	}
+
+	symbol, ok := ex.symbolMap[pid]
+	if !ok {
+		return nil, fmt.Errorf("product %q is not found: %w", pid, os.ErrNotExist)
+	}
+
+	p := &Product{
+		client:          ex.client,
+		exchange:        ex,
+		symbol:          symbol,
+		productData:     productData(pid, symbol),
+		closeCh:         make(chan struct{}),
+		prodTickerTopic: topic.New[*exchange.Ticker](),
+		prodOrderTopic:  topic.New[*exchange.Order](),
+	}
+	if err := p.pollTicker(ctx); err != nil {
+		return nil, fmt.Errorf("could not fetch product %q ticker: %w", pid, err)
+	}
+	ex.client.Go(p.goPollTicker)
+
+	ex.productMap.Store(pid, p)
+	return p, nil
+}
+
+func (p *Product) Close() error {
+	p.exchange.productMap.Delete(p.productData.ProductID)
+	p.closeOnce.Do(func() { close(p.closeCh) })
+	return nil
+}
+
+func (p *Product) ProductID() string {
+	return p.productData.ProductID
+}
+
+func (p *Product) ExchangeName() string {
+	return "binance"
+}
+
+func (p *Product) BaseMinSize() decimal.Decimal {
+	return p.productData.BaseMinSize
+}
+
+func (p *Product) BaseIncrement() decimal.Decimal {
+	return p.productData.BaseIncrement
+}
+
+func (p *Product) TickerCh() (<-chan *exchange.Ticker, func()) {
+	sub, ch, _ := p.prodTickerTopic.Subscribe(1, true /* includeRecent */)
+	return ch, sub.Unsubscribe
+}
+
+func (p *Product) BestBidAsk() *exchange.BidAsk {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.lastBidAsk
+}
+
+func (p *Product) OrderUpdatesCh() (<-chan *exchange.Order, func()) {
+	sub, ch, _ := p.prodOrderTopic.Subscribe(0, true /* includeRecent */)
+	return ch, sub.Unsubscribe
+}
+
+func (p *Product) Get(ctx context.Context, serverOrderID exchange.OrderID) (*exchange.Order, error) {
+	return p.exchange.GetOrder(ctx, serverOrderID)
+}
+
+func (p *Product) LimitBuy(ctx context.Context, clientOrderID string, size, price decimal.Decimal, opts *exchange.LimitOptions) (exchange.OrderID, error) {
+	return p.limit(ctx, "BUY", clientOrderID, size, price, opts)
+}
+
+func (p *Product) LimitSell(ctx context.Context, clientOrderID string, size, price decimal.Decimal, opts *exchange.LimitOptions) (exchange.OrderID, error) {
+	return p.limit(ctx, "SELL", clientOrderID, size, price, opts)
+}
+
+// limit creates a limit order. Post-only orders are created as LIMIT_MAKER
+// orders. Binance doesn't support the GTD orders.
+func (p *Product) limit(ctx context.Context, side, clientOrderID string, size, price decimal.Decimal, opts *exchange.LimitOptions) (exchange.OrderID, error) {
+	if err := opts.Check(); err != nil {
+		return "", err
+	}
+	size, price, err := exchange.NormalizeLimit(p.productData, size, price)
+	if err != nil {
+		return "", err
+	}
+
+	values := make(url.Values)
+	values.Set("symbol", p.symbol.Symbol)
+	values.Set("side", side)
+	values.Set("quantity", size.String())
+	values.Set("price", price.String())
+	values.Set("newClientOrderId", clientOrderID)
+
+	switch {
+	case opts == nil:
+		values.Set("type", "LIMIT")
+		values.Set("timeInForce", "GTC")
+	case opts.TimeInForce == "GTD":
+		return "", fmt.Errorf("binance doesn't support GTD orders: %w", errors.ErrUnsupported)
+	case opts.PostOnly:
+		values.Set("type", "LIMIT_MAKER")
+	default:
+		tif := opts.TimeInForce
+		if tif == "" {
+			tif = "GTC"
+		}
+		values.Set("type", "LIMIT")
+		values.Set("timeInForce", tif)
+	}
+	return p.create(ctx, values)
+}
+
+func (p *Product) MarketBuy(ctx context.Context, clientOrderID string, size decimal.Decimal) (exchange.OrderID, error) {
+	return p.marketSize(ctx, "BUY", clientOrderID, size)
+}
+
+func (p *Product) MarketSell(ctx context.Context, clientOrderID string, size decimal.Decimal) (exchange.OrderID, error) {
+	return p.marketSize(ctx, "SELL", clientOrderID, size)
+}
+
+func (p *Product) MarketBuyFunds(ctx context.Context, clientOrderID string, funds decimal.Decimal) (exchange.OrderID, error) {
+	return p.marketFunds(ctx, "BUY", clientOrderID, funds)
+}
+
+func (p *Product) MarketSellFunds(ctx context.Context, clientOrderID string, funds decimal.Decimal) (exchange.OrderID, error) {
+	return p.marketFunds(ctx, "SELL", clientOrderID, funds)
+}
+
+func (p *Product) marketSize(ctx context.Context, side, clientOrderID string, size decimal.Decimal) (exchange.OrderID, error) {
+	size, err := exchange.NormalizeSize(p.productData, size)
+	if err != nil {
+		return "", err
+	}
+	values := make(url.Values)
+	values.Set("symbol", p.symbol.Symbol)
+	values.Set("side", side)
+	values.Set("type", "MARKET")
+	values.Set("quantity", size.String())
+	values.Set("newClientOrderId", clientOrderID)
+	return p.create(ctx, values)
+}
+
+func (p *Product) marketFunds(ctx context.Context, side, clientOrderID string, funds decimal.Decimal) (exchange.OrderID, error) {
+	funds, err := exchange.NormalizeFunds(p.productData, funds)
+	if err != nil {
+		return "", err
+	}
+	values := make(url.Values)
+	values.Set("symbol", p.symbol.Symbol)
+	values.Set("side", side)
+	values.Set("type", "MARKET")
+	values.Set("quoteOrderQty", funds.String())
+	values.Set("newClientOrderId", clientOrderID)
+	return p.create(ctx, values)
+}
+
+// create creates a new order. Binance rejects the orders with a client order
+// id that is already used by an open order, in which case, the existing order
+// is returned, so that the order creation is idempotent.
+func (p *Product) create(ctx context.Context, values url.Values) (exchange.OrderID, error) {
+	v, err := p.client.CreateOrder(ctx, values)
+	if err != nil {
+		var e *internal.Error
+		if !errors.As(err, &e) || e.Code != internal.ErrCodeNewOrderRejected {
+			return "", err
+		}
+		if strings.Contains(e.Message, "immediately match") {
+			return "", fmt.Errorf("%s: %w", e.Message, exchange.ErrPostOnlyRejected)
+		}
+		if !strings.Contains(e.Message, "Duplicate") {
+			return "", err
+		}
+		old, err := p.client.GetOrderByClientID(ctx, p.symbol.Symbol, values.Get("newClientOrderId"))
+		if err != nil {
+			return "", fmt.Errorf("could not fetch the existing order for duplicate client order id: %w", err)
+		}
+		v = old
+	}
+
+	order, err := p.exchange.exchangeOrder(ctx, v)
+	if err != nil {
+		// Order is created successfully, so it's update is left to the poller.
+		log.Printf("could not fetch binance order %d details (ignored): %v", v.OrderID, err)
+		return formatOrderID(v.Symbol, v.OrderID), nil
+	}
+	p.exchange.dispatchOrder(p.productData.ProductID, order)
+	return order.OrderID, nil
+}
+
+func (p *Product) Cancel(ctx context.Context, serverOrderID exchange.OrderID) error {
+	symbol, orderID, err := parseOrderID(serverOrderID)
+	if err != nil {
+		return err
+	}
+	if _, err := p.client.CancelOrder(ctx, symbol, orderID); err != nil {
+		var e *internal.Error
+		if !errors.As(err, &e) || e.Code != internal.ErrCodeCancelRejected {
+			return err
+		}
+		// Order may already be complete.
+		order, gerr := p.exchange.GetOrder(ctx, serverOrderID)
+		if gerr != nil || !order.Done {
+			return err
+		}
+		return nil
+	}
+	// Fetch the canceled order so that a notification is generated.
+	if _, err := p.exchange.GetOrder(ctx, serverOrderID); err != nil {
+		log.Printf("could not fetch canceled order %s for notification processing (ignored): %v", serverOrderID, err)
+	}
+	return nil
+}
+
+// Edit always fails because binance can only replace an order with a new
+// order, which changes the order id.
+func (p *Product) Edit(ctx context.Context, serverOrderID exchange.OrderID, size, price decimal.Decimal) error {
+	return fmt.Errorf("binance orders cannot be edited in place: %w", exchange.ErrEditRejected)
+}
+
+// goPollTicker periodically polls the ticker price and the best bid and ask
+// for the product.
+func (p *Product) goPollTicker(ctx context.Context) {
+	ticker := time.NewTicker(p.exchange.opts.PollTickerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.closeCh:
+			return
+		case <-ticker.C:
+		}
+
+		if err := p.pollTicker(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("could not poll binance product %s ticker (will retry): %v", p.productData.ProductID, err)
+		}
+	}
+}
+
+func (p *Product) pollTicker(ctx context.Context) error {
+	price, err := p.client.GetTickerPrice(ctx, p.symbol.Symbol)
+	if err != nil {
+		return err
+	}
+	book, err := p.client.GetBookTicker(ctx, p.symbol.Symbol)
+	if err != nil {
+		return err
+	}
+	now := p.client.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastBidAsk = &exchange.BidAsk{
+		Timestamp: now,
+		BidPrice:  book.BidPrice.Decimal,
+		BidSize:   book.BidQty.Decimal,
+		AskPrice:  book.AskPrice.Decimal,
+		AskSize:   book.AskQty.Decimal,
+	}
+	p.prodTickerTopic.Send(&exchange.Ticker{
+		Timestamp: now,
+		Price:     price.Price.Decimal,
+	})
+	return nil
+}
+
+func (p *Product) handleOrder(order *exchange.Order) {
+	p.prodOrderTopic.Send(order)
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package binance
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvkgo/kv"
+)
+
+func init() {
+	exchange.Register("binance", newFromRegistry)
+}
+
+// newFromRegistry creates the binance exchange for the exchange registry.
+// Exchange specific options, when given, must be of *Options type; exchange
+// independent options are used for the fields that are not set in them.
+// Binance exchange doesn't keep any local state, so the database is not used.
+// Binance exchange doesn't support any exchange parameters yet.
+func newFromRegistry(ctx context.Context, db kv.Database, creds *exchange.Credentials, opts *exchange.Options) (exchange.Exchange, error) {
+	bopts := new(Options)
+	if opts.ExchangeOptions != nil {
+		v, ok := opts.ExchangeOptions.(*Options)
+		if !ok {
+			return nil, fmt.Errorf("unexpected binance options type %T: %w", opts.ExchangeOptions, os.ErrInvalid)
+		}
+		*bopts = *v
+	}
+	for key := range opts.Params {
+		return nil, fmt.Errorf("unsupported binance parameter %q: %w", key, os.ErrInvalid)
+	}
+	if bopts.HttpClientTimeout == 0 {
+		bopts.HttpClientTimeout = opts.HttpClientTimeout
+	}
+	if len(bopts.QuoteCurrencies) == 0 {
+		bopts.QuoteCurrencies = opts.QuoteCurrencies
+	}
+	return New(ctx, creds.Key, creds.Secret, bopts)
+}
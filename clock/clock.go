@@ -0,0 +1,113 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+// Package clock defines the time source used by the trader jobs, so that jobs
+// can run against the wall-clock time or a simulated time that advances as
+// fast as the replayed data allows.
+package clock
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+// System is the wall-clock time source.
+var System Clock = systemClock{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Sleep pauses the caller for the duration d as measured by the clock. It
+// returns early with the context's cause if the context is canceled.
+func Sleep(ctx context.Context, c Clock, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-c.After(d):
+		return nil
+	}
+}
+
+type waiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// Simulated is a clock that advances only when it is explicitly moved
+// forward. Channels returned by After are fired when the simulated time
+// reaches their deadline.
+type Simulated struct {
+	mu sync.Mutex
+
+	now time.Time
+
+	waiters []*waiter
+}
+
+func NewSimulated(now time.Time) *Simulated {
+	return &Simulated{now: now}
+}
+
+func (s *Simulated) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.now
+}
+
+func (s *Simulated) After(d time.Duration) <-chan time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := &waiter{deadline: s.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.ch <- s.now
+		return w.ch
+	}
+	s.waiters = append(s.waiters, w)
+	return w.ch
+}
+
+// Set moves the simulated time forward to the input time and fires all
+// waiters with deadline at or before it. Attempts to move the time backwards
+// are ignored.
+func (s *Simulated) Set(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !now.After(s.now) {
+		return
+	}
+	s.now = now
+
+	sort.SliceStable(s.waiters, func(i, j int) bool {
+		return s.waiters[i].deadline.Before(s.waiters[j].deadline)
+	})
+	n := 0
+	for ; n < len(s.waiters); n++ {
+		w := s.waiters[n]
+		if w.deadline.After(now) {
+			break
+		}
+		w.ch <- now
+	}
+	s.waiters = s.waiters[n:]
+}
+
+// Advance moves the simulated time forward by the input duration.
+func (s *Simulated) Advance(d time.Duration) {
+	s.Set(s.Now().Add(d))
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package clock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSimulated(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewSimulated(start)
+
+	minuteCh := c.After(time.Minute)
+	secondCh := c.After(time.Second)
+
+	c.Advance(500 * time.Millisecond)
+	select {
+	case <-secondCh:
+		t.Fatalf("timer fired before the deadline")
+	default:
+	}
+
+	c.Advance(500 * time.Millisecond)
+	if v := <-secondCh; !v.Equal(start.Add(time.Second)) {
+		t.Fatalf("want %s, got %s", start.Add(time.Second), v)
+	}
+
+	// Moving backwards is ignored.
+	c.Set(start)
+	if v := c.Now(); !v.Equal(start.Add(time.Second)) {
+		t.Fatalf("want %s, got %s", start.Add(time.Second), v)
+	}
+
+	c.Set(start.Add(time.Hour))
+	if v := <-minuteCh; !v.Equal(start.Add(time.Hour)) {
+		t.Fatalf("want %s, got %s", start.Add(time.Hour), v)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cause := errors.New("canceled")
+	cancel(cause)
+	if err := Sleep(ctx, c, time.Minute); !errors.Is(err, cause) {
+		t.Fatalf("want %v, got %v", cause, err)
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/bvk/tradebot/coinbase/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/kvutil"
+	"github.com/bvkgo/kv"
+)
+
+// candleRollup describes a coarser candle granularity that is computed from
+// the next finer granularity when minute candles are saved.
+//
+// Rollup candles are saved in the same format as the minute candles, but
+// under a separate directory and in bigger buckets (one key per day, month or
+// year) so that long time ranges can be scanned with fewer keys.
+type candleRollup struct {
+	name        string
+	granularity time.Duration
+	keyFormat   string
+}
+
+// candleRollups are ordered from finer to coarser granularity. Every
+// granularity must be a multiple of the previous granularity and every
+// rollup's period must fit in a single key of the previous granularity.
+var candleRollups = []*candleRollup{
+	{name: "5m", granularity: 5 * time.Minute, keyFormat: "2006-01-02"},
+	{name: "15m", granularity: 15 * time.Minute, keyFormat: "2006-01-02"},
+	{name: "1h", granularity: time.Hour, keyFormat: "2006-01"},
+	{name: "1d", granularity: 24 * time.Hour, keyFormat: "2006"},
+}
+
+// CandleGranularities returns all candle granularities supported by the
+// datastore, including the one minute granularity.
+func CandleGranularities() []time.Duration {
+	gs := []time.Duration{time.Minute}
+	for _, r := range candleRollups {
+		gs = append(gs, r.granularity)
+	}
+	return gs
+}
+
+func findCandleRollup(granularity time.Duration) (int, *candleRollup, bool) {
+	for i, r := range candleRollups {
+		if r.granularity == granularity {
+			return i, r, true
+		}
+	}
+	return -1, nil, false
+}
+
+func (r *candleRollup) key(t time.Time) string {
+	return path.Join(Keyspace, "candles-"+r.name, t.UTC().Format(r.keyFormat))
+}
+
+func (r *candleRollup) minKey() string {
+	return path.Join(Keyspace, "candles-"+r.name, "0000")
+}
+
+func (r *candleRollup) maxKey() string {
+	return path.Join(Keyspace, "candles-"+r.name, "9999")
+}
+
+// candlesCache holds the candle buckets loaded and modified in a single
+// transaction.
+type candlesCache struct {
+	values map[string]*gobs.CoinbaseCandles
+	dirty  map[string]bool
+}
+
+func newCandlesCache() *candlesCache {
+	return &candlesCache{
+		values: make(map[string]*gobs.CoinbaseCandles),
+		dirty:  make(map[string]bool),
+	}
+}
+
+func (cc *candlesCache) get(ctx context.Context, r kv.Reader, key string) (*gobs.CoinbaseCandles, error) {
+	if v, ok := cc.values[key]; ok {
+		return v, nil
+	}
+	value, err := kvutil.Get[gobs.CoinbaseCandles](ctx, r, key)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("could not load coinbase candles at %q: %w", key, err)
+		}
+		value = &gobs.CoinbaseCandles{
+			ProductCandlesMap: make(map[string][]*gobs.CoinbaseCandle),
+		}
+	}
+	cc.values[key] = value
+	return value, nil
+}
+
+func (cc *candlesCache) set(key string, value *gobs.CoinbaseCandles) {
+	cc.values[key] = value
+	cc.dirty[key] = true
+}
+
+func (cc *candlesCache) save(ctx context.Context, w kv.Writer) error {
+	for key := range cc.dirty {
+		if err := kvutil.Set(ctx, w, key, cc.values[key]); err != nil {
+			return fmt.Errorf("could not save candles at key %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// childCandlesLocked returns the candles of the next finer granularity that
+// fall in the rollup period starting at `start`.
+func (ds *Datastore) childCandlesLocked(ctx context.Context, r kv.Reader, cc *candlesCache, productID string, level int, start time.Time) ([]*gobs.CoinbaseCandle, error) {
+	key := path.Join(Keyspace, "candles", start.Format("2006-01-02/15"))
+	if level > 0 {
+		key = candleRollups[level-1].key(start)
+	}
+	value, err := cc.get(ctx, r, key)
+	if err != nil {
+		return nil, err
+	}
+
+	begin, end := start.Unix(), start.Add(candleRollups[level].granularity).Unix()
+	var children []*gobs.CoinbaseCandle
+	for _, c := range value.ProductCandlesMap[productID] {
+		if c.UnixTime >= begin && c.UnixTime < end {
+			children = append(children, c)
+		}
+	}
+	return children, nil
+}
+
+// mergeCandles combines candles sorted by their start time into a single
+// candle starting at `start`.
+func mergeCandles(start time.Time, candles []*gobs.CoinbaseCandle) (*internal.Candle, error) {
+	var result *internal.Candle
+	for _, c := range candles {
+		v := new(internal.Candle)
+		if err := json.Unmarshal([]byte(c.Candle), v); err != nil {
+			return nil, fmt.Errorf("could not json-unmarshal candle data: %w", err)
+		}
+		if result == nil {
+			result = &internal.Candle{
+				Start:  start.Unix(),
+				Low:    v.Low,
+				High:   v.High,
+				Open:   v.Open,
+				Close:  v.Close,
+				Volume: v.Volume,
+			}
+			continue
+		}
+		if v.Low.Decimal.LessThan(result.Low.Decimal) {
+			result.Low = v.Low
+		}
+		if v.High.Decimal.GreaterThan(result.High.Decimal) {
+			result.High = v.High
+		}
+		result.Close = v.Close
+		result.Volume = exchange.NullDecimal{Decimal: result.Volume.Decimal.Add(v.Volume.Decimal)}
+	}
+	return result, nil
+}
+
+// updateRollupsLocked recomputes the rollup candles for all periods that
+// include the minute candles at `starts` unix times. Minute candles must
+// already be updated in the cache.
+func (ds *Datastore) updateRollupsLocked(ctx context.Context, r kv.Reader, cc *candlesCache, productID string, starts []int64) error {
+	times := make(map[time.Time]struct{})
+	for _, s := range starts {
+		times[time.Unix(s, 0)] = struct{}{}
+	}
+
+	for level, rollup := range candleRollups {
+		periods := make(map[time.Time]struct{})
+		for t := range times {
+			periods[t.Truncate(rollup.granularity)] = struct{}{}
+		}
+
+		for start := range periods {
+			children, err := ds.childCandlesLocked(ctx, r, cc, productID, level, start)
+			if err != nil {
+				return err
+			}
+			candle, err := mergeCandles(start, children)
+			if err != nil {
+				return err
+			}
+			if candle == nil {
+				continue
+			}
+			js, err := json.Marshal(candle)
+			if err != nil {
+				return fmt.Errorf("could not json-marshal candle: %w", err)
+			}
+
+			key := rollup.key(start)
+			value, err := cc.get(ctx, r, key)
+			if err != nil {
+				return err
+			}
+			pcandles := value.ProductCandlesMap[productID]
+			pcandles = slices.DeleteFunc(pcandles, func(c *gobs.CoinbaseCandle) bool {
+				return c.UnixTime == candle.Start
+			})
+			pcandles = append(pcandles, &gobs.CoinbaseCandle{UnixTime: candle.Start, Candle: json.RawMessage(js)})
+			slices.SortFunc(pcandles, func(a, b *gobs.CoinbaseCandle) int {
+				return cmp.Compare(a.UnixTime, b.UnixTime)
+			})
+			value.ProductCandlesMap[productID] = pcandles
+			cc.set(key, value)
+		}
+		times = periods
+	}
+	return nil
+}
+
+// RollupCandles recomputes the coarser granularity candles from the minute
+// candles saved between `begin` and `end` timestamps. Rollups are updated
+// automatically when new candles are saved, so this is only necessary for
+// the candles saved before rollups were supported.
+func (ds *Datastore) RollupCandles(ctx context.Context, productID string, begin, end time.Time) error {
+	if len(productID) == 0 {
+		return fmt.Errorf("product id cannot be empty")
+	}
+
+	for day := begin.UTC().Truncate(24 * time.Hour); day.Before(end); day = day.Add(24 * time.Hour) {
+		var candles []*internal.Candle
+		collect := func(c *gobs.Candle) error {
+			candles = append(candles, &internal.Candle{
+				Start:  c.StartTime.Unix(),
+				Low:    exchange.NullDecimal{Decimal: c.Low},
+				High:   exchange.NullDecimal{Decimal: c.High},
+				Open:   exchange.NullDecimal{Decimal: c.Open},
+				Close:  exchange.NullDecimal{Decimal: c.Close},
+				Volume: exchange.NullDecimal{Decimal: c.Volume},
+			})
+			return nil
+		}
+		if err := ds.ScanCandles(ctx, productID, day, day.Add(24*time.Hour), collect); err != nil {
+			return fmt.Errorf("could not scan minute candles for %s: %w", day.Format("2006-01-02"), err)
+		}
+		if len(candles) == 0 {
+			continue
+		}
+		if err := ds.saveCandles(ctx, productID, candles); err != nil {
+			return fmt.Errorf("could not update candle rollups for %s: %w", day.Format("2006-01-02"), err)
+		}
+	}
+	return nil
+}
+
+// ScanCandlesWithGranularity is similar to ScanCandles, but runs the callback
+// with candles of the given granularity, which must be one of the values
+// returned by CandleGranularities.
+func (ds *Datastore) ScanCandlesWithGranularity(ctx context.Context, productID string, granularity time.Duration, begin, end time.Time, fn func(*gobs.Candle) error) error {
+	if granularity == time.Minute {
+		return ds.ScanCandles(ctx, productID, begin, end, fn)
+	}
+	if len(productID) == 0 {
+		return fmt.Errorf("product id cannot be empty")
+	}
+	_, rollup, ok := findCandleRollup(granularity)
+	if !ok {
+		return fmt.Errorf("unsupported candle granularity %s: %w", granularity, os.ErrInvalid)
+	}
+
+	minKey := rollup.minKey()
+	if !begin.IsZero() {
+		minKey = rollup.key(begin.Truncate(granularity))
+	}
+	maxKey := rollup.maxKey()
+
+	scanner := func(ctx context.Context, r kv.Reader, k string, v *gobs.CoinbaseCandles) error {
+		for _, c := range v.ProductCandlesMap[productID] {
+			if !begin.IsZero() && c.UnixTime < begin.Truncate(granularity).Unix() {
+				continue
+			}
+			if !end.IsZero() && c.UnixTime >= end.Unix() {
+				return errStopScan
+			}
+
+			v := new(internal.Candle)
+			if err := json.Unmarshal([]byte(c.Candle), v); err != nil {
+				return fmt.Errorf("could not json-unmarshal candle data: %w", err)
+			}
+			gv := &gobs.Candle{
+				StartTime: gobs.RemoteTime{Time: time.Unix(c.UnixTime, 0)},
+				Duration:  granularity,
+				Low:       v.Low.Decimal,
+				High:      v.High.Decimal,
+				Open:      v.Open.Decimal,
+				Close:     v.Close.Decimal,
+				Volume:    v.Volume.Decimal,
+			}
+			if err := fn(gv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := kvutil.AscendDB[gobs.CoinbaseCandles](ctx, ds.db, minKey, maxKey, scanner); err != nil && !errors.Is(err, errStopScan) {
+		return err
+	}
+	return nil
+}
+
+var errStopScan = errors.New("stop scan")
+
+// CandleGap is a range of missing minute candles, which includes the
+// `Begin` timestamp, but excludes the `End` timestamp.
+type CandleGap struct {
+	Begin time.Time
+	End   time.Time
+}
+
+// NumMinutes returns the number of missing minute candles in the gap.
+func (g *CandleGap) NumMinutes() int {
+	return int(g.End.Sub(g.Begin) / time.Minute)
+}
+
+// CandlesReport describes the holes and duplicates found in the minute
+// candles of a product.
+type CandlesReport struct {
+	ProductID string
+
+	Begin time.Time
+	End   time.Time
+
+	NumCandles int
+
+	Missing []*CandleGap
+
+	Duplicates []time.Time
+}
+
+// NumMissing returns the total number of missing minute candles.
+func (r *CandlesReport) NumMissing() int {
+	n := 0
+	for _, g := range r.Missing {
+		n += g.NumMinutes()
+	}
+	return n
+}
+
+// VerifyCandles scans the minute candles between `begin` and `end` timestamps
+// for missing minutes and duplicates.
+//
+// Note that coinbase doesn't return candles for the minutes without any
+// trades, so gaps are expected for products with low trading volume.
+func (ds *Datastore) VerifyCandles(ctx context.Context, productID string, begin, end time.Time) (*CandlesReport, error) {
+	report := &CandlesReport{
+		ProductID: productID,
+		Begin:     begin.Truncate(time.Minute),
+		End:       end.Truncate(time.Minute),
+	}
+
+	next, last := report.Begin, time.Time{}
+	verifier := func(c *gobs.Candle) error {
+		report.NumCandles++
+		t := c.StartTime.Time
+		if !last.IsZero() && !t.After(last) {
+			report.Duplicates = append(report.Duplicates, t)
+			return nil
+		}
+		if t.After(next) {
+			report.Missing = append(report.Missing, &CandleGap{Begin: next, End: t})
+		}
+		next, last = t.Add(time.Minute), t
+		return nil
+	}
+	if err := ds.ScanCandles(ctx, productID, report.Begin, report.End, verifier); err != nil {
+		return nil, fmt.Errorf("could not scan candles: %w", err)
+	}
+	if next.Before(report.End) {
+		report.Missing = append(report.Missing, &CandleGap{Begin: next, End: report.End})
+	}
+	return report, nil
+}
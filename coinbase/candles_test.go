@@ -5,10 +5,17 @@ package coinbase
 import (
 	"context"
 	"encoding/json"
+	"path"
 	"testing"
 	"time"
 
+	"github.com/bvk/tradebot/coinbase/coinbasetest"
+	"github.com/bvk/tradebot/coinbase/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/kvutil"
 	"github.com/bvkgo/kv/kvmemdb"
+	"github.com/shopspring/decimal"
 )
 
 func TestProductCandles(t *testing.T) {
@@ -37,3 +44,210 @@ func TestProductCandles(t *testing.T) {
 	jsdata, _ := json.MarshalIndent(resp, "", "  ")
 	t.Logf("%s\n", jsdata)
 }
+
+func TestCandleRollups(t *testing.T) {
+	ctx := context.Background()
+	ds := NewDatastore(kvmemdb.New())
+
+	dec := func(v int64) exchange.NullDecimal {
+		return exchange.NullDecimal{Decimal: decimal.NewFromInt(v)}
+	}
+
+	// Two days of minute candles with price equal to the minute index.
+	begin := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var candles []*internal.Candle
+	for i := int64(0); i < 2*24*60; i++ {
+		candles = append(candles, &internal.Candle{
+			Start:  begin.Add(time.Duration(i) * time.Minute).Unix(),
+			Low:    dec(i),
+			High:   dec(i + 1),
+			Open:   dec(i),
+			Close:  dec(i + 1),
+			Volume: dec(1),
+		})
+	}
+	for i := 0; i < len(candles); i += 300 {
+		if err := ds.saveCandles(ctx, "BCH-USD", candles[i:min(i+300, len(candles))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan := func(granularity time.Duration, begin, end time.Time) []*gobs.Candle {
+		var cs []*gobs.Candle
+		if err := ds.ScanCandlesWithGranularity(ctx, "BCH-USD", granularity, begin, end, func(c *gobs.Candle) error {
+			cs = append(cs, c)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return cs
+	}
+
+	end := begin.Add(48 * time.Hour)
+	for _, g := range CandleGranularities() {
+		cs := scan(g, begin, end)
+		if want := int(48 * time.Hour / g); len(cs) != want {
+			t.Fatalf("granularity %s: want %d candles, got %d", g, want, len(cs))
+		}
+		minutes := int64(g / time.Minute)
+		for i, c := range cs {
+			first := int64(i) * minutes
+			if !c.StartTime.Equal(begin.Add(time.Duration(first) * time.Minute)) {
+				t.Fatalf("granularity %s: candle %d has unexpected start time %s", g, i, c.StartTime)
+			}
+			if !c.Open.Equal(decimal.NewFromInt(first)) || !c.Low.Equal(decimal.NewFromInt(first)) {
+				t.Fatalf("granularity %s: candle %d has unexpected open/low %s/%s", g, i, c.Open, c.Low)
+			}
+			if !c.Close.Equal(decimal.NewFromInt(first+minutes)) || !c.High.Equal(decimal.NewFromInt(first+minutes)) {
+				t.Fatalf("granularity %s: candle %d has unexpected close/high %s/%s", g, i, c.Close, c.High)
+			}
+			if !c.Volume.Equal(decimal.NewFromInt(minutes)) {
+				t.Fatalf("granularity %s: candle %d has unexpected volume %s", g, i, c.Volume)
+			}
+		}
+	}
+
+	// Time range must be respected.
+	if cs := scan(time.Hour, begin.Add(90*time.Minute), begin.Add(3*time.Hour)); len(cs) != 2 {
+		t.Fatalf("want 2 hourly candles, got %d", len(cs))
+	}
+
+	// Saving new minute candles must update all rollups incrementally.
+	ds = NewDatastore(kvmemdb.New())
+	spike := &internal.Candle{
+		Start:  begin.Add(30*time.Hour + 7*time.Minute).Unix(),
+		Low:    dec(0),
+		High:   dec(100000),
+		Open:   dec(0),
+		Close:  dec(0),
+		Volume: dec(1),
+	}
+	if err := ds.saveCandles(ctx, "BCH-USD", []*internal.Candle{spike}); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range CandleGranularities()[1:] {
+		cs := scan(g, begin, end)
+		if len(cs) != 1 {
+			t.Fatalf("granularity %s: want 1 candle, got %d", g, len(cs))
+		}
+		if !cs[0].High.Equal(decimal.NewFromInt(100000)) {
+			t.Fatalf("granularity %s: want high 100000, got %s", g, cs[0].High)
+		}
+	}
+	next := &internal.Candle{
+		Start:  spike.Start + 60,
+		Low:    dec(0),
+		High:   dec(5),
+		Open:   dec(0),
+		Close:  dec(3),
+		Volume: dec(2),
+	}
+	if err := ds.saveCandles(ctx, "BCH-USD", []*internal.Candle{next}); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range CandleGranularities()[1:] {
+		cs := scan(g, begin, end)
+		if len(cs) != 1 || !cs[0].Close.Equal(decimal.NewFromInt(3)) || !cs[0].Volume.Equal(decimal.NewFromInt(3)) {
+			t.Fatalf("granularity %s: rollup candle is not updated incrementally", g)
+		}
+	}
+}
+
+func TestVerifyAndBackfillCandles(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+
+	ex := newTestExchange(ctx, t, srv, "test-key", "test-secret")
+	defer ex.Close()
+
+	begin := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := begin.Add(600 * time.Minute)
+	missing := func(i int) bool {
+		return (i >= 10 && i < 20) || (i >= 400 && i < 450)
+	}
+
+	// Server has all candles with price 200 and the datastore has all candles,
+	// except the missing ones, with price 100.
+	var remote []*gobs.Candle
+	var local []*internal.Candle
+	for i := 0; i < 600; i++ {
+		start := begin.Add(time.Duration(i) * time.Minute)
+		remote = append(remote, &gobs.Candle{
+			StartTime: gobs.RemoteTime{Time: start},
+			Low:       decimal.NewFromInt(200),
+			High:      decimal.NewFromInt(200),
+			Open:      decimal.NewFromInt(200),
+			Close:     decimal.NewFromInt(200),
+			Volume:    decimal.NewFromInt(1),
+		})
+		if missing(i) {
+			continue
+		}
+		v := exchange.NullDecimal{Decimal: decimal.NewFromInt(100)}
+		local = append(local, &internal.Candle{Start: start.Unix(), Low: v, High: v, Open: v, Close: v, Volume: v})
+	}
+	srv.AddCandles("BCH-USD", remote)
+	if err := ex.datastore.saveCandles(ctx, "BCH-USD", local); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a duplicate of the first candle into the next hour's key.
+	key := path.Join(Keyspace, "candles", begin.Add(time.Hour).Local().Format("2006-01-02/15"))
+	value, err := kvutil.GetDB[gobs.CoinbaseCandles](ctx, ex.datastore.db, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value.ProductCandlesMap["BCH-USD"] = append(value.ProductCandlesMap["BCH-USD"], value.ProductCandlesMap["BCH-USD"][0])
+	if err := kvutil.SetDB(ctx, ex.datastore.db, key, value); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := ex.datastore.VerifyCandles(ctx, "BCH-USD", begin, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Missing) != 2 || report.NumMissing() != 60 {
+		t.Fatalf("want 60 missing minutes in 2 gaps, got %d in %d gaps", report.NumMissing(), len(report.Missing))
+	}
+	if g := report.Missing[0]; !g.Begin.Equal(begin.Add(10*time.Minute)) || !g.End.Equal(begin.Add(20*time.Minute)) {
+		t.Fatalf("unexpected first gap %s-%s", g.Begin, g.End)
+	}
+	if len(report.Duplicates) != 1 {
+		t.Fatalf("want 1 duplicate, got %d", len(report.Duplicates))
+	}
+
+	if err := ex.BackfillCandles(ctx, "BCH-USD", report.Missing); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := ex.datastore.VerifyCandles(ctx, "BCH-USD", begin, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after.Missing) != 0 {
+		t.Fatalf("want no missing candles after backfill, got %d", after.NumMissing())
+	}
+
+	// Only the missing candles must be fetched from the server.
+	i := 0
+	if err := ex.datastore.ScanCandles(ctx, "BCH-USD", begin, end, func(c *gobs.Candle) error {
+		want := decimal.NewFromInt(100)
+		if missing(int(c.StartTime.Sub(begin) / time.Minute)) {
+			want = decimal.NewFromInt(200)
+		}
+		if !c.Close.Equal(want) {
+			t.Fatalf("candle at %s: want close %s, got %s", c.StartTime, want, c.Close)
+		}
+		i++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if i != 601 {
+		t.Fatalf("want 601 candles including the duplicate, got %d", i)
+	}
+}
@@ -0,0 +1,809 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+// Package coinbasetest implements an in-process fake of the coinbase
+// advanced-trade REST and websocket services for the tests.
+//
+// Fake server keeps all products, accounts, orders and fills in memory. Open
+// limit orders are matched against the ticker prices scripted by the tests
+// using the Tick method, which also publishes the ticker, level2 and user
+// channel updates to the websocket subscribers.
+package coinbasetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bvk/tradebot/coinbase/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+)
+
+const apiPrefix = "/api/v3/brokerage/"
+
+type Server struct {
+	httpServer *httptest.Server
+
+	upgrader websocket.Upgrader
+
+	mu sync.Mutex
+
+	productMap map[string]*internal.Product
+	accountMap map[string]*internal.Account
+	candlesMap map[string][]*internal.Candle
+
+	feeTier internal.FeeTier
+
+	// orderMap holds all orders indexed by the server order id. configMap
+	// holds the order configuration for the open orders.
+	orderMap  map[string]*internal.Order
+	configMap map[string]*internal.OrderConfig
+
+	// clientOrderMap holds client order id to server order id mapping.
+	clientOrderMap map[string]string
+
+	fills []*internal.Fill
+
+	connMap map[*conn]struct{}
+
+	// numRejects is the number of next REST requests to reject with 429
+	// status and retryAfter is the Retry-After header value for them.
+	numRejects int
+	retryAfter string
+}
+
+// NewServer creates and starts a fake coinbase server on the loopback
+// interface. Caller must close the server when it is no longer necessary.
+func NewServer() *Server {
+	s := &Server{
+		productMap:     make(map[string]*internal.Product),
+		accountMap:     make(map[string]*internal.Account),
+		candlesMap:     make(map[string][]*internal.Candle),
+		orderMap:       make(map[string]*internal.Order),
+		configMap:      make(map[string]*internal.OrderConfig),
+		clientOrderMap: make(map[string]string),
+		connMap:        make(map[*conn]struct{}),
+		feeTier: internal.FeeTier{
+			PricingTier:  "Advanced 1",
+			MakerFeeRate: exchange.NullDecimal{Decimal: decimal.RequireFromString("0.004")},
+			TakerFeeRate: exchange.NullDecimal{Decimal: decimal.RequireFromString("0.006")},
+		},
+	}
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close shuts down the server and closes all websocket connections.
+func (s *Server) Close() {
+	s.mu.Lock()
+	for c := range s.connMap {
+		c.ws.Close()
+	}
+	s.mu.Unlock()
+	s.httpServer.Close()
+}
+
+// Hostname returns the host:port address for both REST and websocket
+// services. Clients must use the plain-text "http" and "ws" URL schemes.
+func (s *Server) Hostname() string {
+	u, _ := url.Parse(s.httpServer.URL)
+	return u.Host
+}
+
+// ServerTimeURL returns the URL for the server time endpoint.
+func (s *Server) ServerTimeURL() string {
+	return s.httpServer.URL + "/time"
+}
+
+// AddProduct adds a new spot product with the given initial ticker price.
+// Product id must be in the BASE-QUOTE form, eg: "BTC-USD".
+func (s *Server) AddProduct(productID string, price decimal.Decimal) {
+	base, quote, _ := strings.Cut(productID, "-")
+	p := &internal.Product{
+		ProductID:          productID,
+		Status:             "online",
+		Price:              exchange.NullDecimal{Decimal: price},
+		BaseName:           base,
+		BaseIncr:           exchange.NullDecimal{Decimal: decimal.RequireFromString("0.00000001")},
+		BaseMinSize:        exchange.NullDecimal{Decimal: decimal.RequireFromString("0.00000001")},
+		BaseMaxSize:        exchange.NullDecimal{Decimal: decimal.NewFromInt(1000000)},
+		BaseDisplaySymbol:  base,
+		BaseCurrencyID:     base,
+		QuoteName:          quote,
+		QuoteIncr:          exchange.NullDecimal{Decimal: decimal.RequireFromString("0.01")},
+		QuoteMinSize:       exchange.NullDecimal{Decimal: decimal.NewFromInt(1)},
+		QuoteMaxSize:       exchange.NullDecimal{Decimal: decimal.NewFromInt(10000000)},
+		QuoteDisplaySymbol: quote,
+		QuoteCurrencyID:    quote,
+		ProductType:        "SPOT",
+	}
+	s.mu.Lock()
+	s.productMap[productID] = p
+	s.mu.Unlock()
+}
+
+// SetBalance sets the available balance for the currency.
+func (s *Server) SetBalance(currency string, available decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.accountLocked(currency)
+	a.AvailableBalance.Value.Decimal = available
+}
+
+// SetFeeRates sets the maker and taker fee rates, eg: 0.004 for 0.4%.
+func (s *Server) SetFeeRates(maker, taker decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.feeTier.MakerFeeRate.Decimal = maker
+	s.feeTier.TakerFeeRate.Decimal = taker
+}
+
+// AddCandles adds historical candles for the product.
+func (s *Server) AddCandles(productID string, candles []*gobs.Candle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range candles {
+		s.candlesMap[productID] = append(s.candlesMap[productID], &internal.Candle{
+			Start:  c.StartTime.Unix(),
+			Low:    exchange.NullDecimal{Decimal: c.Low},
+			High:   exchange.NullDecimal{Decimal: c.High},
+			Open:   exchange.NullDecimal{Decimal: c.Open},
+			Close:  exchange.NullDecimal{Decimal: c.Close},
+			Volume: exchange.NullDecimal{Decimal: c.Volume},
+		})
+	}
+}
+
+// Orders returns a copy of all orders known to the server.
+func (s *Server) Orders() []*gobs.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var orders []*gobs.Order
+	for _, v := range s.orderMap {
+		orders = append(orders, &gobs.Order{
+			ServerOrderID: v.OrderID,
+			ClientOrderID: v.ClientOrderID,
+			CreateTime:    gobs.RemoteTime{Time: v.CreatedTime.Time},
+			FinishTime:    gobs.RemoteTime{Time: v.LastFillTime.Time},
+			Side:          v.Side,
+			Status:        v.Status,
+			FilledFee:     v.TotalFees.Decimal,
+			FilledSize:    v.FilledSize.Decimal,
+			FilledPrice:   v.AvgFilledPrice.Decimal,
+			Done:          v.Status != "OPEN" && v.Status != "PENDING",
+		})
+	}
+	return orders
+}
+
+// Tick updates the ticker price for the product, fills or expires the open
+// orders as necessary and publishes the updates to the websocket
+// subscribers.
+func (s *Server) Tick(productID string, price decimal.Decimal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.productMap[productID]
+	if !ok {
+		return fmt.Errorf("product %q not found", productID)
+	}
+	p.Price.Decimal = price
+
+	now := time.Now().UTC()
+	for id, config := range s.configMap {
+		order := s.orderMap[id]
+		if order.ProductID != productID {
+			continue
+		}
+		if gtd := config.LimitGTD; gtd != nil {
+			if end, err := time.Parse(time.RFC3339, gtd.EndTime); err == nil && now.After(end) {
+				s.closeLocked(order, "EXPIRED")
+				continue
+			}
+		}
+		limit, _, _ := limitConfig(config)
+		if crosses(order.Side, limit, price) {
+			s.fillLocked(order, limit, s.feeTier.MakerFeeRate.Decimal)
+		}
+	}
+
+	s.publishTickerLocked(p, now)
+	return nil
+}
+
+func (s *Server) accountLocked(currency string) *internal.Account {
+	for _, a := range s.accountMap {
+		if a.Currency == currency {
+			return a
+		}
+	}
+	a := &internal.Account{
+		UUID:             uuid.New().String(),
+		Name:             currency + " Wallet",
+		Currency:         currency,
+		AvailableBalance: internal.Balance{Currency: currency},
+		Hold:             internal.Balance{Currency: currency},
+		Active:           true,
+		Ready:            true,
+		Type:             "ACCOUNT_TYPE_CRYPTO",
+		CreatedAt:        exchange.RemoteTime{Time: time.Now().UTC()},
+	}
+	s.accountMap[a.UUID] = a
+	return a
+}
+
+// crosses returns true if an order at the limit price on the given side is
+// executable at the ticker price.
+func crosses(side string, limit, price decimal.Decimal) bool {
+	if side == "BUY" {
+		return price.LessThanOrEqual(limit)
+	}
+	return price.GreaterThanOrEqual(limit)
+}
+
+// limitConfig returns the limit price, size and post-only flag for a limit
+// order configuration. Limit price is zero for the market orders.
+func limitConfig(config *internal.OrderConfig) (price, size decimal.Decimal, postOnly bool) {
+	switch {
+	case config.LimitGTC != nil:
+		return config.LimitGTC.LimitPrice.Decimal, config.LimitGTC.BaseSize.Decimal, config.LimitGTC.PostOnly
+	case config.LimitGTD != nil:
+		return config.LimitGTD.LimitPrice.Decimal, config.LimitGTD.BaseSize.Decimal, config.LimitGTD.PostOnly
+	case config.LimitIOC != nil:
+		return config.LimitIOC.LimitPrice.Decimal, config.LimitIOC.BaseSize.Decimal, false
+	case config.LimitFOK != nil:
+		return config.LimitFOK.LimitPrice.Decimal, config.LimitFOK.BaseSize.Decimal, false
+	}
+	return decimal.Zero, decimal.Zero, false
+}
+
+// fillLocked fills the order completely at the given price and closes it.
+func (s *Server) fillLocked(order *internal.Order, price, feeRate decimal.Decimal) {
+	now := time.Now().UTC()
+	size := order.FilledSize.Decimal
+	if config, ok := s.configMap[order.OrderID]; ok {
+		_, size, _ = limitConfig(config)
+	}
+	value := size.Mul(price)
+	fee := value.Mul(feeRate)
+
+	order.FilledSize.Decimal = size
+	order.AvgFilledPrice.Decimal = price
+	order.FilledValue.Decimal = value
+	order.TotalFees.Decimal = fee
+	order.NumberOfFills = "1"
+	order.LastFillTime = exchange.RemoteTime{Time: now}
+
+	s.fills = append(s.fills, &internal.Fill{
+		EntryID:           uuid.New().String(),
+		TradeID:           uuid.New().String(),
+		OrderID:           order.OrderID,
+		TradeTime:         exchange.RemoteTime{Time: now},
+		TradeType:         "FILL",
+		Price:             exchange.NullDecimal{Decimal: price},
+		Size:              exchange.NullDecimal{Decimal: size},
+		Commission:        exchange.NullDecimal{Decimal: fee},
+		ProductID:         order.ProductID,
+		SequenceTimestamp: exchange.RemoteTime{Time: now},
+		Side:              order.Side,
+	})
+
+	if p, ok := s.productMap[order.ProductID]; ok {
+		base := s.accountLocked(p.BaseCurrencyID)
+		quote := s.accountLocked(p.QuoteCurrencyID)
+		if order.Side == "BUY" {
+			base.AvailableBalance.Value.Decimal = base.AvailableBalance.Value.Decimal.Add(size)
+			quote.AvailableBalance.Value.Decimal = quote.AvailableBalance.Value.Decimal.Sub(value.Add(fee))
+		} else {
+			base.AvailableBalance.Value.Decimal = base.AvailableBalance.Value.Decimal.Sub(size)
+			quote.AvailableBalance.Value.Decimal = quote.AvailableBalance.Value.Decimal.Add(value.Sub(fee))
+		}
+	}
+	s.closeLocked(order, "FILLED")
+}
+
+// closeLocked moves the order to a final status and publishes the update.
+func (s *Server) closeLocked(order *internal.Order, status string) {
+	order.Status = status
+	delete(s.configMap, order.OrderID)
+	s.publishOrderLocked(order)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebsocket(w, r)
+		return
+	}
+	if r.URL.Path == "/time" {
+		now := time.Now().UTC()
+		writeJSON(w, map[string]any{
+			"iso":   now.Format("2006-01-02T15:04:05.000Z"),
+			"epoch": float64(now.UnixMilli()) / 1000,
+		})
+		return
+	}
+
+	path, ok := strings.CutPrefix(r.URL.Path, apiPrefix)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if !isAuthenticated(r) {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	if retryAfter, ok := s.reject(); ok {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && path == "orders":
+		s.createOrder(w, r)
+	case r.Method == http.MethodPost && path == "orders/batch_cancel":
+		s.cancelOrders(w, r)
+	case r.Method == http.MethodPost && path == "orders/edit":
+		s.editOrder(w, r)
+	case r.Method == http.MethodGet && path == "orders/historical/batch":
+		s.listOrders(w, r)
+	case r.Method == http.MethodGet && path == "orders/historical/fills":
+		s.listFills(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "orders/historical/"):
+		s.getOrder(w, r, strings.TrimPrefix(path, "orders/historical/"))
+	case r.Method == http.MethodGet && path == "best_bid_ask":
+		s.getBestBidAsk(w, r)
+	case r.Method == http.MethodGet && path == "products":
+		s.listProducts(w, r)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/candles"):
+		s.getCandles(w, r, strings.TrimSuffix(strings.TrimPrefix(path, "products/"), "/candles"))
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "products/"):
+		s.getProduct(w, r, strings.TrimPrefix(path, "products/"))
+	case r.Method == http.MethodGet && path == "accounts":
+		s.listAccounts(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "accounts/"):
+		s.getAccount(w, r, strings.TrimPrefix(path, "accounts/"))
+	case r.Method == http.MethodGet && path == "transaction_summary":
+		s.getTransactionSummary(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// RejectRequests makes the server reject the next n REST requests with 429
+// status. Optional retryAfter is sent as the Retry-After header value.
+func (s *Server) RejectRequests(n int, retryAfter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.numRejects, s.retryAfter = n, retryAfter
+}
+
+func (s *Server) reject() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.numRejects == 0 {
+		return "", false
+	}
+	s.numRejects--
+	return s.retryAfter, true
+}
+
+// isAuthenticated returns true if the request carries either the legacy
+// HMAC signature headers or a JWT bearer token. Signatures are not verified.
+func isAuthenticated(r *http.Request) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.Count(token, ".") == 2
+	}
+	return len(r.Header.Get("CB-ACCESS-KEY")) > 0 && len(r.Header.Get("CB-ACCESS-SIGN")) > 0
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func createFailure(reason string) *internal.CreateOrderResponse {
+	return &internal.CreateOrderResponse{
+		Success:       false,
+		FailureReason: "UNKNOWN_FAILURE_REASON",
+		ErrorResponse: &internal.CreateOrderErrorResponse{
+			Error:                reason,
+			PreviewFailureReason: "PREVIEW_" + reason,
+		},
+	}
+}
+
+func (s *Server) createOrder(w http.ResponseWriter, r *http.Request) {
+	req := new(internal.CreateOrderRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	success := func(order *internal.Order) {
+		writeJSON(w, &internal.CreateOrderResponse{
+			Success: true,
+			SuccessResponse: &internal.CreateOrderSuccessResponse{
+				OrderID:       order.OrderID,
+				ProductID:     order.ProductID,
+				Side:          order.Side,
+				ClientOrderID: order.ClientOrderID,
+			},
+		})
+	}
+
+	if id, ok := s.clientOrderMap[req.ClientOrderID]; ok && len(req.ClientOrderID) > 0 {
+		success(s.orderMap[id])
+		return
+	}
+
+	p, ok := s.productMap[req.ProductID]
+	if !ok {
+		writeJSON(w, createFailure("INVALID_PRODUCT_ID"))
+		return
+	}
+	if req.Side != "BUY" && req.Side != "SELL" {
+		writeJSON(w, createFailure("INVALID_SIDE"))
+		return
+	}
+	if req.Order == nil {
+		writeJSON(w, createFailure("INVALID_ORDER_CONFIG"))
+		return
+	}
+
+	order := &internal.Order{
+		OrderID:       uuid.New().String(),
+		ClientOrderID: req.ClientOrderID,
+		ProductID:     req.ProductID,
+		ProductType:   "SPOT",
+		Side:          req.Side,
+		Status:        "OPEN",
+		CreatedTime:   exchange.RemoteTime{Time: time.Now().UTC()},
+	}
+	price := p.Price.Decimal
+
+	if m := req.Order.MarketIOC; m != nil {
+		var size decimal.Decimal
+		switch {
+		case m.BaseSize != nil:
+			size = m.BaseSize.Decimal
+		case m.QuoteSize != nil && req.Side == "BUY" && price.IsPositive():
+			// Quote size is only accepted for the buy orders.
+			size = m.QuoteSize.Decimal.Div(price).Truncate(int32(-p.BaseIncr.Decimal.Exponent()))
+		}
+		if !size.IsPositive() {
+			writeJSON(w, createFailure("INVALID_SIZE"))
+			return
+		}
+		order.OrderType = "MARKET"
+		order.FilledSize.Decimal = size
+		s.addOrderLocked(order, nil)
+		s.fillLocked(order, price, s.feeTier.TakerFeeRate.Decimal)
+		success(order)
+		return
+	}
+
+	limit, size, postOnly := limitConfig(req.Order)
+	if !limit.IsPositive() || !size.IsPositive() {
+		writeJSON(w, createFailure("INVALID_ORDER_CONFIG"))
+		return
+	}
+	if size.LessThan(p.BaseMinSize.Decimal) || size.GreaterThan(p.BaseMaxSize.Decimal) {
+		writeJSON(w, createFailure("INVALID_SIZE"))
+		return
+	}
+
+	cross := crosses(req.Side, limit, price)
+	if cross && postOnly {
+		writeJSON(w, createFailure("INVALID_LIMIT_PRICE_POST_ONLY"))
+		return
+	}
+
+	order.OrderType = "LIMIT"
+	s.addOrderLocked(order, req.Order)
+	switch {
+	case cross:
+		s.fillLocked(order, price, s.feeTier.TakerFeeRate.Decimal)
+	case req.Order.LimitIOC != nil || req.Order.LimitFOK != nil:
+		s.closeLocked(order, "CANCELLED")
+	}
+	success(order)
+}
+
+func (s *Server) addOrderLocked(order *internal.Order, config *internal.OrderConfig) {
+	s.orderMap[order.OrderID] = order
+	if len(order.ClientOrderID) > 0 {
+		s.clientOrderMap[order.ClientOrderID] = order.OrderID
+	}
+	if config != nil {
+		s.configMap[order.OrderID] = config
+	}
+	s.publishOrderLocked(order)
+}
+
+func (s *Server) cancelOrders(w http.ResponseWriter, r *http.Request) {
+	req := new(internal.CancelOrderRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := new(internal.CancelOrderResponse)
+	for _, id := range req.OrderIDs {
+		result := internal.CancelOrderResultResponse{OrderID: id}
+		order, ok := s.orderMap[id]
+		switch {
+		case !ok:
+			result.FailureReason = "UNKNOWN_CANCEL_ORDER"
+		case order.Status == "CANCELLED":
+			result.FailureReason = "DUPLICATE_CANCEL_REQUEST"
+		case order.Status != "OPEN":
+			result.FailureReason = "INVALID_CANCEL_REQUEST"
+		default:
+			s.closeLocked(order, "CANCELLED")
+			result.Success = true
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	writeJSON(w, resp)
+}
+
+// editOrder changes the size and price of an open GTC limit order. Like
+// coinbase, other order types cannot be edited.
+func (s *Server) editOrder(w http.ResponseWriter, r *http.Request) {
+	req := new(internal.EditOrderRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failure := func(reason string) *internal.EditOrderResponse {
+		return &internal.EditOrderResponse{
+			Errors: []internal.EditOrderErrorMsg{{EditFailureReason: reason}},
+		}
+	}
+
+	order, ok := s.orderMap[req.OrderID]
+	if !ok {
+		writeJSON(w, failure("ORDER_NOT_FOUND"))
+		return
+	}
+	config, ok := s.configMap[req.OrderID]
+	if !ok || order.Status != "OPEN" {
+		writeJSON(w, failure("CANNOT_EDIT_TO_BELOW_FILLED_SIZE"))
+		return
+	}
+	if config.LimitGTC == nil {
+		writeJSON(w, failure("INVALID_EDITED_ORDER_TYPE"))
+		return
+	}
+	if !req.Size.Decimal.IsPositive() || !req.Price.Decimal.IsPositive() {
+		writeJSON(w, failure("INVALID_EDITED_SIZE"))
+		return
+	}
+	config.LimitGTC.BaseSize = req.Size
+	config.LimitGTC.LimitPrice = req.Price
+	s.publishOrderLocked(order)
+	writeJSON(w, &internal.EditOrderResponse{Success: true})
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request, orderID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orderMap[orderID]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, &internal.GetOrderResponse{Order: order})
+}
+
+func (s *Server) listOrders(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	statuses := values["order_status"]
+	productID := values.Get("product_id")
+
+	var start time.Time
+	if v := values.Get("start_date"); len(v) > 0 {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		start = t
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := new(internal.ListOrdersResponse)
+	for _, order := range s.orderMap {
+		if len(statuses) > 0 && !slices.Contains(statuses, order.Status) {
+			continue
+		}
+		if len(productID) > 0 && order.ProductID != productID {
+			continue
+		}
+		if order.CreatedTime.Time.Before(start) {
+			continue
+		}
+		resp.Orders = append(resp.Orders, order)
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) listFills(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	orderID := values.Get("order_id")
+
+	var start time.Time
+	if v := values.Get("start_sequence_timestamp"); len(v) > 0 {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		start = t
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := new(internal.ListFillsResponse)
+	for _, fill := range s.fills {
+		if len(orderID) > 0 && fill.OrderID != orderID {
+			continue
+		}
+		if fill.SequenceTimestamp.Time.Before(start) {
+			continue
+		}
+		resp.Fills = append(resp.Fills, fill)
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) listProducts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := new(internal.ListProductsResponse)
+	for _, p := range s.productMap {
+		resp.Products = append(resp.Products, p)
+	}
+	resp.NumProducts = int32(len(resp.Products))
+	writeJSON(w, resp)
+}
+
+func (s *Server) getProduct(w http.ResponseWriter, r *http.Request, productID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.productMap[productID]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, &internal.GetProductResponse{
+		ProductID:          p.ProductID,
+		Status:             p.Status,
+		Price:              p.Price,
+		BaseIncrement:      p.BaseIncr,
+		BaseMinSize:        p.BaseMinSize,
+		BaseMaxSize:        p.BaseMaxSize,
+		BaseName:           p.BaseName,
+		BaseCurrencyID:     p.BaseCurrencyID,
+		BaseDisplaySymbol:  p.BaseDisplaySymbol,
+		QuoteIncrement:     p.QuoteIncr,
+		QuoteMinSize:       p.QuoteMinSize,
+		QuoteMaxSize:       p.QuoteMaxSize,
+		QuoteName:          p.QuoteName,
+		QuoteCurrencyID:    p.QuoteCurrencyID,
+		QuoteDisplaySymbol: p.QuoteDisplaySymbol,
+		ProductType:        p.ProductType,
+	})
+}
+
+// getBestBidAsk returns a single bid and ask price level around the current
+// ticker price for each product, same as the level2 snapshots.
+func (s *Server) getBestBidAsk(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := new(internal.GetBestBidAskResponse)
+	for _, pid := range r.URL.Query()["product_ids"] {
+		p, ok := s.productMap[pid]
+		if !ok {
+			continue
+		}
+		incr := p.QuoteIncr.Decimal
+		size := exchange.NullDecimal{Decimal: decimal.NewFromInt(1)}
+		resp.PriceBooks = append(resp.PriceBooks, &internal.PriceBook{
+			ProductID: pid,
+			Bids:      []*internal.PriceLevel{{Price: exchange.NullDecimal{Decimal: p.Price.Decimal.Sub(incr)}, Size: size}},
+			Asks:      []*internal.PriceLevel{{Price: exchange.NullDecimal{Decimal: p.Price.Decimal.Add(incr)}, Size: size}},
+			Time:      exchange.RemoteTime{Time: time.Now()},
+		})
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) getCandles(w http.ResponseWriter, r *http.Request, productID string) {
+	values := r.URL.Query()
+	var start, end int64
+	fmt.Sscan(values.Get("start"), &start)
+	fmt.Sscan(values.Get("end"), &end)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.productMap[productID]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+	resp := new(internal.GetProductCandlesResponse)
+	for _, c := range s.candlesMap[productID] {
+		if c.Start < start || (end != 0 && c.Start > end) {
+			continue
+		}
+		resp.Candles = append(resp.Candles, c)
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) listAccounts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := new(internal.ListAccountsResponse)
+	for _, a := range s.accountMap {
+		resp.Accounts = append(resp.Accounts, a)
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) getAccount(w http.ResponseWriter, r *http.Request, uuid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.accountMap[uuid]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, &internal.GetAccountResponse{Account: *a})
+}
+
+func (s *Server) getTransactionSummary(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, &internal.GetTransactionSummaryResponse{FeeTier: s.feeTier})
+}
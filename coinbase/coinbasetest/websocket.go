@@ -0,0 +1,272 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbasetest
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bvk/tradebot/coinbase/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+)
+
+// conn holds the state for a websocket client connection.
+type conn struct {
+	ws *websocket.Conn
+
+	// writeMu serializes the writes to the websocket connection. It also
+	// protects the sequence number and the subscriptions.
+	writeMu sync.Mutex
+
+	sequence int64
+
+	// chanProductsMap holds the subscribed products for each channel.
+	chanProductsMap map[string][]string
+
+	// dropMap holds the number of messages to drop for each channel. Dropped
+	// messages still consume the sequence numbers, so clients can detect
+	// them as gaps.
+	dropMap map[string]int
+}
+
+func (c *conn) isSubscribed(channel, productID string) bool {
+	return slices.Contains(c.chanProductsMap[channel], productID)
+}
+
+func (c *conn) sendLocked(channel string, timestamp time.Time, events []internal.Event) {
+	msg := &internal.Message{
+		Channel:   channel,
+		Timestamp: timestamp.Format(time.RFC3339Nano),
+		Sequence:  c.sequence,
+		Events:    events,
+	}
+	c.sequence++
+	if n := c.dropMap[channel]; n > 0 {
+		c.dropMap[channel] = n - 1
+		return
+	}
+	if err := c.ws.WriteJSON(msg); err != nil {
+		log.Printf("could not write to websocket client (ignored): %v", err)
+	}
+}
+
+func (s *Server) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("could not upgrade to websocket: %v", err)
+		return
+	}
+	c := &conn{
+		ws:              ws,
+		chanProductsMap: make(map[string][]string),
+		dropMap:         make(map[string]int),
+	}
+
+	s.mu.Lock()
+	s.connMap[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.connMap, c)
+		s.mu.Unlock()
+		ws.Close()
+	}()
+
+	for {
+		msg := new(internal.Message)
+		if err := ws.ReadJSON(msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case "subscribe":
+			s.subscribe(c, msg.Channel, msg.ProductIDs)
+		case "unsubscribe":
+			c.writeMu.Lock()
+			c.chanProductsMap[msg.Channel] = slices.DeleteFunc(c.chanProductsMap[msg.Channel], func(p string) bool {
+				return slices.Contains(msg.ProductIDs, p)
+			})
+			c.writeMu.Unlock()
+		}
+	}
+}
+
+// subscribe adds the products to the channel subscriptions and sends the
+// initial snapshot for the channel.
+func (s *Server) subscribe(c *conn, channel string, productIDs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	for _, pid := range productIDs {
+		if !slices.Contains(c.chanProductsMap[channel], pid) {
+			c.chanProductsMap[channel] = append(c.chanProductsMap[channel], pid)
+		}
+	}
+
+	now := time.Now().UTC()
+	switch channel {
+	case "ticker":
+		event := internal.Event{Type: "snapshot"}
+		for _, pid := range productIDs {
+			if p, ok := s.productMap[pid]; ok {
+				event.Tickers = append(event.Tickers, tickerEvent(p))
+			}
+		}
+		c.sendLocked("ticker", now, []internal.Event{event})
+
+	case "level2":
+		var events []internal.Event
+		for _, pid := range productIDs {
+			if p, ok := s.productMap[pid]; ok {
+				events = append(events, level2Event(p, now))
+			}
+		}
+		c.sendLocked("l2_data", now, events)
+
+	case "user":
+		event := internal.Event{Type: "snapshot"}
+		for _, order := range s.orderMap {
+			if order.Status == "OPEN" && slices.Contains(productIDs, order.ProductID) {
+				event.Orders = append(event.Orders, orderEvent(order))
+			}
+		}
+		c.sendLocked("user", now, []internal.Event{event})
+	}
+}
+
+func tickerEvent(p *internal.Product) *internal.TickerEvent {
+	return &internal.TickerEvent{
+		Type:      "ticker",
+		ProductID: p.ProductID,
+		Price:     p.Price,
+	}
+}
+
+// level2Event returns an order book snapshot with a single bid and ask
+// price level around the current ticker price.
+func level2Event(p *internal.Product, timestamp time.Time) internal.Event {
+	incr := p.QuoteIncr.Decimal
+	size := decimal.NewFromInt(1)
+	eventTime := timestamp.Format(time.RFC3339Nano)
+	return internal.Event{
+		Type:      "snapshot",
+		ProductID: p.ProductID,
+		Updates: []*internal.UpdateEvent{
+			{
+				Side:        "bid",
+				EventTime:   eventTime,
+				PriceLevel:  exchange.NullDecimal{Decimal: p.Price.Decimal.Sub(incr)},
+				NewQuantity: exchange.NullDecimal{Decimal: size},
+			},
+			{
+				Side:        "offer",
+				EventTime:   eventTime,
+				PriceLevel:  exchange.NullDecimal{Decimal: p.Price.Decimal.Add(incr)},
+				NewQuantity: exchange.NullDecimal{Decimal: size},
+			},
+		},
+	}
+}
+
+func orderEvent(order *internal.Order) *internal.OrderEvent {
+	return &internal.OrderEvent{
+		OrderID:            order.OrderID,
+		ClientOrderID:      order.ClientOrderID,
+		Status:             order.Status,
+		ProductID:          order.ProductID,
+		CreatedTime:        order.CreatedTime,
+		OrderSide:          order.Side,
+		OrderType:          order.OrderType,
+		CumulativeQuantity: order.FilledSize,
+		TotalFees:          order.TotalFees,
+		AvgPrice:           order.AvgFilledPrice,
+	}
+}
+
+func (s *Server) publishTickerLocked(p *internal.Product, timestamp time.Time) {
+	for c := range s.connMap {
+		c.writeMu.Lock()
+		if c.isSubscribed("ticker", p.ProductID) {
+			event := internal.Event{Type: "update", Tickers: []*internal.TickerEvent{tickerEvent(p)}}
+			c.sendLocked("ticker", timestamp, []internal.Event{event})
+		}
+		if c.isSubscribed("level2", p.ProductID) {
+			c.sendLocked("l2_data", timestamp, []internal.Event{level2Event(p, timestamp)})
+		}
+		c.writeMu.Unlock()
+	}
+}
+
+func (s *Server) publishOrderLocked(order *internal.Order) {
+	now := time.Now().UTC()
+	for c := range s.connMap {
+		c.writeMu.Lock()
+		if c.isSubscribed("user", order.ProductID) {
+			event := internal.Event{Type: "update", Orders: []*internal.OrderEvent{orderEvent(order)}}
+			c.sendLocked("user", now, []internal.Event{event})
+		}
+		c.writeMu.Unlock()
+	}
+}
+
+// DropMessages drops the next n messages for the channel on all websocket
+// connections to simulate the lost messages. Use "l2_data" for the level2
+// channel.
+func (s *Server) DropMessages(channel string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.connMap {
+		c.writeMu.Lock()
+		c.dropMap[channel] += n
+		c.writeMu.Unlock()
+	}
+}
+
+// Heartbeat sends a heartbeats channel message to all the subscribers.
+func (s *Server) Heartbeat() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	for c := range s.connMap {
+		c.writeMu.Lock()
+		if len(c.chanProductsMap["heartbeats"]) > 0 {
+			c.sendLocked("heartbeats", now, []internal.Event{{Type: "update"}})
+		}
+		c.writeMu.Unlock()
+	}
+}
+
+// NumConnections returns the number of open websocket connections.
+func (s *Server) NumConnections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.connMap)
+}
+
+// NumSubscribers returns the number of websocket connections subscribed to
+// the channel for the product.
+func (s *Server) NumSubscribers(channel, productID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for c := range s.connMap {
+		c.writeMu.Lock()
+		if c.isSubscribed(channel, productID) {
+			n++
+		}
+		c.writeMu.Unlock()
+	}
+	return n
+}
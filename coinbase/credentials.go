@@ -2,7 +2,28 @@
 
 package coinbase
 
+// Credentials holds the coinbase API key and secret. Legacy API keys use a
+// random string as the secret. Coinbase Developer Platform (CDP) API keys use
+// the key name (eg: "organizations/{org_id}/apiKeys/{key_id}") as the key and
+// a PEM encoded EC private key as the secret; they can also be given with the
+// "name" and "privateKey" fields as found in the downloaded CDP key files.
 type Credentials struct {
 	Key    string
 	Secret string
+
+	Name       string `json:"name,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"`
+}
+
+// KeySecret returns the API key and secret from the credentials. Client
+// chooses the authentication scheme automatically from the secret.
+func (c *Credentials) KeySecret() (key, secret string) {
+	key, secret = c.Key, c.Secret
+	if len(key) == 0 {
+		key = c.Name
+	}
+	if len(secret) == 0 {
+		secret = c.PrivateKey
+	}
+	return key, secret
 }
@@ -144,15 +144,12 @@ func (ds *Datastore) saveCandles(ctx context.Context, productID string, candles
 	}
 
 	saver := func(ctx context.Context, rw kv.ReadWriter) error {
+		cc := newCandlesCache()
+		var starts []int64
 		for key, candles := range kmap {
-			value, err := kvutil.Get[gobs.CoinbaseCandles](ctx, rw, key)
+			value, err := cc.get(ctx, rw, key)
 			if err != nil {
-				if !errors.Is(err, os.ErrNotExist) {
-					return fmt.Errorf("could not load coinbase candles at %q: %w", key, err)
-				}
-				value = &gobs.CoinbaseCandles{
-					ProductCandlesMap: make(map[string][]*gobs.CoinbaseCandle),
-				}
+				return err
 			}
 			pcandles := value.ProductCandlesMap[productID]
 			for _, c := range candles {
@@ -161,15 +158,17 @@ func (ds *Datastore) saveCandles(ctx context.Context, productID string, candles
 					return fmt.Errorf("could not json-marshal candle: %w", err)
 				}
 				pcandles = append(pcandles, &gobs.CoinbaseCandle{UnixTime: c.Start, Candle: json.RawMessage(js)})
+				starts = append(starts, c.Start)
 			}
 			slices.SortFunc(pcandles, cmp)
 			pcandles = slices.CompactFunc(pcandles, equal)
 			value.ProductCandlesMap[productID] = pcandles
-			if err := kvutil.Set(ctx, rw, key, value); err != nil {
-				return fmt.Errorf("could not save candles at key %q: %w", key, err)
-			}
+			cc.set(key, value)
 		}
-		return nil
+		if err := ds.updateRollupsLocked(ctx, rw, cc, productID, starts); err != nil {
+			return fmt.Errorf("could not update candle rollups: %w", err)
+		}
+		return cc.save(ctx, rw)
 	}
 	if err := kv.WithReadWriter(ctx, ds.db, saver); err != nil {
 		return err
@@ -330,6 +329,12 @@ func (ds *Datastore) saveOrderLocked(ctx context.Context, rw kv.ReadWriter, v *i
 	if err := kvutil.Set(ctx, rw, key, value); err != nil {
 		return fmt.Errorf("could not save coinbase order at key %q: %w", key, err)
 	}
+	if len(v.ClientOrderID) > 0 {
+		ckey := path.Join(Keyspace, "client-order-ids", v.ClientOrderID)
+		if err := kvutil.SetString(ctx, rw, ckey, v.OrderID); err != nil {
+			return fmt.Errorf("could not save client order id at key %q: %w", ckey, err)
+		}
+	}
 	return nil
 }
 
@@ -418,6 +423,73 @@ func (ds *Datastore) ProductsPriceMap(ctx context.Context) (map[string]decimal.D
 	return pmap, nil
 }
 
+// LoadProduct returns the most recently saved product information for the
+// given product id.
+func (ds *Datastore) LoadProduct(ctx context.Context, productID string) (*gobs.Product, error) {
+	var product *gobs.Product
+	load := func(ctx context.Context, r kv.Reader) error {
+		key := path.Join(Keyspace, "products")
+		value, err := kvutil.Get[gobs.CoinbaseProducts](ctx, r, key)
+		if err != nil {
+			return fmt.Errorf("could not load coinbase products information: %w", err)
+		}
+		for _, p := range value.Products {
+			if p.ProductID != productID {
+				continue
+			}
+			v := new(internal.Product)
+			if err := json.Unmarshal(p.Product, v); err != nil {
+				return fmt.Errorf("could not json-unmarshal coinbase product: %w", err)
+			}
+			product = &gobs.Product{
+				ProductID: v.ProductID,
+				Status:    v.Status,
+				Price:     v.Price.Decimal,
+
+				BaseName:          v.BaseName,
+				BaseMinSize:       v.BaseMinSize.Decimal,
+				BaseMaxSize:       v.BaseMaxSize.Decimal,
+				BaseIncrement:     v.BaseIncr.Decimal,
+				BaseDisplaySymbol: v.BaseDisplaySymbol,
+				BaseCurrencyID:    v.BaseCurrencyID,
+
+				QuoteName:          v.QuoteName,
+				QuoteMinSize:       v.QuoteMinSize.Decimal,
+				QuoteMaxSize:       v.QuoteMaxSize.Decimal,
+				QuoteIncrement:     v.QuoteIncr.Decimal,
+				QuoteDisplaySymbol: v.QuoteDisplaySymbol,
+				QuoteCurrencyID:    v.QuoteCurrencyID,
+			}
+			return nil
+		}
+		return fmt.Errorf("product %q is not found: %w", productID, os.ErrNotExist)
+	}
+
+	if err := kv.WithReader(ctx, ds.db, load); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (ds *Datastore) SaveFeeSchedule(ctx context.Context, v *gobs.FeeSchedule) error {
+	key := path.Join(Keyspace, "fee-schedule")
+	if err := kvutil.SetDB(ctx, ds.db, key, v); err != nil {
+		return fmt.Errorf("could not save fee schedule at key %q: %w", key, err)
+	}
+	return nil
+}
+
+// LoadFeeSchedule returns the fee schedule last fetched from the exchange. It
+// returns os.ErrNotExist if fee schedule was never fetched.
+func (ds *Datastore) LoadFeeSchedule(ctx context.Context) (*gobs.FeeSchedule, error) {
+	key := path.Join(Keyspace, "fee-schedule")
+	v, err := kvutil.GetDB[gobs.FeeSchedule](ctx, ds.db, key)
+	if err != nil {
+		return nil, fmt.Errorf("could not load fee schedule: %w", err)
+	}
+	return v, nil
+}
+
 func (ds *Datastore) saveAccounts(ctx context.Context, as []*internal.Account) error {
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
@@ -6,19 +6,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/url"
 	"os"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bvk/tradebot/coinbase/internal"
 	"github.com/bvk/tradebot/ctxutil"
 	"github.com/bvk/tradebot/exchange"
 	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/logdir"
 	"github.com/bvk/tradebot/syncmap"
 	"github.com/bvkgo/kv"
+	"github.com/shopspring/decimal"
 )
 
 type Exchange struct {
@@ -26,16 +31,48 @@ type Exchange struct {
 
 	client *internal.Client
 
-	websocket *internal.Websocket
-
-	// clientOrderIDMap holds client-order-id to exchange.Order mapping for all
-	// known orders. TODO: We should cleanup the oldest orders.
-	clientOrderIDMap syncmap.Map[string, *exchange.Order]
+	// websocketLog is non-nil when the WebsocketLogDir option is set.
+	websocketLog *logdir.Backend
+
+	// websocket is the single connection shared by all product channels and
+	// userWebsocket is the connection for the user channel. Sequence numbers
+	// are unique only within a connection, so keeping the user channel on a
+	// separate connection limits the order resyncs to the gaps in the order
+	// updates and the order book resets to the gaps in the level2 updates.
+	// They are created on first use by the sharedWebsocket and the
+	// getUserWebsocket methods.
+	websocket     *internal.Websocket
+	websocketOnce sync.Once
+
+	userWebsocket     *internal.Websocket
+	userWebsocketOnce sync.Once
+
+	// productIDs holds all supported product ids, which are used for the
+	// heartbeats channel.
+	productIDs []string
+
+	// aliasesMap maps a product id to the product ids that use it's ticker and
+	// order book updates as per the PriceAliases option.
+	aliasesMap map[string][]string
+
+	// clientOrderIDMap holds client-order-id to exchange.Order mapping for the
+	// recently used orders. Older completed orders are evicted and must be
+	// looked up in the datastore.
+	clientOrderIDMap *orderCache
+
+	// cancelled holds the completed orders with zero filled size that are not
+	// yet saved to the datastore. Map values are nil till the full order is
+	// known.
+	cancelledMu sync.Mutex
+	cancelled   map[string]*internal.Order
 
 	productMap syncmap.Map[string, *Product]
 
 	datastore *Datastore
 
+	// recorder is non-nil when the RecordTickers option is enabled.
+	recorder *tickerRecorder
+
 	// lastFilledTime keeps track of a timestamp before which all completed
 	// orders with non-zero filled-size are saved and available in our local
 	// datastore. We determine this timestamp by scanning the datastore keys in
@@ -48,6 +85,15 @@ type Exchange struct {
 	// operations (eg: CancelOrder), so we use this map to make the callers wait
 	// till the orders becomes ready.
 	pendingMap syncmap.Map[string, chan struct{}]
+
+	// resyncCh is signaled when order updates on the user channel could've
+	// been lost, so that the open orders are refreshed through the REST api.
+	resyncCh chan struct{}
+
+	// numGaps and numResyncs count the websocket message gaps and the order
+	// resyncs triggered by them. They indicate the websocket feed quality.
+	numGaps    atomic.Int64
+	numResyncs atomic.Int64
 }
 
 // New creates a client for coinbase exchange.
@@ -60,11 +106,37 @@ func New(ctx context.Context, db kv.Database, key, secret string, opts *Options)
 	copts := &internal.Options{
 		RestHostname:           opts.RestHostname,
 		WebsocketHostname:      opts.WebsocketHostname,
+		RestScheme:             opts.RestScheme,
+		WebsocketScheme:        opts.WebsocketScheme,
+		ServerTimeURL:          opts.ServerTimeURL,
 		HttpClientTimeout:      opts.HttpClientTimeout,
 		WebsocketRetryInterval: opts.WebsocketRetryInterval,
-		MaxTimeAdjustment:      opts.MaxTimeAdjustment,
-		MaxFetchTimeLatency:    opts.MaxFetchTimeLatency,
+
+		RetryCount:                      int(opts.RetryCount),
+		MaxWebsocketOutOfOrderAllowance: opts.MaxWebsocketOutOfOrderAllowance,
+		MaxTimeAdjustment:               opts.MaxTimeAdjustment,
+		MaxFetchTimeLatency:             opts.MaxFetchTimeLatency,
+	}
+
+	var websocketLog *logdir.Backend
+	if len(opts.WebsocketLogDir) > 0 {
+		lopts := &logdir.Options{
+			MaxFiles: opts.WebsocketLogMaxFiles,
+			MaxAge:   opts.WebsocketLogMaxAge,
+		}
+		v, err := logdir.New(opts.WebsocketLogDir, "coinbase-websocket", 0, lopts)
+		if err != nil {
+			return nil, fmt.Errorf("could not create websocket log: %w", err)
+		}
+		defer func() {
+			if status != nil {
+				v.Close()
+			}
+		}()
+		websocketLog = v
+		copts.WebsocketTee = v
 	}
+
 	client, err := internal.New(ctx, key, secret, copts)
 	if err != nil {
 		return nil, fmt.Errorf("could not create coinbase client: %w", err)
@@ -81,22 +153,42 @@ func New(ctx context.Context, db kv.Database, key, secret string, opts *Options)
 	}
 	pids := make([]string, 0, len(ps.Products))
 	for _, p := range ps.Products {
-		if p.QuoteDisplaySymbol == "USD" || p.QuoteDisplaySymbol == "USDC" {
+		if slices.Contains(opts.QuoteCurrencies, p.QuoteDisplaySymbol) {
 			pids = append(pids, p.ProductID)
 		}
 	}
 
+	aliases := opts.PriceAliases
+	if aliases == nil {
+		aliases = defaultPriceAliases(pids)
+	}
+	aliasesMap := make(map[string][]string)
+	for alias, pid := range aliases {
+		aliasesMap[pid] = append(aliasesMap[pid], alias)
+	}
+
 	exchange := &Exchange{
-		opts:      *opts,
-		client:    client,
-		datastore: NewDatastore(db),
+		opts:             *opts,
+		client:           client,
+		websocketLog:     websocketLog,
+		clientOrderIDMap: newOrderCache(opts.MaxCachedOrders),
+		datastore:        NewDatastore(db),
+		resyncCh:         make(chan struct{}, 1),
+		productIDs:       pids,
+		aliasesMap:       aliasesMap,
 	}
 
-	// User channel is subscribed for all supported products in a separate
-	// connection from product specific channels.
+	// User channel is subscribed for all supported products on a separate
+	// connection from the product specific channels.
 	if !opts.subcmdMode {
-		exchange.websocket = client.GetMessages("heartbeats", pids, exchange.dispatchMessage)
-		exchange.websocket.Subscribe("user", pids)
+		exchange.getUserWebsocket().Subscribe("user", pids)
+	}
+
+	// Ticker channel is subscribed for all watched products so that their
+	// tickers are recorded even when the products are not opened.
+	if opts.RecordTickers && !opts.subcmdMode {
+		exchange.recorder = newTickerRecorder(exchange.datastore, opts.WatchProductIDs, opts.TickerRetention)
+		exchange.sharedWebsocket().Subscribe("ticker", opts.WatchProductIDs)
 	}
 
 	// Find out the last saved timestamp and fetch all FILLED and CANCELLED
@@ -114,12 +206,30 @@ func New(ctx context.Context, db kv.Database, key, secret string, opts *Options)
 	exchange.lastFilledTime = lastFilledTime.Add(-6 * time.Hour)
 
 	if !opts.subcmdMode {
+		// Orders saved by older versions may not have the client-order-id
+		// index, which is necessary to lookup the evicted orders.
+		n, err := exchange.datastore.indexClientOrderIDs(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not index client order ids: %w", err)
+		}
+		if n > 0 {
+			log.Printf("added %d missing client order ids to the datastore index", n)
+		}
+
 		if err := exchange.sync(ctx); err != nil {
 			return nil, fmt.Errorf("could not sync for lost data: %w", err)
 		}
 
+		client.Go(exchange.goResyncOrders)
 		client.Go(exchange.goFetchProducts)
 		client.Go(exchange.goFetchCandles)
+		client.Go(exchange.goCompactCancelled)
+
+		if exchange.recorder != nil {
+			client.Go(func(ctx context.Context) {
+				exchange.recorder.goFlush(ctx, opts.RecordTickersInterval)
+			})
+		}
 
 		client.Go(func(ctx context.Context) {
 			exchange.goRunBackgroundTasks(ctx)
@@ -130,9 +240,101 @@ func New(ctx context.Context, db kv.Database, key, secret string, opts *Options)
 
 func (ex *Exchange) Close() error {
 	ex.client.Close()
+	if ex.websocketLog != nil {
+		ex.websocketLog.Close()
+	}
+	return nil
+}
+
+// ReplayMessages reads the raw websocket messages saved through the
+// WebsocketLogDir option and dispatches them to the exchange in the same
+// order as they were received, so that the order and ticker updates can be
+// reproduced deterministically. Message sequence numbers are verified the
+// same way as the live messages, so lost messages and reconnects in the log
+// are reported as gaps. Live websocket messages, if any, are also dispatched
+// concurrently, so the exchange should be created with a server that doesn't
+// publish any messages.
+func (ex *Exchange) ReplayMessages(ctx context.Context, r io.Reader) error {
+	// Sequence numbers are verified separately for every websocket
+	// connection in the log.
+	type connState struct {
+		seq      *internal.Sequencer
+		channels []string
+	}
+	connMap := make(map[int64]*connState)
+
+	replay := func(raw *internal.RawMessage, msg *internal.Message) error {
+		if err := context.Cause(ctx); err != nil {
+			return err
+		}
+		conn, ok := connMap[raw.Websocket]
+		if !ok {
+			conn = &connState{seq: internal.NewSequencer()}
+			connMap[raw.Websocket] = conn
+		}
+		// Sequence numbers restart from zero on a new connection.
+		if msg.Sequence == 0 && len(conn.channels) > 0 {
+			ex.handleGap(&internal.Gap{Channels: conn.channels, Reconnect: true})
+			conn.seq, conn.channels = internal.NewSequencer(), nil
+		}
+		if ch := subscriptionChannel(msg.Channel); ch != "" && !slices.Contains(conn.channels, ch) {
+			conn.channels = append(conn.channels, ch)
+		}
+		if gap := conn.seq.Check(msg); gap != nil {
+			gap.Channels = conn.channels
+			ex.handleGap(gap)
+		}
+		ex.dispatchMessage(msg)
+		return nil
+	}
+	if err := internal.ReadRawMessages(r, replay); err != nil {
+		return fmt.Errorf("could not replay websocket messages: %w", err)
+	}
 	return nil
 }
 
+// subscriptionChannel returns the subscription channel name for the channel
+// name in a websocket message.
+func subscriptionChannel(channel string) string {
+	switch channel {
+	case "subscriptions":
+		return ""
+	case "l2_data":
+		return "level2"
+	}
+	return channel
+}
+
+// defaultPriceAliases returns the price aliases from X-USDC products to the
+// X-USD products.
+func defaultPriceAliases(pids []string) map[string]string {
+	aliases := make(map[string]string)
+	for _, pid := range pids {
+		if base, ok := strings.CutSuffix(pid, "-USDC"); ok && slices.Contains(pids, base+"-USD") {
+			aliases[pid] = base + "-USD"
+		}
+	}
+	return aliases
+}
+
+// sharedWebsocket returns the websocket connection shared by all product
+// channel subscriptions. Connection is created on the first call.
+func (ex *Exchange) sharedWebsocket() *internal.Websocket {
+	ex.websocketOnce.Do(func() {
+		ex.websocket = ex.client.GetMessages("heartbeats", ex.productIDs, ex.dispatchMessage, ex.handleGap)
+	})
+	return ex.websocket
+}
+
+// getUserWebsocket returns the websocket connection for the user channel.
+// Connection is created on the first call.
+func (ex *Exchange) getUserWebsocket() *internal.Websocket {
+	ex.userWebsocketOnce.Do(func() {
+		ex.userWebsocket = ex.client.GetMessages("heartbeats", ex.productIDs, ex.dispatchMessage, ex.handleGap)
+	})
+	return ex.userWebsocket
+}
+
 func (ex *Exchange) ExchangeName() string {
 	return "coinbase"
 }
@@ -150,9 +352,16 @@ func (ex *Exchange) sync(ctx context.Context) error {
 
 	log.Printf("fetched %d filled orders from %s", len(filled), ex.lastFilledTime)
 
-	// FIXME: Number of cancelled orders can be huge. We probably don't need to
-	// fetch all cancelled orders.
-	cancelled, err := ex.ListOrders(ctx, ex.lastFilledTime, "CANCELLED")
+	// Number of cancelled orders can be huge, so cancelled orders older than
+	// the retention period are not fetched. They are looked up in the
+	// datastore when necessary.
+	from := ex.lastFilledTime
+	if retention := ex.opts.CancelledOrderRetention; retention > 0 {
+		if v := time.Now().Add(-retention); from.Before(v) {
+			from = v
+		}
+	}
+	cancelled, err := ex.ListOrders(ctx, from, "CANCELLED")
 	if err != nil {
 		return fmt.Errorf("could not fetch old canceled orders: %w", err)
 	}
@@ -161,7 +370,10 @@ func (ex *Exchange) sync(ctx context.Context) error {
 			ex.clientOrderIDMap.Store(v.ClientOrderID, v)
 		}
 	}
-	log.Printf("fetched %d canceled orders from %s", len(cancelled), ex.lastFilledTime)
+	log.Printf("fetched %d canceled orders from %s", len(cancelled), from)
+	if err := ex.saveCancelled(ctx); err != nil {
+		return fmt.Errorf("could not save canceled orders: %w", err)
+	}
 	return nil
 }
 
@@ -219,6 +431,10 @@ func (ex *Exchange) goRunBackgroundTasks(ctx context.Context) {
 	last := ex.lastFilledTime
 	timeout := ex.opts.PollOrdersRetryInterval
 	for ctxutil.Sleep(ctx, timeout); ctx.Err() == nil; ctxutil.Sleep(ctx, timeout) {
+		if err := ex.saveCancelled(ctx); err != nil {
+			log.Printf("could not save cancelled orders (will retry): %v", err)
+		}
+
 		now := ex.client.Now().Time
 		fills, err := ex.listFillsFrom(ctx, last)
 		if err != nil {
@@ -266,6 +482,120 @@ func (ex *Exchange) goRunBackgroundTasks(ctx context.Context) {
 	}
 }
 
+// FeedStats returns the number of websocket message gaps found and the number
+// of order resyncs performed because of them.
+func (ex *Exchange) FeedStats() (gaps, resyncs int64) {
+	return ex.numGaps.Load(), ex.numResyncs.Load()
+}
+
+// ThrottleStatus describes the current state of a REST api request budget.
+type ThrottleStatus = exchange.ThrottleStatus
+
+// ThrottleStatus returns the current state of the REST api request budgets
+// for diagnostics.
+func (ex *Exchange) ThrottleStatus() []*ThrottleStatus {
+	return ex.client.ThrottleStatus()
+}
+
+var _ exchange.StatsReporter = &Exchange{}
+
+// Stats implements the exchange.StatsReporter interface.
+func (ex *Exchange) Stats() *exchange.Stats {
+	gaps, resyncs := ex.FeedStats()
+	return &exchange.Stats{
+		NumGaps:    gaps,
+		NumResyncs: resyncs,
+		Throttles:  ex.ThrottleStatus(),
+	}
+}
+
+// handleGap is invoked when websocket messages are lost or reordered on a
+// connection. Open orders are resynced when the connection carries the user
+// channel, because a lost order update can leave the jobs waiting forever.
+// Order books are cleared and resubscribed when the connection carries the
+// level2 channel, because a lost update leaves the books inconsistent.
+func (ex *Exchange) handleGap(gap *internal.Gap) {
+	n := ex.numGaps.Add(1)
+	if gap.Reconnect {
+		log.Printf("websocket for channels %v is reconnected; messages may be lost (%d gaps so far)", gap.Channels, n)
+	} else {
+		log.Printf("websocket for channels %v expected sequence %d, but received %d (%d gaps so far)", gap.Channels, gap.Expected, gap.Received, n)
+	}
+	if slices.Contains(gap.Channels, "level2") {
+		ex.resetOrderBooks(!gap.Reconnect)
+	}
+	if !slices.Contains(gap.Channels, "user") {
+		return
+	}
+	select {
+	case ex.resyncCh <- struct{}{}:
+	default:
+	}
+}
+
+// resetOrderBooks clears the order books of all open products. When
+// resubscribe is true, level2 channel is subscribed again on the current
+// connection to receive new snapshots. New connections always receive new
+// snapshots, so resubscribe is not necessary after reconnects.
+func (ex *Exchange) resetOrderBooks(resubscribe bool) {
+	nproducts := 0
+	ex.productMap.Range(func(pid string, p *Product) bool {
+		p.orderBook.reset()
+		nproducts++
+		return true
+	})
+	if resubscribe && nproducts > 0 {
+		ex.sharedWebsocket().Resubscribe("level2")
+	}
+}
+
+func (ex *Exchange) goResyncOrders(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ex.resyncCh:
+			if err := ex.resyncOrders(ctx); err != nil {
+				log.Printf("could not resync orders after a websocket gap (will retry): %v", err)
+				ctxutil.Sleep(ctx, time.Second)
+				select {
+				case ex.resyncCh <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// resyncOrders fetches all orders that are not known to be complete through
+// the REST api and dispatches them to the products as if they were received
+// on the user channel.
+func (ex *Exchange) resyncOrders(ctx context.Context) error {
+	var ids []exchange.OrderID
+	ex.clientOrderIDMap.Range(func(_ string, order *exchange.Order) bool {
+		if !order.Done {
+			ids = append(ids, order.OrderID)
+		}
+		return true
+	})
+
+	var errs []error
+	for _, id := range ids {
+		resp, err := ex.client.GetOrder(ctx, string(id))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not get order %s: %w", id, err))
+			continue
+		}
+		v := exchangeOrderFromOrder(resp.Order)
+		ex.clientOrderIDMap.Store(v.ClientOrderID, v)
+		ex.dispatchOrder(resp.Order.ProductID, v)
+	}
+
+	n := ex.numResyncs.Add(1)
+	log.Printf("resynced %d orders after a websocket gap (%d resyncs so far)", len(ids)-len(errs), n)
+	return errors.Join(errs...)
+}
+
 // dispatchOrder relays the order fetched from coinbase for any reason to the
 // appropriate product for side-channel handling.
 func (ex *Exchange) dispatchOrder(productID string, order *exchange.Order) {
@@ -287,7 +617,17 @@ func (ex *Exchange) dispatchOrder(productID string, order *exchange.Order) {
 		}
 	}
 
-	ex.clientOrderIDMap.LoadOrStore(order.ClientOrderID, order)
+	// Cached order is replaced only when the order is complete, so that
+	// delayed updates cannot override the final order state.
+	old, loaded := ex.clientOrderIDMap.LoadOrStore(order.ClientOrderID, order)
+	if loaded && !old.Done && done {
+		ex.clientOrderIDMap.Store(order.ClientOrderID, order)
+	}
+	// Completed orders with zero filled size are saved in the datastore only
+	// once, when they are seen completed for the first time.
+	if done && order.FilledSize.IsZero() && (!loaded || !old.Done) {
+		ex.addCancelled(order.OrderID)
+	}
 
 	// Relay the order to the appropriate product.
 	if p, ok := ex.productMap.Load(productID); ok {
@@ -295,6 +635,12 @@ func (ex *Exchange) dispatchOrder(productID string, order *exchange.Order) {
 	}
 }
 
+// withAliases returns the product id along with the product ids that use it
+// as the price alias.
+func (ex *Exchange) withAliases(pid string) []string {
+	return append([]string{pid}, ex.aliasesMap[pid]...)
+}
+
 // dispatchMessage relays the websocket message to appropriate product.
 func (ex *Exchange) dispatchMessage(msg *internal.Message) {
 	if msg.Channel == "user" {
@@ -308,6 +654,23 @@ func (ex *Exchange) dispatchMessage(msg *internal.Message) {
 		}
 	}
 
+	// Level2 channel subscriptions receive their messages on the l2_data
+	// channel.
+	if msg.Channel == "l2_data" {
+		timestamp, err := time.Parse(time.RFC3339Nano, msg.Timestamp)
+		if err != nil {
+			log.Printf("error: could not parse websocket msg timestamp %q (ignored): %v", msg.Timestamp, err)
+			return
+		}
+		for _, event := range msg.Events {
+			for _, pid := range ex.withAliases(event.ProductID) {
+				if p, ok := ex.productMap.Load(pid); ok {
+					p.orderBook.handleEvent(timestamp, &event)
+				}
+			}
+		}
+	}
+
 	if msg.Channel == "ticker" {
 		timestamp, err := time.Parse(time.RFC3339Nano, msg.Timestamp)
 		if err != nil {
@@ -316,15 +679,13 @@ func (ex *Exchange) dispatchMessage(msg *internal.Message) {
 		}
 		for _, event := range msg.Events {
 			for _, ticker := range event.Tickers {
-				if p, ok := ex.productMap.Load(ticker.ProductID); ok {
-					p.handleTickerEvent(timestamp, ticker)
-				}
-				if !strings.HasSuffix(ticker.ProductID, "-USD") {
-					continue
-				}
-				usdcProduct := strings.TrimSuffix(ticker.ProductID, "-USD") + "-USDC"
-				if p, ok := ex.productMap.Load(usdcProduct); ok {
-					p.handleTickerEvent(timestamp, ticker)
+				for _, pid := range ex.withAliases(ticker.ProductID) {
+					if ex.recorder != nil {
+						ex.recorder.record(pid, timestamp, ticker.Price.Decimal)
+					}
+					if p, ok := ex.productMap.Load(pid); ok {
+						p.handleTickerEvent(timestamp, ticker)
+					}
 				}
 			}
 		}
@@ -365,10 +726,18 @@ func (ex *Exchange) createReadyOrder(ctx context.Context, req *internal.CreateOr
 	return resp, err
 }
 
-func (ex *Exchange) recreateOldOrder(clientOrderID string) (*exchange.Order, bool) {
+func (ex *Exchange) recreateOldOrder(ctx context.Context, clientOrderID string) (*exchange.Order, bool) {
 	old, ok := ex.clientOrderIDMap.Load(clientOrderID)
 	if !ok {
-		return nil, false
+		v, err := ex.datastore.OrderByClientID(ctx, clientOrderID)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Printf("could not lookup client order id %s in the datastore (ignored): %v", clientOrderID, err)
+			}
+			return nil, false
+		}
+		old = exchangeOrderFromOrder(v)
+		ex.clientOrderIDMap.Store(clientOrderID, old)
 	}
 	log.Printf("recreate order request for already used client-id %s is short-circuited to return old server order id %s", clientOrderID, old.OrderID)
 	return old, true
@@ -384,6 +753,7 @@ func (ex *Exchange) GetOrder(ctx context.Context, orderID exchange.OrderID) (*ex
 	}
 	v := exchangeOrderFromOrder(resp.Order)
 	ex.dispatchOrder(resp.Order.ProductID, v)
+	ex.setCancelled(resp.Order)
 	return v, nil
 }
 
@@ -470,6 +840,7 @@ func (ex *Exchange) ListOrders(ctx context.Context, from time.Time, status strin
 	for _, order := range rorders {
 		v := exchangeOrderFromOrder(order)
 		ex.dispatchOrder(order.ProductID, v)
+		ex.setCancelled(order)
 		orders = append(orders, v)
 	}
 	return orders, nil
@@ -491,13 +862,75 @@ func (ex *Exchange) listRawAccounts(ctx context.Context) ([]*internal.Account, e
 	return accounts, nil
 }
 
+func (ex *Exchange) Balances(ctx context.Context) ([]*gobs.Account, error) {
+	raws, err := ex.listRawAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list accounts: %w", err)
+	}
+	now := time.Now()
+	var accounts []*gobs.Account
+	for _, v := range raws {
+		if v.AvailableBalance.Value.Decimal.IsZero() && v.Hold.Value.Decimal.IsZero() {
+			continue
+		}
+		accounts = append(accounts, &gobs.Account{
+			Timestamp:  now,
+			Name:       v.Name,
+			CurrencyID: v.Currency,
+			Available:  v.AvailableBalance.Value.Decimal,
+			Hold:       v.Hold.Value.Decimal,
+		})
+	}
+	return accounts, nil
+}
+
+// feeScheduleMaxAge is the duration after which the saved fee schedule is
+// refreshed from the exchange.
+const feeScheduleMaxAge = 24 * time.Hour
+
+// FeeSchedule returns the current fee tier for the account. Fee schedule is
+// saved in the datastore and is refreshed from the exchange once a day. The
+// saved value is returned if it could not be refreshed.
+func (ex *Exchange) FeeSchedule(ctx context.Context) (*gobs.FeeSchedule, error) {
+	saved, err := ex.datastore.LoadFeeSchedule(ctx)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if saved != nil && time.Since(saved.Timestamp) < feeScheduleMaxAge {
+		return saved, nil
+	}
+
+	resp, err := ex.client.GetTransactionSummary(ctx)
+	if err != nil {
+		if saved != nil {
+			log.Printf("could not refresh fee schedule (using the saved value): %v", err)
+			return saved, nil
+		}
+		return nil, fmt.Errorf("could not fetch transaction summary: %w", err)
+	}
+	d100 := decimal.NewFromInt(100)
+	v := &gobs.FeeSchedule{
+		Timestamp:   time.Now(),
+		TierName:    resp.FeeTier.PricingTier,
+		MakerFeePct: resp.FeeTier.MakerFeeRate.Decimal.Mul(d100),
+		TakerFeePct: resp.FeeTier.TakerFeeRate.Decimal.Mul(d100),
+	}
+	if err := ex.datastore.SaveFeeSchedule(ctx, v); err != nil {
+		log.Printf("could not save fee schedule (ignored): %v", err)
+	}
+	return v, nil
+}
+
 func (ex *Exchange) GetProduct(ctx context.Context, productID string) (*gobs.Product, error) {
 	resp, err := ex.client.GetProduct(ctx, productID)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch product %q info: %w", productID, err)
 	}
+	return gobsProduct(resp), nil
+}
 
-	product := &gobs.Product{
+func gobsProduct(resp *internal.GetProductResponse) *gobs.Product {
+	return &gobs.Product{
 		ProductID: resp.ProductID,
 		Status:    resp.Status,
 		Price:     resp.Price.Decimal,
@@ -516,7 +949,6 @@ func (ex *Exchange) GetProduct(ctx context.Context, productID string) (*gobs.Pro
 		QuoteDisplaySymbol: resp.QuoteDisplaySymbol,
 		QuoteCurrencyID:    resp.QuoteCurrencyID,
 	}
-	return product, nil
 }
 
 // SyncCandles fetches `ONE_MINUTE` candles from coinbase between the `begin`
@@ -553,6 +985,30 @@ func (ex *Exchange) SyncCandles(ctx context.Context, productID string, begin, en
 	return nil
 }
 
+// BackfillCandles refetches the minute candles for the missing time ranges
+// from coinbase and saves them to the datastore. Only the candles inside the
+// gaps are saved.
+func (ex *Exchange) BackfillCandles(ctx context.Context, productID string, gaps []*CandleGap) error {
+	for _, gap := range gaps {
+		for from := gap.Begin; from.Before(gap.End); from = from.Add(300 * time.Minute) {
+			candles, err := ex.getRawCandles(ctx, productID, from)
+			if err != nil {
+				return fmt.Errorf("could not fetch candles from coinbase: %w", err)
+			}
+			candles = slices.DeleteFunc(candles, func(c *internal.Candle) bool {
+				return c.Start < gap.Begin.Unix() || c.Start >= gap.End.Unix()
+			})
+			if len(candles) == 0 {
+				continue
+			}
+			if err := ex.datastore.saveCandles(ctx, productID, candles); err != nil {
+				return fmt.Errorf("could not save candles to datastore: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
 // getRawCandles fetches `ONE_MINUTE` candles from coinbase starting at `from`
 // timestamp. Returns maximum of 300 candles.
 func (ex *Exchange) getRawCandles(ctx context.Context, productID string, from time.Time) ([]*internal.Candle, error) {
@@ -0,0 +1,614 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/coinbase/coinbasetest"
+	"github.com/bvk/tradebot/coinbase/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvkgo/kv/kvmemdb"
+	"github.com/shopspring/decimal"
+)
+
+func newTestOptions(srv *coinbasetest.Server) *Options {
+	return &Options{
+		RestHostname:         srv.Hostname(),
+		WebsocketHostname:    srv.Hostname(),
+		RestScheme:           "http",
+		WebsocketScheme:      "ws",
+		ServerTimeURL:        srv.ServerTimeURL(),
+		FetchCandlesInterval: -1,
+		WatchProductIDs:      []string{"BCH-USD"},
+	}
+}
+
+func newTestExchange(ctx context.Context, t *testing.T, srv *coinbasetest.Server, key, secret string) *Exchange {
+	return newTestExchangeWithOptions(ctx, t, srv, key, secret, newTestOptions(srv))
+}
+
+func newTestExchangeWithOptions(ctx context.Context, t *testing.T, srv *coinbasetest.Server, key, secret string, opts *Options) *Exchange {
+	ex, err := New(ctx, kvmemdb.New(), key, secret, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ex
+}
+
+func waitForStatus(t *testing.T, ch <-chan *exchange.Order, id exchange.OrderID, status string) *exchange.Order {
+	timeoutCh := time.After(5 * time.Second)
+	for {
+		select {
+		case order := <-ch:
+			if order.OrderID == id && order.Status == status {
+				return order
+			}
+		case <-timeoutCh:
+			t.Fatalf("timed out waiting for order %s to reach %s status", id, status)
+		}
+	}
+}
+
+func TestFakeServer(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+	srv.SetBalance("USD", decimal.NewFromInt(10000))
+
+	ex := newTestExchange(ctx, t, srv, "test-key", "test-secret")
+	defer ex.Close()
+
+	p, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	orderCh, orderStop := p.OrderUpdatesCh()
+	defer orderStop()
+
+	tickerCh, tickerStop := p.TickerCh()
+	defer tickerStop()
+
+	select {
+	case ticker := <-tickerCh:
+		if !ticker.Price.Equal(decimal.NewFromInt(100)) {
+			t.Fatalf("want ticker price 100, got %s", ticker.Price)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the ticker snapshot")
+	}
+
+	size := decimal.NewFromInt(1)
+	buyID, err := p.LimitBuy(ctx, "buy-1", size, decimal.NewFromInt(90), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Tick("BCH-USD", decimal.NewFromInt(89)); err != nil {
+		t.Fatal(err)
+	}
+	if order := waitForStatus(t, orderCh, buyID, "FILLED"); !order.FilledSize.Equal(size) {
+		t.Fatalf("want filled size %s, got %s", size, order.FilledSize)
+	}
+
+	sellID, err := p.LimitSell(ctx, "sell-1", size, decimal.NewFromInt(120), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Cancel(ctx, sellID); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, orderCh, sellID, "CANCELLED")
+
+	postOnly := &exchange.LimitOptions{PostOnly: true}
+	if _, err := p.LimitSell(ctx, "sell-2", size, decimal.NewFromInt(80), postOnly); !errors.Is(err, exchange.ErrPostOnlyRejected) {
+		t.Fatalf("want ErrPostOnlyRejected, got %v", err)
+	}
+
+	marketID, err := p.MarketBuy(ctx, "buy-2", size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order, err := p.Get(ctx, marketID); err != nil || order.Status != "FILLED" {
+		t.Fatalf("want market order to be filled, got %v (err %v)", order, err)
+	}
+
+	fees, err := ex.FeeSchedule(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := decimal.RequireFromString("0.4"); !fees.MakerFeePct.Equal(want) {
+		t.Fatalf("want maker fee pct %s, got %s", want, fees.MakerFeePct)
+	}
+
+	accounts, err := ex.Balances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range accounts {
+		if a.CurrencyID == "BCH" && !a.Available.Equal(decimal.NewFromInt(2)) {
+			t.Fatalf("want BCH balance 2, got %s", a.Available)
+		}
+	}
+
+	// Funds for a market sell are converted to the base size at the best bid
+	// price or the last ticker price, which are close to 89.
+	timeoutCh := time.After(5 * time.Second)
+	for last := decimal.Zero; !last.Equal(decimal.NewFromInt(89)); {
+		select {
+		case v := <-tickerCh:
+			last = v.Price
+		case <-timeoutCh:
+			t.Fatalf("timed out waiting for the ticker")
+		}
+	}
+	marketSellID, err := p.MarketSellFunds(ctx, "sell-3", decimal.NewFromInt(89))
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := p.Get(ctx, marketSellID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxSize := decimal.RequireFromString("1.01"); order.Status != "FILLED" || order.FilledSize.LessThan(size) || order.FilledSize.GreaterThan(maxSize) {
+		t.Fatalf("want market sell order of size close to %s to be filled, got %v with size %s", size, order, order.FilledSize)
+	}
+}
+
+func TestFakeServerWithCDPKey(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+	srv.SetBalance("USD", decimal.NewFromInt(10000))
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := &Credentials{
+		Name:       "organizations/test-org/apiKeys/test-key",
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})),
+	}
+
+	apiKey, secret := creds.KeySecret()
+	ex := newTestExchange(ctx, t, srv, apiKey, secret)
+	defer ex.Close()
+
+	accounts, err := ex.Balances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || !accounts[0].Available.Equal(decimal.NewFromInt(10000)) {
+		t.Fatalf("want one USD account with 10000 balance, got %v", accounts)
+	}
+}
+
+func TestWebsocketGapResync(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+
+	ex := newTestExchange(ctx, t, srv, "test-key", "test-secret")
+	defer ex.Close()
+
+	p, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	orderCh, orderStop := p.OrderUpdatesCh()
+	defer orderStop()
+
+	buyID, err := p.LimitBuy(ctx, "buy-1", decimal.NewFromInt(1), decimal.NewFromInt(90), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, orderCh, buyID, "OPEN")
+
+	// Drop the FILLED order update; next heartbeat must reveal the gap and
+	// the order must be resynced through the REST api.
+	srv.DropMessages("user", 1)
+	if err := srv.Tick("BCH-USD", decimal.NewFromInt(89)); err != nil {
+		t.Fatal(err)
+	}
+	srv.Heartbeat()
+	waitForStatus(t, orderCh, buyID, "FILLED")
+
+	if gaps, resyncs := ex.FeedStats(); gaps == 0 || resyncs == 0 {
+		t.Fatalf("want non-zero gaps and resyncs, got %d and %d", gaps, resyncs)
+	}
+}
+
+func TestSharedWebsocket(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+	srv.AddProduct("ETH-USD", decimal.NewFromInt(2000))
+
+	ex := newTestExchange(ctx, t, srv, "test-key", "test-secret")
+	defer ex.Close()
+
+	bch, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bch.Close()
+
+	eth, err := ex.OpenProduct(ctx, "ETH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor := func(desc string, cond func() bool) {
+		timeoutCh := time.After(5 * time.Second)
+		for !cond() {
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-timeoutCh:
+				t.Fatalf("timed out waiting for %s", desc)
+			}
+		}
+	}
+
+	waitFor("ticker subscriptions", func() bool {
+		return srv.NumSubscribers("ticker", "BCH-USD") == 1 && srv.NumSubscribers("ticker", "ETH-USD") == 1
+	})
+	// User channel uses a separate connection from the product channels.
+	if n := srv.NumConnections(); n != 2 {
+		t.Fatalf("want two websocket connections, got %d", n)
+	}
+
+	// Closing a product must unsubscribe it without affecting the others.
+	if err := eth.Close(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("ticker unsubscription", func() bool {
+		return srv.NumSubscribers("ticker", "ETH-USD") == 0
+	})
+	if srv.NumSubscribers("ticker", "BCH-USD") != 1 || srv.NumSubscribers("user", "BCH-USD") != 1 {
+		t.Fatalf("want BCH-USD ticker and user subscriptions to remain")
+	}
+	if n := srv.NumConnections(); n != 2 {
+		t.Fatalf("want two websocket connections, got %d", n)
+	}
+}
+
+func TestThrottleBackoff(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+	srv.SetBalance("USD", decimal.NewFromInt(10000))
+
+	ex := newTestExchange(ctx, t, srv, "test-key", "test-secret")
+	defer ex.Close()
+
+	numThrottled := func() (n int64) {
+		for _, s := range ex.ThrottleStatus() {
+			n += s.NumThrottled
+		}
+		return n
+	}
+
+	// Rejected requests must be retried after the backoff.
+	srv.RejectRequests(2, "0")
+	if _, err := ex.Balances(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := numThrottled(); n < 2 {
+		t.Fatalf("want at least 2 throttled requests, got %d", n)
+	}
+
+	// Requests must fail after RetryCount retries.
+	srv.RejectRequests(10, "")
+	if _, err := ex.Balances(ctx); err == nil {
+		t.Fatalf("want balances to fail after too many 429 responses")
+	}
+	srv.RejectRequests(0, "")
+	if n := numThrottled(); n < 6 {
+		t.Fatalf("want at least 6 throttled requests, got %d", n)
+	}
+}
+
+func TestEditOrder(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+	srv.SetBalance("USD", decimal.NewFromInt(10000))
+
+	ex := newTestExchange(ctx, t, srv, "test-key", "test-secret")
+	defer ex.Close()
+
+	p, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	orderCh, orderStop := p.OrderUpdatesCh()
+	defer orderStop()
+
+	buyID, err := p.LimitBuy(ctx, "buy-1", decimal.NewFromInt(2), decimal.NewFromInt(90), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Edit(ctx, buyID, decimal.NewFromInt(1), decimal.NewFromInt(91)); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Tick("BCH-USD", decimal.RequireFromString("90.5")); err != nil {
+		t.Fatal(err)
+	}
+	if order := waitForStatus(t, orderCh, buyID, "FILLED"); !order.FilledSize.Equal(decimal.NewFromInt(1)) {
+		t.Fatalf("want filled size 1, got %s", order.FilledSize)
+	}
+
+	// Only GTC orders can be edited.
+	gtd := &exchange.LimitOptions{TimeInForce: "GTD", EndTime: time.Now().Add(time.Hour)}
+	sellID, err := p.LimitSell(ctx, "sell-1", decimal.NewFromInt(1), decimal.NewFromInt(120), gtd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Edit(ctx, sellID, decimal.NewFromInt(1), decimal.NewFromInt(110)); !errors.Is(err, exchange.ErrEditRejected) {
+		t.Fatalf("want ErrEditRejected, got %v", err)
+	}
+}
+
+func TestQuoteCurrenciesAndPriceAliases(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+	srv.AddProduct("BCH-USDC", decimal.NewFromInt(100))
+	srv.AddProduct("BCH-EUR", decimal.NewFromInt(90))
+	srv.AddProduct("BCH-BTC", decimal.RequireFromString("0.01"))
+
+	opts := newTestOptions(srv)
+	opts.QuoteCurrencies = []string{"USD", "EUR"}
+	opts.PriceAliases = map[string]string{"BCH-EUR": "BCH-USD"}
+
+	ex := newTestExchangeWithOptions(ctx, t, srv, "test-key", "test-secret", opts)
+	defer ex.Close()
+
+	for _, pid := range []string{"BCH-USD", "BCH-EUR"} {
+		if !slices.Contains(ex.productIDs, pid) {
+			t.Fatalf("want product %s to be supported", pid)
+		}
+	}
+	for _, pid := range []string{"BCH-USDC", "BCH-BTC"} {
+		if slices.Contains(ex.productIDs, pid) {
+			t.Fatalf("want product %s to be ignored", pid)
+		}
+	}
+
+	usd, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer usd.Close()
+
+	eur, err := ex.OpenProduct(ctx, "BCH-EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eur.Close()
+
+	tickerCh, tickerStop := eur.TickerCh()
+	defer tickerStop()
+
+	// Aliased product must receive the tickers from the BCH-USD product.
+	srv.Tick("BCH-USD", decimal.NewFromInt(120))
+	timeoutCh := time.After(5 * time.Second)
+	for {
+		select {
+		case ticker := <-tickerCh:
+			if ticker.Price.Equal(decimal.NewFromInt(120)) {
+				return
+			}
+		case <-timeoutCh:
+			t.Fatalf("timed out waiting for the aliased ticker")
+		}
+	}
+}
+
+func TestDefaultPriceAliases(t *testing.T) {
+	aliases := defaultPriceAliases([]string{"BTC-USD", "BTC-USDC", "ETH-USDC", "ETH-EUR"})
+	if len(aliases) != 1 || aliases["BTC-USDC"] != "BTC-USD" {
+		t.Fatalf("want only BTC-USDC to BTC-USD alias, got %v", aliases)
+	}
+}
+
+func TestWebsocketReplay(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+
+	logDir := t.TempDir()
+	opts := newTestOptions(srv)
+	opts.WebsocketLogDir = logDir
+	ex := newTestExchangeWithOptions(ctx, t, srv, "test-key", "test-secret", opts)
+
+	p, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tickerCh, stopf := p.TickerCh()
+	defer stopf()
+
+	for i := int64(101); i <= 103; i++ {
+		if err := srv.Tick("BCH-USD", decimal.NewFromInt(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	timeoutCh := time.After(5 * time.Second)
+	for last := decimal.Zero; !last.Equal(decimal.NewFromInt(103)); {
+		select {
+		case v := <-tickerCh:
+			last = v.Price
+		case <-timeoutCh:
+			t.Fatalf("timed out waiting for the tickers")
+		}
+	}
+	p.Close()
+	ex.Close()
+
+	// Replay the saved messages into a new exchange on a server without any
+	// price changes.
+	srv2 := coinbasetest.NewServer()
+	defer srv2.Close()
+
+	srv2.AddProduct("BCH-USD", decimal.NewFromInt(100))
+	ex2 := newTestExchange(ctx, t, srv2, "test-key", "test-secret")
+	defer ex2.Close()
+
+	p2, err := ex2.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p2.Close()
+
+	files, err := filepath.Glob(filepath.Join(logDir, "coinbase-websocket-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("want one websocket log file, got %d", len(files))
+	}
+	fp, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	if err := ex2.ReplayMessages(ctx, fp); err != nil {
+		t.Fatal(err)
+	}
+	product := p2.(*Product)
+	product.mu.Lock()
+	last := product.lastTicker
+	product.mu.Unlock()
+	if last == nil || !last.Price.Equal(decimal.NewFromInt(103)) {
+		t.Fatalf("want last ticker price 103 after the replay, got %v", last)
+	}
+}
+
+func TestWebsocketReplayGaps(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+	ex := newTestExchange(ctx, t, srv, "test-key", "test-secret")
+	defer ex.Close()
+
+	// Second connection starts from sequence zero and has a missing message
+	// with sequence number 2 on the user channel.
+	const messages = `{"receive_time":"2024-01-01T00:00:00Z","message":{"channel":"heartbeats","sequence_num":0}}
+{"receive_time":"2024-01-01T00:00:01Z","message":{"channel":"user","sequence_num":1}}
+{"receive_time":"2024-01-01T00:00:02Z","message":{"channel":"heartbeats","sequence_num":0}}
+{"receive_time":"2024-01-01T00:00:03Z","message":{"channel":"user","sequence_num":1}}
+{"receive_time":"2024-01-01T00:00:04Z","message":{"channel":"user","sequence_num":3}}
+`
+	gaps, _ := ex.FeedStats()
+	if err := ex.ReplayMessages(ctx, strings.NewReader(messages)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := ex.FeedStats(); n-gaps != 2 {
+		t.Fatalf("want 2 gaps from the replay, got %d", n-gaps)
+	}
+
+	// Sequence numbers of the interleaved messages from different connections
+	// are verified separately.
+	const interleaved = `{"receive_time":"2024-01-01T00:00:00Z","websocket":1,"message":{"channel":"heartbeats","sequence_num":0}}
+{"receive_time":"2024-01-01T00:00:01Z","websocket":2,"message":{"channel":"user","sequence_num":0}}
+{"receive_time":"2024-01-01T00:00:02Z","websocket":1,"message":{"channel":"heartbeats","sequence_num":1}}
+{"receive_time":"2024-01-01T00:00:03Z","websocket":2,"message":{"channel":"user","sequence_num":1}}
+`
+	gaps, _ = ex.FeedStats()
+	if err := ex.ReplayMessages(ctx, strings.NewReader(interleaved)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := ex.FeedStats(); n != gaps {
+		t.Fatalf("want no gaps from the interleaved replay, got %d", n-gaps)
+	}
+}
+
+func TestLevel2GapResubscribe(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+	ex := newTestExchange(ctx, t, srv, "test-key", "test-secret")
+	defer ex.Close()
+
+	p, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	waitForBook := func() {
+		t.Helper()
+		for timeout := time.After(5 * time.Second); p.BestBidAsk() == nil; {
+			select {
+			case <-timeout:
+				t.Fatalf("timed out waiting for the order book")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	waitForBook()
+
+	// Gaps in the user channel must not affect the order books.
+	ex.handleGap(&internal.Gap{Channels: []string{"heartbeats", "user"}, Expected: 5, Received: 7})
+	if p.BestBidAsk() == nil {
+		t.Fatalf("want order book to be retained after a user channel gap")
+	}
+
+	// Book is cleared on a level2 gap and must be rebuilt from the snapshot
+	// sent for the resubscribe without any price changes.
+	ex.handleGap(&internal.Gap{Channels: []string{"heartbeats", "level2"}, Expected: 5, Received: 7})
+	waitForBook()
+}
@@ -5,6 +5,7 @@ package internal
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -17,12 +18,12 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"path"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/bvk/tradebot/ctxutil"
 	"github.com/bvk/tradebot/exchange"
-	"golang.org/x/time/rate"
 )
 
 type Client struct {
@@ -33,15 +34,31 @@ type Client struct {
 	key    string
 	secret []byte
 
+	// privateKey is non-nil for the CDP API keys, in which case, requests are
+	// authenticated with JWTs instead of the legacy HMAC signatures.
+	privateKey *ecdsa.PrivateKey
+
 	client *http.Client
 
-	limiter *rate.Limiter
+	// publicThrottle, privateThrottle and orderThrottle are the request
+	// budgets for the market data endpoints, the account and order read
+	// endpoints and the order placement endpoints respectively.
+	publicThrottle  *throttle
+	privateThrottle *throttle
+	orderThrottle   *throttle
 
 	// timeAdjustment is positive when local time is found to be ahead of the
 	// server time, in which case, this value must be subtracted from the local
 	// time before the local time can be used as a timestamp in the signature
 	// calculations.
 	timeAdjustment atomic.Int64
+
+	// teeMu serializes the writes to the WebsocketTee option.
+	teeMu sync.Mutex
+
+	// lastWebsocketID is used to assign unique ids to the websockets, so that
+	// their messages can be told apart in the WebsocketTee output.
+	lastWebsocketID atomic.Int64
 }
 
 // New creates a client for coinbase exchange.
@@ -51,7 +68,7 @@ func New(ctx context.Context, key, secret string, opts *Options) (*Client, error
 	}
 	opts.setDefaults()
 
-	adjustment, err := findTimeAdjustment(ctx, opts.MaxFetchTimeLatency)
+	adjustment, err := findTimeAdjustment(ctx, opts.ServerTimeURL, opts.MaxFetchTimeLatency)
 	if err != nil {
 		return nil, err
 	}
@@ -65,15 +82,27 @@ func New(ctx context.Context, key, secret string, opts *Options) (*Client, error
 		return nil, fmt.Errorf("could not create cookiejar: %w", err)
 	}
 
+	var privateKey *ecdsa.PrivateKey
+	if IsPrivateKey(secret) {
+		v, err := parsePrivateKey(secret)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse cdp api key: %w", err)
+		}
+		privateKey = v
+	}
+
 	c := &Client{
-		opts:   *opts,
-		key:    key,
-		secret: []byte(secret),
+		opts:       *opts,
+		key:        key,
+		secret:     []byte(secret),
+		privateKey: privateKey,
 		client: &http.Client{
 			Jar:     jar,
 			Timeout: opts.HttpClientTimeout,
 		},
-		limiter: rate.NewLimiter(25, 1),
+		publicThrottle:  newThrottle("public", opts.PublicRequestRate, opts.RequestBurst),
+		privateThrottle: newThrottle("private", opts.PrivateRequestRate, opts.RequestBurst),
+		orderThrottle:   newThrottle("order", opts.OrderRequestRate, opts.RequestBurst),
 	}
 
 	c.timeAdjustment.Store(int64(adjustment))
@@ -89,30 +118,36 @@ func (c *Client) Close() error {
 
 func (c *Client) goFindTimeAdjustment(ctx context.Context) {
 	for ctxutil.Sleep(ctx, c.opts.SyncTimeInterval); ctx.Err() == nil; ctxutil.Sleep(ctx, c.opts.SyncTimeInterval) {
-		if diff, err := findTimeAdjustment(ctx, c.opts.MaxFetchTimeLatency); err == nil && diff != 0 {
+		if diff, err := findTimeAdjustment(ctx, c.opts.ServerTimeURL, c.opts.MaxFetchTimeLatency); err == nil && diff != 0 {
 			log.Printf("local time needs to be adjusted by -%s to match the coinbase server time", diff)
 			c.timeAdjustment.Store(int64(diff))
 		}
 	}
 }
 
-func findTimeAdjustment(ctx context.Context, maxLatency time.Duration) (time.Duration, error) {
+func findTimeAdjustment(ctx context.Context, timeURL string, maxLatency time.Duration) (time.Duration, error) {
 	type ServerTime struct {
 		ISO string `json:"iso"`
 	}
 
 	for ; ctx.Err() == nil; ctxutil.Sleep(ctx, time.Second) {
 		start := time.Now()
-		resp, err := http.Get("https://api.exchange.coinbase.com/time")
+		resp, err := http.Get(timeURL)
 		stop := time.Now()
+		if err != nil {
+			log.Printf("warning: could not get coinbase server time (will retry): %v", err)
+			continue
+		}
 
 		latency := stop.Sub(start)
 		if latency > maxLatency {
 			log.Printf("warning: get coinbase server time took %s > %s (too long; will retry)", latency, maxLatency)
+			resp.Body.Close()
 			continue // retry
 		}
 
 		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
 			return 0, fmt.Errorf("could not ready server time response: %w", err)
 		}
@@ -155,32 +190,104 @@ func (c *Client) sign(message string) string {
 	return sig
 }
 
-func (c *Client) getJSON(ctx context.Context, url *url.URL, result interface{}) error {
-	at := fmt.Sprintf("%d", c.Now().Unix())
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
-	if err != nil {
-		return err
-	}
-	sdata := fmt.Sprintf("%s%s%s%s", at, req.Method, url.Path, "")
-	signature := c.sign(sdata)
+// addHeaders adds the common and authentication headers to the request. CDP
+// API keys use a per-request JWT as the bearer token and legacy API keys use
+// the HMAC signature of the request.
+func (c *Client) addHeaders(req *http.Request, payload []byte) error {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Cache-Control", "no-store")
+
+	if c.privateKey != nil {
+		uri := fmt.Sprintf("%s %s%s", req.Method, req.URL.Host, req.URL.Path)
+		token, err := signJWT(c.key, c.privateKey, uri, c.Now().Time)
+		if err != nil {
+			return err
+		}
+		req.Header.Add("Authorization", "Bearer "+token)
+		return nil
+	}
+
+	at := fmt.Sprintf("%d", c.Now().Unix())
+	sdata := fmt.Sprintf("%s%s%s%s", at, req.Method, req.URL.Path, payload)
 	req.Header.Add("CB-ACCESS-KEY", c.key)
-	req.Header.Add("CB-ACCESS-SIGN", signature)
+	req.Header.Add("CB-ACCESS-SIGN", c.sign(sdata))
 	req.Header.Add("CB-ACCESS-TIMESTAMP", at)
-	if err := c.limiter.Wait(ctx); err != nil {
-		return err
+	return nil
+}
+
+// ThrottleStatus returns the current state of all request budgets.
+func (c *Client) ThrottleStatus() []*ThrottleStatus {
+	return []*ThrottleStatus{
+		c.publicThrottle.status(),
+		c.privateThrottle.status(),
+		c.orderThrottle.status(),
 	}
-	resp, err := c.client.Do(req)
+}
+
+// do sends the request within the throttle budget. Requests rejected with 429
+// status are retried after a backoff up to RetryCount times. Idempotent
+// requests are also retried on network errors and server errors.
+func (c *Client) do(ctx context.Context, t *throttle, method string, url *url.URL, payload []byte, idempotent bool) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url.String(), body)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.addHeaders(req, payload); err != nil {
+			return nil, err
+		}
+		if err := t.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		s := time.Now()
+		resp, err := c.client.Do(req)
+		if d := time.Now().Sub(s); d > c.opts.HttpClientTimeout {
+			log.Printf("warning: %s request took %s which is more than the http client timeout %s", method, d, c.opts.HttpClientTimeout)
+		}
+
+		canRetry := retry < c.opts.RetryCount && ctx.Err() == nil
+		if err != nil {
+			if !idempotent || !canRetry {
+				return nil, err
+			}
+			log.Printf("warning: %s request to %s has failed (retrying): %v", method, url.Path, err)
+			ctxutil.Sleep(ctx, min(minThrottleBackoff<<retry, maxThrottleBackoff))
+			continue
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			resp.Body.Close()
+			d := t.backoff(resp.Header)
+			if !canRetry {
+				return nil, fmt.Errorf("http %s returned 429 - too many requests after %d retries", method, retry)
+			}
+			log.Printf("warning: %s request returned with status code 429 - too many requests (retrying after %s in %s budget)", method, d, t.name)
+			continue
+		}
+		t.reset()
+
+		if resp.StatusCode >= http.StatusInternalServerError && idempotent && canRetry {
+			resp.Body.Close()
+			log.Printf("warning: %s request to %s returned %d (retrying)", method, url.Path, resp.StatusCode)
+			ctxutil.Sleep(ctx, min(minThrottleBackoff<<retry, maxThrottleBackoff))
+			continue
+		}
+		return resp, nil
+	}
+}
+
+func (c *Client) getJSON(ctx context.Context, t *throttle, url *url.URL, result interface{}) error {
+	resp, err := c.do(ctx, t, http.MethodGet, url, nil, true /* idempotent */)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusTooManyRequests {
-			log.Printf("warning: get request returned with status code 429 - too many requests (retrying)")
-			return c.getJSON(ctx, url, result)
-		}
 		slog.Error("http GET is unsuccessful", "status", resp.StatusCode, "url", url.String())
 		return fmt.Errorf("http GET returned %d", resp.StatusCode)
 	}
@@ -200,40 +307,17 @@ func (c *Client) getJSON(ctx context.Context, url *url.URL, result interface{})
 	return nil
 }
 
-func (c *Client) postJSON(ctx context.Context, url *url.URL, request, resultPtr interface{}) error {
+func (c *Client) postJSON(ctx context.Context, t *throttle, url *url.URL, request, resultPtr interface{}) error {
 	payload, err := json.Marshal(request)
 	if err != nil {
 		return err
 	}
-	at := fmt.Sprintf("%d", c.Now().Unix())
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url.String(), bytes.NewReader(payload))
-	if err != nil {
-		return err
-	}
-	sdata := fmt.Sprintf("%s%s%s%s", at, req.Method, url.Path, payload)
-	signature := c.sign(sdata)
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Cache-Control", "no-store")
-	req.Header.Add("CB-ACCESS-KEY", c.key)
-	req.Header.Add("CB-ACCESS-SIGN", signature)
-	req.Header.Add("CB-ACCESS-TIMESTAMP", at)
-	if err := c.limiter.Wait(ctx); err != nil {
-		return err
-	}
-	s := time.Now()
-	resp, err := c.client.Do(req)
-	if d := time.Now().Sub(s); d > c.opts.HttpClientTimeout {
-		log.Printf("warning: post request took %s which is more than the http client timeout %s", d, c.opts.HttpClientTimeout)
-	}
+	resp, err := c.do(ctx, t, http.MethodPost, url, payload, false /* idempotent */)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusTooManyRequests {
-			log.Printf("warning: post request returned with status code 429 - too many requests (retrying)")
-			return c.postJSON(ctx, url, request, resultPtr)
-		}
 		slog.Error("http POST is unsuccessful", "status", resp.StatusCode)
 		return fmt.Errorf("http POST returned %d", resp.StatusCode)
 	}
@@ -258,24 +342,7 @@ func (c *Client) Do(ctx context.Context, method string, url *url.URL, payload in
 	if err != nil {
 		return nil, err
 	}
-
-	at := fmt.Sprintf("%d", c.Now().Unix())
-	req, err := http.NewRequestWithContext(ctx, method, url.String(), bytes.NewReader(data))
-	if err != nil {
-		return nil, err
-	}
-	sdata := fmt.Sprintf("%s%s%s%s", at, req.Method, url.Path, data)
-	signature := c.sign(sdata)
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Cache-Control", "no-store")
-	req.Header.Add("CB-ACCESS-KEY", c.key)
-	req.Header.Add("CB-ACCESS-SIGN", signature)
-	req.Header.Add("CB-ACCESS-TIMESTAMP", at)
-
-	if err := c.limiter.Wait(ctx); err != nil {
-		return nil, err
-	}
-	return c.client.Do(req)
+	return c.do(ctx, c.privateThrottle, method, url, data, method == http.MethodGet)
 }
 
 func (c *Client) Go(f func(context.Context)) {
@@ -288,12 +355,12 @@ func (c *Client) AfterDurationFunc(d time.Duration, f func(context.Context)) {
 
 func (c *Client) GetOrder(ctx context.Context, orderID string) (*GetOrderResponse, error) {
 	url := &url.URL{
-		Scheme: "https",
+		Scheme: c.opts.RestScheme,
 		Host:   c.opts.RestHostname,
 		Path:   "/api/v3/brokerage/orders/historical/" + orderID,
 	}
 	resp := new(GetOrderResponse)
-	if err := c.getJSON(ctx, url, resp); err != nil {
+	if err := c.getJSON(ctx, c.privateThrottle, url, resp); err != nil {
 		return nil, err
 	}
 	return resp, nil
@@ -301,12 +368,25 @@ func (c *Client) GetOrder(ctx context.Context, orderID string) (*GetOrderRespons
 
 func (c *Client) GetAccount(ctx context.Context, uuid string) (*GetAccountResponse, error) {
 	url := &url.URL{
-		Scheme: "https",
+		Scheme: c.opts.RestScheme,
 		Host:   c.opts.RestHostname,
 		Path:   "/api/v3/brokerage/accounts/" + uuid,
 	}
 	resp := new(GetAccountResponse)
-	if err := c.getJSON(ctx, url, resp); err != nil {
+	if err := c.getJSON(ctx, c.privateThrottle, url, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) GetTransactionSummary(ctx context.Context) (*GetTransactionSummaryResponse, error) {
+	url := &url.URL{
+		Scheme: c.opts.RestScheme,
+		Host:   c.opts.RestHostname,
+		Path:   "/api/v3/brokerage/transaction_summary",
+	}
+	resp := new(GetTransactionSummaryResponse)
+	if err := c.getJSON(ctx, c.privateThrottle, url, resp); err != nil {
 		return nil, err
 	}
 	return resp, nil
@@ -314,13 +394,13 @@ func (c *Client) GetAccount(ctx context.Context, uuid string) (*GetAccountRespon
 
 func (c *Client) ListAccounts(ctx context.Context, values url.Values) (_ *ListAccountsResponse, cont url.Values, _ error) {
 	url := &url.URL{
-		Scheme:   "https",
+		Scheme:   c.opts.RestScheme,
 		Host:     c.opts.RestHostname,
 		Path:     "/api/v3/brokerage/accounts",
 		RawQuery: values.Encode(),
 	}
 	resp := new(ListAccountsResponse)
-	if err := c.getJSON(ctx, url, resp); err != nil {
+	if err := c.getJSON(ctx, c.privateThrottle, url, resp); err != nil {
 		return nil, nil, err
 	}
 	if len(resp.Cursor) > 0 {
@@ -332,13 +412,13 @@ func (c *Client) ListAccounts(ctx context.Context, values url.Values) (_ *ListAc
 
 func (c *Client) ListFills(ctx context.Context, values url.Values) (_ *ListFillsResponse, cont url.Values, _ error) {
 	url := &url.URL{
-		Scheme:   "https",
+		Scheme:   c.opts.RestScheme,
 		Host:     c.opts.RestHostname,
 		Path:     "/api/v3/brokerage/orders/historical/fills",
 		RawQuery: values.Encode(),
 	}
 	resp := new(ListFillsResponse)
-	if err := c.getJSON(ctx, url, resp); err != nil {
+	if err := c.getJSON(ctx, c.privateThrottle, url, resp); err != nil {
 		return nil, nil, err
 	}
 	if len(resp.Cursor) > 0 {
@@ -350,13 +430,13 @@ func (c *Client) ListFills(ctx context.Context, values url.Values) (_ *ListFills
 
 func (c *Client) ListOrders(ctx context.Context, values url.Values) (_ *ListOrdersResponse, cont url.Values, _ error) {
 	url := &url.URL{
-		Scheme:   "https",
+		Scheme:   c.opts.RestScheme,
 		Host:     c.opts.RestHostname,
 		Path:     "/api/v3/brokerage/orders/historical/batch",
 		RawQuery: values.Encode(),
 	}
 	resp := new(ListOrdersResponse)
-	if err := c.getJSON(ctx, url, resp); err != nil {
+	if err := c.getJSON(ctx, c.privateThrottle, url, resp); err != nil {
 		return nil, nil, err
 	}
 	if len(resp.Cursor) > 0 {
@@ -368,29 +448,48 @@ func (c *Client) ListOrders(ctx context.Context, values url.Values) (_ *ListOrde
 
 func (c *Client) GetProduct(ctx context.Context, productID string) (*GetProductResponse, error) {
 	url := &url.URL{
-		Scheme: "https",
+		Scheme: c.opts.RestScheme,
 		Host:   c.opts.RestHostname,
 		Path:   path.Join("/api/v3/brokerage/products/", productID),
 	}
 	resp := new(GetProductResponse)
-	if err := c.getJSON(ctx, url, resp); err != nil {
+	if err := c.getJSON(ctx, c.publicThrottle, url, resp); err != nil {
 		return nil, fmt.Errorf("could not http-get product %q: %w", productID, err)
 	}
 	return resp, nil
 }
 
+func (c *Client) GetBestBidAsk(ctx context.Context, productIDs []string) (*GetBestBidAskResponse, error) {
+	values := make(url.Values)
+	for _, pid := range productIDs {
+		values.Add("product_ids", pid)
+	}
+
+	url := &url.URL{
+		Scheme:   c.opts.RestScheme,
+		Host:     c.opts.RestHostname,
+		Path:     "/api/v3/brokerage/best_bid_ask",
+		RawQuery: values.Encode(),
+	}
+	resp := new(GetBestBidAskResponse)
+	if err := c.getJSON(ctx, c.privateThrottle, url, resp); err != nil {
+		return nil, fmt.Errorf("could not http-get best bid-ask for %v: %w", productIDs, err)
+	}
+	return resp, nil
+}
+
 func (c *Client) ListProducts(ctx context.Context, productType string) (*ListProductsResponse, error) {
 	values := make(url.Values)
 	values.Set("product_type", productType)
 
 	url := &url.URL{
-		Scheme:   "https",
+		Scheme:   c.opts.RestScheme,
 		Host:     c.opts.RestHostname,
 		Path:     "/api/v3/brokerage/products",
 		RawQuery: values.Encode(),
 	}
 	resp := new(ListProductsResponse)
-	if err := c.getJSON(ctx, url, resp); err != nil {
+	if err := c.getJSON(ctx, c.publicThrottle, url, resp); err != nil {
 		return nil, err
 	}
 	return resp, nil
@@ -398,12 +497,12 @@ func (c *Client) ListProducts(ctx context.Context, productType string) (*ListPro
 
 func (c *Client) CreateOrder(ctx context.Context, request *CreateOrderRequest) (*CreateOrderResponse, error) {
 	url := &url.URL{
-		Scheme: "https",
+		Scheme: c.opts.RestScheme,
 		Host:   c.opts.RestHostname,
 		Path:   "/api/v3/brokerage/orders",
 	}
 	resp := new(CreateOrderResponse)
-	if err := c.postJSON(ctx, url, request, resp); err != nil {
+	if err := c.postJSON(ctx, c.orderThrottle, url, request, resp); err != nil {
 		return nil, err
 	}
 	return resp, nil
@@ -411,12 +510,25 @@ func (c *Client) CreateOrder(ctx context.Context, request *CreateOrderRequest) (
 
 func (c *Client) CancelOrder(ctx context.Context, request *CancelOrderRequest) (*CancelOrderResponse, error) {
 	url := &url.URL{
-		Scheme: "https",
+		Scheme: c.opts.RestScheme,
 		Host:   c.opts.RestHostname,
 		Path:   "/api/v3/brokerage/orders/batch_cancel",
 	}
 	resp := new(CancelOrderResponse)
-	if err := c.postJSON(ctx, url, request, resp); err != nil {
+	if err := c.postJSON(ctx, c.orderThrottle, url, request, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) EditOrder(ctx context.Context, request *EditOrderRequest) (*EditOrderResponse, error) {
+	url := &url.URL{
+		Scheme: c.opts.RestScheme,
+		Host:   c.opts.RestHostname,
+		Path:   "/api/v3/brokerage/orders/edit",
+	}
+	resp := new(EditOrderResponse)
+	if err := c.postJSON(ctx, c.orderThrottle, url, request, resp); err != nil {
 		return nil, err
 	}
 	return resp, nil
@@ -424,13 +536,13 @@ func (c *Client) CancelOrder(ctx context.Context, request *CancelOrderRequest) (
 
 func (c *Client) GetProductCandles(ctx context.Context, productID string, values url.Values) (*GetProductCandlesResponse, error) {
 	url := &url.URL{
-		Scheme:   "https",
+		Scheme:   c.opts.RestScheme,
 		Host:     c.opts.RestHostname,
 		Path:     path.Join("/api/v3/brokerage/products/", productID, "/candles"),
 		RawQuery: values.Encode(),
 	}
 	resp := new(GetProductCandlesResponse)
-	if err := c.getJSON(ctx, url, resp); err != nil {
+	if err := c.getJSON(ctx, c.publicThrottle, url, resp); err != nil {
 		return nil, fmt.Errorf("could not http-get product candles %q: %w", productID, err)
 	}
 	return resp, nil
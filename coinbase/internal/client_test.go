@@ -78,7 +78,7 @@ func TestClient(t *testing.T) {
 	}
 
 	products := []string{"DOGE-USDC"}
-	ws := c.GetMessages("heartbeats", products, handler)
+	ws := c.GetMessages("heartbeats", products, handler, nil)
 	ws.Subscribe("user", products)
 	ws.Subscribe("ticker", products)
 
@@ -0,0 +1,106 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// jwtLifetime is the validity duration for the JWTs. Coinbase rejects JWTs
+// that are valid for more than two minutes.
+const jwtLifetime = 2 * time.Minute
+
+// IsPrivateKey returns true if the secret is a PEM encoded private key, which
+// is the case for the Coinbase Developer Platform (CDP) API keys. Legacy API
+// keys use a plain random string as the secret.
+func IsPrivateKey(secret string) bool {
+	return strings.HasPrefix(strings.TrimSpace(secret), "-----BEGIN")
+}
+
+// parsePrivateKey parses the PEM encoded EC private key of a CDP API key.
+// Both SEC1 and PKCS8 encodings are accepted.
+func parsePrivateKey(secret string) (*ecdsa.PrivateKey, error) {
+	// Private keys copied from the JSON files may have escaped newlines.
+	secret = strings.ReplaceAll(strings.TrimSpace(secret), `\n`, "\n")
+
+	block, _ := pem.Decode([]byte(secret))
+	if block == nil {
+		return nil, fmt.Errorf("could not decode pem block from the private key")
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		v, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse private key: %w", err)
+		}
+		k, ok := v.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is not an ecdsa key")
+		}
+		key = k
+	}
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("private key is not on the P-256 curve")
+	}
+	return key, nil
+}
+
+// signJWT returns an ES256 signed JWT for the CDP API key. Parameter uri must
+// be in "METHOD host/path" form for the REST requests and must be empty for
+// the websocket messages.
+func signJWT(keyName string, key *ecdsa.PrivateKey, uri string, now time.Time) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("could not generate jwt nonce: %w", err)
+	}
+
+	header := map[string]any{
+		"alg":   "ES256",
+		"typ":   "JWT",
+		"kid":   keyName,
+		"nonce": hex.EncodeToString(nonce),
+	}
+	claims := map[string]any{
+		"iss": "cdp",
+		"sub": keyName,
+		"nbf": now.Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+	}
+	if len(uri) > 0 {
+		claims["uri"] = uri
+	}
+
+	hdata, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal jwt header: %w", err)
+	}
+	cdata, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal jwt claims: %w", err)
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(hdata) + "." + enc.EncodeToString(cdata)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("could not sign jwt: %w", err)
+	}
+
+	// ES256 signature is the fixed size concatenation of R and S values.
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignJWT(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	if !IsPrivateKey(secret) {
+		t.Fatalf("pem encoded key must be detected as a private key")
+	}
+	if IsPrivateKey("legacy-random-secret") {
+		t.Fatalf("legacy secret must not be detected as a private key")
+	}
+
+	// Keys copied from the CDP key files may have escaped newlines.
+	escaped := strings.ReplaceAll(secret, "\n", `\n`)
+	parsed, err := parsePrivateKey(escaped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Equal(key) {
+		t.Fatalf("parsed private key doesn't match the original key")
+	}
+
+	keyName := "organizations/test-org/apiKeys/test-key"
+	uri := "GET api.coinbase.com/api/v3/brokerage/accounts"
+	now := time.Now()
+	token, err := signJWT(keyName, parsed, uri, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("want 3 jwt parts, got %d", len(parts))
+	}
+	enc := base64.RawURLEncoding
+
+	var header map[string]any
+	hdata, _ := enc.DecodeString(parts[0])
+	if err := json.Unmarshal(hdata, &header); err != nil {
+		t.Fatal(err)
+	}
+	if header["alg"] != "ES256" || header["kid"] != keyName {
+		t.Fatalf("unexpected jwt header %v", header)
+	}
+
+	var claims map[string]any
+	cdata, _ := enc.DecodeString(parts[1])
+	if err := json.Unmarshal(cdata, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims["sub"] != keyName || claims["uri"] != uri {
+		t.Fatalf("unexpected jwt claims %v", claims)
+	}
+	if exp := int64(claims["exp"].(float64)); exp != now.Add(jwtLifetime).Unix() {
+		t.Fatalf("want expiry %d, got %d", now.Add(jwtLifetime).Unix(), exp)
+	}
+
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		t.Fatalf("invalid jwt signature %q (err %v)", parts[2], err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(&key.PublicKey, digest[:], r, s) {
+		t.Fatalf("jwt signature verification has failed")
+	}
+}
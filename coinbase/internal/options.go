@@ -2,11 +2,16 @@
 
 package internal
 
-import "time"
+import (
+	"io"
+	"time"
+)
 
 var (
 	RestHostname      = "api.coinbase.com"
 	WebsocketHostname = "advanced-trade-ws.coinbase.com"
+
+	ServerTimeURL = "https://api.exchange.coinbase.com/time"
 )
 
 type Options struct {
@@ -14,12 +19,25 @@ type Options struct {
 	RestHostname      string
 	WebsocketHostname string
 
+	// URL schemes for the REST and WebSocket service endpoints. Defaults are
+	// "https" and "wss" respectively; tests can use "http" and "ws" to talk
+	// with local servers.
+	RestScheme      string
+	WebsocketScheme string
+
+	// URL to fetch the coinbase server time for time adjustments.
+	ServerTimeURL string
+
 	// Timeout to use for the HTTP requests.
 	HttpClientTimeout time.Duration
 
 	// Timeout interval to create a new websocket session after a failure.
 	WebsocketRetryInterval time.Duration
 
+	// Max number of out of order websocket messages allowed before restarting
+	// the websocket.
+	MaxWebsocketOutOfOrderAllowance int
+
 	// Max limit for time difference between local time and the server times.
 	MaxTimeAdjustment time.Duration
 
@@ -29,6 +47,22 @@ type Options struct {
 	// Periodic timeout interval to recalculate time difference between local
 	// time and the exchange time.
 	SyncTimeInterval time.Duration
+
+	// RetryCount is the max number of retries for the requests rejected with
+	// 429 status and for the failed idempotent requests.
+	RetryCount int
+
+	// Request rates (per second) for the market data endpoints, the account
+	// and order read endpoints and the order placement endpoints. RequestBurst
+	// is the burst size for all of them.
+	PublicRequestRate  float64
+	PrivateRequestRate float64
+	OrderRequestRate   float64
+	RequestBurst       int
+
+	// WebsocketTee, when non-nil, receives every raw websocket message as a
+	// RawMessage json object in a single line. Writes are serialized.
+	WebsocketTee io.Writer
 }
 
 func (v *Options) setDefaults() {
@@ -38,12 +72,24 @@ func (v *Options) setDefaults() {
 	if v.WebsocketHostname == "" {
 		v.WebsocketHostname = WebsocketHostname
 	}
+	if v.RestScheme == "" {
+		v.RestScheme = "https"
+	}
+	if v.WebsocketScheme == "" {
+		v.WebsocketScheme = "wss"
+	}
+	if v.ServerTimeURL == "" {
+		v.ServerTimeURL = ServerTimeURL
+	}
 	if v.HttpClientTimeout == 0 {
 		v.HttpClientTimeout = 5 * time.Second
 	}
 	if v.WebsocketRetryInterval == 0 {
 		v.WebsocketRetryInterval = time.Second
 	}
+	if v.MaxWebsocketOutOfOrderAllowance == 0 {
+		v.MaxWebsocketOutOfOrderAllowance = 10
+	}
 	if v.MaxTimeAdjustment == 0 {
 		v.MaxTimeAdjustment = time.Minute
 	}
@@ -53,4 +99,19 @@ func (v *Options) setDefaults() {
 	if v.SyncTimeInterval == 0 {
 		v.SyncTimeInterval = 30 * time.Minute
 	}
+	if v.RetryCount == 0 {
+		v.RetryCount = 3
+	}
+	if v.PublicRequestRate == 0 {
+		v.PublicRequestRate = 10
+	}
+	if v.PrivateRequestRate == 0 {
+		v.PrivateRequestRate = 15
+	}
+	if v.OrderRequestRate == 0 {
+		v.OrderRequestRate = 15
+	}
+	if v.RequestBurst == 0 {
+		v.RequestBurst = 5
+	}
 }
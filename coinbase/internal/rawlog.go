@@ -0,0 +1,65 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// RawMessage is a websocket message as received from the server along with
+// it's receive time. Raw messages are saved as one json object per line when
+// the WebsocketTee option is set.
+type RawMessage struct {
+	ReceiveTime time.Time       `json:"receive_time"`
+	Message     json.RawMessage `json:"message"`
+
+	// Websocket identifies the connection that received the message, because
+	// message sequence numbers are unique only within a connection. It is
+	// zero for the messages saved before multiple connections were used.
+	Websocket int64 `json:"websocket,omitempty"`
+}
+
+func writeRawMessage(w io.Writer, websocket int64, at time.Time, data []byte) error {
+	js, err := json.Marshal(&RawMessage{ReceiveTime: at, Message: json.RawMessage(data), Websocket: websocket})
+	if err != nil {
+		return fmt.Errorf("could not json-marshal raw message: %w", err)
+	}
+	js = append(js, '\n')
+	if _, err := w.Write(js); err != nil {
+		return fmt.Errorf("could not write raw message: %w", err)
+	}
+	return nil
+}
+
+// ReadRawMessages parses the raw messages saved through the WebsocketTee
+// option and invokes the callback for every message in the same order.
+func ReadRawMessages(r io.Reader, fn func(*RawMessage, *Message) error) error {
+	br := bufio.NewReader(r)
+	for lineno := 1; ; lineno++ {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			raw := new(RawMessage)
+			if err := json.Unmarshal(line, raw); err != nil {
+				return fmt.Errorf("could not parse raw message at line %d: %w", lineno, err)
+			}
+			msg := new(Message)
+			if err := json.Unmarshal(raw.Message, msg); err != nil {
+				return fmt.Errorf("could not parse websocket message at line %d: %w", lineno, err)
+			}
+			if err := fn(raw, msg); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("could not read raw messages: %w", err)
+		}
+	}
+}
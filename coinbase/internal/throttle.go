@@ -0,0 +1,124 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package internal
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bvk/tradebot/ctxutil"
+	"github.com/bvk/tradebot/exchange"
+	"golang.org/x/time/rate"
+)
+
+const (
+	minThrottleBackoff = 250 * time.Millisecond
+	maxThrottleBackoff = 30 * time.Second
+)
+
+// throttle is a request budget for a class of endpoints. Requests wait for
+// the rate limiter tokens and also for the backoff period after the server
+// rejects requests with 429 status.
+type throttle struct {
+	name string
+
+	limiter *rate.Limiter
+
+	mu sync.Mutex
+
+	// backoffUntil is the time before which no requests are sent.
+	backoffUntil time.Time
+
+	// numBackoffs is the number of consecutive 429 responses, which is used to
+	// compute the exponential backoff duration.
+	numBackoffs int
+
+	numRequests  int64
+	numThrottled int64
+}
+
+// ThrottleStatus describes the current state of a request budget.
+type ThrottleStatus = exchange.ThrottleStatus
+
+func newThrottle(name string, limit float64, burst int) *throttle {
+	return &throttle{
+		name:    name,
+		limiter: rate.NewLimiter(rate.Limit(limit), burst),
+	}
+}
+
+// wait blocks till a request can be sent within the budget.
+func (t *throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	t.numRequests++
+	until := t.backoffUntil
+	t.mu.Unlock()
+
+	if d := time.Until(until); d > 0 {
+		if ctxutil.Sleep(ctx, d); ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+	}
+	return t.limiter.Wait(ctx)
+}
+
+// backoff pauses all requests in the budget for the duration suggested by
+// the Retry-After header or for an exponentially increasing duration when the
+// header is missing.
+func (t *throttle) backoff(header http.Header) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.numThrottled++
+	d, ok := parseRetryAfter(header.Get("Retry-After"))
+	if !ok {
+		d = min(minThrottleBackoff<<t.numBackoffs, maxThrottleBackoff)
+	}
+	t.numBackoffs++
+	t.backoffUntil = time.Now().Add(d)
+	return d
+}
+
+// reset clears the exponential backoff state after a successful request.
+func (t *throttle) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.numBackoffs = 0
+}
+
+func (t *throttle) status() *ThrottleStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := &ThrottleStatus{
+		Name:         t.name,
+		Limit:        float64(t.limiter.Limit()),
+		Burst:        t.limiter.Burst(),
+		Tokens:       t.limiter.Tokens(),
+		NumRequests:  t.numRequests,
+		NumThrottled: t.numThrottled,
+	}
+	if time.Now().Before(t.backoffUntil) {
+		s.BackoffUntil = t.backoffUntil
+	}
+	return s
+}
+
+// parseRetryAfter parses the Retry-After header value, which is either a
+// number of seconds or an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
@@ -28,6 +28,20 @@ type Account struct {
 	Hold             Balance             `json:"hold"`
 }
 
+type FeeTier struct {
+	PricingTier  string               `json:"pricing_tier"`
+	USDFrom      exchange.NullDecimal `json:"usd_from"`
+	USDTo        exchange.NullDecimal `json:"usd_to"`
+	TakerFeeRate exchange.NullDecimal `json:"taker_fee_rate"`
+	MakerFeeRate exchange.NullDecimal `json:"maker_fee_rate"`
+}
+
+type GetTransactionSummaryResponse struct {
+	TotalVolume float64 `json:"total_volume"`
+	TotalFees   float64 `json:"total_fees"`
+	FeeTier     FeeTier `json:"fee_tier"`
+}
+
 type GetAccountResponse struct {
 	Account Account `json:"account"`
 }
@@ -78,8 +92,8 @@ type GetOrderResponse struct {
 }
 
 type MarketMarketIOC struct {
-	QuoteSize exchange.NullDecimal `json:"quote_size"`
-	BaseSize  exchange.NullDecimal `json:"base_size"`
+	QuoteSize *exchange.NullDecimal `json:"quote_size,omitempty"`
+	BaseSize  *exchange.NullDecimal `json:"base_size,omitempty"`
 }
 
 type LimitLimitGTC struct {
@@ -95,6 +109,16 @@ type LimitLimitGTD struct {
 	EndTime    string               `json:"end_time"`
 }
 
+type LimitLimitFOK struct {
+	BaseSize   exchange.NullDecimal `json:"base_size"`
+	LimitPrice exchange.NullDecimal `json:"limit_price"`
+}
+
+type SorLimitIOC struct {
+	BaseSize   exchange.NullDecimal `json:"base_size"`
+	LimitPrice exchange.NullDecimal `json:"limit_price"`
+}
+
 type StopLimitStopLimitGTC struct {
 	BaseSize      exchange.NullDecimal `json:"base_size"`
 	LimitPrice    exchange.NullDecimal `json:"limit_price"`
@@ -114,6 +138,8 @@ type OrderConfig struct {
 	MarketIOC    *MarketMarketIOC       `json:"market_market_ioc"`
 	LimitGTC     *LimitLimitGTC         `json:"limit_limit_gtc"`
 	LimitGTD     *LimitLimitGTD         `json:"limit_limit_gtd"`
+	LimitFOK     *LimitLimitFOK         `json:"limit_limit_fok,omitempty"`
+	LimitIOC     *SorLimitIOC           `json:"sor_limit_ioc,omitempty"`
 	StopLimitGTD *StopLimitStopLimitGTD `json:"stop_limit_stop_limit_gtd"`
 	StopLimitGTC *StopLimitStopLimitGTC `json:"stop_limit_stop_limit_gtc"`
 }
@@ -226,6 +252,22 @@ type GetProductResponse struct {
 	MidMarketPrice           string          `json:"mid_market_price"`
 }
 
+type PriceLevel struct {
+	Price exchange.NullDecimal `json:"price"`
+	Size  exchange.NullDecimal `json:"size"`
+}
+
+type PriceBook struct {
+	ProductID string              `json:"product_id"`
+	Bids      []*PriceLevel       `json:"bids"`
+	Asks      []*PriceLevel       `json:"asks"`
+	Time      exchange.RemoteTime `json:"time"`
+}
+
+type GetBestBidAskResponse struct {
+	PriceBooks []*PriceBook `json:"pricebooks"`
+}
+
 type Candle struct {
 	Start  int64                `json:"start,string"`
 	Low    exchange.NullDecimal `json:"low"`
@@ -281,3 +323,19 @@ type CancelOrderResultResponse struct {
 	FailureReason string `json:"failure_reason"`
 	OrderID       string `json:"order_id"`
 }
+
+type EditOrderRequest struct {
+	OrderID string               `json:"order_id"`
+	Price   exchange.NullDecimal `json:"price"`
+	Size    exchange.NullDecimal `json:"size"`
+}
+
+type EditOrderResponse struct {
+	Success bool                `json:"success"`
+	Errors  []EditOrderErrorMsg `json:"errors"`
+}
+
+type EditOrderErrorMsg struct {
+	EditFailureReason    string `json:"edit_failure_reason"`
+	PreviewFailureReason string `json:"preview_failure_reason"`
+}
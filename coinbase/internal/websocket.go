@@ -5,6 +5,7 @@ package internal
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
@@ -12,7 +13,6 @@ import (
 	"sort"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/bvk/tradebot/ctxutil"
@@ -32,6 +32,10 @@ type Message struct {
 	Timestamp  string   `json:"timestamp"`
 	Signature  string   `json:"signature"`
 
+	// JWT holds the authentication token for the CDP API keys, in which case,
+	// APIKey, Timestamp and Signature fields are unused.
+	JWT string `json:"jwt,omitempty"`
+
 	Sequence int64 `json:"sequence_num,number"`
 
 	ClientID string  `json:"client_id"`
@@ -80,33 +84,56 @@ type OrderEvent struct {
 	AvgPrice           exchange.NullDecimal `json:"avg_price"`
 }
 
+// Websocket is a single websocket connection shared by all channel
+// subscriptions. Subscriptions can be added or removed at any time and are
+// applied to the connection immediately. They are restored automatically when
+// the connection is recreated after a failure.
 type Websocket struct {
 	client *Client
 
+	id int64
+
 	mu sync.Mutex
 
-	dirty           atomic.Bool
 	chanProductsMap map[string][]string
+
+	// resubscribes holds the channels that must be unsubscribed and subscribed
+	// again on the current connection.
+	resubscribes []string
+
+	// updateCh is signaled when the subscriptions are changed.
+	updateCh chan struct{}
 }
 
 func (c *Client) newWebsocket() (_ *Websocket) {
 	return &Websocket{
 		client:          c,
+		id:              c.lastWebsocketID.Add(1),
 		chanProductsMap: make(map[string][]string),
+		updateCh:        make(chan struct{}, 1),
+	}
+}
+
+// notify signals the connection to apply the subscription changes.
+func (w *Websocket) notify() {
+	select {
+	case w.updateCh <- struct{}{}:
+	default:
 	}
 }
 
+// Close removes all subscriptions, which also closes the connection.
 func (w *Websocket) Close() {
 	w.mu.Lock()
 	w.chanProductsMap = make(map[string][]string)
-	w.dirty.Store(true)
 	w.mu.Unlock()
+	w.notify()
 	// TODO: Wait for GetMessages to return
 }
 
 func (w *Websocket) dial(ctx context.Context) (*websocket.Conn, error) {
 	var dialer websocket.Dialer
-	conn, _, err := dialer.DialContext(ctx, "wss://"+w.client.opts.WebsocketHostname, nil)
+	conn, _, err := dialer.DialContext(ctx, w.client.opts.WebsocketScheme+"://"+w.client.opts.WebsocketHostname, nil)
 	if err != nil {
 		slog.ErrorContext(ctx, "could not dial to websocket feed", "error", err)
 		return nil, err
@@ -130,7 +157,7 @@ func (w *Websocket) Subscribe(channel string, products []string) {
 	if dirty {
 		sort.Strings(nproducts)
 		w.chanProductsMap[channel] = nproducts
-		w.dirty.Store(true)
+		w.notify()
 	}
 }
 
@@ -150,9 +177,25 @@ func (w *Websocket) Unsubscribe(channel string, products []string) {
 	}
 
 	if dirty {
-		sort.Strings(nproducts)
-		w.chanProductsMap[channel] = nproducts
-		w.dirty.Store(true)
+		if len(nproducts) == 0 {
+			delete(w.chanProductsMap, channel)
+		} else {
+			w.chanProductsMap[channel] = nproducts
+		}
+		w.notify()
+	}
+}
+
+// Resubscribe unsubscribes and subscribes again all products of the channel
+// on the current connection, so that the server sends a fresh snapshot for
+// the channel.
+func (w *Websocket) Resubscribe(channel string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.chanProductsMap[channel]; ok && !slices.Contains(w.resubscribes, channel) {
+		w.resubscribes = append(w.resubscribes, channel)
+		w.notify()
 	}
 }
 
@@ -162,7 +205,8 @@ func (w *Websocket) diff(oldMap map[string][]string) (newMap, subMap, unsubMap m
 	for k, v := range w.chanProductsMap {
 		newMap[k] = slices.Clone(v)
 	}
-	w.dirty.Store(false)
+	resubscribes := w.resubscribes
+	w.resubscribes = nil
 	w.mu.Unlock()
 
 	// subSlice returns `a-b` as a slice, i.e., items present in `a`, but not in `b`.
@@ -202,10 +246,21 @@ func (w *Websocket) diff(oldMap map[string][]string) (newMap, subMap, unsubMap m
 		}
 	}
 
+	// Products that remain subscribed are unsubscribed and subscribed again
+	// for the resubscribe requests.
+	for _, k := range resubscribes {
+		for _, p := range oldMap[k] {
+			if slices.Contains(newMap[k], p) && !slices.Contains(unsubMap[k], p) {
+				unsubMap[k] = append(unsubMap[k], p)
+				subMap[k] = append(subMap[k], p)
+			}
+		}
+	}
+
 	return newMap, subMap, unsubMap
 }
 
-func readMessage(ctx context.Context, conn *websocket.Conn) (*Message, error) {
+func (w *Websocket) readMessage(ctx context.Context, conn *websocket.Conn) (*Message, error) {
 	stopc := make(chan struct{})
 	stop := context.AfterFunc(ctx, func() {
 		conn.SetReadDeadline(time.Now())
@@ -224,6 +279,15 @@ func readMessage(ctx context.Context, conn *websocket.Conn) (*Message, error) {
 		return nil, err
 	}
 
+	if tee := w.client.opts.WebsocketTee; tee != nil {
+		w.client.teeMu.Lock()
+		err := writeRawMessage(tee, w.id, time.Now(), msg)
+		w.client.teeMu.Unlock()
+		if err != nil {
+			log.Printf("could not tee the websocket message (ignored): %v", err)
+		}
+	}
+
 	m := new(Message)
 	if err := json.Unmarshal(msg, m); err != nil {
 		return nil, err
@@ -240,39 +304,104 @@ func readMessage(ctx context.Context, conn *websocket.Conn) (*Message, error) {
 	return m, nil
 }
 
-func (c *Client) subscribeMsg(channel string, products []string) *Message {
-	submsg := &Message{
-		Type:       "subscribe",
-		ProductIDs: products,
-		Channel:    channel,
-		APIKey:     c.key,
-		Timestamp:  fmt.Sprintf("%d", c.Now().Unix()),
-		Signature:  "",
-	}
-	subdata := fmt.Sprintf("%s%s%s", submsg.Timestamp, submsg.Channel, strings.Join(submsg.ProductIDs, ","))
-	signature := c.sign(subdata)
-	submsg.Signature = signature
-	return submsg
+func (c *Client) subscribeMsg(channel string, products []string) (*Message, error) {
+	return c.signedMsg("subscribe", channel, products)
+}
+
+func (c *Client) unsubscribeMsg(channel string, products []string) (*Message, error) {
+	return c.signedMsg("unsubscribe", channel, products)
 }
 
-func (c *Client) unsubscribeMsg(channel string, products []string) *Message {
-	unsubmsg := &Message{
-		Type:       "unsubscribe",
+// signedMsg returns a subscribe or unsubscribe message authenticated with a
+// JWT for the CDP API keys or with an HMAC signature for the legacy API keys.
+func (c *Client) signedMsg(msgType, channel string, products []string) (*Message, error) {
+	msg := &Message{
+		Type:       msgType,
 		ProductIDs: products,
 		Channel:    channel,
-		APIKey:     c.key,
-		Timestamp:  fmt.Sprintf("%d", c.Now().Unix()),
-		Signature:  "",
 	}
-	unsubdata := fmt.Sprintf("%s%s%s", unsubmsg.Timestamp, unsubmsg.Channel, strings.Join(unsubmsg.ProductIDs, ","))
-	signature := c.sign(unsubdata)
-	unsubmsg.Signature = signature
-	return unsubmsg
+	if c.privateKey != nil {
+		token, err := signJWT(c.key, c.privateKey, "", c.Now().Time)
+		if err != nil {
+			return nil, err
+		}
+		msg.JWT = token
+		return msg, nil
+	}
+	msg.APIKey = c.key
+	msg.Timestamp = fmt.Sprintf("%d", c.Now().Unix())
+	data := fmt.Sprintf("%s%s%s", msg.Timestamp, msg.Channel, strings.Join(msg.ProductIDs, ","))
+	msg.Signature = c.sign(data)
+	return msg, nil
 }
 
 type MessageHandler = func(*Message)
 
-func (c *Client) GetMessages(channel string, products []string, handler MessageHandler) *Websocket {
+// errNoSubscriptions is the cause for closing the connection when all
+// subscriptions are removed.
+var errNoSubscriptions = errors.New("no websocket subscriptions")
+
+// Gap describes the websocket messages that are lost or reordered on a
+// connection. Coinbase assigns consecutive sequence numbers to all messages on
+// a connection, so a mismatch with the expected sequence number indicates
+// that some messages were dropped or reordered.
+type Gap struct {
+	// Channels holds the channels subscribed on the connection.
+	Channels []string
+
+	// Expected and Received hold the expected and the actual sequence numbers
+	// of the message. They are zero when Reconnect is true.
+	Expected, Received int64
+
+	// Reconnect is true when the gap is because of a new connection replacing
+	// a failed connection, in which case, any number of messages may be lost.
+	Reconnect bool
+}
+
+type GapHandler = func(*Gap)
+
+// Sequencer verifies the sequence numbers of the messages received on a
+// single websocket connection.
+type Sequencer struct {
+	lastSequence  int64
+	numOutOfOrder int
+}
+
+func NewSequencer() *Sequencer {
+	return &Sequencer{lastSequence: -1}
+}
+
+// Check returns a non-nil Gap when the message sequence number doesn't follow
+// the previous message. Channels field of the returned Gap is not set.
+func (s *Sequencer) Check(msg *Message) *Gap {
+	defer func() {
+		s.lastSequence = max(s.lastSequence, msg.Sequence)
+	}()
+
+	if s.lastSequence < 0 || msg.Sequence == s.lastSequence+1 {
+		return nil
+	}
+	if msg.Sequence <= s.lastSequence {
+		s.numOutOfOrder++
+	}
+	return &Gap{Expected: s.lastSequence + 1, Received: msg.Sequence}
+}
+
+// NumOutOfOrder returns the number of messages received with a sequence
+// number older than a previous message.
+func (s *Sequencer) NumOutOfOrder() int {
+	return s.numOutOfOrder
+}
+
+// GetMessages subscribes to the channel for the products and invokes the
+// handler for every message received. Optional gapHandler is invoked when
+// messages are lost or reordered on the connection. Connection is restarted
+// when more than the allowed number of out-of-order messages are received.
+//
+// Returned Websocket can be used to subscribe or unsubscribe more channels and
+// products on the same connection. Connection is closed when all
+// subscriptions are removed.
+func (c *Client) GetMessages(channel string, products []string, handler MessageHandler, gapHandler GapHandler) *Websocket {
 	w := c.newWebsocket()
 	w.Subscribe(channel, products)
 
@@ -283,58 +412,137 @@ func (c *Client) GetMessages(channel string, products []string, handler MessageH
 		return
 	}
 
+	reportGap := func(gap *Gap) {
+		if gapHandler != nil {
+			gapHandler(gap)
+		}
+	}
+
+	// update applies the subscription changes to the connection and returns
+	// the current subscriptions.
+	update := func(conn *websocket.Conn, old map[string][]string) (map[string][]string, error) {
+		clone, subs, unsubs := w.diff(old)
+		for ch, ps := range unsubs {
+			msg, err := w.client.unsubscribeMsg(ch, ps)
+			if err != nil {
+				log.Printf("could not create unsubscribe message for channel %s: %v", ch, err)
+				return nil, err
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				log.Printf("could not unsubscribe %v products from channel %s: %v", ps, ch, err)
+				return nil, err
+			}
+			log.Printf("unsubscribed from channel %s for products %v", ch, ps)
+		}
+		for ch, ps := range subs {
+			msg, err := w.client.subscribeMsg(ch, ps)
+			if err != nil {
+				log.Printf("could not create subscribe message for channel %s: %v", ch, err)
+				return nil, err
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				log.Printf("could not subscribe to channel %s for products %v: %v", ch, ps, err)
+				return nil, err
+			}
+			log.Printf("subscribed to channel %s for products %v", ch, ps)
+		}
+		if len(subs) > 0 || len(unsubs) > 0 {
+			log.Printf("websocket is updated to watch channels %v from previous %v", keys(clone), keys(old))
+		}
+		return clone, nil
+	}
+
+	connected := false
 	dispatch := func(ctx context.Context) error {
 		conn, err := w.dial(ctx)
 		if err != nil {
 			log.Printf("could not open new websocket (will retry): %v", err)
 			return err
 		}
-		defer conn.Close()
 
+		ctx, cancel := context.WithCancelCause(ctx)
+		var wg sync.WaitGroup
+		defer func() {
+			cancel(nil)
+			conn.Close()
+			wg.Wait()
+		}()
+
+		var chmu sync.Mutex
 		channels := []string{}
-		chanProductsMap := make(map[string][]string)
+		getChannels := func() []string {
+			chmu.Lock()
+			defer chmu.Unlock()
+			return channels
+		}
 
-		for ctx.Err() == nil {
-			if w.dirty.Load() {
-				clone, subs, unsubs := w.diff(chanProductsMap)
-				for ch, ps := range unsubs {
-					if err := conn.WriteJSON(w.client.unsubscribeMsg(ch, ps)); err != nil {
-						log.Printf("could not unsubscribe %v products from channel %s: %v", ps, ch, err)
-						return err
-					}
-					log.Printf("unsubscribed from channel %s for products %v", ch, ps)
-				}
-				for ch, ps := range subs {
-					if err := conn.WriteJSON(w.client.subscribeMsg(ch, ps)); err != nil {
-						log.Printf("could not subscribe to channel %s for products %v: %v", ch, ps, err)
-						return err
-					}
-					log.Printf("subscribed to channel %s for products %v", ch, ps)
+		reconnect := connected
+		connected = true
+
+		// Subscription changes are written from a separate goroutine so that
+		// they are not delayed till the next message is received.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			chanProductsMap := make(map[string][]string)
+			for ctx.Err() == nil {
+				clone, err := update(conn, chanProductsMap)
+				if err != nil {
+					cancel(err)
+					return
 				}
-				oldChannels := keys(chanProductsMap)
 				chanProductsMap = clone
 				if len(clone) == 0 {
-					break
+					cancel(errNoSubscriptions)
+					return
 				}
+
+				chmu.Lock()
 				channels = keys(clone)
-				log.Printf("websocket is updated to watch channels %v from previous %v", channels, oldChannels)
+				chmu.Unlock()
+
+				if reconnect {
+					reconnect = false
+					reportGap(&Gap{Channels: getChannels(), Reconnect: true})
+				}
+
+				select {
+				case <-ctx.Done():
+				case <-w.updateCh:
+				}
 			}
+		}()
 
-			msg, err := readMessage(ctx, conn)
+		seq := NewSequencer()
+
+		for {
+			msg, err := w.readMessage(ctx, conn)
 			if err != nil {
+				if errors.Is(err, errNoSubscriptions) {
+					return nil
+				}
 				if ctx.Err() == nil {
-					log.Printf("closing the websocket connection to channels %v: %v", channels, err)
+					log.Printf("closing the websocket connection to channels %v: %v", getChannels(), err)
 				}
 				return err
 			}
+
+			if gap := seq.Check(msg); gap != nil {
+				log.Printf("websocket message sequence number %d doesn't match the expected sequence number %d on channels %v", gap.Received, gap.Expected, getChannels())
+				if seq.NumOutOfOrder() > c.opts.MaxWebsocketOutOfOrderAllowance {
+					return fmt.Errorf("too many out of order messages on the websocket")
+				}
+				gap.Channels = getChannels()
+				reportGap(gap)
+			}
+
 			handler(msg)
 		}
-		return context.Cause(ctx)
 	}
 
 	c.Go(func(ctx context.Context) {
 		for ctx.Err() == nil {
-			w.dirty.Store(true)
 			if err := dispatch(ctx); err != nil && ctx.Err() == nil {
 				ctxutil.Sleep(ctx, c.opts.WebsocketRetryInterval)
 				continue
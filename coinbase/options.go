@@ -2,11 +2,17 @@
 
 package coinbase
 
-import "time"
+import (
+	"time"
+
+	"github.com/bvk/tradebot/exchange"
+)
 
 var (
 	RestHostname      = "api.coinbase.com"
 	WebsocketHostname = "advanced-trade-ws.coinbase.com"
+
+	ServerTimeURL = "https://api.exchange.coinbase.com/time"
 )
 
 type Options struct {
@@ -14,6 +20,15 @@ type Options struct {
 	RestHostname      string
 	WebsocketHostname string
 
+	// URL schemes for the REST and WebSocket service endpoints. Defaults are
+	// "https" and "wss" respectively; tests can use "http" and "ws" to talk
+	// with local servers.
+	RestScheme      string
+	WebsocketScheme string
+
+	// URL to fetch the coinbase server time for time adjustments.
+	ServerTimeURL string
+
 	// Timeout to use for the HTTP requests.
 	HttpClientTimeout time.Duration
 
@@ -45,6 +60,58 @@ type Options struct {
 	// List of product ids to fetch and save data in the data store.
 	WatchProductIDs []string
 
+	// QuoteCurrencies holds the quote currencies for the supported products.
+	// Products with other quote currencies are ignored.
+	QuoteCurrencies []string
+
+	// PriceAliases maps a product id to another product id whose ticker and
+	// order book updates are also used for the product. Coinbase publishes
+	// the USDC product updates under the USD products, so by default, every
+	// X-USDC product is mapped to X-USD product when both products exist.
+	PriceAliases map[string]string
+
+	// Max duration without any websocket ticker for a product before it's
+	// ticker feed is considered as stalled, in which case, tickers are
+	// synthesized from the best bid and ask prices polled from the REST api
+	// every PollTickerInterval till the feed recovers. Negative value disables
+	// the stale feed detection.
+	StaleTickerTimeout time.Duration
+	PollTickerInterval time.Duration
+
+	// RecordTickers, when true, saves every ticker of the WatchProductIDs
+	// products in the datastore every RecordTickersInterval, so that they can
+	// be replayed later. Recorded tickers older than TickerRetention are
+	// deleted; negative retention keeps the tickers forever.
+	RecordTickers         bool
+	RecordTickersInterval time.Duration
+	TickerRetention       time.Duration
+
+	// CancelledOrderRetention is the duration after which the cancelled orders
+	// with zero filled size are removed from the datastore. Also, cancelled
+	// orders older than this duration are not fetched on startup. Negative
+	// value keeps the cancelled orders forever.
+	CancelledOrderRetention time.Duration
+
+	// MaxCachedOrders is the max number of completed orders kept in memory
+	// for the client-order-id lookups. Older orders are looked up in the
+	// datastore.
+	MaxCachedOrders int
+
+	// WebsocketLogDir, when non-empty, is the directory where every raw
+	// websocket message is saved into size limited log files, which can be
+	// replayed later with the Exchange.ReplayMessages method.
+	WebsocketLogDir string
+
+	// WebsocketLogMaxFiles and WebsocketLogMaxAge are the retention limits for
+	// the websocket log files. Default max age is 7 days and a negative value
+	// keeps the log files forever. Number of log files is not limited by
+	// default.
+	WebsocketLogMaxFiles int
+	WebsocketLogMaxAge   time.Duration
+
+	// Messenger, when non-nil, is notified about the ticker feed outages.
+	Messenger exchange.Messenger
+
 	subcmdMode bool
 }
 
@@ -55,6 +122,15 @@ func (v *Options) setDefaults() {
 	if v.WebsocketHostname == "" {
 		v.WebsocketHostname = WebsocketHostname
 	}
+	if v.RestScheme == "" {
+		v.RestScheme = "https"
+	}
+	if v.WebsocketScheme == "" {
+		v.WebsocketScheme = "wss"
+	}
+	if v.ServerTimeURL == "" {
+		v.ServerTimeURL = ServerTimeURL
+	}
 	if v.HttpClientTimeout == 0 {
 		v.HttpClientTimeout = 5 * time.Second
 	}
@@ -79,6 +155,30 @@ func (v *Options) setDefaults() {
 	if v.FetchProductsInterval == 0 {
 		v.FetchProductsInterval = time.Minute
 	}
+	if v.StaleTickerTimeout == 0 {
+		v.StaleTickerTimeout = time.Minute
+	}
+	if v.PollTickerInterval == 0 {
+		v.PollTickerInterval = 5 * time.Second
+	}
+	if v.RecordTickersInterval == 0 {
+		v.RecordTickersInterval = 5 * time.Second
+	}
+	if v.TickerRetention == 0 {
+		v.TickerRetention = 7 * 24 * time.Hour
+	}
+	if v.CancelledOrderRetention == 0 {
+		v.CancelledOrderRetention = 7 * 24 * time.Hour
+	}
+	if v.MaxCachedOrders == 0 {
+		v.MaxCachedOrders = 10000
+	}
+	if v.WebsocketLogMaxAge == 0 {
+		v.WebsocketLogMaxAge = 7 * 24 * time.Hour
+	}
+	if len(v.QuoteCurrencies) == 0 {
+		v.QuoteCurrencies = []string{"USD", "USDC"}
+	}
 	if len(v.WatchProductIDs) == 0 {
 		v.WatchProductIDs = []string{
 			"BTC-USD", "BCH-USD", "ETH-USD", "AVAX-USD", "DOGE-USD", "SHIB-USD",
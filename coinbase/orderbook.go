@@ -0,0 +1,126 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bvk/tradebot/coinbase/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/shopspring/decimal"
+)
+
+// orderBook is an in-memory level2 order book for a product built from the
+// websocket level2 channel snapshots and updates.
+type orderBook struct {
+	mu sync.Mutex
+
+	timestamp time.Time
+
+	bids bookSide
+	asks bookSide
+}
+
+// bookSide holds the price levels for one side of the order book. Best price
+// level is tracked incrementally and is recomputed only when the best level is
+// removed.
+type bookSide struct {
+	isBid bool
+
+	levels map[string]decimal.Decimal
+
+	best     decimal.Decimal
+	bestSize decimal.Decimal
+}
+
+func newOrderBook() *orderBook {
+	return &orderBook{
+		bids: bookSide{isBid: true, levels: make(map[string]decimal.Decimal)},
+		asks: bookSide{levels: make(map[string]decimal.Decimal)},
+	}
+}
+
+func (s *bookSide) isBetter(a, b decimal.Decimal) bool {
+	if s.isBid {
+		return a.GreaterThan(b)
+	}
+	return a.LessThan(b)
+}
+
+func (s *bookSide) reset() {
+	clear(s.levels)
+	s.best, s.bestSize = decimal.Zero, decimal.Zero
+}
+
+func (s *bookSide) update(price, size decimal.Decimal) {
+	key := price.String()
+	if size.IsZero() {
+		delete(s.levels, key)
+		if price.Equal(s.best) {
+			s.rescan()
+		}
+		return
+	}
+
+	s.levels[key] = size
+	if s.bestSize.IsZero() || price.Equal(s.best) || s.isBetter(price, s.best) {
+		s.best, s.bestSize = price, size
+	}
+}
+
+func (s *bookSide) rescan() {
+	s.best, s.bestSize = decimal.Zero, decimal.Zero
+	for key, size := range s.levels {
+		price := decimal.RequireFromString(key)
+		if s.bestSize.IsZero() || s.isBetter(price, s.best) {
+			s.best, s.bestSize = price, size
+		}
+	}
+}
+
+// reset removes all price levels from the book. Book remains empty till the
+// next snapshot is received.
+func (b *orderBook) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bids.reset()
+	b.asks.reset()
+	b.timestamp = time.Time{}
+}
+
+func (b *orderBook) handleEvent(timestamp time.Time, event *internal.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if event.Type == "snapshot" {
+		b.bids.reset()
+		b.asks.reset()
+	}
+	b.timestamp = timestamp
+	for _, u := range event.Updates {
+		switch u.Side {
+		case "bid":
+			b.bids.update(u.PriceLevel.Decimal, u.NewQuantity.Decimal)
+		case "offer", "ask":
+			b.asks.update(u.PriceLevel.Decimal, u.NewQuantity.Decimal)
+		}
+	}
+}
+
+func (b *orderBook) bestBidAsk() *exchange.BidAsk {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.bids.bestSize.IsZero() || b.asks.bestSize.IsZero() {
+		return nil
+	}
+	return &exchange.BidAsk{
+		Timestamp: exchange.RemoteTime{Time: b.timestamp},
+		BidPrice:  b.bids.best,
+		BidSize:   b.bids.bestSize,
+		AskPrice:  b.asks.best,
+		AskSize:   b.asks.bestSize,
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/coinbase/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/shopspring/decimal"
+)
+
+func TestOrderBook(t *testing.T) {
+	level := func(side, price, size string) *internal.UpdateEvent {
+		return &internal.UpdateEvent{
+			Side:        side,
+			PriceLevel:  exchange.NullDecimal{Decimal: decimal.RequireFromString(price)},
+			NewQuantity: exchange.NullDecimal{Decimal: decimal.RequireFromString(size)},
+		}
+	}
+	check := func(b *orderBook, bid, ask string) {
+		t.Helper()
+		v := b.bestBidAsk()
+		if v == nil {
+			t.Fatalf("want best bid/ask %s/%s, got nil", bid, ask)
+		}
+		if !v.BidPrice.Equal(decimal.RequireFromString(bid)) || !v.AskPrice.Equal(decimal.RequireFromString(ask)) {
+			t.Fatalf("want best bid/ask %s/%s, got %s/%s", bid, ask, v.BidPrice, v.AskPrice)
+		}
+	}
+
+	b := newOrderBook()
+	if v := b.bestBidAsk(); v != nil {
+		t.Fatalf("want nil for empty book, got %v", v)
+	}
+
+	now := time.Now()
+	b.handleEvent(now, &internal.Event{
+		Type: "snapshot",
+		Updates: []*internal.UpdateEvent{
+			level("bid", "99", "1"),
+			level("bid", "98.5", "2"),
+			level("offer", "101", "1"),
+			level("offer", "102", "3"),
+		},
+	})
+	check(b, "99", "101")
+
+	// Better levels replace the best.
+	b.handleEvent(now, &internal.Event{
+		Type:    "update",
+		Updates: []*internal.UpdateEvent{level("bid", "99.5", "1"), level("offer", "100.5", "1")},
+	})
+	check(b, "99.5", "100.5")
+
+	// Removing the best levels falls back to the next best.
+	b.handleEvent(now, &internal.Event{
+		Type:    "update",
+		Updates: []*internal.UpdateEvent{level("bid", "99.5", "0"), level("offer", "100.5", "0"), level("offer", "101", "0")},
+	})
+	check(b, "99", "102")
+	if v := b.bestBidAsk(); !v.Spread().Equal(decimal.NewFromInt(3)) {
+		t.Fatalf("want spread 3, got %s", v.Spread())
+	}
+
+	// Snapshots reset the book.
+	b.handleEvent(now, &internal.Event{
+		Type:    "snapshot",
+		Updates: []*internal.UpdateEvent{level("bid", "50", "1"), level("offer", "51", "1")},
+	})
+	check(b, "50", "51")
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/bvk/tradebot/exchange"
+)
+
+// orderCache is a bounded client-order-id to exchange.Order mapping. When the
+// number of orders exceed the capacity, least recently used orders that are
+// complete are evicted. Orders that are not complete are never evicted,
+// because they are required to resync the orders after websocket failures.
+type orderCache struct {
+	mu sync.Mutex
+
+	capacity int
+
+	// lru holds the *orderCacheEntry values with the most recently used entry
+	// at the front.
+	lru *list.List
+
+	entryMap map[string]*list.Element
+}
+
+type orderCacheEntry struct {
+	clientOrderID string
+	order         *exchange.Order
+}
+
+func newOrderCache(capacity int) *orderCache {
+	return &orderCache{
+		capacity: capacity,
+		lru:      list.New(),
+		entryMap: make(map[string]*list.Element),
+	}
+}
+
+// Len returns the number of orders in the cache.
+func (c *orderCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+func (c *orderCache) Load(clientOrderID string) (*exchange.Order, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entryMap[clientOrderID]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*orderCacheEntry).order, true
+}
+
+func (c *orderCache) Store(clientOrderID string, order *exchange.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entryMap[clientOrderID]; ok {
+		elem.Value.(*orderCacheEntry).order = order
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.insertLocked(clientOrderID, order)
+}
+
+func (c *orderCache) LoadOrStore(clientOrderID string, order *exchange.Order) (*exchange.Order, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entryMap[clientOrderID]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*orderCacheEntry).order, true
+	}
+	c.insertLocked(clientOrderID, order)
+	return order, false
+}
+
+// Range calls the function for all orders in the cache from the most recently
+// used to the least recently used order till the function returns false.
+// Function must not access the cache.
+func (c *orderCache) Range(fn func(string, *exchange.Order) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*orderCacheEntry)
+		if !fn(entry.clientOrderID, entry.order) {
+			return
+		}
+	}
+}
+
+func (c *orderCache) insertLocked(clientOrderID string, order *exchange.Order) {
+	c.entryMap[clientOrderID] = c.lru.PushFront(&orderCacheEntry{clientOrderID: clientOrderID, order: order})
+
+	for elem := c.lru.Back(); elem != nil && c.lru.Len() > c.capacity; {
+		prev := elem.Prev()
+		if entry := elem.Value.(*orderCacheEntry); entry.order.Done {
+			c.lru.Remove(elem)
+			delete(c.entryMap, entry.clientOrderID)
+		}
+		elem = prev
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bvk/tradebot/exchange"
+)
+
+func TestOrderCacheEviction(t *testing.T) {
+	c := newOrderCache(3)
+
+	order := func(id string, done bool) *exchange.Order {
+		return &exchange.Order{OrderID: exchange.OrderID(id), ClientOrderID: id, Done: done}
+	}
+
+	// Orders that are not complete must never be evicted.
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("open-%d", i)
+		c.Store(id, order(id, false))
+	}
+	if n := c.Len(); n != 5 {
+		t.Fatalf("want 5 orders, got %d", n)
+	}
+
+	// Completing the orders makes them evictable in the lru order.
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("open-%d", i)
+		c.Store(id, order(id, true))
+	}
+	c.Load("open-0")
+	c.Store("new", order("new", true))
+	if n := c.Len(); n != 3 {
+		t.Fatalf("want 3 orders, got %d", n)
+	}
+	for _, id := range []string{"open-0", "open-4", "new"} {
+		if _, ok := c.Load(id); !ok {
+			t.Fatalf("want order %s to be in the cache", id)
+		}
+	}
+	for _, id := range []string{"open-1", "open-2", "open-3"} {
+		if _, ok := c.Load(id); ok {
+			t.Fatalf("want order %s to be evicted", id)
+		}
+	}
+
+	if v, loaded := c.LoadOrStore("new", order("other", true)); !loaded || v.OrderID != "new" {
+		t.Fatalf("want the existing order from LoadOrStore")
+	}
+}
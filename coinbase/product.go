@@ -10,6 +10,8 @@ import (
 	"log/slog"
 	"os"
 	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bvk/tradebot/coinbase/internal"
@@ -23,14 +25,32 @@ type Product struct {
 
 	exchange *Exchange
 
+	// mu protects the lastTicker and the stale feed detection state, which are
+	// updated by both websocket and polled tickers.
+	mu sync.Mutex
+
 	lastTicker *exchange.Ticker
 
+	// openTime is the local time when the product was opened. Websocket feed
+	// is not considered stalled till StaleTickerTimeout from this time.
+	openTime time.Time
+
+	// lastTickerTime is the local time when a ticker for the product was last
+	// received on the websocket.
+	lastTickerTime time.Time
+
+	// staleSince is non-zero when websocket feed is stalled.
+	staleSince time.Time
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+
+	orderBook *orderBook
+
 	prodTickerTopic *topic.Topic[*exchange.Ticker]
 	prodOrderTopic  *topic.Topic[*exchange.Order]
 
 	productData *internal.GetProductResponse
-
-	websocket *internal.Websocket
 }
 
 func (ex *Exchange) OpenProduct(ctx context.Context, pid string) (_ exchange.Product, status error) {
@@ -49,9 +69,17 @@ func (ex *Exchange) OpenProduct(ctx context.Context, pid string) (_ exchange.Pro
 		productData:     product,
 		prodTickerTopic: topic.New[*exchange.Ticker](),
 		prodOrderTopic:  topic.New[*exchange.Order](),
-		websocket:       ex.client.GetMessages("heartbeats", []string{pid}, ex.dispatchMessage),
+		orderBook:       newOrderBook(),
+		openTime:        time.Now(),
+		closeCh:         make(chan struct{}),
+	}
+	ws := ex.sharedWebsocket()
+	ws.Subscribe("ticker", []string{pid})
+	ws.Subscribe("level2", []string{pid})
+
+	if ex.opts.StaleTickerTimeout > 0 {
+		ex.client.Go(p.goWatchTicker)
 	}
-	p.websocket.Subscribe("ticker", []string{pid})
 
 	ex.productMap.Store(pid, p)
 	return p, nil
@@ -59,7 +87,13 @@ func (ex *Exchange) OpenProduct(ctx context.Context, pid string) (_ exchange.Pro
 
 func (p *Product) Close() error {
 	p.exchange.productMap.Delete(p.productData.ProductID)
-	p.websocket.Close()
+	p.closeOnce.Do(func() { close(p.closeCh) })
+	ws := p.exchange.sharedWebsocket()
+	// Ticker channel is left subscribed when the product is being recorded.
+	if r := p.exchange.recorder; r == nil || !slices.Contains(r.productIDs, p.productData.ProductID) {
+		ws.Unsubscribe("ticker", []string{p.productData.ProductID})
+	}
+	ws.Unsubscribe("level2", []string{p.productData.ProductID})
 	return nil
 }
 
@@ -75,11 +109,19 @@ func (p *Product) BaseMinSize() decimal.Decimal {
 	return p.productData.BaseMinSize.Decimal
 }
 
+func (p *Product) BaseIncrement() decimal.Decimal {
+	return p.productData.BaseIncrement.Decimal
+}
+
 func (p *Product) TickerCh() (<-chan *exchange.Ticker, func()) {
 	sub, ch, _ := p.prodTickerTopic.Subscribe(1, true /* includeRecent */)
 	return ch, sub.Unsubscribe
 }
 
+func (p *Product) BestBidAsk() *exchange.BidAsk {
+	return p.orderBook.bestBidAsk()
+}
+
 func (p *Product) OrderUpdatesCh() (<-chan *exchange.Order, func()) {
 	sub, ch, _ := p.prodOrderTopic.Subscribe(0, true /* includeRecent */)
 	return ch, sub.Unsubscribe
@@ -89,32 +131,34 @@ func (p *Product) Get(ctx context.Context, serverOrderID exchange.OrderID) (*exc
 	return p.exchange.GetOrder(ctx, serverOrderID)
 }
 
-func (p *Product) LimitBuy(ctx context.Context, clientOrderID string, size, price decimal.Decimal) (exchange.OrderID, error) {
-	if size.LessThan(p.productData.BaseMinSize.Decimal) {
-		return "", fmt.Errorf("min size is %s: %w", p.productData.BaseMinSize.Decimal, os.ErrInvalid)
+func (p *Product) LimitBuy(ctx context.Context, clientOrderID string, size, price decimal.Decimal, opts *exchange.LimitOptions) (exchange.OrderID, error) {
+	return p.limit(ctx, "BUY", clientOrderID, size, price, opts)
+}
+
+func (p *Product) LimitSell(ctx context.Context, clientOrderID string, size, price decimal.Decimal, opts *exchange.LimitOptions) (exchange.OrderID, error) {
+	return p.limit(ctx, "SELL", clientOrderID, size, price, opts)
+}
+
+func (p *Product) limit(ctx context.Context, side, clientOrderID string, size, price decimal.Decimal, opts *exchange.LimitOptions) (exchange.OrderID, error) {
+	roundSize, roundPrice, err := exchange.NormalizeLimit(gobsProduct(p.productData), size, price)
+	if err != nil {
+		return "", err
 	}
-	if size.GreaterThan(p.productData.BaseMaxSize.Decimal) {
-		return "", fmt.Errorf("max size is %s: %w", p.productData.BaseMaxSize.Decimal, os.ErrInvalid)
+	if err := opts.Check(); err != nil {
+		return "", fmt.Errorf("invalid limit order options: %w", errors.Join(err, os.ErrInvalid))
 	}
 
 	// check if this is a retry request for the clientOrderID.
-	if order, ok := p.exchange.recreateOldOrder(clientOrderID); ok {
+	if order, ok := p.exchange.recreateOldOrder(ctx, clientOrderID); ok {
 		p.prodOrderTopic.Send(order)
 		return order.OrderID, nil
 	}
 
-	roundPrice := price.Sub(price.Mod(p.productData.QuoteIncrement.Decimal))
-
 	req := &internal.CreateOrderRequest{
 		ClientOrderID: clientOrderID,
 		ProductID:     p.productData.ProductID,
-		Side:          "BUY",
-		Order: &internal.OrderConfig{
-			LimitGTC: &internal.LimitLimitGTC{
-				BaseSize:   exchange.NullDecimal{Decimal: size},
-				LimitPrice: exchange.NullDecimal{Decimal: roundPrice},
-			},
-		},
+		Side:          side,
+		Order:         limitOrderConfig(roundSize, roundPrice, opts),
 	}
 	resp, err := p.exchange.createReadyOrder(ctx, req)
 	if err != nil {
@@ -122,36 +166,145 @@ func (p *Product) LimitBuy(ctx context.Context, clientOrderID string, size, pric
 	}
 	if !resp.Success {
 		slog.ErrorContext(ctx, "create order has failed", "error_response", resp.ErrorResponse)
+		if isPostOnlyFailure(resp) {
+			return "", fmt.Errorf("%s: %w", resp.FailureReason, exchange.ErrPostOnlyRejected)
+		}
 		return "", errors.New(resp.FailureReason)
 	}
 	return exchange.OrderID(resp.OrderID), nil
 }
 
-func (p *Product) LimitSell(ctx context.Context, clientOrderID string, size, price decimal.Decimal) (exchange.OrderID, error) {
-	if size.LessThan(p.productData.BaseMinSize.Decimal) {
-		return "", fmt.Errorf("min size is %s: %w", p.productData.BaseMinSize.Decimal, os.ErrInvalid)
+func limitOrderConfig(size, price decimal.Decimal, opts *exchange.LimitOptions) *internal.OrderConfig {
+	if opts == nil {
+		opts = new(exchange.LimitOptions)
 	}
-	if size.GreaterThan(p.productData.BaseMaxSize.Decimal) {
-		return "", fmt.Errorf("max size is %s: %w", p.productData.BaseMaxSize.Decimal, os.ErrInvalid)
+	switch opts.TimeInForce {
+	case "GTD":
+		return &internal.OrderConfig{
+			LimitGTD: &internal.LimitLimitGTD{
+				BaseSize:   exchange.NullDecimal{Decimal: size},
+				LimitPrice: exchange.NullDecimal{Decimal: price},
+				PostOnly:   opts.PostOnly,
+				EndTime:    opts.EndTime.UTC().Format(time.RFC3339),
+			},
+		}
+	case "IOC":
+		return &internal.OrderConfig{
+			LimitIOC: &internal.SorLimitIOC{
+				BaseSize:   exchange.NullDecimal{Decimal: size},
+				LimitPrice: exchange.NullDecimal{Decimal: price},
+			},
+		}
+	case "FOK":
+		return &internal.OrderConfig{
+			LimitFOK: &internal.LimitLimitFOK{
+				BaseSize:   exchange.NullDecimal{Decimal: size},
+				LimitPrice: exchange.NullDecimal{Decimal: price},
+			},
+		}
+	}
+	return &internal.OrderConfig{
+		LimitGTC: &internal.LimitLimitGTC{
+			BaseSize:   exchange.NullDecimal{Decimal: size},
+			LimitPrice: exchange.NullDecimal{Decimal: price},
+			PostOnly:   opts.PostOnly,
+		},
+	}
+}
+
+// isPostOnlyFailure returns true if the create order response indicates that
+// a post-only order is rejected because it would cross the spread.
+func isPostOnlyFailure(resp *internal.CreateOrderResponse) bool {
+	reasons := []string{resp.FailureReason}
+	if e := resp.ErrorResponse; e != nil {
+		reasons = append(reasons, e.Error, e.Message, e.PreviewFailureReason, e.NewOrderFailureReason)
+	}
+	for _, r := range reasons {
+		if strings.Contains(strings.ToUpper(r), "POST_ONLY") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Product) MarketBuy(ctx context.Context, clientOrderID string, size decimal.Decimal) (exchange.OrderID, error) {
+	size, err := exchange.NormalizeSize(gobsProduct(p.productData), size)
+	if err != nil {
+		return "", err
+	}
+	config := &internal.MarketMarketIOC{
+		BaseSize: &exchange.NullDecimal{Decimal: size},
+	}
+	return p.market(ctx, "BUY", clientOrderID, config)
+}
+
+func (p *Product) MarketSell(ctx context.Context, clientOrderID string, size decimal.Decimal) (exchange.OrderID, error) {
+	size, err := exchange.NormalizeSize(gobsProduct(p.productData), size)
+	if err != nil {
+		return "", err
+	}
+	config := &internal.MarketMarketIOC{
+		BaseSize: &exchange.NullDecimal{Decimal: size},
 	}
+	return p.market(ctx, "SELL", clientOrderID, config)
+}
+
+func (p *Product) MarketBuyFunds(ctx context.Context, clientOrderID string, funds decimal.Decimal) (exchange.OrderID, error) {
+	funds, err := exchange.NormalizeFunds(gobsProduct(p.productData), funds)
+	if err != nil {
+		return "", err
+	}
+	config := &internal.MarketMarketIOC{
+		QuoteSize: &exchange.NullDecimal{Decimal: funds},
+	}
+	return p.market(ctx, "BUY", clientOrderID, config)
+}
 
+// MarketSellFunds sells the base size worth the given funds at the current
+// market price. Coinbase accepts the quote size only for the market buy
+// orders, so the funds are converted to the base size using the best bid
+// price or the last ticker price.
+func (p *Product) MarketSellFunds(ctx context.Context, clientOrderID string, funds decimal.Decimal) (exchange.OrderID, error) {
+	funds, err := exchange.NormalizeFunds(gobsProduct(p.productData), funds)
+	if err != nil {
+		return "", err
+	}
+	price, err := p.marketPrice()
+	if err != nil {
+		return "", err
+	}
+	return p.MarketSell(ctx, clientOrderID, funds.Div(price))
+}
+
+// marketPrice returns the best bid price from the order book or the last
+// ticker price when the order book is not available.
+func (p *Product) marketPrice() (decimal.Decimal, error) {
+	if v := p.BestBidAsk(); v != nil && v.BidPrice.IsPositive() {
+		return v.BidPrice, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lastTicker == nil || !p.lastTicker.Price.IsPositive() {
+		return decimal.Zero, fmt.Errorf("market price is not known yet: %w", os.ErrInvalid)
+	}
+	return p.lastTicker.Price, nil
+}
+
+func (p *Product) market(ctx context.Context, side, clientOrderID string, config *internal.MarketMarketIOC) (exchange.OrderID, error) {
 	// check if this is a retry request for the clientOrderID.
-	if order, ok := p.exchange.recreateOldOrder(clientOrderID); ok {
+	if order, ok := p.exchange.recreateOldOrder(ctx, clientOrderID); ok {
 		p.prodOrderTopic.Send(order)
 		return order.OrderID, nil
 	}
 
-	roundPrice := price.Sub(price.Mod(p.productData.QuoteIncrement.Decimal))
-
 	req := &internal.CreateOrderRequest{
 		ClientOrderID: clientOrderID,
 		ProductID:     p.productData.ProductID,
-		Side:          "SELL",
+		Side:          side,
 		Order: &internal.OrderConfig{
-			LimitGTC: &internal.LimitLimitGTC{
-				BaseSize:   exchange.NullDecimal{Decimal: size},
-				LimitPrice: exchange.NullDecimal{Decimal: roundPrice},
-			},
+			MarketIOC: config,
 		},
 	}
 	resp, err := p.exchange.createReadyOrder(ctx, req)
@@ -162,7 +315,6 @@ func (p *Product) LimitSell(ctx context.Context, clientOrderID string, size, pri
 		slog.ErrorContext(ctx, "create order has failed", "error_response", resp.ErrorResponse)
 		return "", errors.New(resp.FailureReason)
 	}
-
 	return exchange.OrderID(resp.OrderID), nil
 }
 
@@ -195,17 +347,142 @@ func (p *Product) Cancel(ctx context.Context, serverOrderID exchange.OrderID) er
 	return nil
 }
 
+// Edit changes the size and price of an open limit order using the
+// edit-order api. Coinbase can only edit the GTC limit orders, so other orders
+// are rejected with exchange.ErrEditRejected.
+func (p *Product) Edit(ctx context.Context, serverOrderID exchange.OrderID, size, price decimal.Decimal) error {
+	roundSize, roundPrice, err := exchange.NormalizeLimit(gobsProduct(p.productData), size, price)
+	if err != nil {
+		return err
+	}
+	req := &internal.EditOrderRequest{
+		OrderID: string(serverOrderID),
+		Price:   exchange.NullDecimal{Decimal: roundPrice},
+		Size:    exchange.NullDecimal{Decimal: roundSize},
+	}
+	resp, err := p.client.EditOrder(ctx, req)
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		var reasons []string
+		for _, e := range resp.Errors {
+			reasons = append(reasons, e.EditFailureReason, e.PreviewFailureReason)
+		}
+		reasons = slices.DeleteFunc(reasons, func(s string) bool { return s == "" })
+		return fmt.Errorf("%s: %w", strings.Join(reasons, ","), exchange.ErrEditRejected)
+	}
+	return nil
+}
+
 func (p *Product) handleTickerEvent(timestamp time.Time, event *internal.TickerEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastTickerTime = time.Now()
+	p.sendTickerLocked(timestamp, event.Price.Decimal)
+}
+
+func (p *Product) sendTickerLocked(timestamp time.Time, price decimal.Decimal) {
 	if p.lastTicker != nil && timestamp.Before(p.lastTicker.Timestamp.Time) {
 		return
 	}
 	p.lastTicker = &exchange.Ticker{
 		Timestamp: exchange.RemoteTime{Time: timestamp},
-		Price:     event.Price.Decimal,
+		Price:     price,
 	}
 	p.prodTickerTopic.Send(p.lastTicker)
 }
 
+func (p *Product) notifyFeedLocked(msgfmt string, args ...interface{}) {
+	log.Printf(msgfmt, args...)
+	if m := p.exchange.opts.Messenger; m != nil {
+		msg := fmt.Sprintf(msgfmt, args...)
+		p.client.Go(func(ctx context.Context) {
+			m.SendMessage(ctx, time.Now(), "%s", msg)
+		})
+	}
+}
+
+// goWatchTicker detects the stalled websocket ticker feed for the product and
+// synthesizes the tickers at the middle of the best bid and ask prices polled
+// through the REST api till the feed recovers, so that the traders waiting on
+// the tickers can make progress. Feed is considered stalled when no ticker for
+// the product is received for StaleTickerTimeout duration, because messages
+// for other products or the heartbeats do not prove that the product's
+// subscription is alive. Best bid and ask prices are polled instead of the
+// last trade price, because quiet products may not have any trades for long
+// durations while their order books still move.
+func (p *Product) goWatchTicker(ctx context.Context) {
+	interval := min(p.exchange.opts.PollTickerInterval, p.exchange.opts.StaleTickerTimeout)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.closeCh:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		now := time.Now()
+		lastFeedTime := p.lastTickerTime
+		if lastFeedTime.Before(p.openTime) {
+			lastFeedTime = p.openTime
+		}
+		stale := now.Sub(lastFeedTime) > p.exchange.opts.StaleTickerTimeout
+		if stale && p.staleSince.IsZero() {
+			p.staleSince = lastFeedTime
+			p.notifyFeedLocked("Websocket feed for product %s (coinbase) has stalled for %s; polling the REST api for prices.", p.productData.ProductID, now.Sub(lastFeedTime).Round(time.Second))
+		}
+		if !stale && !p.staleSince.IsZero() {
+			p.notifyFeedLocked("Websocket feed for product %s (coinbase) has recovered after an outage of %s.", p.productData.ProductID, now.Sub(p.staleSince).Round(time.Second))
+			p.staleSince = time.Time{}
+		}
+		p.mu.Unlock()
+
+		if !stale {
+			continue
+		}
+
+		price, err := p.pollMidPrice(ctx)
+		if err != nil {
+			log.Printf("could not poll best bid-ask for product %s (will retry): %v", p.productData.ProductID, err)
+			continue
+		}
+
+		p.mu.Lock()
+		if !p.staleSince.IsZero() {
+			p.sendTickerLocked(p.client.Now().Time, price)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// pollMidPrice returns the middle of the best bid and ask prices for the
+// product fetched through the REST api.
+func (p *Product) pollMidPrice(ctx context.Context) (decimal.Decimal, error) {
+	pid := p.productData.ProductID
+	resp, err := p.client.GetBestBidAsk(ctx, []string{pid})
+	if err != nil {
+		return decimal.Zero, err
+	}
+	for _, book := range resp.PriceBooks {
+		if book.ProductID != pid || len(book.Bids) == 0 || len(book.Asks) == 0 {
+			continue
+		}
+		bid, ask := book.Bids[0].Price.Decimal, book.Asks[0].Price.Decimal
+		if !bid.IsPositive() || !ask.IsPositive() {
+			continue
+		}
+		return bid.Add(ask).Div(decimal.NewFromInt(2)), nil
+	}
+	return decimal.Zero, fmt.Errorf("best bid-ask for product %s is not available: %w", pid, os.ErrNotExist)
+}
+
 func (p *Product) handleOrder(order *exchange.Order) {
 	// We don't want to expose PENDING state outside this package.
 	if slices.Contains(readyStatuses, order.Status) {
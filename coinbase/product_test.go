@@ -0,0 +1,138 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/coinbase/coinbasetest"
+	"github.com/shopspring/decimal"
+)
+
+type testMessenger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (m *testMessenger) SendMessage(_ context.Context, _ time.Time, msgfmt string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.msgs = append(m.msgs, fmt.Sprintf(msgfmt, args...))
+}
+
+func (m *testMessenger) contains(s string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range m.msgs {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStaleTickerPolling(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+
+	messenger := new(testMessenger)
+	opts := newTestOptions(srv)
+	opts.StaleTickerTimeout = 200 * time.Millisecond
+	opts.PollTickerInterval = 20 * time.Millisecond
+	opts.Messenger = messenger
+
+	ex := newTestExchangeWithOptions(ctx, t, srv, "test-key", "test-secret", opts)
+	defer ex.Close()
+
+	p, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	tickerCh, tickerStop := p.TickerCh()
+	defer tickerStop()
+
+	waitForPrice := func(price decimal.Decimal) {
+		timeoutCh := time.After(5 * time.Second)
+		for {
+			select {
+			case ticker := <-tickerCh:
+				if ticker.Price.Equal(price) {
+					return
+				}
+			case <-timeoutCh:
+				t.Fatalf("timed out waiting for ticker with price %s", price)
+			}
+		}
+	}
+	waitForMessage := func(s string) {
+		timeoutCh := time.After(5 * time.Second)
+		for !messenger.contains(s) {
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-timeoutCh:
+				t.Fatalf("timed out waiting for a notification with %q", s)
+			}
+		}
+	}
+
+	waitForPrice(decimal.NewFromInt(100))
+
+	// Lost websocket ticker must be replaced by the polled ticker.
+	srv.DropMessages("ticker", 1)
+	if err := srv.Tick("BCH-USD", decimal.NewFromInt(105)); err != nil {
+		t.Fatal(err)
+	}
+	waitForPrice(decimal.NewFromInt(105))
+	waitForMessage("stalled")
+
+	if err := srv.Tick("BCH-USD", decimal.NewFromInt(110)); err != nil {
+		t.Fatal(err)
+	}
+	waitForPrice(decimal.NewFromInt(110))
+	waitForMessage("recovered")
+}
+
+func TestHeartbeatsWithStalledTicker(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+
+	messenger := new(testMessenger)
+	opts := newTestOptions(srv)
+	opts.StaleTickerTimeout = 200 * time.Millisecond
+	opts.PollTickerInterval = 20 * time.Millisecond
+	opts.Messenger = messenger
+
+	ex := newTestExchangeWithOptions(ctx, t, srv, "test-key", "test-secret", opts)
+	defer ex.Close()
+
+	p, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// Heartbeats on the websocket must not hide the missing tickers for the
+	// product.
+	for end := time.Now().Add(5 * time.Second); !messenger.contains("stalled"); {
+		if time.Now().After(end) {
+			t.Fatalf("want a stall notification for a product without tickers")
+		}
+		srv.Heartbeat()
+		time.Sleep(50 * time.Millisecond)
+	}
+}
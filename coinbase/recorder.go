@@ -0,0 +1,317 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bvk/tradebot/ctxutil"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/kvutil"
+	"github.com/bvkgo/kv"
+	"github.com/shopspring/decimal"
+)
+
+// tickerRecorder saves every ticker received for the watched products into
+// the datastore, so that the exact price movements can be replayed later.
+//
+// Tickers are buffered in memory and saved every flush interval into keys
+// partitioned by the ticker timestamp minute. Keys older than the retention
+// period are deleted.
+type tickerRecorder struct {
+	ds *Datastore
+
+	productIDs []string
+
+	retention time.Duration
+
+	mu sync.Mutex
+
+	pending map[string]*gobs.CoinbaseTickerList
+}
+
+func newTickerRecorder(ds *Datastore, productIDs []string, retention time.Duration) *tickerRecorder {
+	return &tickerRecorder{
+		ds:         ds,
+		productIDs: slices.Clone(productIDs),
+		retention:  retention,
+		pending:    make(map[string]*gobs.CoinbaseTickerList),
+	}
+}
+
+func tickersKey(t time.Time) string {
+	return path.Join(Keyspace, "tickers", t.UTC().Format("2006-01-02/15/04"))
+}
+
+func (r *tickerRecorder) record(productID string, timestamp time.Time, price decimal.Decimal) {
+	if !slices.Contains(r.productIDs, productID) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, ok := r.pending[productID]
+	if !ok {
+		list = new(gobs.CoinbaseTickerList)
+		r.pending[productID] = list
+	}
+	list.UnixNanos = append(list.UnixNanos, timestamp.UnixNano())
+	list.Prices = append(list.Prices, price)
+}
+
+func (r *tickerRecorder) goFlush(ctx context.Context, interval time.Duration) {
+	// Pending tickers must be saved even when the exchange is closed.
+	defer func() {
+		if err := r.flush(context.WithoutCancel(ctx)); err != nil {
+			log.Printf("could not save recorded tickers: %v", err)
+		}
+	}()
+
+	var lastGC time.Time
+	for ctxutil.Sleep(ctx, interval); ctx.Err() == nil; ctxutil.Sleep(ctx, interval) {
+		if err := r.flush(ctx); err != nil {
+			log.Printf("could not save recorded tickers (will retry): %v", err)
+			continue
+		}
+		if r.retention > 0 && time.Since(lastGC) > time.Minute {
+			if err := r.ds.deleteTickers(ctx, time.Now().Add(-r.retention)); err != nil {
+				log.Printf("could not delete old recorded tickers (will retry): %v", err)
+				continue
+			}
+			lastGC = time.Now()
+		}
+	}
+}
+
+func (r *tickerRecorder) flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[string]*gobs.CoinbaseTickerList)
+	r.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	if err := r.ds.saveTickers(ctx, pending); err != nil {
+		// Put back the tickers so that they are saved in the next attempt.
+		r.mu.Lock()
+		for pid, list := range pending {
+			if v, ok := r.pending[pid]; ok {
+				list.UnixNanos = append(list.UnixNanos, v.UnixNanos...)
+				list.Prices = append(list.Prices, v.Prices...)
+			}
+			r.pending[pid] = list
+		}
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+type recordedTicker struct {
+	unixNano int64
+	price    decimal.Decimal
+}
+
+func (ds *Datastore) saveTickers(ctx context.Context, productTickersMap map[string]*gobs.CoinbaseTickerList) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	// Group the tickers by their key.
+	kmap := make(map[string]map[string][]recordedTicker)
+	for pid, list := range productTickersMap {
+		for i, nanos := range list.UnixNanos {
+			key := tickersKey(time.Unix(0, nanos))
+			pmap, ok := kmap[key]
+			if !ok {
+				pmap = make(map[string][]recordedTicker)
+				kmap[key] = pmap
+			}
+			pmap[pid] = append(pmap[pid], recordedTicker{unixNano: nanos, price: list.Prices[i]})
+		}
+	}
+
+	saver := func(ctx context.Context, rw kv.ReadWriter) error {
+		for key, pmap := range kmap {
+			value, err := kvutil.Get[gobs.CoinbaseTickers](ctx, rw, key)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					return fmt.Errorf("could not load tickers at %q: %w", key, err)
+				}
+				value = &gobs.CoinbaseTickers{
+					ProductTickersMap: make(map[string]*gobs.CoinbaseTickerList),
+				}
+			}
+			for pid, tickers := range pmap {
+				var all []recordedTicker
+				if list, ok := value.ProductTickersMap[pid]; ok {
+					for i, nanos := range list.UnixNanos {
+						all = append(all, recordedTicker{unixNano: nanos, price: list.Prices[i]})
+					}
+				}
+				all = append(all, tickers...)
+				slices.SortStableFunc(all, func(a, b recordedTicker) int {
+					return cmp.Compare(a.unixNano, b.unixNano)
+				})
+
+				list := &gobs.CoinbaseTickerList{
+					UnixNanos: make([]int64, 0, len(all)),
+					Prices:    make([]decimal.Decimal, 0, len(all)),
+				}
+				for _, t := range all {
+					list.UnixNanos = append(list.UnixNanos, t.unixNano)
+					list.Prices = append(list.Prices, t.price)
+				}
+				value.ProductTickersMap[pid] = list
+			}
+			if err := kvutil.Set(ctx, rw, key, value); err != nil {
+				return fmt.Errorf("could not save tickers at key %q: %w", key, err)
+			}
+		}
+		return nil
+	}
+	return kv.WithReadWriter(ctx, ds.db, saver)
+}
+
+// deleteTickers removes the recorded tickers older than the `before`
+// timestamp.
+func (ds *Datastore) deleteTickers(ctx context.Context, before time.Time) error {
+	minKey := path.Join(Keyspace, "tickers", "0000-00-00/00/00")
+	maxKey := tickersKey(before)
+
+	deleter := func(ctx context.Context, rw kv.ReadWriter) error {
+		it, err := rw.Ascend(ctx, minKey, maxKey)
+		if err != nil {
+			return err
+		}
+		defer kv.Close(it)
+
+		var keys []string
+		for k, _, err := it.Fetch(ctx, false); err == nil; k, _, err = it.Fetch(ctx, true) {
+			keys = append(keys, k)
+		}
+		if _, _, err := it.Fetch(ctx, false); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("could not scan recorded tickers: %w", err)
+		}
+		for _, k := range keys {
+			if err := rw.Delete(ctx, k); err != nil {
+				return fmt.Errorf("could not delete recorded tickers at key %q: %w", k, err)
+			}
+		}
+		return nil
+	}
+	return kv.WithReadWriter(ctx, ds.db, deleter)
+}
+
+// ScanTickers runs the callback with the recorded tickers of a product in the
+// order of their timestamps between the `begin` and `end` timestamps. Zero
+// `begin` and `end` timestamps refer to the first and last recorded tickers.
+func (ds *Datastore) ScanTickers(ctx context.Context, productID string, begin, end time.Time, fn func(*exchange.Ticker) error) error {
+	if len(productID) == 0 {
+		return fmt.Errorf("product id cannot be empty")
+	}
+
+	minKey := path.Join(Keyspace, "tickers", "0000-00-00/00/00")
+	if !begin.IsZero() {
+		minKey = tickersKey(begin)
+	}
+	maxKey := path.Join(Keyspace, "tickers", "9999-99-99/99/99")
+	if !end.IsZero() {
+		// Include the key for the end timestamp's minute.
+		maxKey = tickersKey(end) + "\x00"
+	}
+
+	scanner := func(ctx context.Context, r kv.Reader, k string, v *gobs.CoinbaseTickers) error {
+		list, ok := v.ProductTickersMap[productID]
+		if !ok {
+			return nil
+		}
+		for i, nanos := range list.UnixNanos {
+			if !begin.IsZero() && nanos < begin.UnixNano() {
+				continue
+			}
+			if !end.IsZero() && nanos >= end.UnixNano() {
+				break
+			}
+			ticker := &exchange.Ticker{
+				Timestamp: exchange.RemoteTime{Time: time.Unix(0, nanos)},
+				Price:     list.Prices[i],
+			}
+			if err := fn(ticker); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return kvutil.AscendDB[gobs.CoinbaseTickers](ctx, ds.db, minKey, maxKey, scanner)
+}
+
+// ReplayTickers returns a channel that receives the recorded tickers of a
+// product between the `begin` and `end` timestamps in the same way as the
+// exchange.Product.TickerCh method. Zero `end` timestamp refers to the
+// current time. Channel is closed after all tickers are sent or when the
+// returned stop function is called.
+func (ds *Datastore) ReplayTickers(ctx context.Context, productID string, begin, end time.Time) (<-chan *exchange.Ticker, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	ch := make(chan *exchange.Ticker)
+	done := make(chan struct{})
+
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	go func() {
+		defer close(done)
+		defer close(ch)
+
+		if begin.IsZero() {
+			log.Printf("could not replay tickers for %s: begin timestamp cannot be zero", productID)
+			return
+		}
+
+		// Tickers are loaded one hour at a time, so that database transactions
+		// are not held open while the receiver is processing the tickers.
+		for from := begin; from.Before(end); from = from.Add(time.Hour) {
+			to := from.Add(time.Hour)
+			if end.Before(to) {
+				to = end
+			}
+			var tickers []*exchange.Ticker
+			collect := func(t *exchange.Ticker) error {
+				tickers = append(tickers, t)
+				return nil
+			}
+			if err := ds.ScanTickers(ctx, productID, from, to, collect); err != nil {
+				if ctx.Err() == nil {
+					log.Printf("could not scan recorded tickers for %s: %v", productID, err)
+				}
+				return
+			}
+			for _, t := range tickers {
+				select {
+				case <-ctx.Done():
+					return
+				case ch <- t:
+				}
+			}
+		}
+	}()
+
+	stopf := func() {
+		cancel()
+		<-done
+	}
+	return ch, stopf
+}
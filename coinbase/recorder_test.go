@@ -0,0 +1,115 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/coinbase/coinbasetest"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/shopspring/decimal"
+)
+
+func TestTickerRecorder(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+
+	opts := newTestOptions(srv)
+	opts.RecordTickers = true
+	opts.RecordTickersInterval = 20 * time.Millisecond
+
+	ex := newTestExchangeWithOptions(ctx, t, srv, "test-key", "test-secret", opts)
+	defer ex.Close()
+
+	waitFor := func(desc string, cond func() bool) {
+		timeoutCh := time.After(5 * time.Second)
+		for !cond() {
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-timeoutCh:
+				t.Fatalf("timed out waiting for %s", desc)
+			}
+		}
+	}
+
+	// Watched products must be recorded even when they are not opened.
+	waitFor("ticker subscription", func() bool {
+		return srv.NumSubscribers("ticker", "BCH-USD") == 1
+	})
+
+	// Closing the product must not stop the recording.
+	p, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	begin := time.Now().Add(-time.Second)
+	for i := int64(101); i <= 105; i++ {
+		if err := srv.Tick("BCH-USD", decimal.NewFromInt(i)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var recorded []*exchange.Ticker
+	waitFor("recorded tickers", func() bool {
+		recorded = recorded[:0]
+		if err := ex.datastore.ScanTickers(ctx, "BCH-USD", begin, time.Time{}, func(v *exchange.Ticker) error {
+			if v.Price.GreaterThan(decimal.NewFromInt(100)) {
+				recorded = append(recorded, v)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return len(recorded) == 5
+	})
+	for i, v := range recorded {
+		if want := decimal.NewFromInt(int64(101 + i)); !v.Price.Equal(want) {
+			t.Fatalf("ticker %d: want price %s, got %s", i, want, v.Price)
+		}
+	}
+
+	// Replay must return the same tickers in the same order.
+	ch, stopf := ex.datastore.ReplayTickers(ctx, "BCH-USD", begin, time.Now().Add(time.Second))
+	defer stopf()
+
+	var replayed []*exchange.Ticker
+	for v := range ch {
+		if v.Price.GreaterThan(decimal.NewFromInt(100)) {
+			replayed = append(replayed, v)
+		}
+	}
+	if len(replayed) != len(recorded) {
+		t.Fatalf("want %d replayed tickers, got %d", len(recorded), len(replayed))
+	}
+	for i := range replayed {
+		if !replayed[i].Timestamp.Equal(recorded[i].Timestamp.Time) || !replayed[i].Price.Equal(recorded[i].Price) {
+			t.Fatalf("replayed ticker %d doesn't match the recorded ticker", i)
+		}
+	}
+
+	// Tickers older than the retention period must be deleted.
+	if err := ex.datastore.deleteTickers(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	if err := ex.datastore.ScanTickers(ctx, "BCH-USD", time.Time{}, time.Time{}, func(v *exchange.Ticker) error {
+		n++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("want no tickers after the retention cleanup, got %d", n)
+	}
+}
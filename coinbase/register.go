@@ -0,0 +1,117 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvkgo/kv"
+)
+
+func init() {
+	exchange.Register("coinbase", newFromRegistry)
+}
+
+// newFromRegistry creates the coinbase exchange for the exchange registry.
+// Exchange specific options, when given, must be of *Options type; exchange
+// parameters and the exchange independent options are used for the fields
+// that are not set in them.
+func newFromRegistry(ctx context.Context, db kv.Database, creds *exchange.Credentials, opts *exchange.Options) (exchange.Exchange, error) {
+	cbopts := new(Options)
+	if opts.ExchangeOptions != nil {
+		v, ok := opts.ExchangeOptions.(*Options)
+		if !ok {
+			return nil, fmt.Errorf("unexpected coinbase options type %T: %w", opts.ExchangeOptions, os.ErrInvalid)
+		}
+		*cbopts = *v
+	}
+	if err := parseParams(cbopts, opts.DataDir, opts.Params); err != nil {
+		return nil, err
+	}
+	if cbopts.HttpClientTimeout == 0 {
+		cbopts.HttpClientTimeout = opts.HttpClientTimeout
+	}
+	if cbopts.MaxFetchTimeLatency == 0 {
+		cbopts.MaxFetchTimeLatency = time.Second
+	}
+	if cbopts.Messenger == nil {
+		cbopts.Messenger = opts.Messenger
+	}
+	if len(cbopts.QuoteCurrencies) == 0 {
+		cbopts.QuoteCurrencies = opts.QuoteCurrencies
+	}
+	return New(ctx, db, creds.Key, creds.Secret, cbopts)
+}
+
+// parseParams updates the options that are not already set with the exchange
+// parameters. Parameter names are same as the corresponding flag names of the
+// "run" command.
+func parseParams(cbopts *Options, dataDir string, params map[string]string) error {
+	for key, value := range params {
+		var err error
+		switch key {
+		case "max-fetch-time-latency":
+			if cbopts.MaxFetchTimeLatency == 0 {
+				cbopts.MaxFetchTimeLatency, err = time.ParseDuration(value)
+			}
+		case "price-aliases":
+			if len(cbopts.PriceAliases) == 0 {
+				cbopts.PriceAliases, err = parsePriceAliases(value)
+			}
+		case "no-fetch-candles":
+			var v bool
+			if v, err = strconv.ParseBool(value); err == nil && v && cbopts.FetchCandlesInterval == 0 {
+				cbopts.FetchCandlesInterval = -1
+			}
+		case "record-tickers":
+			var v bool
+			if v, err = strconv.ParseBool(value); err == nil && v {
+				cbopts.RecordTickers = true
+			}
+		case "ticker-retention":
+			if cbopts.TickerRetention == 0 {
+				cbopts.TickerRetention, err = time.ParseDuration(value)
+			}
+		case "websocket-log-dir":
+			if cbopts.WebsocketLogDir == "" && value != "" {
+				if !filepath.IsAbs(value) && dataDir != "" {
+					value = filepath.Join(dataDir, value)
+				}
+				cbopts.WebsocketLogDir = value
+			}
+		case "cancelled-order-retention":
+			if cbopts.CancelledOrderRetention == 0 {
+				cbopts.CancelledOrderRetention, err = time.ParseDuration(value)
+			}
+		default:
+			return fmt.Errorf("unsupported coinbase parameter %q: %w", key, os.ErrInvalid)
+		}
+		if err != nil {
+			return fmt.Errorf("could not parse coinbase parameter %q value %q: %w", key, value, err)
+		}
+	}
+	return nil
+}
+
+// parsePriceAliases parses a comma separated list of product=source pairs.
+func parsePriceAliases(value string) (map[string]string, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	aliases := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		product, source, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("price alias %q must be in product=source form: %w", pair, os.ErrInvalid)
+		}
+		aliases[strings.TrimSpace(product)] = strings.TrimSpace(source)
+	}
+	return aliases, nil
+}
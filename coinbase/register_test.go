@@ -0,0 +1,58 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseParams(t *testing.T) {
+	params := map[string]string{
+		"max-fetch-time-latency":    "2s",
+		"price-aliases":             "BCH-USDC=BCH-USD, ETH-USDC = ETH-USD",
+		"no-fetch-candles":          "true",
+		"record-tickers":            "true",
+		"ticker-retention":          "24h",
+		"websocket-log-dir":         "wslogs",
+		"cancelled-order-retention": "-1s",
+	}
+	opts := new(Options)
+	if err := parseParams(opts, "/data", params); err != nil {
+		t.Fatal(err)
+	}
+	if opts.MaxFetchTimeLatency != 2*time.Second {
+		t.Fatalf("want 2s max fetch time latency, got %s", opts.MaxFetchTimeLatency)
+	}
+	if len(opts.PriceAliases) != 2 || opts.PriceAliases["ETH-USDC"] != "ETH-USD" {
+		t.Fatalf("unexpected price aliases %v", opts.PriceAliases)
+	}
+	if opts.FetchCandlesInterval >= 0 || !opts.RecordTickers || opts.TickerRetention != 24*time.Hour || opts.CancelledOrderRetention >= 0 {
+		t.Fatalf("unexpected options %#v", opts)
+	}
+	if want := filepath.Join("/data", "wslogs"); opts.WebsocketLogDir != want {
+		t.Fatalf("want websocket log dir %q, got %q", want, opts.WebsocketLogDir)
+	}
+
+	// Exchange specific options take precedence over the parameters.
+	opts = &Options{TickerRetention: time.Hour, WebsocketLogDir: "/logs"}
+	if err := parseParams(opts, "/data", params); err != nil {
+		t.Fatal(err)
+	}
+	if opts.TickerRetention != time.Hour || opts.WebsocketLogDir != "/logs" {
+		t.Fatalf("exchange options must not be overridden by the parameters: %#v", opts)
+	}
+
+	if err := parseParams(new(Options), "", map[string]string{"no-such-param": "1"}); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("unknown parameter: want os.ErrInvalid, got %v", err)
+	}
+	if err := parseParams(new(Options), "", map[string]string{"price-aliases": "BCH-USDC"}); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("bad price alias: want os.ErrInvalid, got %v", err)
+	}
+	if err := parseParams(new(Options), "", map[string]string{"ticker-retention": "1 day"}); err == nil {
+		t.Fatalf("bad duration: want an error")
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/bvk/tradebot/coinbase/internal"
+	"github.com/bvk/tradebot/ctxutil"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/kvutil"
+	"github.com/bvkgo/kv"
+)
+
+// saveCancelledOrders saves the completed orders with zero filled size. Orders
+// are saved in one key per hour layout similar to the filled orders, but the
+// date and hour are picked from the order creation timestamp. Cancelled
+// orders are removed by the CompactCancelled method after the retention
+// period.
+func (ds *Datastore) saveCancelledOrders(ctx context.Context, orders []*internal.Order) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	kmap := make(map[string][]*internal.Order)
+	for _, v := range orders {
+		if !v.FilledSize.Decimal.IsZero() {
+			return fmt.Errorf("filled size of a cancelled order must be zero")
+		}
+		createdAt := v.CreatedTime.Time
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+		key := path.Join(Keyspace, "cancelled", createdAt.UTC().Format("2006-01-02/15"))
+		kmap[key] = append(kmap[key], v)
+	}
+
+	saver := func(ctx context.Context, rw kv.ReadWriter) error {
+		for key, orders := range kmap {
+			value, err := kvutil.Get[gobs.CoinbaseOrderIDs](ctx, rw, key)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					return fmt.Errorf("could not load coinbase orders at %q: %w", key, err)
+				}
+				value = &gobs.CoinbaseOrderIDs{
+					ProductOrderIDsMap: make(map[string][]string),
+				}
+			}
+			for _, v := range orders {
+				if err := ds.saveOrderLocked(ctx, rw, v); err != nil {
+					return err
+				}
+				ids := append(value.ProductOrderIDsMap[v.ProductID], v.OrderID)
+				sort.Strings(ids)
+				value.ProductOrderIDsMap[v.ProductID] = slices.Compact(ids)
+			}
+			if err := kvutil.Set(ctx, rw, key, value); err != nil {
+				return fmt.Errorf("could not update coinbase orders at %q: %w", key, err)
+			}
+		}
+		return nil
+	}
+	return kv.WithReadWriter(ctx, ds.db, saver)
+}
+
+// OrderByClientID returns the saved order with the given client-order-id.
+func (ds *Datastore) OrderByClientID(ctx context.Context, clientOrderID string) (*internal.Order, error) {
+	var order *internal.Order
+	load := func(ctx context.Context, r kv.Reader) error {
+		key := path.Join(Keyspace, "client-order-ids", clientOrderID)
+		orderID, err := kvutil.GetString[string](ctx, r, key)
+		if err != nil {
+			return fmt.Errorf("could not load order id for client order id %q: %w", clientOrderID, err)
+		}
+		v, err := ds.loadOrderLocked(ctx, r, orderID)
+		if err != nil {
+			return err
+		}
+		order = v
+		return nil
+	}
+	if err := kv.WithReader(ctx, ds.db, load); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// indexClientOrderIDs adds the client-order-id index entries for the orders
+// saved before the index was introduced. Index is built only once and a
+// marker key is saved to skip the scan on later calls. Returns the number of
+// index entries added.
+func (ds *Datastore) indexClientOrderIDs(ctx context.Context) (int, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	marker := path.Join(Keyspace, "client-order-ids-indexed")
+
+	indexed := false
+	cidMap := make(map[string]string)
+	scanner := func(ctx context.Context, r kv.Reader, key string, value *gobs.CoinbaseOrder) error {
+		order := new(internal.Order)
+		if err := json.Unmarshal([]byte(value.Order), order); err != nil {
+			return fmt.Errorf("could not json-unmarshal coinbase order at %q: %w", key, err)
+		}
+		if len(order.ClientOrderID) > 0 {
+			cidMap[order.ClientOrderID] = order.OrderID
+		}
+		return nil
+	}
+	loader := func(ctx context.Context, r kv.Reader) error {
+		if _, err := kvutil.GetString[string](ctx, r, marker); err == nil {
+			indexed = true
+			return nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("could not load client order id index marker: %w", err)
+		}
+		begin, end := kvutil.PathRange(path.Join(Keyspace, "orders"))
+		if err := kvutil.Ascend(ctx, r, begin, end, scanner); err != nil {
+			return fmt.Errorf("could not scan coinbase orders: %w", err)
+		}
+		return nil
+	}
+	if err := kv.WithReader(ctx, ds.db, loader); err != nil {
+		return 0, err
+	}
+	if indexed {
+		return 0, nil
+	}
+
+	nadded := 0
+	indexer := func(ctx context.Context, rw kv.ReadWriter) error {
+		for cid, id := range cidMap {
+			key := path.Join(Keyspace, "client-order-ids", cid)
+			if _, err := kvutil.GetString[string](ctx, rw, key); err == nil {
+				continue
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("could not load client order id at %q: %w", key, err)
+			}
+			if err := kvutil.SetString(ctx, rw, key, id); err != nil {
+				return fmt.Errorf("could not save client order id at %q: %w", key, err)
+			}
+			nadded++
+		}
+		if err := kvutil.SetString(ctx, rw, marker, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return fmt.Errorf("could not save client order id index marker: %w", err)
+		}
+		return nil
+	}
+	if err := kv.WithReadWriter(ctx, ds.db, indexer); err != nil {
+		return 0, err
+	}
+	return nadded, nil
+}
+
+// CompactCancelled removes the cancelled orders with zero filled size that
+// are created before the `before` timestamp. Returns the number of orders
+// removed.
+func (ds *Datastore) CompactCancelled(ctx context.Context, before time.Time) (int, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	minKey := path.Join(Keyspace, "cancelled", "0000-00-00/00")
+	maxKey := path.Join(Keyspace, "cancelled", before.UTC().Format("2006-01-02/15"))
+
+	norders := 0
+	compactor := func(ctx context.Context, rw kv.ReadWriter) error {
+		it, err := rw.Ascend(ctx, minKey, maxKey)
+		if err != nil {
+			return err
+		}
+		defer kv.Close(it)
+
+		var keys []string
+		for k, _, err := it.Fetch(ctx, false); err == nil; k, _, err = it.Fetch(ctx, true) {
+			keys = append(keys, k)
+		}
+		if _, _, err := it.Fetch(ctx, false); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("could not scan cancelled orders: %w", err)
+		}
+
+		for _, key := range keys {
+			value, err := kvutil.Get[gobs.CoinbaseOrderIDs](ctx, rw, key)
+			if err != nil {
+				return fmt.Errorf("could not load cancelled orders at %q: %w", key, err)
+			}
+			for _, ids := range value.ProductOrderIDsMap {
+				for _, id := range ids {
+					if err := ds.deleteOrderLocked(ctx, rw, id); err != nil {
+						return err
+					}
+					norders++
+				}
+			}
+			if err := rw.Delete(ctx, key); err != nil {
+				return fmt.Errorf("could not delete cancelled orders at %q: %w", key, err)
+			}
+		}
+		return nil
+	}
+	if err := kv.WithReadWriter(ctx, ds.db, compactor); err != nil {
+		return 0, err
+	}
+	return norders, nil
+}
+
+func (ds *Datastore) deleteOrderLocked(ctx context.Context, rw kv.ReadWriter, orderID string) error {
+	order, err := ds.loadOrderLocked(ctx, rw, orderID)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if len(order.ClientOrderID) > 0 {
+		key := path.Join(Keyspace, "client-order-ids", order.ClientOrderID)
+		if err := rw.Delete(ctx, key); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("could not delete client order id at %q: %w", key, err)
+		}
+	}
+	key := path.Join(Keyspace, "orders", orderID)
+	if err := rw.Delete(ctx, key); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not delete coinbase order at %q: %w", key, err)
+	}
+	return nil
+}
+
+// addCancelled queues a completed order with zero filled size to be saved in
+// the datastore by the background tasks. Websocket order events do not carry
+// all order fields, so the full order is fetched from the exchange at save
+// time unless it is already known through the setCancelled method.
+func (ex *Exchange) addCancelled(orderID exchange.OrderID) {
+	ex.cancelledMu.Lock()
+	defer ex.cancelledMu.Unlock()
+
+	if ex.cancelled == nil {
+		ex.cancelled = make(map[string]*internal.Order)
+	}
+	if _, ok := ex.cancelled[string(orderID)]; !ok {
+		ex.cancelled[string(orderID)] = nil
+	}
+}
+
+// setCancelled records the full order for a queued cancelled order, so that
+// it need not be fetched again from the exchange.
+func (ex *Exchange) setCancelled(order *internal.Order) {
+	ex.cancelledMu.Lock()
+	defer ex.cancelledMu.Unlock()
+
+	if _, ok := ex.cancelled[order.OrderID]; ok {
+		ex.cancelled[order.OrderID] = order
+	}
+}
+
+// saveCancelled saves the queued cancelled orders in the datastore.
+func (ex *Exchange) saveCancelled(ctx context.Context) error {
+	ex.cancelledMu.Lock()
+	queued := ex.cancelled
+	ex.cancelled = nil
+	ex.cancelledMu.Unlock()
+
+	if len(queued) == 0 {
+		return nil
+	}
+
+	requeue := func() {
+		ex.cancelledMu.Lock()
+		defer ex.cancelledMu.Unlock()
+
+		if ex.cancelled == nil {
+			ex.cancelled = make(map[string]*internal.Order)
+		}
+		for id, v := range queued {
+			if old, ok := ex.cancelled[id]; !ok || old == nil {
+				ex.cancelled[id] = v
+			}
+		}
+	}
+
+	var orders []*internal.Order
+	for id, v := range queued {
+		if v == nil {
+			resp, err := ex.client.GetOrder(ctx, id)
+			if err != nil {
+				requeue()
+				return fmt.Errorf("could not fetch cancelled order %s: %w", id, err)
+			}
+			v = resp.Order
+			queued[id] = v
+		}
+		// Orders with non-zero filled size are saved along with the filled
+		// orders.
+		if !v.FilledSize.Decimal.IsZero() {
+			continue
+		}
+		orders = append(orders, v)
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+	if err := ex.datastore.saveCancelledOrders(ctx, orders); err != nil {
+		requeue()
+		return err
+	}
+	return nil
+}
+
+// goCompactCancelled periodically removes the cancelled orders older than the
+// retention period from the datastore.
+func (ex *Exchange) goCompactCancelled(ctx context.Context) {
+	retention := ex.opts.CancelledOrderRetention
+	if retention < 0 {
+		return
+	}
+
+	for ctxutil.Sleep(ctx, time.Hour); ctx.Err() == nil; ctxutil.Sleep(ctx, time.Hour) {
+		n, err := ex.datastore.CompactCancelled(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("could not compact cancelled orders (will retry): %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("removed %d cancelled orders older than %s from the datastore", n, retention)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/coinbase/coinbasetest"
+	"github.com/bvk/tradebot/coinbase/internal"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/kvutil"
+	"github.com/bvkgo/kv"
+	"github.com/bvkgo/kv/kvmemdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestCancelledOrderRetention(t *testing.T) {
+	ctx := context.Background()
+	ds := NewDatastore(kvmemdb.New())
+
+	now := time.Now()
+	cancelled := func(id string, createdAt time.Time) *internal.Order {
+		return &internal.Order{
+			Status:        "CANCELLED",
+			OrderID:       id,
+			ClientOrderID: "client-" + id,
+			ProductID:     "BCH-USD",
+			CreatedTime:   exchange.RemoteTime{Time: createdAt},
+		}
+	}
+	orders := []*internal.Order{
+		cancelled("old-1", now.Add(-10*24*time.Hour)),
+		cancelled("old-2", now.Add(-8*24*time.Hour)),
+		cancelled("new-1", now.Add(-time.Hour)),
+	}
+	if err := ds.saveCancelledOrders(ctx, orders); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range orders {
+		order, err := ds.OrderByClientID(ctx, v.ClientOrderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.OrderID != v.OrderID {
+			t.Fatalf("want order %s for client id %s, got %s", v.OrderID, v.ClientOrderID, order.OrderID)
+		}
+	}
+
+	n, err := ds.CompactCancelled(ctx, now.Add(-7*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want 2 orders removed, got %d", n)
+	}
+	for _, id := range []string{"old-1", "old-2"} {
+		if _, err := ds.OrderByClientID(ctx, "client-"+id); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("want os.ErrNotExist for removed order %s, got %v", id, err)
+		}
+		if _, err := ds.GetOrder(ctx, id); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("want os.ErrNotExist for removed order %s, got %v", id, err)
+		}
+	}
+	if _, err := ds.OrderByClientID(ctx, "client-new-1"); err != nil {
+		t.Fatalf("want recent order to be retained: %v", err)
+	}
+}
+
+func TestRecreateOldOrderFallback(t *testing.T) {
+	ctx := context.Background()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+	srv.SetBalance("USD", decimal.NewFromInt(10000))
+
+	opts := newTestOptions(srv)
+	opts.MaxCachedOrders = 1
+	ex := newTestExchangeWithOptions(ctx, t, srv, "test-key", "test-secret", opts)
+	defer ex.Close()
+
+	p, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	orderCh, orderStop := p.OrderUpdatesCh()
+	defer orderStop()
+
+	size, price := decimal.NewFromInt(1), decimal.NewFromInt(90)
+	for _, cid := range []string{"client-1", "client-2"} {
+		id, err := p.LimitBuy(ctx, cid, size, price, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Cancel(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		waitForStatus(t, orderCh, id, "CANCELLED")
+	}
+	if err := ex.saveCancelled(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Second order must have evicted the first order from the cache.
+	if _, ok := ex.clientOrderIDMap.Load("client-1"); ok {
+		t.Fatalf("want client-1 to be evicted from the cache")
+	}
+
+	// Saved order must be the full order from the exchange, not the partial
+	// order from the websocket events.
+	saved, err := ex.datastore.OrderByClientID(ctx, "client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.ProductID != "BCH-USD" || saved.OrderType != "LIMIT" {
+		t.Fatalf("want full order in the datastore, got product %q type %q", saved.ProductID, saved.OrderType)
+	}
+
+	old, ok := ex.recreateOldOrder(ctx, "client-1")
+	if !ok {
+		t.Fatalf("want old order to be found in the datastore")
+	}
+	if old.OrderID != exchange.OrderID(saved.OrderID) || !old.Done {
+		t.Fatalf("want old order %s in done state, got %s (done=%v)", saved.OrderID, old.OrderID, old.Done)
+	}
+	if _, ok := ex.recreateOldOrder(ctx, "client-3"); ok {
+		t.Fatalf("want unknown client order id to be not found")
+	}
+}
+
+func TestIndexClientOrderIDs(t *testing.T) {
+	ctx := context.Background()
+	db := kvmemdb.New()
+	ds := NewDatastore(db)
+
+	// Save an order without the client-order-id index like the older versions.
+	js, err := json.Marshal(&internal.Order{OrderID: "order-1", ClientOrderID: "client-1", Status: "FILLED"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saver := func(ctx context.Context, rw kv.ReadWriter) error {
+		value := &gobs.CoinbaseOrder{OrderID: "order-1", Order: json.RawMessage(js)}
+		return kvutil.Set(ctx, rw, path.Join(Keyspace, "orders", "order-1"), value)
+	}
+	if err := kv.WithReadWriter(ctx, db, saver); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.OrderByClientID(ctx, "client-1"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want os.ErrNotExist before the index is built, got %v", err)
+	}
+
+	n, err := ds.indexClientOrderIDs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("want 1 index entry added, got %d", n)
+	}
+	order, err := ds.OrderByClientID(ctx, "client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.OrderID != "order-1" {
+		t.Fatalf("want order-1 for client-1, got %s", order.OrderID)
+	}
+
+	if n, err := ds.indexClientOrderIDs(ctx); err != nil || n != 0 {
+		t.Fatalf("want index to be built only once, got %d (err %v)", n, err)
+	}
+}
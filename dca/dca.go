@@ -0,0 +1,311 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package dca
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/idgen"
+	"github.com/bvk/tradebot/kvutil"
+	"github.com/bvk/tradebot/syncmap"
+	"github.com/bvk/tradebot/trader"
+	"github.com/bvkgo/kv"
+	"github.com/shopspring/decimal"
+)
+
+const DefaultKeyspace = "/dcas/"
+
+// DCA is a dollar-cost-averaging trader that buys a fixed value or a fixed
+// size of a product with a market order every interval.
+type DCA struct {
+	runtimeLock sync.Mutex
+
+	productID    string
+	exchangeName string
+
+	uid string
+
+	interval time.Duration
+
+	idgen *idgen.Generator
+
+	// lastBuyTime is the local time when the last buy order was created. Next
+	// buy order is due after the interval from this time.
+	lastBuyTime time.Time
+
+	// orderMap holds all orders created by the job. It is used by Run and Save
+	// methods, so it needs to be thread-safe.
+	orderMap syncmap.Map[exchange.OrderID, *exchange.Order]
+
+	optionMap map[string]string
+
+	// holdOpt when true, pauses the buys by this job.
+	holdOpt atomic.Bool
+
+	// amountOpt and sizeOpt hold the value in quote currency or the size in
+	// base currency to buy every interval. Only one of them is non-zero.
+	amountOpt atomic.Pointer[decimal.Decimal]
+	sizeOpt   atomic.Pointer[decimal.Decimal]
+
+	// ceilingOpt when non-zero, holds the price at or above which the due buys
+	// are deferred.
+	ceilingOpt atomic.Pointer[decimal.Decimal]
+}
+
+var _ trader.Trader = &DCA{}
+
+// New creates a job that buys the given amount in quote currency or the given
+// size in base currency every interval. When ceiling is non-zero, a due buy
+// is deferred while the ticker price is at or above the ceiling price.
+func New(uid, exchangeName, productID string, amount, size decimal.Decimal, interval time.Duration, ceiling decimal.Decimal) (*DCA, error) {
+	v := &DCA{
+		productID:    productID,
+		exchangeName: exchangeName,
+		uid:          uid,
+		interval:     interval,
+		idgen:        idgen.New(uid, 0),
+		optionMap:    make(map[string]string),
+	}
+	v.amountOpt.Store(&amount)
+	v.sizeOpt.Store(&size)
+	v.ceilingOpt.Store(&ceiling)
+	if err := v.check(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *DCA) check() error {
+	if len(v.uid) == 0 {
+		return fmt.Errorf("dca uid is empty")
+	}
+	if v.interval <= 0 {
+		return fmt.Errorf("buy interval must be positive")
+	}
+	amount, size := v.amount(), v.size()
+	if amount.IsNegative() || size.IsNegative() {
+		return fmt.Errorf("buy amount or size cannot be negative")
+	}
+	if amount.IsZero() == size.IsZero() {
+		return fmt.Errorf("exactly one of buy amount or size must be set")
+	}
+	if v.ceiling().IsNegative() {
+		return fmt.Errorf("ceiling price cannot be negative")
+	}
+	return nil
+}
+
+func (v *DCA) String() string {
+	return "dca:" + v.uid
+}
+
+func (v *DCA) UID() string {
+	return v.uid
+}
+
+func (v *DCA) ProductID() string {
+	return v.productID
+}
+
+func (v *DCA) ExchangeName() string {
+	return v.exchangeName
+}
+
+func (v *DCA) Interval() time.Duration {
+	return v.interval
+}
+
+func (v *DCA) Amount() decimal.Decimal {
+	return v.amount()
+}
+
+func (v *DCA) Size() decimal.Decimal {
+	return v.size()
+}
+
+func (v *DCA) Ceiling() decimal.Decimal {
+	return v.ceiling()
+}
+
+func (v *DCA) amount() decimal.Decimal {
+	return *v.amountOpt.Load()
+}
+
+func (v *DCA) size() decimal.Decimal {
+	return *v.sizeOpt.Load()
+}
+
+func (v *DCA) ceiling() decimal.Decimal {
+	return *v.ceilingOpt.Load()
+}
+
+// BudgetAt returns the value required for a single buy. Value for the
+// size-based buys is estimated with the ceiling price or the average price of
+// the past buys.
+func (v *DCA) BudgetAt(feePct float64) decimal.Decimal {
+	value := v.amount()
+	if value.IsZero() {
+		price := v.ceiling()
+		if price.IsZero() {
+			price = v.AvgPrice()
+		}
+		value = v.size().Mul(price)
+	}
+	return value.Add(value.Mul(decimal.NewFromFloat(feePct).Div(decimal.NewFromInt(100))))
+}
+
+func (v *DCA) dupOrderMap() map[exchange.OrderID]*exchange.Order {
+	dup := make(map[exchange.OrderID]*exchange.Order)
+	v.orderMap.Range(func(id exchange.OrderID, order *exchange.Order) bool {
+		dup[id] = order
+		return true
+	})
+	return dup
+}
+
+func (v *DCA) updateOrderMap(order *exchange.Order) {
+	if _, ok := v.orderMap.Load(order.OrderID); ok {
+		v.orderMap.Store(order.OrderID, order)
+	}
+}
+
+func (v *DCA) Fees() decimal.Decimal {
+	var sum decimal.Decimal
+	for _, order := range v.dupOrderMap() {
+		sum = sum.Add(order.Fee)
+	}
+	return sum
+}
+
+func (v *DCA) FilledSize() decimal.Decimal {
+	var filled decimal.Decimal
+	for _, order := range v.dupOrderMap() {
+		filled = filled.Add(order.FilledSize)
+	}
+	return filled
+}
+
+func (v *DCA) FilledValue() decimal.Decimal {
+	var value decimal.Decimal
+	for _, order := range v.dupOrderMap() {
+		value = value.Add(order.FilledSize.Mul(order.FilledPrice))
+	}
+	return value
+}
+
+// AvgPrice returns the average price of all buys. It returns zero if nothing
+// is bought yet.
+func (v *DCA) AvgPrice() decimal.Decimal {
+	size := v.FilledSize()
+	if size.IsZero() {
+		return decimal.Zero
+	}
+	return v.FilledValue().Div(size)
+}
+
+// Actions returns one buy action for every filled order.
+func (v *DCA) Actions() []*gobs.Action {
+	var actions []*gobs.Action
+	for _, order := range v.dupOrderMap() {
+		if !order.Done || order.FilledSize.IsZero() {
+			continue
+		}
+		gorder := exchange.GobOrder(order)
+		action := &gobs.Action{
+			UID:        v.uid,
+			PairingKey: v.uid,
+			Point: gobs.Point{
+				Size:  order.FilledSize,
+				Price: order.FilledPrice,
+			},
+			Orders: []*gobs.Order{gorder},
+		}
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Orders[0].CreateTime.Time.Before(actions[j].Orders[0].CreateTime.Time)
+	})
+	if len(actions) == 0 {
+		return nil
+	}
+	return actions
+}
+
+func (v *DCA) Save(ctx context.Context, rw kv.ReadWriter) error {
+	gv := &gobs.DCAState{
+		V1: &gobs.DCAStateV1{
+			ProductID:        v.productID,
+			ExchangeName:     v.exchangeName,
+			ClientIDSeed:     v.idgen.Seed(),
+			ClientIDOffset:   v.idgen.Offset(),
+			Amount:           v.amount(),
+			Size:             v.size(),
+			Interval:         v.interval,
+			Ceiling:          v.ceiling(),
+			LastBuyTime:      v.lastBuyTime,
+			ServerIDOrderMap: make(map[string]*gobs.Order),
+			Options:          v.optionMap,
+		},
+	}
+	for k, v := range v.dupOrderMap() {
+		gv.V1.ServerIDOrderMap[string(k)] = exchange.GobOrder(v)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gv); err != nil {
+		return fmt.Errorf("could not encode dca state: %w", err)
+	}
+	key := path.Join(DefaultKeyspace, v.uid)
+	if err := rw.Set(ctx, key, &buf); err != nil {
+		return fmt.Errorf("could not save dca state: %w", err)
+	}
+	return nil
+}
+
+func Load(ctx context.Context, uid string, r kv.Reader) (*DCA, error) {
+	if err := trader.CheckUID(uid); err != nil {
+		return nil, err
+	}
+	key := path.Join(DefaultKeyspace, uid)
+	gv, err := kvutil.Get[gobs.DCAState](ctx, r, key)
+	if err != nil {
+		return nil, fmt.Errorf("could not load dca state: %w", err)
+	}
+	seed := uid
+	if len(gv.V1.ClientIDSeed) > 0 {
+		seed = gv.V1.ClientIDSeed
+	}
+	v := &DCA{
+		uid:          uid,
+		productID:    gv.V1.ProductID,
+		exchangeName: gv.V1.ExchangeName,
+		interval:     gv.V1.Interval,
+		idgen:        idgen.New(seed, gv.V1.ClientIDOffset),
+		lastBuyTime:  gv.V1.LastBuyTime,
+		optionMap:    make(map[string]string),
+	}
+	v.amountOpt.Store(&gv.V1.Amount)
+	v.sizeOpt.Store(&gv.V1.Size)
+	v.ceilingOpt.Store(&gv.V1.Ceiling)
+	for kk, vv := range gv.V1.ServerIDOrderMap {
+		v.orderMap.Store(exchange.OrderID(kk), exchange.OrderFromGob(vv))
+	}
+	if err := v.check(); err != nil {
+		return nil, err
+	}
+	for opt, val := range gv.V1.Options {
+		if err := v.SetOption(opt, val); err != nil {
+			return nil, fmt.Errorf("could not set options: %v", err)
+		}
+	}
+	return v, nil
+}
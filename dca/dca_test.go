@@ -0,0 +1,129 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package dca
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/clock"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/paper"
+	"github.com/bvk/tradebot/trader"
+	"github.com/bvkgo/kv"
+	"github.com/bvkgo/kv/kvmemdb"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestDCA(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ex := paper.New(nil, &paper.Options{SyncTickers: true})
+	defer ex.Close()
+
+	product, err := ex.AddProduct(&gobs.Product{
+		ProductID:     "BCH-USD",
+		BaseIncrement: decimal.RequireFromString("0.001"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uid := uuid.New().String()
+	amount, ceiling := decimal.NewFromInt(100), decimal.NewFromInt(95)
+	buyer, err := New(uid, "coinbase", "BCH-USD", amount, decimal.Zero, time.Hour, ceiling)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := kvmemdb.New()
+	now := time.Now()
+	sim := clock.NewSimulated(now)
+	rt := &trader.Runtime{
+		Database: db,
+		Product:  product,
+		Clock:    sim,
+	}
+
+	runCtx, runCancel := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- buyer.Run(runCtx, rt)
+	}()
+
+	tick := func(price int64) {
+		product.HandleTicker(&exchange.Ticker{
+			Timestamp: exchange.RemoteTime{Time: sim.Now()},
+			Price:     decimal.NewFromInt(price),
+		})
+	}
+	waitForBuys := func(n int) {
+		for len(buyer.Actions()) < n {
+			if ctx.Err() != nil {
+				t.Fatalf("timed out waiting for %d buys", n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// First buy is due immediately, but must wait for the price to drop below
+	// the ceiling.
+	tick(100)
+	tick(100)
+	if n := len(buyer.Actions()); n != 0 {
+		t.Fatalf("want no buys above the ceiling price, got %d", n)
+	}
+	tick(80)
+	waitForBuys(1)
+
+	// Next buy must wait for the interval.
+	tick(80)
+	tick(80)
+	if n := len(buyer.Actions()); n != 1 {
+		t.Fatalf("want one buy before the interval, got %d", n)
+	}
+	sim.Advance(time.Hour)
+	tick(80)
+	waitForBuys(2)
+
+	runCancel()
+	<-errCh
+
+	if err := buyer.SetOption("amount", "50"); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.WithReadWriter(ctx, db, buyer.Save); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded *DCA
+	load := func(ctx context.Context, r kv.Reader) (err error) {
+		loaded, err = Load(ctx, uid, r)
+		return err
+	}
+	if err := kv.WithReader(ctx, db, load); err != nil {
+		t.Fatal(err)
+	}
+	actions := loaded.Actions()
+	if len(actions) != 2 {
+		t.Fatalf("want 2 buy actions, got %d", len(actions))
+	}
+	for _, a := range actions {
+		if !a.Orders[0].FilledPrice.Equal(decimal.NewFromInt(80)) {
+			t.Fatalf("want buys at price 80, got %s", a.Orders[0].FilledPrice)
+		}
+	}
+	if want := decimal.RequireFromString("2.5"); !loaded.FilledSize().Equal(want) {
+		t.Fatalf("want filled size %s, got %s", want, loaded.FilledSize())
+	}
+	if want := decimal.NewFromInt(50); !loaded.Amount().Equal(want) {
+		t.Fatalf("want amount option %s after load, got %s", want, loaded.Amount())
+	}
+	if s := loaded.Status(nil); s.NumBuys != 2 || !s.UnsoldSize.Equal(loaded.FilledSize()) {
+		t.Fatalf("unexpected status %v", s)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package dca
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+func (v *DCA) SetOption(key, value string) error {
+	optMap := map[string]func(string) error{
+		"hold":    v.setHoldOption,
+		"amount":  v.setAmountOption,
+		"size":    v.setSizeOption,
+		"ceiling": v.setCeilingOption,
+	}
+	handler, ok := optMap[key]
+	if !ok {
+		return fmt.Errorf("invalid option key %q", key)
+	}
+
+	if err := handler(value); err != nil {
+		return err
+	}
+	v.optionMap[key] = value
+	// Amount and size options replace each other.
+	switch key {
+	case "amount":
+		delete(v.optionMap, "size")
+	case "size":
+		delete(v.optionMap, "amount")
+	}
+	return nil
+}
+
+func (v *DCA) setHoldOption(arg string) error {
+	arg = strings.ToLower(arg)
+	if arg == "true" {
+		v.holdOpt.Store(true)
+		return nil
+	}
+	if arg == "false" {
+		v.holdOpt.Store(false)
+		return nil
+	}
+	return fmt.Errorf(`%v: hold option only takes a "true" or "false" value`, v.uid)
+}
+
+// setAmountOption updates the value in quote currency to buy every interval.
+// Job buys a fixed value instead of a fixed size after this option is set.
+func (v *DCA) setAmountOption(arg string) error {
+	amount, err := decimal.NewFromString(arg)
+	if err != nil {
+		return err
+	}
+	if !amount.IsPositive() {
+		return fmt.Errorf("amount value must be positive")
+	}
+	zero := decimal.Zero
+	v.amountOpt.Store(&amount)
+	v.sizeOpt.Store(&zero)
+	return nil
+}
+
+// setSizeOption updates the size in base currency to buy every interval. Job
+// buys a fixed size instead of a fixed value after this option is set.
+func (v *DCA) setSizeOption(arg string) error {
+	size, err := decimal.NewFromString(arg)
+	if err != nil {
+		return err
+	}
+	if !size.IsPositive() {
+		return fmt.Errorf("size value must be positive")
+	}
+	zero := decimal.Zero
+	v.sizeOpt.Store(&size)
+	v.amountOpt.Store(&zero)
+	return nil
+}
+
+// setCeilingOption updates the price at or above which due buys are deferred.
+// A zero value removes the ceiling.
+func (v *DCA) setCeilingOption(arg string) error {
+	ceiling, err := decimal.NewFromString(arg)
+	if err != nil {
+		return err
+	}
+	if ceiling.IsNegative() {
+		return fmt.Errorf("ceiling price cannot be -ve")
+	}
+	v.ceilingOpt.Store(&ceiling)
+	return nil
+}
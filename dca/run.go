@@ -0,0 +1,158 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package dca
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/trader"
+	"github.com/bvkgo/kv"
+)
+
+func (v *DCA) Refresh(ctx context.Context, rt *trader.Runtime) error {
+	v.runtimeLock.Lock()
+	defer v.runtimeLock.Unlock()
+
+	if _, err := v.fetchOrderMap(ctx, rt.Product); err != nil {
+		return fmt.Errorf("could not refresh dca state: %w", err)
+	}
+	return nil
+}
+
+func (v *DCA) Run(ctx context.Context, rt *trader.Runtime) error {
+	v.runtimeLock.Lock()
+	defer v.runtimeLock.Unlock()
+
+	log.Printf("%s: started dca job", v.uid)
+	if rt.Product.ProductID() != v.productID {
+		return os.ErrInvalid
+	}
+	if _, err := v.fetchOrderMap(ctx, rt.Product); err != nil {
+		log.Printf("%s: could not refresh/fetch order map: %v", v.uid, err)
+		return err
+	}
+
+	dirty := 0
+	flushCh := rt.After(time.Minute)
+
+	localCtx := context.Background()
+
+	tickerCh, stopTickers := rt.Product.TickerCh()
+	defer stopTickers()
+
+	orderUpdatesCh, stopUpdates := rt.Product.OrderUpdatesCh()
+	defer stopUpdates()
+
+	// Buy is due immediately when the job is new or when it was stopped past
+	// it's next buy time.
+	due := false
+	buyCh := rt.After(v.lastBuyTime.Add(v.interval).Sub(rt.Now()))
+
+	var lastTicker *exchange.Ticker
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+			continue
+
+		case <-flushCh:
+			if dirty > 0 {
+				if err := kv.WithReadWriter(ctx, rt.Database, v.Save); err != nil {
+					log.Printf("%s: dirty dca state could not be saved to the database (will retry): %v", v.uid, err)
+				} else {
+					dirty = 0
+				}
+			}
+			flushCh = rt.After(time.Minute)
+
+		case order := <-orderUpdatesCh:
+			if _, ok := v.orderMap.Load(order.OrderID); ok {
+				v.updateOrderMap(order)
+				dirty++
+			}
+
+		case lastTicker = <-tickerCh:
+
+		case <-buyCh:
+			due = true
+		}
+
+		if !due || v.holdOpt.Load() {
+			continue
+		}
+		// Wait for the ticker price to drop below the ceiling.
+		if c := v.ceiling(); c.IsPositive() && (lastTicker == nil || lastTicker.Price.GreaterThanOrEqual(c)) {
+			continue
+		}
+
+		due = false
+		if err := v.buy(localCtx, rt); err != nil {
+			retry := min(v.interval, time.Minute)
+			log.Printf("%s: could not create the buy order (will retry after %s): %v", v.uid, retry, err)
+			buyCh = rt.After(retry)
+			continue
+		}
+		dirty++
+		buyCh = rt.After(v.interval)
+	}
+
+	if err := kv.WithReadWriter(localCtx, rt.Database, v.Save); err != nil {
+		log.Printf("%s: dirty dca state could not be saved to the database (will retry): %v", v.uid, err)
+	}
+	return context.Cause(ctx)
+}
+
+// buy creates a market order for the buy amount or size.
+func (v *DCA) buy(ctx context.Context, rt *trader.Runtime) error {
+	offset := v.idgen.Offset()
+	clientOrderID := v.idgen.NextID()
+
+	var err error
+	var orderID exchange.OrderID
+	if amount := v.amount(); amount.IsPositive() {
+		orderID, err = rt.Product.MarketBuyFunds(ctx, clientOrderID.String(), amount)
+	} else {
+		orderID, err = rt.Product.MarketBuy(ctx, clientOrderID.String(), v.size())
+	}
+	if err != nil {
+		v.idgen.RevertID()
+		log.Printf("%s: create market order with client-order-id %s (%d reverted) has failed: %v", v.uid, clientOrderID, offset, err)
+		return err
+	}
+
+	// Order updates for market orders may be received before the order is
+	// added to the order map, so order state is fetched explicitly.
+	order := &exchange.Order{
+		OrderID:       orderID,
+		ClientOrderID: clientOrderID.String(),
+		Side:          "BUY",
+	}
+	if o, err := rt.Product.Get(ctx, orderID); err == nil {
+		order = o
+	}
+	v.orderMap.Store(orderID, order)
+	v.lastBuyTime = rt.Now()
+
+	log.Printf("%s: created a new market buy order %s with client-order-id %s (%d)", v.uid, orderID, clientOrderID, offset)
+	return nil
+}
+
+func (v *DCA) fetchOrderMap(ctx context.Context, product exchange.Product) (nupdated int, status error) {
+	for id, order := range v.dupOrderMap() {
+		if order.Done {
+			continue
+		}
+		norder, err := product.Get(ctx, id)
+		if err != nil {
+			log.Printf("%s: could not fetch order with id %s: %v", v.uid, id, err)
+			return nupdated, err
+		}
+		v.orderMap.Store(id, norder)
+		nupdated++
+	}
+	return nupdated, nil
+}
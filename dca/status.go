@@ -0,0 +1,57 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package dca
+
+import (
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/timerange"
+	"github.com/bvk/tradebot/trader"
+)
+
+// Status returns the summary of all buys in the time period. DCA jobs never
+// sell, so all bought assets are reported as unsold.
+func (v *DCA) Status(period *timerange.Range) *trader.Status {
+	actions := v.Actions()
+	if len(actions) == 0 {
+		return &trader.Status{
+			UID:          v.uid,
+			ProductID:    v.productID,
+			ExchangeName: v.exchangeName,
+			Summary: &trader.Summary{
+				Budget: v.BudgetAt(exchange.DefaultFeePct),
+			},
+		}
+	}
+
+	if period == nil || period.IsZero() {
+		period = &timerange.Range{Begin: actions[0].Orders[0].CreateTime.Time}
+	}
+
+	summary := &trader.Summary{TimePeriod: *period}
+	for _, a := range actions {
+		if !period.InRange(a.Orders[0].FinishTime.Time) {
+			continue
+		}
+		fees := exchange.FilledFee(a.Orders)
+		size := exchange.FilledSize(a.Orders)
+		value := exchange.FilledValue(a.Orders)
+
+		summary.NumBuys++
+		summary.BoughtFees = summary.BoughtFees.Add(fees)
+		summary.BoughtSize = summary.BoughtSize.Add(size)
+		summary.BoughtValue = summary.BoughtValue.Add(value)
+	}
+	summary.UnsoldFees = summary.BoughtFees
+	summary.UnsoldSize = summary.BoughtSize
+	summary.UnsoldValue = summary.BoughtValue
+
+	s := &trader.Status{
+		UID:          v.uid,
+		ProductID:    v.productID,
+		ExchangeName: v.exchangeName,
+		Summary:      summary,
+	}
+	feePct, _ := s.FeePct().Float64()
+	s.Budget = v.BudgetAt(feePct)
+	return s
+}
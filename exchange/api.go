@@ -4,7 +4,10 @@ package exchange
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/bvk/tradebot/gobs"
 	"github.com/shopspring/decimal"
@@ -12,6 +15,13 @@ import (
 
 type OrderID string
 
+// Messenger sends notification messages to the user. It is used by the
+// exchanges to report feed outages and by the traders to report their
+// progress.
+type Messenger interface {
+	SendMessage(context.Context, time.Time, string, ...interface{})
+}
+
 type Order struct {
 	OrderID OrderID
 
@@ -43,6 +53,71 @@ type Ticker struct {
 	Price     decimal.Decimal
 }
 
+// BidAsk holds the best bid and ask price levels from the order book.
+type BidAsk struct {
+	Timestamp RemoteTime
+
+	BidPrice decimal.Decimal
+	BidSize  decimal.Decimal
+
+	AskPrice decimal.Decimal
+	AskSize  decimal.Decimal
+}
+
+// Spread returns the difference between the best ask and bid prices.
+func (v *BidAsk) Spread() decimal.Decimal {
+	return v.AskPrice.Sub(v.BidPrice)
+}
+
+// DefaultFeePct is the fee percentage used when the current fee rates from
+// the exchange are not available.
+const DefaultFeePct = 0.25
+
+// ErrPostOnlyRejected is returned when a post-only limit order is rejected
+// because it would have matched immediately as a taker.
+var ErrPostOnlyRejected = errors.New("post-only order would take liquidity")
+
+// ErrEditRejected is returned when an order cannot be edited in place, in
+// which case, callers can cancel and recreate the order instead.
+var ErrEditRejected = errors.New("order cannot be edited")
+
+// LimitOptions holds optional parameters for the limit orders. A nil or zero
+// value creates a good-till-cancelled limit order.
+type LimitOptions struct {
+	// PostOnly when true, makes the exchange reject the order if it would match
+	// immediately with an existing order. It is only valid for the GTC and GTD
+	// orders.
+	PostOnly bool
+
+	// TimeInForce is one of GTC (good-till-cancelled), GTD (good-till-date),
+	// IOC (immediate-or-cancel) or FOK (fill-or-kill). Empty value is same as
+	// the GTC.
+	TimeInForce string
+
+	// EndTime is the expiry time for the GTD orders.
+	EndTime time.Time
+}
+
+func (v *LimitOptions) Check() error {
+	if v == nil {
+		return nil
+	}
+	switch v.TimeInForce {
+	case "", "GTC":
+	case "GTD":
+		if v.EndTime.IsZero() {
+			return fmt.Errorf("end time is required for GTD orders")
+		}
+	case "IOC", "FOK":
+		if v.PostOnly {
+			return fmt.Errorf("post-only is not valid for %s orders", v.TimeInForce)
+		}
+	default:
+		return fmt.Errorf("invalid time-in-force value %q", v.TimeInForce)
+	}
+	return nil
+}
+
 type Product interface {
 	io.Closer
 
@@ -50,15 +125,41 @@ type Product interface {
 	ExchangeName() string
 	BaseMinSize() decimal.Decimal
 
+	// BaseIncrement returns the smallest unit for the order sizes. Limit and
+	// market orders with sizes that are not a multiple of the base increment
+	// are rounded down.
+	BaseIncrement() decimal.Decimal
+
 	TickerCh() (ch <-chan *Ticker, stopf func())
+
+	// BestBidAsk returns the current best bid and ask from the product's order
+	// book. It returns nil if the order book is not available yet.
+	BestBidAsk() *BidAsk
 	OrderUpdatesCh() (ch <-chan *Order, stopf func())
 
-	LimitBuy(ctx context.Context, clientOrderID string, size, price decimal.Decimal) (OrderID, error)
-	LimitSell(ctx context.Context, clientOrderID string, size, price decimal.Decimal) (OrderID, error)
+	LimitBuy(ctx context.Context, clientOrderID string, size, price decimal.Decimal, opts *LimitOptions) (OrderID, error)
+	LimitSell(ctx context.Context, clientOrderID string, size, price decimal.Decimal, opts *LimitOptions) (OrderID, error)
+
+	// MarketBuy and MarketSell create orders that are executed immediately at
+	// the best available price for the given base size.
+	MarketBuy(ctx context.Context, clientOrderID string, size decimal.Decimal) (OrderID, error)
+	MarketSell(ctx context.Context, clientOrderID string, size decimal.Decimal) (OrderID, error)
+
+	// MarketBuyFunds and MarketSellFunds are similar to the MarketBuy and
+	// MarketSell, but the order size is given as funds in the quote currency.
+	// Exchanges that accept the quote funds only for the buy orders convert
+	// the funds to the base size at the current market price for the sells.
+	MarketBuyFunds(ctx context.Context, clientOrderID string, funds decimal.Decimal) (OrderID, error)
+	MarketSellFunds(ctx context.Context, clientOrderID string, funds decimal.Decimal) (OrderID, error)
 
 	Get(ctx context.Context, id OrderID) (*Order, error)
 	Cancel(ctx context.Context, id OrderID) error
 
+	// Edit changes the size and price of an open limit order without changing
+	// it's order id. Size is the total order size including the filled size. It
+	// returns ErrEditRejected when the order cannot be edited.
+	Edit(ctx context.Context, id OrderID, size, price decimal.Decimal) error
+
 	// Retire(id OrderID)
 }
 
@@ -72,5 +173,51 @@ type Exchange interface {
 	GetProduct(ctx context.Context, id string) (*gobs.Product, error)
 	GetOrder(ctx context.Context, id OrderID) (*Order, error)
 
+	// Balances returns the available and on-hold balances for all non-empty
+	// accounts in the exchange. Exchanges that cannot report balances return
+	// an error wrapping errors.ErrUnsupported.
+	Balances(ctx context.Context) ([]*gobs.Account, error)
+
+	// FeeSchedule returns the current maker and taker fee rates for the
+	// exchange account.
+	FeeSchedule(ctx context.Context) (*gobs.FeeSchedule, error)
+
 	IsDone(status string) bool
 }
+
+// ThrottleStatus describes the current state of a REST api request budget.
+type ThrottleStatus struct {
+	Name string
+
+	// Limit and Burst are the configured request rate and burst size.
+	Limit float64
+	Burst int
+
+	// Tokens is the number of requests that can be sent immediately.
+	Tokens float64
+
+	// NumRequests and NumThrottled count the total number of requests and the
+	// requests rejected by the exchange for exceeding the rate limits.
+	NumRequests  int64
+	NumThrottled int64
+
+	// BackoffUntil is non-zero when requests are paused because of the
+	// throttling responses from the exchange.
+	BackoffUntil time.Time
+}
+
+// Stats holds the websocket feed and REST api diagnostics for an exchange.
+type Stats struct {
+	// NumGaps is the number of websocket message gaps found and NumResyncs is
+	// the number of order resyncs performed because of them.
+	NumGaps    int64
+	NumResyncs int64
+
+	Throttles []*ThrottleStatus
+}
+
+// StatsReporter is an optional interface implemented by the exchanges that
+// can report their diagnostics.
+type StatsReporter interface {
+	Stats() *Stats
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package exchange
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bvk/tradebot/gobs"
+	"github.com/shopspring/decimal"
+)
+
+// SizeError is returned when an order size, or the order value in the quote
+// currency, doesn't satisfy the product's constraints. SizeError matches
+// os.ErrInvalid with errors.Is so that callers checking for invalid
+// arguments keep working.
+type SizeError struct {
+	ProductID string
+
+	// Size is the order size after rounding to the base increment. Value is
+	// the order value in the quote currency; it is zero when the order price
+	// is not known.
+	Size  decimal.Decimal
+	Value decimal.Decimal
+
+	// Constraint is the violated product constraint, which is one of "min
+	// size", "max size", "min value" or "max value", and Limit is it's value.
+	Constraint string
+	Limit      decimal.Decimal
+}
+
+func (e *SizeError) Error() string {
+	switch e.Constraint {
+	case "min value", "max value":
+		return fmt.Sprintf("order value %s for product %s is invalid: %s is %s", e.Value, e.ProductID, e.Constraint, e.Limit)
+	}
+	return fmt.Sprintf("order size %s for product %s is invalid: %s is %s", e.Size, e.ProductID, e.Constraint, e.Limit)
+}
+
+func (e *SizeError) Is(target error) bool {
+	return target == os.ErrInvalid
+}
+
+// RoundDown rounds the value down to a multiple of the increment. Value is
+// returned as is when the increment is not positive.
+func RoundDown(value, increment decimal.Decimal) decimal.Decimal {
+	if !increment.IsPositive() {
+		return value
+	}
+	return value.Sub(value.Mod(increment))
+}
+
+// NormalizeSize rounds the size down to the product's base increment and
+// checks it against the product's min and max base sizes.
+func NormalizeSize(product *gobs.Product, size decimal.Decimal) (decimal.Decimal, error) {
+	size = RoundDown(size, product.BaseIncrement)
+	if min := product.BaseMinSize; !size.IsPositive() || size.LessThan(min) {
+		if !min.IsPositive() {
+			min = product.BaseIncrement
+		}
+		return decimal.Zero, &SizeError{ProductID: product.ProductID, Size: size, Constraint: "min size", Limit: min}
+	}
+	if max := product.BaseMaxSize; max.IsPositive() && size.GreaterThan(max) {
+		return decimal.Zero, &SizeError{ProductID: product.ProductID, Size: size, Constraint: "max size", Limit: max}
+	}
+	return size, nil
+}
+
+// NormalizeFunds rounds the funds down to the product's quote increment and
+// checks it against the product's min and max quote sizes.
+func NormalizeFunds(product *gobs.Product, funds decimal.Decimal) (decimal.Decimal, error) {
+	funds = RoundDown(funds, product.QuoteIncrement)
+	if min := product.QuoteMinSize; !funds.IsPositive() || funds.LessThan(min) {
+		if !min.IsPositive() {
+			min = product.QuoteIncrement
+		}
+		return decimal.Zero, &SizeError{ProductID: product.ProductID, Value: funds, Constraint: "min value", Limit: min}
+	}
+	if max := product.QuoteMaxSize; max.IsPositive() && funds.GreaterThan(max) {
+		return decimal.Zero, &SizeError{ProductID: product.ProductID, Value: funds, Constraint: "max value", Limit: max}
+	}
+	return funds, nil
+}
+
+// NormalizeLimit rounds the size and price of a limit order down to the
+// product's base and quote increments respectively and checks the size and
+// the order value against the product's constraints.
+func NormalizeLimit(product *gobs.Product, size, price decimal.Decimal) (nsize, nprice decimal.Decimal, err error) {
+	price = RoundDown(price, product.QuoteIncrement)
+	if !price.IsPositive() {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("limit price must be positive: %w", os.ErrInvalid)
+	}
+	size, err = NormalizeSize(product, size)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	value := size.Mul(price)
+	if min := product.QuoteMinSize; value.LessThan(min) {
+		return decimal.Zero, decimal.Zero, &SizeError{ProductID: product.ProductID, Size: size, Value: value, Constraint: "min value", Limit: min}
+	}
+	if max := product.QuoteMaxSize; max.IsPositive() && value.GreaterThan(max) {
+		return decimal.Zero, decimal.Zero, &SizeError{ProductID: product.ProductID, Size: size, Value: value, Constraint: "max value", Limit: max}
+	}
+	return size, price, nil
+}
+
+// TradableSize returns the size rounded down to the product's base increment.
+// It returns zero when the rounded size is too small to trade, so that a
+// leftover size below the min size can be treated as complete.
+func TradableSize(product Product, size decimal.Decimal) decimal.Decimal {
+	size = RoundDown(size, product.BaseIncrement())
+	if !size.IsPositive() || size.LessThan(product.BaseMinSize()) {
+		return decimal.Zero
+	}
+	return size
+}
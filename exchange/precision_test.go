@@ -0,0 +1,65 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package exchange
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bvk/tradebot/gobs"
+	"github.com/shopspring/decimal"
+)
+
+func TestNormalizeLimit(t *testing.T) {
+	d := decimal.RequireFromString
+	product := &gobs.Product{
+		ProductID:      "BTC-USD",
+		BaseMinSize:    d("0.001"),
+		BaseMaxSize:    d("10"),
+		BaseIncrement:  d("0.001"),
+		QuoteMinSize:   d("1"),
+		QuoteIncrement: d("0.01"),
+	}
+
+	size, price, err := NormalizeLimit(product, d("0.12345"), d("100.129"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !size.Equal(d("0.123")) || !price.Equal(d("100.12")) {
+		t.Fatalf("want size 0.123 and price 100.12, got %s and %s", size, price)
+	}
+
+	tests := []struct {
+		size, price string
+		constraint  string
+	}{
+		{"0.0009", "100", "min size"},
+		{"11", "100", "max size"},
+		{"0.005", "100", "min value"},
+	}
+	for _, test := range tests {
+		_, _, err := NormalizeLimit(product, d(test.size), d(test.price))
+		var serr *SizeError
+		if !errors.As(err, &serr) {
+			t.Fatalf("size %s: want a SizeError, got %v", test.size, err)
+		}
+		if serr.Constraint != test.constraint {
+			t.Fatalf("size %s: want %q constraint, got %q", test.size, test.constraint, serr.Constraint)
+		}
+		if !errors.Is(err, os.ErrInvalid) {
+			t.Fatalf("size %s: want SizeError to match os.ErrInvalid", test.size)
+		}
+	}
+
+	funds, err := NormalizeFunds(product, d("5.129"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !funds.Equal(d("5.12")) {
+		t.Fatalf("want funds 5.12, got %s", funds)
+	}
+	if _, err := NormalizeFunds(product, d("0.5")); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want funds below the min value to fail, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+// Credentials holds the API credentials for an exchange. Exchanges interpret
+// the fields as per their own authentication schemes.
+type Credentials struct {
+	Key    string
+	Secret string
+
+	// Passphrase is an optional third secret required by some exchanges.
+	Passphrase string `json:",omitempty"`
+}
+
+// Options holds the exchange independent options for the exchange
+// constructors. Exchanges map these options onto their own options and
+// ignore the options they don't support. Exchange specific options are passed
+// as Params, which are parsed by the exchanges themselves, or as
+// ExchangeOptions, whose values take precedence over all other options.
+type Options struct {
+	// DataDir, when non-empty, is the directory where the exchanges can keep
+	// their local files. Relative paths in the Params are resolved against
+	// this directory.
+	DataDir string
+
+	// HttpClientTimeout is the timeout for the HTTP requests.
+	HttpClientTimeout time.Duration
+
+	// Messenger, when non-nil, is notified about the exchange feed outages.
+	Messenger Messenger
+
+	// QuoteCurrencies holds the quote currencies for the supported products.
+	QuoteCurrencies []string
+
+	// Params holds the exchange specific options as key-value strings (eg:
+	// "record-tickers" => "true"). Exchanges fail with os.ErrInvalid for the
+	// parameters they don't support.
+	Params map[string]string
+
+	// ExchangeOptions holds the optional, exchange specific options (eg:
+	// *coinbase.Options). Exchanges use their default options when it is nil.
+	ExchangeOptions any
+}
+
+// NewFunc is the constructor for an exchange. Database can be used by the
+// exchanges to keep their local state.
+type NewFunc func(ctx context.Context, db kv.Database, creds *Credentials, opts *Options) (Exchange, error)
+
+var (
+	registryMu  sync.Mutex
+	registryMap = make(map[string]NewFunc)
+)
+
+// Register adds an exchange constructor with the given name to the registry.
+// Exchange names are case-insensitive. It is typically called from the init
+// functions of the exchange packages and panics if the name is already
+// registered.
+func Register(name string, fn NewFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name = strings.ToLower(name)
+	if _, ok := registryMap[name]; ok {
+		panic(fmt.Sprintf("exchange %q is already registered", name))
+	}
+	registryMap[name] = fn
+}
+
+// Names returns the sorted list of all registered exchange names.
+func Names() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	names := make([]string, 0, len(registryMap))
+	for name := range registryMap {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// New creates an exchange client using the registered constructor with the
+// given name.
+func New(ctx context.Context, name string, db kv.Database, creds *Credentials, opts *Options) (Exchange, error) {
+	registryMu.Lock()
+	fn, ok := registryMap[strings.ToLower(name)]
+	registryMu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("exchange %q is not registered: %w", name, os.ErrNotExist)
+	}
+	if creds == nil {
+		return nil, fmt.Errorf("exchange %q credentials cannot be nil: %w", name, os.ErrInvalid)
+	}
+	if opts == nil {
+		opts = new(Options)
+	}
+	return fn(ctx, db, creds, opts)
+}
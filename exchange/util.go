@@ -135,3 +135,37 @@ func MinPrice(vs []*gobs.Order) decimal.Decimal {
 	}
 	return min
 }
+
+// GobOrder returns the order in the format used for the persistent state.
+func GobOrder(v *Order) *gobs.Order {
+	return &gobs.Order{
+		ServerOrderID: string(v.OrderID),
+		ClientOrderID: v.ClientOrderID,
+		CreateTime:    gobs.RemoteTime{Time: v.CreateTime.Time},
+		FinishTime:    gobs.RemoteTime{Time: v.FinishTime.Time},
+		Side:          v.Side,
+		Status:        v.Status,
+		FilledFee:     v.Fee,
+		FilledSize:    v.FilledSize,
+		FilledPrice:   v.FilledPrice,
+		Done:          v.Done,
+		DoneReason:    v.DoneReason,
+	}
+}
+
+// OrderFromGob returns the order from its persistent state format.
+func OrderFromGob(v *gobs.Order) *Order {
+	return &Order{
+		OrderID:       OrderID(v.ServerOrderID),
+		ClientOrderID: v.ClientOrderID,
+		CreateTime:    RemoteTime{Time: v.CreateTime.Time},
+		FinishTime:    RemoteTime{Time: v.FinishTime.Time},
+		Side:          v.Side,
+		Status:        v.Status,
+		Fee:           v.FilledFee,
+		FilledSize:    v.FilledSize,
+		FilledPrice:   v.FilledPrice,
+		Done:          v.Done,
+		DoneReason:    v.DoneReason,
+	}
+}
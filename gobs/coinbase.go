@@ -29,6 +29,19 @@ type CoinbaseCandles struct {
 	ProductCandlesMap map[string][]*CoinbaseCandle
 }
 
+// CoinbaseTickers holds the tickers recorded for multiple products in a short
+// time interval.
+type CoinbaseTickers struct {
+	ProductTickersMap map[string]*CoinbaseTickerList
+}
+
+// CoinbaseTickerList holds ticker timestamps and prices in two parallel lists
+// for a compact encoding.
+type CoinbaseTickerList struct {
+	UnixNanos []int64
+	Prices    []decimal.Decimal
+}
+
 type CoinbaseAccount struct {
 	CurrencyID string
 	Account    json.RawMessage
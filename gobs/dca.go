@@ -0,0 +1,33 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package gobs
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type DCAState struct {
+	V1 *DCAStateV1
+}
+
+type DCAStateV1 struct {
+	ProductID      string
+	ExchangeName   string
+	ClientIDSeed   string
+	ClientIDOffset uint64
+
+	// Amount is the value in quote currency and Size is the size in base
+	// currency to buy every interval. Only one of them is non-zero.
+	Amount decimal.Decimal
+	Size   decimal.Decimal
+
+	Interval time.Duration
+	Ceiling  decimal.Decimal
+
+	LastBuyTime time.Time
+
+	ServerIDOrderMap map[string]*Order
+	Options          map[string]string
+}
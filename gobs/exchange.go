@@ -68,6 +68,17 @@ type Product struct {
 	QuoteIncrement     decimal.Decimal
 }
 
+// FeeSchedule holds the current fee rates for an exchange account. Fee rates
+// are given as percentages of the order value.
+type FeeSchedule struct {
+	Timestamp time.Time
+
+	TierName string
+
+	MakerFeePct decimal.Decimal
+	TakerFeePct decimal.Decimal
+}
+
 type Account struct {
 	Timestamp time.Time
 
@@ -17,6 +17,10 @@ func NewByTypename(typename string) (any, error) {
 		v = new(LooperState)
 	case "WallerState":
 		v = new(WallerState)
+	case "DCAState":
+		v = new(DCAState)
+	case "TrailerState":
+		v = new(TrailerState)
 	case "KeyValue":
 		v = new(KeyValue)
 	case "NameData":
@@ -29,12 +33,16 @@ func NewByTypename(typename string) (any, error) {
 		v = new(CoinbaseOrderIDs)
 	case "CoinbaseCandles":
 		v = new(CoinbaseCandles)
+	case "CoinbaseTickers":
+		v = new(CoinbaseTickers)
 	case "CoinbaseOrder":
 		v = new(CoinbaseOrder)
 	case "CoinbaseAccounts":
 		v = new(CoinbaseAccounts)
 	case "CoinbaseProducts":
 		v = new(CoinbaseProducts)
+	case "FeeSchedule":
+		v = new(FeeSchedule)
 	default:
 		return nil, fmt.Errorf("unsupported type name %q", typename)
 	}
@@ -0,0 +1,37 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package gobs
+
+import "github.com/shopspring/decimal"
+
+type TrailerState struct {
+	V1 *TrailerStateV1
+}
+
+type TrailerStateV1 struct {
+	ProductID      string
+	ExchangeName   string
+	ClientIDSeed   string
+	ClientIDOffset uint64
+
+	Size decimal.Decimal
+
+	// TrailAmount is the retrace amount in quote currency and TrailPct is the
+	// retrace percentage from the high price. Only one of them is non-zero.
+	TrailAmount decimal.Decimal
+	TrailPct    decimal.Decimal
+
+	// OrderType is one of MARKET or LIMIT. LimitOffset is the offset below
+	// the stop price for the LIMIT orders.
+	OrderType   string
+	LimitOffset decimal.Decimal
+
+	ActivationPrice decimal.Decimal
+
+	// HighPrice is the high-water mark of the ticker price. It is zero till
+	// the trail is activated.
+	HighPrice decimal.Decimal
+
+	ServerIDOrderMap map[string]*Order
+	Options          map[string]string
+}
@@ -5,13 +5,14 @@ package limiter
 import (
 	"context"
 
+	"github.com/bvk/tradebot/clock"
 	"github.com/bvk/tradebot/ctxutil"
 	"github.com/bvk/tradebot/exchange"
 	"github.com/bvkgo/kv"
 )
 
-func RunBackgroundTasks(cg *ctxutil.CloseGroup, db kv.Database, ex exchange.Exchange) {
+func RunBackgroundTasks(cg *ctxutil.CloseGroup, db kv.Database, ex exchange.Exchange, clk clock.Clock) {
 	cg.Go(func(ctx context.Context) {
-		fixFinishTimes(ctx, db, ex)
+		fixFinishTimes(ctx, db, ex, clk)
 	})
 }
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bvk/tradebot/clock"
 	"github.com/bvk/tradebot/exchange"
 	"github.com/bvk/tradebot/gobs"
 	"github.com/bvk/tradebot/kvutil"
@@ -21,7 +22,7 @@ var activeLimiters syncmap.Map[*Limiter, bool]
 
 // fixFinishTimes is a background job that updates FinishTime field in order
 // metadata stored by active and completed limiters.
-func fixFinishTimes(ctx context.Context, db kv.Database, ex exchange.Exchange) {
+func fixFinishTimes(ctx context.Context, db kv.Database, ex exchange.Exchange, clk clock.Clock) {
 	// Check if a table scan is required.
 	fixed := true
 	check := func(ctx context.Context, r kv.Reader, key string, value *gobs.LimiterState) error {
@@ -58,8 +59,8 @@ func fixFinishTimes(ctx context.Context, db kv.Database, ex exchange.Exchange) {
 		return nil
 	}
 
-	scanTimeoutCh := time.After(time.Minute)
-	activeLimitersCh := time.After(5 * time.Second)
+	scanTimeoutCh := clk.After(time.Minute)
+	activeLimitersCh := clk.After(5 * time.Second)
 
 	for ctx.Err() == nil {
 		select {
@@ -67,9 +68,12 @@ func fixFinishTimes(ctx context.Context, db kv.Database, ex exchange.Exchange) {
 			continue
 
 		case <-activeLimitersCh:
-			activeLimitersCh = time.After(5 * time.Second)
+			activeLimitersCh = clk.After(5 * time.Second)
 
 			activeLimiters.Range(func(l *Limiter, _ bool) bool {
+				if !strings.EqualFold(l.ExchangeName(), ex.ExchangeName()) {
+					return true
+				}
 				if err := updateActiveLimiter(ctx, ex, l); err != nil {
 					log.Printf("%s: could not update finish time (will retry): %v", l.uid, err)
 				} else {
@@ -81,7 +85,7 @@ func fixFinishTimes(ctx context.Context, db kv.Database, ex exchange.Exchange) {
 
 		case <-scanTimeoutCh:
 			if !fixed {
-				scanTimeoutCh = time.After(time.Minute)
+				scanTimeoutCh = clk.After(time.Minute)
 				if err := kv.WithReadWriter(ctx, db, fix); err != nil {
 					log.Printf("could not apply finish time fix (will retry): %v", err)
 					continue
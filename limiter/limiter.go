@@ -22,7 +22,6 @@ import (
 	"github.com/bvk/tradebot/syncmap"
 	"github.com/bvk/tradebot/trader"
 	"github.com/bvkgo/kv"
-	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
@@ -60,6 +59,18 @@ type Limiter struct {
 	// orders. It's value is typically less than the total size so that large
 	// orders can be avoided.
 	sizeLimitOpt atomic.Pointer[decimal.Decimal]
+
+	// postOnlyOpt when true, creates the exchange orders as post-only orders so
+	// that they never pay the taker fees.
+	postOnlyOpt atomic.Bool
+
+	// timeInForceOpt when set, holds the time-in-force policy for the exchange
+	// orders; one of GTC, GTD, IOC or FOK.
+	timeInForceOpt atomic.Pointer[string]
+
+	// gtdExpiryOpt holds the lifetime for the GTD orders. Orders that expire
+	// are recreated when the ticker price is still in range.
+	gtdExpiryOpt atomic.Int64
 }
 
 var _ trader.Trader = &Limiter{}
@@ -113,6 +124,11 @@ func (v *Limiter) BudgetAt(feePct float64) decimal.Decimal {
 	return v.point.Value().Add(v.point.FeeAt(feePct))
 }
 
+func (v *Limiter) Point() *point.Point {
+	p := v.point
+	return &p
+}
+
 func (v *Limiter) IsBuy() bool {
 	return v.point.Side() == "BUY"
 }
@@ -146,20 +162,7 @@ func (v *Limiter) Actions() []*gobs.Action {
 	var orders []*gobs.Order
 	for _, order := range v.dupOrderMap() {
 		if order.Done && !order.FilledSize.IsZero() {
-			gorder := &gobs.Order{
-				ServerOrderID: string(order.OrderID),
-				ClientOrderID: order.ClientOrderID,
-				CreateTime:    gobs.RemoteTime{Time: order.CreateTime.Time},
-				FinishTime:    gobs.RemoteTime{Time: order.FinishTime.Time},
-				Side:          order.Side,
-				Status:        order.Status,
-				FilledFee:     order.Fee,
-				FilledSize:    order.FilledSize,
-				FilledPrice:   order.FilledPrice,
-				Done:          order.Done,
-				DoneReason:    order.DoneReason,
-			}
-			orders = append(orders, gorder)
+			orders = append(orders, exchange.GobOrder(order))
 		}
 	}
 	sort.Slice(orders, func(i, j int) bool {
@@ -224,6 +227,13 @@ func (v *Limiter) PendingSize() decimal.Decimal {
 	return size
 }
 
+// TradableSize returns the pending size rounded down to the product's base
+// increment. It returns zero when the leftover size is below the product's min
+// size, in which case the limiter is considered complete.
+func (v *Limiter) TradableSize(product exchange.Product) decimal.Decimal {
+	return exchange.TradableSize(product, v.PendingSize())
+}
+
 func (v *Limiter) PendingValue() decimal.Decimal {
 	return v.PendingSize().Mul(v.point.Price)
 }
@@ -261,20 +271,7 @@ func (v *Limiter) Save(ctx context.Context, rw kv.ReadWriter) error {
 		},
 	}
 	for k, v := range v.dupOrderMap() {
-		order := &gobs.Order{
-			ServerOrderID: string(v.OrderID),
-			ClientOrderID: v.ClientOrderID,
-			CreateTime:    gobs.RemoteTime{Time: v.CreateTime.Time},
-			FinishTime:    gobs.RemoteTime{Time: v.FinishTime.Time},
-			Side:          v.Side,
-			Status:        v.Status,
-			FilledFee:     v.Fee,
-			FilledSize:    v.FilledSize,
-			FilledPrice:   v.FilledPrice,
-			Done:          v.Done,
-			DoneReason:    v.DoneReason,
-		}
-		gv.V2.ServerIDOrderMap[string(k)] = order
+		gv.V2.ServerIDOrderMap[string(k)] = exchange.GobOrder(v)
 	}
 	var buf bytes.Buffer
 	if err := gob.NewEncoder(&buf).Encode(gv); err != nil {
@@ -287,17 +284,6 @@ func (v *Limiter) Save(ctx context.Context, rw kv.ReadWriter) error {
 	return nil
 }
 
-func checkUID(uid string) error {
-	fs := strings.Split(uid, "/")
-	if len(fs) == 0 {
-		return fmt.Errorf("uid cannot be empty")
-	}
-	if _, err := uuid.Parse(fs[0]); err != nil {
-		return fmt.Errorf("uid %q doesn't start with an uuid: %w", uid, err)
-	}
-	return nil
-}
-
 func cleanUID(uid string) string {
 	uid = strings.TrimPrefix(uid, "/wallers/")
 	uid = strings.TrimPrefix(uid, "/limiters/")
@@ -306,7 +292,7 @@ func cleanUID(uid string) string {
 }
 
 func Load(ctx context.Context, uid string, r kv.Reader) (*Limiter, error) {
-	if err := checkUID(uid); err != nil {
+	if err := trader.CheckUID(uid); err != nil {
 		return nil, err
 	}
 	key := path.Join(DefaultKeyspace, uid)
@@ -333,20 +319,7 @@ func Load(ctx context.Context, uid string, r kv.Reader) (*Limiter, error) {
 		},
 	}
 	for kk, vv := range gv.V2.ServerIDOrderMap {
-		order := &exchange.Order{
-			OrderID:       exchange.OrderID(vv.ServerOrderID),
-			ClientOrderID: vv.ClientOrderID,
-			CreateTime:    exchange.RemoteTime{Time: vv.CreateTime.Time},
-			FinishTime:    exchange.RemoteTime{Time: vv.FinishTime.Time},
-			Side:          vv.Side,
-			Status:        vv.Status,
-			Fee:           vv.FilledFee,
-			FilledSize:    vv.FilledSize,
-			FilledPrice:   vv.FilledPrice,
-			Done:          vv.Done,
-			DoneReason:    vv.DoneReason,
-		}
-		v.orderMap.Store(exchange.OrderID(kk), order)
+		v.orderMap.Store(exchange.OrderID(kk), exchange.OrderFromGob(vv))
 	}
 	if err := v.check(); err != nil {
 		return nil, err
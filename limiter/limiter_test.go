@@ -0,0 +1,310 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/clock"
+	"github.com/bvk/tradebot/coinbase"
+	"github.com/bvk/tradebot/coinbase/coinbasetest"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/paper"
+	"github.com/bvk/tradebot/point"
+	"github.com/bvk/tradebot/trader"
+	"github.com/bvkgo/kv/kvmemdb"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestLimiterWithFakeCoinbase(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	srv := coinbasetest.NewServer()
+	defer srv.Close()
+
+	srv.AddProduct("BCH-USD", decimal.NewFromInt(100))
+
+	db := kvmemdb.New()
+	opts := &coinbase.Options{
+		RestHostname:         srv.Hostname(),
+		WebsocketHostname:    srv.Hostname(),
+		RestScheme:           "http",
+		WebsocketScheme:      "ws",
+		ServerTimeURL:        srv.ServerTimeURL(),
+		FetchCandlesInterval: -1,
+		WatchProductIDs:      []string{"BCH-USD"},
+	}
+	ex, err := coinbase.New(ctx, db, "test-key", "test-secret", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ex.Close()
+
+	product, err := ex.OpenProduct(ctx, "BCH-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer product.Close()
+
+	buy := &point.Point{
+		Size:   decimal.NewFromInt(1),
+		Price:  decimal.NewFromInt(90),
+		Cancel: decimal.NewFromInt(95),
+	}
+	limit, err := New(uuid.New().String(), "coinbase", "BCH-USD", buy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := &trader.Runtime{
+		Database: db,
+		Product:  product,
+		Clock:    clock.System,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- limit.Run(ctx, rt)
+	}()
+
+	// Bring the ticker into the cancel range and wait for the limit order.
+	for len(srv.Orders()) == 0 {
+		if err := srv.Tick("BCH-USD", decimal.NewFromInt(94)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := srv.Tick("BCH-USD", decimal.NewFromInt(89)); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	if !limit.PendingSize().IsZero() {
+		t.Fatalf("want zero pending size, got %s", limit.PendingSize())
+	}
+	if want := decimal.NewFromInt(90); !limit.FilledValue().Equal(want) {
+		t.Fatalf("want filled value %s, got %s", want, limit.FilledValue())
+	}
+}
+
+func TestLimiterEditsOrderForSizeLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ex := paper.New(nil, &paper.Options{SyncTickers: true})
+	defer ex.Close()
+
+	product, err := ex.AddProduct(&gobs.Product{
+		ProductID:   "BCH-USD",
+		BaseMinSize: decimal.RequireFromString("0.01"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buy := &point.Point{
+		Size:   decimal.NewFromInt(2),
+		Price:  decimal.NewFromInt(90),
+		Cancel: decimal.NewFromInt(95),
+	}
+	limit, err := New(uuid.New().String(), "coinbase", "BCH-USD", buy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := &trader.Runtime{
+		Database: kvmemdb.New(),
+		Product:  product,
+		Clock:    clock.System,
+	}
+	errCh := make(chan error, 1)
+	removeReceiver := product.AddSyncReceiver()
+	go func() {
+		err := limit.Run(ctx, rt)
+		removeReceiver()
+		errCh <- err
+	}()
+
+	now := time.Now()
+	tick := func(price int64) {
+		now = now.Add(time.Second)
+		product.HandleTicker(&exchange.Ticker{
+			Timestamp: exchange.RemoteTime{Time: now},
+			Price:     decimal.NewFromInt(price),
+		})
+	}
+
+	// Active order must be edited in place when size-limit is changed.
+	tick(94)
+	if err := limit.SetOption("size-limit", "1"); err != nil {
+		t.Fatal(err)
+	}
+	tick(94)
+	tick(94)
+	tick(89)
+	// Remaining size must be bought with a new order.
+	tick(94)
+	tick(94)
+	tick(89)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	if want := decimal.NewFromInt(180); !limit.FilledValue().Equal(want) {
+		t.Fatalf("want filled value %s, got %s", want, limit.FilledValue())
+	}
+	orders := limit.dupOrderMap()
+	if len(orders) != 2 {
+		t.Fatalf("want 2 orders, got %d", len(orders))
+	}
+	for _, order := range orders {
+		if !order.FilledSize.Equal(decimal.NewFromInt(1)) {
+			t.Fatalf("want filled size 1 for order %s, got %s", order.OrderID, order.FilledSize)
+		}
+	}
+}
+
+func TestLimiterOrderSize(t *testing.T) {
+	d := decimal.RequireFromString
+
+	ex := paper.New(nil, &paper.Options{SyncTickers: true})
+	defer ex.Close()
+
+	product, err := ex.AddProduct(&gobs.Product{
+		ProductID:     "BCH-USD",
+		BaseMinSize:   d("0.1"),
+		BaseIncrement: d("0.01"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		size, sizeLimit, want string
+	}{
+		{"1", "", "1"},
+		{"1.005", "", "1"},
+		{"1", "0.4", "0.4"},
+		// Final partial order must not be smaller than the min size.
+		{"1", "0.95", "0.9"},
+		{"0.15", "0.1", "0.15"},
+	}
+	for _, test := range tests {
+		buy := &point.Point{
+			Size:   d(test.size),
+			Price:  decimal.NewFromInt(90),
+			Cancel: decimal.NewFromInt(95),
+		}
+		limit, err := New(uuid.New().String(), "coinbase", "BCH-USD", buy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.sizeLimit != "" {
+			if err := limit.SetOption("size-limit", test.sizeLimit); err != nil {
+				t.Fatal(err)
+			}
+		}
+		size, err := limit.orderSize(product)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !size.Equal(d(test.want)) {
+			t.Fatalf("size %s with size-limit %q: want order size %s, got %s", test.size, test.sizeLimit, test.want, size)
+		}
+	}
+
+	// Pending size below the min size must not be bumped up to the min size.
+	small := &point.Point{
+		Size:   d("0.05"),
+		Price:  decimal.NewFromInt(90),
+		Cancel: decimal.NewFromInt(95),
+	}
+	limit, err := New(uuid.New().String(), "coinbase", "BCH-USD", small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var serr *exchange.SizeError
+	if _, err := limit.orderSize(product); !errors.As(err, &serr) {
+		t.Fatalf("want a SizeError for pending size below the min size, got %v", err)
+	}
+}
+
+func TestLimiterCompletesWithLeftoverSize(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	d := decimal.RequireFromString
+
+	ex := paper.New(nil, &paper.Options{SyncTickers: true})
+	defer ex.Close()
+
+	product, err := ex.AddProduct(&gobs.Product{
+		ProductID:     "BCH-USD",
+		BaseMinSize:   d("0.1"),
+		BaseIncrement: d("0.01"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Point size is not a multiple of the base increment, so a leftover size
+	// of 0.005 remains after the order for size 1 is filled.
+	buy := &point.Point{
+		Size:   d("1.005"),
+		Price:  decimal.NewFromInt(90),
+		Cancel: decimal.NewFromInt(95),
+	}
+	limit, err := New(uuid.New().String(), "coinbase", "BCH-USD", buy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim := clock.NewSimulated(time.Now())
+	rt := &trader.Runtime{
+		Database: kvmemdb.New(),
+		Product:  product,
+		Clock:    sim,
+	}
+	errCh := make(chan error, 1)
+	removeReceiver := product.AddSyncReceiver()
+	go func() {
+		err := limit.Run(ctx, rt)
+		removeReceiver()
+		errCh <- err
+	}()
+
+	for _, p := range []int64{94, 89} {
+		product.HandleTicker(&exchange.Ticker{
+			Timestamp: exchange.RemoteTime{Time: sim.Now()},
+			Price:     decimal.NewFromInt(p),
+		})
+	}
+
+	select {
+	case <-ctx.Done():
+		t.Fatalf("timed out waiting for the limiter to complete")
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := decimal.NewFromInt(1); !limit.FilledSize().Equal(want) {
+		t.Fatalf("want filled size %s, got %s", want, limit.FilledSize())
+	}
+	if !limit.TradableSize(product).IsZero() {
+		t.Fatalf("want zero tradable size, got %s", limit.TradableSize(product))
+	}
+
+	// Limiter must complete immediately when it is resumed.
+	if err := limit.Run(ctx, rt); err != nil {
+		t.Fatal(err)
+	}
+}
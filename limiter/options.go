@@ -5,7 +5,9 @@ package limiter
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/bvk/tradebot/exchange"
 	"github.com/shopspring/decimal"
 )
 
@@ -14,6 +16,9 @@ func (v *Limiter) SetOption(key, value string) error {
 		"hold":                 v.setHoldOption,
 		"size-limit":           v.setSizeLimitOption,
 		"wait-for-ticker-side": v.setWaitForTickerSideOption,
+		"post-only":            v.setPostOnlyOption,
+		"time-in-force":        v.setTimeInForceOption,
+		"gtd-expiry":           v.setGTDExpiryOption,
 	}
 	handler, ok := optMap[key]
 	if !ok {
@@ -96,3 +101,64 @@ func (v *Limiter) isTickerSideReady(price decimal.Decimal) bool {
 	}
 	return false
 }
+
+func (v *Limiter) setPostOnlyOption(value string) error {
+	arg := strings.ToLower(value)
+	if arg == "true" {
+		v.postOnlyOpt.Store(true)
+		return nil
+	}
+	if arg == "false" {
+		v.postOnlyOpt.Store(false)
+		return nil
+	}
+	return fmt.Errorf(`%v: post-only option only takes a "true" or "false" value`, v.uid)
+}
+
+func (v *Limiter) setTimeInForceOption(value string) error {
+	arg := strings.ToUpper(value)
+	switch arg {
+	case "GTC", "GTD", "IOC", "FOK":
+		v.timeInForceOpt.Store(&arg)
+		return nil
+	}
+	return fmt.Errorf(`%v: time-in-force option only takes one of "GTC", "GTD", "IOC" or "FOK" values`, v.uid)
+}
+
+func (v *Limiter) setGTDExpiryOption(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("gtd expiry duration must be positive")
+	}
+	v.gtdExpiryOpt.Store(int64(d))
+	return nil
+}
+
+// defaultGTDExpiry is the lifetime for GTD orders when gtd-expiry option is
+// not set.
+const defaultGTDExpiry = time.Hour
+
+// limitOptions returns the exchange order options for a new order created at
+// the given time.
+func (v *Limiter) limitOptions(now time.Time) *exchange.LimitOptions {
+	opts := &exchange.LimitOptions{
+		PostOnly: v.postOnlyOpt.Load(),
+	}
+	if p := v.timeInForceOpt.Load(); p != nil {
+		opts.TimeInForce = *p
+	}
+	if opts.TimeInForce == "GTD" {
+		expiry := time.Duration(v.gtdExpiryOpt.Load())
+		if expiry == 0 {
+			expiry = defaultGTDExpiry
+		}
+		opts.EndTime = now.Add(expiry)
+	}
+	if opts.TimeInForce == "IOC" || opts.TimeInForce == "FOK" {
+		opts.PostOnly = false
+	}
+	return opts
+}
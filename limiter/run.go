@@ -4,6 +4,7 @@ package limiter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"github.com/bvk/tradebot/exchange"
 	"github.com/bvk/tradebot/trader"
 	"github.com/bvkgo/kv"
+	"github.com/shopspring/decimal"
 )
 
 func (v *Limiter) Run(ctx context.Context, rt *trader.Runtime) error {
@@ -29,15 +31,6 @@ func (v *Limiter) Run(ctx context.Context, rt *trader.Runtime) error {
 		return err
 	}
 
-	if p := v.PendingSize(); p.IsZero() {
-		if nupdated != 0 {
-			_ = kv.WithReadWriter(ctx, rt.Database, v.Save)
-		}
-		asyncUpdateFinishTime(v)
-		log.Printf("%s:%s: limiter is complete cause pending size is zero", v.uid, v.point)
-		return nil
-	}
-
 	// Check if any of the orders in the orderMap are still active on the
 	// exchange.
 	var live []*exchange.Order
@@ -53,6 +46,16 @@ func (v *Limiter) Run(ctx context.Context, rt *trader.Runtime) error {
 		return fmt.Errorf("found %d live orders (want 0 or 1)", nlive)
 	}
 
+	// Leftover size that is too small to trade is treated as complete.
+	if nlive == 0 && v.TradableSize(rt.Product).IsZero() {
+		if nupdated != 0 {
+			_ = kv.WithReadWriter(ctx, rt.Database, v.Save)
+		}
+		asyncUpdateFinishTime(v)
+		log.Printf("%s:%s: limiter is complete cause pending size %s is not tradable", v.uid, v.point, v.PendingSize())
+		return nil
+	}
+
 	var activeOrderID exchange.OrderID
 	if nlive != 0 {
 		activeOrderID = live[0].OrderID
@@ -60,7 +63,7 @@ func (v *Limiter) Run(ctx context.Context, rt *trader.Runtime) error {
 	}
 
 	dirty := 0
-	flushCh := time.After(time.Minute)
+	flushCh := rt.After(time.Minute)
 
 	localCtx := context.Background()
 
@@ -72,7 +75,30 @@ func (v *Limiter) Run(ctx context.Context, rt *trader.Runtime) error {
 
 	lastSizeLimit := v.sizeLimit()
 
-	for p := v.PendingSize(); !p.IsZero(); p = v.PendingSize() {
+	// Post-only orders are rejected by the exchange when the ticker moves past
+	// the limit price, so order creation is retried with an exponential backoff
+	// instead of failing the job.
+	var retryAt time.Time
+	var backoff time.Duration
+	createOrder := func() (exchange.OrderID, error) {
+		if now := rt.Now(); now.Before(retryAt) {
+			return "", nil
+		}
+		id, err := v.create(localCtx, rt, v.limitOptions(rt.Now()))
+		if err != nil {
+			if !errors.Is(err, exchange.ErrPostOnlyRejected) {
+				return "", err
+			}
+			backoff = min(max(2*backoff, time.Second), time.Minute)
+			retryAt = rt.Now().Add(backoff)
+			log.Printf("%s:%s: post-only limit order is rejected (will retry after %s): %v", v.uid, v.point, backoff, err)
+			return "", nil
+		}
+		backoff = 0
+		return id, nil
+	}
+
+	for activeOrderID != "" || !v.TradableSize(rt.Product).IsZero() {
 		select {
 		case <-ctx.Done():
 			if activeOrderID != "" {
@@ -96,7 +122,7 @@ func (v *Limiter) Run(ctx context.Context, rt *trader.Runtime) error {
 					dirty = 0
 				}
 			}
-			flushCh = time.After(time.Minute)
+			flushCh = rt.After(time.Minute)
 
 		case order := <-orderUpdatesCh:
 			dirty++
@@ -122,15 +148,20 @@ func (v *Limiter) Run(ctx context.Context, rt *trader.Runtime) error {
 				continue
 			}
 
-			// Cancel the active order if size-limit option value has changed; order
-			// will be recreated with correct size-limit.
+			// Edit the active order if size-limit option value has changed. When
+			// order cannot be edited, it is canceled and will be recreated with
+			// correct size-limit.
 			if x := v.sizeLimit(); activeOrderID != "" && !lastSizeLimit.Equal(x) {
-				log.Printf("%v: canceling existing order %s cause size-limit has changed from %s to %s", v.uid, activeOrderID, lastSizeLimit, x)
-				if err := v.cancel(localCtx, rt.Product, activeOrderID); err != nil {
-					return err
+				if err := v.edit(localCtx, rt.Product, activeOrderID); err != nil {
+					log.Printf("%v: canceling existing order %s cause size-limit has changed from %s to %s and order could not be edited: %v", v.uid, activeOrderID, lastSizeLimit, x, err)
+					if err := v.cancel(localCtx, rt.Product, activeOrderID); err != nil {
+						return err
+					}
+					activeOrderID = ""
+				} else {
+					log.Printf("%v: edited existing order %s cause size-limit has changed from %s to %s", v.uid, activeOrderID, lastSizeLimit, x)
 				}
 				dirty++
-				activeOrderID = ""
 				lastSizeLimit = x
 			}
 
@@ -151,12 +182,14 @@ func (v *Limiter) Run(ctx context.Context, rt *trader.Runtime) error {
 				}
 				if ticker.Price.GreaterThan(v.point.Cancel) {
 					if activeOrderID == "" {
-						id, err := v.create(localCtx, rt.Product)
+						id, err := createOrder()
 						if err != nil {
 							return err
 						}
-						dirty++
-						activeOrderID = id
+						if id != "" {
+							dirty++
+							activeOrderID = id
+						}
 					}
 				}
 				continue
@@ -174,12 +207,14 @@ func (v *Limiter) Run(ctx context.Context, rt *trader.Runtime) error {
 				}
 				if ticker.Price.LessThan(v.point.Cancel) {
 					if activeOrderID == "" {
-						id, err := v.create(localCtx, rt.Product)
+						id, err := createOrder()
 						if err != nil {
 							return err
 						}
-						dirty++
-						activeOrderID = id
+						if id != "" {
+							dirty++
+							activeOrderID = id
+						}
 					}
 				}
 				continue
@@ -216,29 +251,57 @@ func (v *Limiter) Refresh(ctx context.Context, rt *trader.Runtime) error {
 	return nil
 }
 
-func (v *Limiter) create(ctx context.Context, product exchange.Product) (exchange.OrderID, error) {
-	offset := v.idgen.Offset()
-	clientOrderID := v.idgen.NextID()
+// orderSize returns the size for the next limit order. Order sizes are
+// rounded down to the product's base increment and are never bumped up to the
+// min size, so that total filled size never exceeds the point size. When the
+// size-limit option splits the pending size into multiple orders, order size
+// is reduced so that the final partial order isn't smaller than the min size.
+func (v *Limiter) orderSize(product exchange.Product) (decimal.Decimal, error) {
+	minSize, incr := product.BaseMinSize(), product.BaseIncrement()
+
+	pending := v.TradableSize(product)
+	if pending.IsZero() {
+		return decimal.Zero, &exchange.SizeError{
+			ProductID:  product.ProductID(),
+			Size:       exchange.RoundDown(v.PendingSize(), incr),
+			Constraint: "min size",
+			Limit:      minSize,
+		}
+	}
 
-	size := v.PendingSize()
-	if s := v.sizeLimit(); size.GreaterThan(s) {
+	size := pending
+	if s := exchange.RoundDown(v.sizeLimit(), incr); size.GreaterThan(s) {
 		size = s
+		if rest := pending.Sub(size); rest.LessThan(minSize) {
+			size = pending.Sub(minSize)
+		}
+		if size.LessThan(minSize) || !size.IsPositive() {
+			size = pending
+		}
 	}
-	if size.LessThan(product.BaseMinSize()) {
-		size = product.BaseMinSize()
+	return size, nil
+}
+
+func (v *Limiter) create(ctx context.Context, rt *trader.Runtime, opts *exchange.LimitOptions) (exchange.OrderID, error) {
+	size, err := v.orderSize(rt.Product)
+	if err != nil {
+		log.Printf("%s:%s: could not plan the limit order size for pending size %s: %v", v.uid, v.point, v.PendingSize(), err)
+		return "", err
 	}
 
-	var err error
+	offset := v.idgen.Offset()
+	clientOrderID := v.idgen.NextID()
+
 	var latency time.Duration
 	var orderID exchange.OrderID
 	if v.IsSell() {
-		s := time.Now()
-		orderID, err = product.LimitSell(ctx, clientOrderID.String(), size, v.point.Price)
-		latency = time.Now().Sub(s)
+		s := rt.Now()
+		orderID, err = rt.Product.LimitSell(ctx, clientOrderID.String(), size, v.point.Price, opts)
+		latency = rt.Now().Sub(s)
 	} else {
-		s := time.Now()
-		orderID, err = product.LimitBuy(ctx, clientOrderID.String(), size, v.point.Price)
-		latency = time.Now().Sub(s)
+		s := rt.Now()
+		orderID, err = rt.Product.LimitBuy(ctx, clientOrderID.String(), size, v.point.Price, opts)
+		latency = rt.Now().Sub(s)
 	}
 	if err != nil {
 		v.idgen.RevertID()
@@ -256,6 +319,28 @@ func (v *Limiter) create(ctx context.Context, product exchange.Product) (exchang
 	return orderID, nil
 }
 
+// edit resizes the active order for the current size-limit, so that the order
+// keeps it's order id.
+func (v *Limiter) edit(ctx context.Context, product exchange.Product, activeOrderID exchange.OrderID) error {
+	order, ok := v.orderMap.Load(activeOrderID)
+	if !ok {
+		return fmt.Errorf("active order %s is not found: %w", activeOrderID, os.ErrNotExist)
+	}
+
+	size, err := v.orderSize(product)
+	if err != nil {
+		return err
+	}
+	// Edited size includes the already filled size of the order.
+	size = size.Add(order.FilledSize)
+
+	if err := product.Edit(ctx, activeOrderID, size, v.point.Price); err != nil {
+		log.Printf("%s:%s: edit limit order %s to size %s has failed: %v", v.uid, v.point, activeOrderID, size, err)
+		return err
+	}
+	return nil
+}
+
 func (v *Limiter) cancel(ctx context.Context, product exchange.Product, activeOrderID exchange.OrderID) error {
 	if err := product.Cancel(ctx, activeOrderID); err != nil {
 		log.Printf("%s:%s: cancel limit order %s has failed: %v", v.uid, v.point, activeOrderID, err)
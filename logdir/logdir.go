@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 )
 
@@ -25,32 +26,65 @@ var (
 	// file names.
 	FileNameTimeLocation = time.UTC
 
-	// FileSizeLimitMB contains the maximum size limit for the log files.
+	// FileSizeLimitMB contains the default maximum size limit for the log
+	// files.
 	FileSizeLimitMB int64 = 100
 
 	// FileMode contains the file mode and permissions value for the log files.
 	FileMode = os.FileMode(0600)
 )
 
+// Options holds the retention limits for the log files. Zero values do not
+// limit the log files.
+type Options struct {
+	// MaxFiles is the max number of log files kept in the directory for the log
+	// name, including the current log file.
+	MaxFiles int
+
+	// MaxAge is the max duration the log files are kept in the directory after
+	// their last modification.
+	MaxAge time.Duration
+}
+
 type Backend struct {
 	fp *os.File
 
+	opts Options
+
 	size int64
 
+	// sizeLimit is the maximum size limit for the log files in bytes.
+	sizeLimit int64
+
 	dirname, logname string
 }
 
-func New(dirname, logname string) (*Backend, error) {
-	fp, size, err := openFile(dirname, logname, FileNameReuseInterval)
+// New creates a log backend that writes to the log files in the `dirname`
+// directory. A new log file is created when the current log file grows beyond
+// `sizeLimitMB` megabytes. Zero `sizeLimitMB` uses the FileSizeLimitMB value.
+// Older log files beyond the retention limits in `opts`, if any, are removed
+// when a log file is opened.
+func New(dirname, logname string, sizeLimitMB int64, opts *Options) (*Backend, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if sizeLimitMB <= 0 {
+		sizeLimitMB = FileSizeLimitMB
+	}
+	sizeLimit := sizeLimitMB * 1024 * 1024
+	fp, size, err := openFile(dirname, logname, FileNameReuseInterval, sizeLimit)
 	if err != nil {
 		return nil, fmt.Errorf("could not open log file: %w", err)
 	}
 	b := &Backend{
-		fp:      fp,
-		size:    size,
-		dirname: dirname,
-		logname: logname,
+		fp:        fp,
+		opts:      *opts,
+		size:      size,
+		sizeLimit: sizeLimit,
+		dirname:   dirname,
+		logname:   logname,
 	}
+	b.removeOld()
 	return b, nil
 }
 
@@ -68,7 +102,7 @@ func fileName(logname string, at time.Time, truncate time.Duration) string {
 	return fmt.Sprintf("%s-%s.log", logname, uniq)
 }
 
-func openFile(dirname, logname string, truncate time.Duration) (*os.File, int64, error) {
+func openFile(dirname, logname string, truncate time.Duration, sizeLimit int64) (*os.File, int64, error) {
 	filename := fileName(logname, time.Now(), truncate)
 	fp, err := os.OpenFile(filepath.Join(dirname, filename), os.O_CREATE|os.O_WRONLY|os.O_APPEND, FileMode)
 	if err != nil {
@@ -80,23 +114,63 @@ func openFile(dirname, logname string, truncate time.Duration) (*os.File, int64,
 		return nil, -1, fmt.Errorf("could not get file size: %w", err)
 	}
 	size := finfo.Size()
-	if size >= FileSizeLimitMB*1024*1024 {
+	if size >= sizeLimit {
 		fp.Close()
-		return openFile(dirname, logname, 0)
+		return openFile(dirname, logname, 0, sizeLimit)
 	}
 	return fp, size, nil
 }
 
 func (b *Backend) Write(data []byte) (int, error) {
-	if b.size+int64(len(data)) > FileSizeLimitMB*1024*1024 {
-		fp, size, err := openFile(b.dirname, b.logname, FileNameReuseInterval)
+	if b.size+int64(len(data)) > b.sizeLimit {
+		fp, size, err := openFile(b.dirname, b.logname, FileNameReuseInterval, b.sizeLimit)
 		if err != nil {
 			return 0, fmt.Errorf("could not open new log file: %w", err)
 		}
 		b.fp.Close()
 		b.fp, b.size = fp, size
+		b.removeOld()
 	}
 	n, err := b.fp.Write(data)
 	b.size += int64(n)
 	return n, err
 }
+
+// removeOld removes the log files beyond the retention limits. Current log
+// file is never removed. Errors are ignored because the log backend cannot
+// report them without writing into itself.
+func (b *Backend) removeOld() {
+	if b.opts.MaxFiles <= 0 && b.opts.MaxAge <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(filepath.Join(b.dirname, b.logname+"-*.log"))
+	if err != nil {
+		return
+	}
+	// File names have the creation timestamp, so newest files are at the end.
+	sort.Strings(matches)
+
+	current := b.fp.Name()
+	var old []string
+	for _, m := range matches {
+		if m != current {
+			old = append(old, m)
+		}
+	}
+
+	nremove := 0
+	if b.opts.MaxFiles > 0 && len(old) >= b.opts.MaxFiles {
+		nremove = len(old) - b.opts.MaxFiles + 1
+	}
+	for i, m := range old {
+		if i < nremove {
+			os.Remove(m)
+			continue
+		}
+		if b.opts.MaxAge > 0 {
+			if finfo, err := os.Stat(m); err == nil && time.Since(finfo.ModTime()) > b.opts.MaxAge {
+				os.Remove(m)
+			}
+		}
+	}
+}
@@ -2,11 +2,15 @@ package logdir
 
 import (
 	"log"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestLogDir(t *testing.T) {
-	b, err := New("/tmp", "testlogdir", 1)
+	b, err := New("/tmp", "testlogdir", 1, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -15,3 +19,42 @@ func TestLogDir(t *testing.T) {
 		log.Printf("hello world")
 	}
 }
+
+func TestLogDirRetention(t *testing.T) {
+	dir := t.TempDir()
+
+	// Create old log files with distinct timestamps in their names.
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		at := now.Add(-time.Duration(i) * time.Hour)
+		name := filepath.Join(dir, fileName("retention", at, 0))
+		if err := os.WriteFile(name, []byte("old\n"), FileMode); err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			old := now.Add(-48 * time.Hour)
+			if err := os.Chtimes(name, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	b, err := New(dir, "retention", 1, &Options{MaxFiles: 3, MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	matches, err := filepath.Glob(filepath.Join(dir, "retention-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Three oldest files are removed for the max files limit and the newest old
+	// file is removed for the max age limit.
+	if len(matches) != 2 {
+		t.Fatalf("want 2 log files after retention, got %d", len(matches))
+	}
+	if !slices.Contains(matches, b.fp.Name()) {
+		t.Fatalf("want current log file %s to be retained", b.fp.Name())
+	}
+}
@@ -21,7 +21,6 @@ import (
 	"github.com/bvk/tradebot/point"
 	"github.com/bvk/tradebot/trader"
 	"github.com/bvkgo/kv"
-	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
@@ -218,17 +217,6 @@ func (v *Looper) Save(ctx context.Context, rw kv.ReadWriter) error {
 	return nil
 }
 
-func checkUID(uid string) error {
-	fs := strings.Split(uid, "/")
-	if len(fs) == 0 {
-		return fmt.Errorf("uid cannot be empty")
-	}
-	if _, err := uuid.Parse(fs[0]); err != nil {
-		return fmt.Errorf("uid %q doesn't start with an uuid: %w", uid, err)
-	}
-	return nil
-}
-
 func cleanUID(uid string) string {
 	uid = strings.TrimPrefix(uid, "/wallers/")
 	uid = strings.TrimPrefix(uid, "/limiters/")
@@ -237,7 +225,7 @@ func cleanUID(uid string) string {
 }
 
 func Load(ctx context.Context, uid string, r kv.Reader) (*Looper, error) {
-	if err := checkUID(uid); err != nil {
+	if err := trader.CheckUID(uid); err != nil {
 		return nil, err
 	}
 	key := path.Join(DefaultKeyspace, uid)
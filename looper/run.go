@@ -9,6 +9,7 @@ import (
 	"path"
 	"time"
 
+	"github.com/bvk/tradebot/clock"
 	"github.com/bvk/tradebot/exchange"
 	"github.com/bvk/tradebot/limiter"
 	"github.com/bvk/tradebot/trader"
@@ -123,11 +124,11 @@ func (v *Looper) Run(ctx context.Context, rt *trader.Runtime) error {
 
 			log.Printf("%s: current holding size %s-%s=%s is less than buy size %s (starting a buy)", v.uid, bought, sold, holdings, v.buyPoint.Size)
 
-			if nbuys == 0 || v.buys[nbuys-1].PendingSize().IsZero() {
+			if nbuys == 0 || v.buys[nbuys-1].TradableSize(rt.Product).IsZero() {
 				if err := v.addNewBuy(ctx, rt); err != nil {
 					if ctx.Err() == nil {
 						log.Printf("could not add limit-buy %d (retrying): %v", nbuys, err)
-						time.Sleep(time.Second)
+						clock.Sleep(ctx, rt, time.Second)
 						continue
 					}
 					log.Printf("%v: could not create new limit-buy op (will retry): %v", v.uid, err)
@@ -139,7 +140,7 @@ func (v *Looper) Run(ctx context.Context, rt *trader.Runtime) error {
 			if err := v.buys[nbuys-1].Run(ctx, rt); err != nil {
 				if ctx.Err() == nil {
 					log.Printf("limit-buy %d has failed (retrying): %v", nbuys, err)
-					time.Sleep(time.Second)
+					clock.Sleep(ctx, rt, time.Second)
 					continue
 				}
 				log.Printf("%v: could not complete limit-buy op (will retry): %v", v.uid, err)
@@ -151,11 +152,11 @@ func (v *Looper) Run(ctx context.Context, rt *trader.Runtime) error {
 		if holdings.GreaterThanOrEqual(v.sellPoint.Size) {
 			v.readyWaitForSell(ctx, rt)
 			log.Printf("%s: current holding size %s-%s=%s is greater-than or equal to sell size %s (starting a sell)", v.uid, bought, sold, holdings, v.sellPoint.Size)
-			if nsells == 0 || v.sells[nsells-1].PendingSize().IsZero() {
+			if nsells == 0 || v.sells[nsells-1].TradableSize(rt.Product).IsZero() {
 				if err := v.addNewSell(ctx, rt); err != nil {
 					if ctx.Err() == nil {
 						log.Printf("could not add limit-sell %d (retrying); %v", nsells, err)
-						time.Sleep(time.Second)
+						clock.Sleep(ctx, rt, time.Second)
 						continue
 					}
 					log.Printf("%v: could not create new limit-sell op (will retry): %v", v.uid, err)
@@ -167,7 +168,7 @@ func (v *Looper) Run(ctx context.Context, rt *trader.Runtime) error {
 			if err := v.sells[nsells-1].Run(ctx, rt); err != nil {
 				if ctx.Err() == nil {
 					log.Printf("limit-sell %d has failed (retrying): %v", nsells, err)
-					time.Sleep(time.Second)
+					clock.Sleep(ctx, rt, time.Second)
 					continue
 				}
 				log.Printf("%v: could not complete limit-sell op (will retry): %v", v.uid, err)
@@ -177,7 +178,7 @@ func (v *Looper) Run(ctx context.Context, rt *trader.Runtime) error {
 			sell, buy := v.sells[nsells-1], v.buys[nbuys-1]
 			fees := sell.Fees().Add(buy.Fees())
 			profit := sell.SoldValue().Sub(buy.BoughtValue()).Sub(fees)
-			rt.Messenger.SendMessage(ctx, time.Now(), "A sell is completed successfully at price %s in product %s (%s) with %s of profit.", v.sellPoint.Price.StringFixed(3), v.productID, v.exchangeName, profit.StringFixed(3))
+			rt.Messenger.SendMessage(ctx, rt.Now(), "A sell is completed successfully at price %s in product %s (%s) with %s of profit.", v.sellPoint.Price.StringFixed(3), v.productID, v.exchangeName, profit.StringFixed(3))
 		}
 	}
 	return context.Cause(ctx)
@@ -63,7 +63,7 @@ func (v *Looper) Status(period *timerange.Range) *trader.Status {
 			ProductID:    v.productID,
 			ExchangeName: v.exchangeName,
 			Summary: &trader.Summary{
-				Budget: v.BudgetAt(0.25),
+				Budget: v.BudgetAt(exchange.DefaultFeePct),
 			},
 		}
 	}
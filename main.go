@@ -11,11 +11,13 @@ import (
 	"github.com/bvk/tradebot/subcmds"
 	"github.com/bvk/tradebot/subcmds/coinbase"
 	"github.com/bvk/tradebot/subcmds/db"
+	"github.com/bvk/tradebot/subcmds/dca"
 	"github.com/bvk/tradebot/subcmds/exchange"
 	"github.com/bvk/tradebot/subcmds/fix"
 	"github.com/bvk/tradebot/subcmds/job"
 	"github.com/bvk/tradebot/subcmds/limiter"
 	"github.com/bvk/tradebot/subcmds/looper"
+	"github.com/bvk/tradebot/subcmds/trailer"
 	"github.com/bvk/tradebot/subcmds/waller"
 )
 
@@ -46,12 +48,14 @@ func main() {
 		new(job.Import),
 		new(job.SetName),
 		new(job.SetOption),
+		new(job.Backtest),
 	}
 
 	limiterCmds := []cli.Command{
 		new(limiter.Add),
 		new(limiter.List),
 		new(limiter.Get),
+		new(limiter.Market),
 	}
 
 	looperCmds := []cli.Command{
@@ -66,17 +70,38 @@ func main() {
 		new(waller.Get),
 		new(waller.Query),
 		new(waller.Upgrade),
+		new(waller.Backtest),
+	}
+
+	dcaCmds := []cli.Command{
+		new(dca.Add),
+	}
+
+	trailerCmds := []cli.Command{
+		new(trailer.Add),
 	}
 
 	exchangeCmds := []cli.Command{
 		new(exchange.GetOrder),
 		new(exchange.GetProduct),
+		new(exchange.Market),
+		new(exchange.Balances),
+		new(exchange.Fees),
+		new(exchange.Stats),
+	}
+
+	candlesCmds := []cli.Command{
+		new(coinbase.PrintCandles),
+		new(coinbase.VerifyCandles),
 	}
 
 	coinbaseCmds := []cli.Command{
 		new(coinbase.Sync),
 		new(coinbase.List),
 		new(coinbase.GetOrder),
+		new(coinbase.GC),
+		new(coinbase.Replay),
+		cli.CommandGroup("candles", "Print and verify saved candles", candlesCmds...),
 	}
 
 	cmds := []cli.Command{
@@ -89,6 +114,8 @@ func main() {
 		cli.CommandGroup("limiter", "Manage limit buys/sells", limiterCmds...),
 		cli.CommandGroup("looper", "Manage buy-sell loops", looperCmds...),
 		cli.CommandGroup("waller", "Manage trades in a price range", wallerCmds...),
+		cli.CommandGroup("dca", "Manage recurring buys", dcaCmds...),
+		cli.CommandGroup("trailer", "Manage trailing stop sells", trailerCmds...),
 		cli.CommandGroup("exchange", "View/query exchange directly", exchangeCmds...),
 		cli.CommandGroup("coinbase", "Handles coinbase specific operations", coinbaseCmds...),
 	}
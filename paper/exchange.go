@@ -0,0 +1,183 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+// Package paper implements a simulated exchange for paper-trading. Limit
+// orders are never sent to a real exchange; instead they rest in memory and
+// are matched against an incoming price feed.
+package paper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/shopspring/decimal"
+)
+
+var doneStatuses = []string{"FILLED", "CANCELLED", "EXPIRED"}
+
+type Exchange struct {
+	opts Options
+
+	// feed is the optional real exchange used for product metadata and the
+	// ticker prices. It is never used to place orders.
+	feed exchange.Exchange
+
+	mu sync.Mutex
+
+	productMap map[string]*Product
+
+	orderMap map[exchange.OrderID]*Product
+}
+
+var _ exchange.Exchange = &Exchange{}
+
+// New creates a paper exchange. When feed is non-nil, products are opened on
+// the feed exchange to receive live ticker prices, so that jobs can run
+// against real market data without placing any real orders. When feed is
+// nil, products must be added with AddProduct and prices must be supplied
+// with Product.HandleTicker. Paper exchange takes the ownership of the feed
+// exchange, so it is closed when the paper exchange is closed.
+func New(feed exchange.Exchange, opts *Options) *Exchange {
+	if opts == nil {
+		opts = new(Options)
+	}
+	opts.setDefaults()
+
+	return &Exchange{
+		opts:       *opts,
+		feed:       feed,
+		productMap: make(map[string]*Product),
+		orderMap:   make(map[exchange.OrderID]*Product),
+	}
+}
+
+func (ex *Exchange) Close() error {
+	ex.mu.Lock()
+	products := make([]*Product, 0, len(ex.productMap))
+	for _, p := range ex.productMap {
+		products = append(products, p)
+	}
+	ex.mu.Unlock()
+
+	for _, p := range products {
+		p.Close()
+	}
+	if ex.feed != nil {
+		ex.feed.Close()
+	}
+	return nil
+}
+
+func (ex *Exchange) ExchangeName() string {
+	if ex.feed != nil {
+		return ex.feed.ExchangeName()
+	}
+	return ex.opts.ExchangeName
+}
+
+func (ex *Exchange) IsDone(status string) bool {
+	return slices.Contains(doneStatuses, status)
+}
+
+// AddProduct registers a product with the paper exchange. Prices for the
+// product must be supplied by the caller with the HandleTicker method.
+func (ex *Exchange) AddProduct(data *gobs.Product) (*Product, error) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	if _, ok := ex.productMap[data.ProductID]; ok {
+		return nil, fmt.Errorf("product %q already exists: %w", data.ProductID, os.ErrExist)
+	}
+	p := newProduct(ex, data)
+	ex.productMap[data.ProductID] = p
+	return p, nil
+}
+
+func (ex *Exchange) OpenProduct(ctx context.Context, pid string) (exchange.Product, error) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	if p, ok := ex.productMap[pid]; ok {
+		return p, nil
+	}
+	if ex.feed == nil {
+		return nil, fmt.Errorf("product %q is not found: %w", pid, os.ErrNotExist)
+	}
+
+	data, err := ex.feed.GetProduct(ctx, pid)
+	if err != nil {
+		return nil, fmt.Errorf("could not get product %q from the feed exchange: %w", pid, err)
+	}
+	feedProduct, err := ex.feed.OpenProduct(ctx, pid)
+	if err != nil {
+		return nil, fmt.Errorf("could not open product %q on the feed exchange: %w", pid, err)
+	}
+
+	p := newProduct(ex, data)
+	p.goFollow(feedProduct)
+	ex.productMap[pid] = p
+	return p, nil
+}
+
+func (ex *Exchange) GetProduct(ctx context.Context, pid string) (*gobs.Product, error) {
+	ex.mu.Lock()
+	p, ok := ex.productMap[pid]
+	ex.mu.Unlock()
+
+	if ok {
+		return p.productData(), nil
+	}
+	if ex.feed == nil {
+		return nil, fmt.Errorf("product %q is not found: %w", pid, os.ErrNotExist)
+	}
+	return ex.feed.GetProduct(ctx, pid)
+}
+
+func (ex *Exchange) GetOrder(ctx context.Context, id exchange.OrderID) (*exchange.Order, error) {
+	ex.mu.Lock()
+	p, ok := ex.orderMap[id]
+	ex.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("order %s is not found: %w", id, os.ErrNotExist)
+	}
+	return p.Get(ctx, id)
+}
+
+// Balances always fails because paper trading doesn't hold real funds, so
+// that available funds checks are skipped for the paper trading jobs.
+func (ex *Exchange) Balances(ctx context.Context) ([]*gobs.Account, error) {
+	return nil, fmt.Errorf("paper exchange has no account balances: %w", errors.ErrUnsupported)
+}
+
+// FeeSchedule returns the simulated fee percentage as both maker and taker
+// fee rates.
+func (ex *Exchange) FeeSchedule(ctx context.Context) (*gobs.FeeSchedule, error) {
+	pct := decimal.NewFromFloat(ex.opts.FeePct)
+	v := &gobs.FeeSchedule{
+		Timestamp:   ex.opts.Clock.Now(),
+		TierName:    "paper",
+		MakerFeePct: pct,
+		TakerFeePct: pct,
+	}
+	return v, nil
+}
+
+func (ex *Exchange) addOrder(id exchange.OrderID, p *Product) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	ex.orderMap[id] = p
+}
+
+func (ex *Exchange) removeProduct(pid string) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	delete(ex.productMap, pid)
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package paper
+
+import (
+	"time"
+
+	"github.com/bvk/tradebot/clock"
+)
+
+type Options struct {
+	// FeePct is the fee percentage charged on the filled value of every order.
+	// Zero value is replaced with the default fee percentage, so NoFees must
+	// be used to simulate an exchange without any fees.
+	FeePct float64
+
+	// NoFees when true, fills the orders without any fees and FeePct is
+	// ignored.
+	NoFees bool
+
+	// ExchangeName is the name reported by the paper exchange when it is not
+	// backed by a real exchange for the price feed.
+	ExchangeName string
+
+	// SyncTickers when true, makes Product.HandleTicker block till the tickers
+	// are received by all ticker channel receivers. This is useful to replay
+	// historical prices as fast as the receivers can process them.
+	SyncTickers bool
+
+	// SyncTimeout is the max time to wait for the missing ticker receivers
+	// when SyncTickers is true and no receivers are declared with the
+	// Product.AddSyncReceiver method.
+	SyncTimeout time.Duration
+
+	// Clock is the time source for the order and fee schedule timestamps
+	// before any ticker is received. Defaults to the wall-clock time.
+	Clock clock.Clock
+}
+
+func (v *Options) setDefaults() {
+	if v.NoFees {
+		v.FeePct = 0
+	} else if v.FeePct == 0 {
+		v.FeePct = 0.25
+	}
+	if v.ExchangeName == "" {
+		v.ExchangeName = "paper"
+	}
+	if v.SyncTimeout == 0 {
+		v.SyncTimeout = time.Second
+	}
+	if v.Clock == nil {
+		v.Clock = clock.System
+	}
+}
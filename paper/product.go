@@ -0,0 +1,684 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package paper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvkgo/topic"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type Product struct {
+	exchange *Exchange
+
+	data gobs.Product
+
+	orderTopic *topic.Topic[*exchange.Order]
+
+	closeCh chan struct{}
+
+	// feed is the real product followed by this product, if any.
+	feed exchange.Product
+
+	followCancel context.CancelCauseFunc
+	followWG     sync.WaitGroup
+
+	mu sync.Mutex
+
+	lastTicker *exchange.Ticker
+
+	tickerReceivers []*tickerReceiver
+
+	orderReceivers []*orderReceiver
+
+	// subscribeCh is closed and replaced when a new ticker receiver is added
+	// or when the number of declared sync receivers changes.
+	subscribeCh chan struct{}
+
+	// numSyncReceivers is the number of ticker receivers expected when
+	// SyncTickers option is true.
+	numSyncReceivers int
+
+	// declaredReceivers is the number of ticker receivers declared with the
+	// AddSyncReceiver method. When non-zero, it is used as the number of
+	// expected receivers and missing receivers are waited for without a
+	// timeout.
+	declaredReceivers int
+
+	orderMap map[exchange.OrderID]*order
+
+	clientIDMap map[string]exchange.OrderID
+}
+
+// order holds the limit order parameters along with it's current state.
+type order struct {
+	size  decimal.Decimal
+	price decimal.Decimal
+	state *exchange.Order
+
+	// endTime is the expiry time for the GTD orders.
+	endTime time.Time
+}
+
+// tickerReceiver is a ticker channel returned by the TickerCh method.
+type tickerReceiver struct {
+	ch   chan *exchange.Ticker
+	done chan struct{}
+}
+
+// orderReceiver is an order updates channel returned by the OrderUpdatesCh
+// method when SyncTickers option is true.
+type orderReceiver struct {
+	ch   chan *exchange.Order
+	done chan struct{}
+
+	// queue holds the order updates not yet delivered to the receiver.
+	queue []*exchange.Order
+}
+
+var _ exchange.Product = &Product{}
+
+func newProduct(ex *Exchange, data *gobs.Product) *Product {
+	return &Product{
+		exchange:    ex,
+		data:        *data,
+		orderTopic:  topic.New[*exchange.Order](),
+		closeCh:     make(chan struct{}),
+		subscribeCh: make(chan struct{}),
+		// Wait for at least one receiver for the first ticker.
+		numSyncReceivers: 1,
+		orderMap:         make(map[exchange.OrderID]*order),
+		clientIDMap:      make(map[string]exchange.OrderID),
+	}
+}
+
+// goFollow starts a background goroutine that feeds the tickers from a real
+// product into the paper product.
+func (p *Product) goFollow(feed exchange.Product) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	p.feed = feed
+	p.followCancel = cancel
+
+	p.followWG.Add(1)
+	go func() {
+		defer p.followWG.Done()
+		defer feed.Close()
+
+		tickerCh, stopTickers := feed.TickerCh()
+		defer stopTickers()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ticker, ok := <-tickerCh:
+				if !ok {
+					log.Printf("paper: ticker channel for product %q is closed", p.data.ProductID)
+					return
+				}
+				p.HandleTicker(ticker)
+			}
+		}
+	}()
+}
+
+func (p *Product) Close() error {
+	if p.followCancel != nil {
+		p.followCancel(os.ErrClosed)
+		p.followWG.Wait()
+	}
+	p.exchange.removeProduct(p.data.ProductID)
+	close(p.closeCh)
+	p.orderTopic.Close()
+	return nil
+}
+
+func (p *Product) ProductID() string {
+	return p.data.ProductID
+}
+
+func (p *Product) ExchangeName() string {
+	return p.exchange.ExchangeName()
+}
+
+func (p *Product) BaseMinSize() decimal.Decimal {
+	return p.data.BaseMinSize
+}
+
+func (p *Product) BaseIncrement() decimal.Decimal {
+	return p.data.BaseIncrement
+}
+
+// TickerCh returns a channel that receives the ticker prices. When
+// SyncTickers option is true, receiver doesn't get the most recent ticker and
+// the channel is unbuffered; otherwise, channel holds only the latest ticker.
+func (p *Product) TickerCh() (<-chan *exchange.Ticker, func()) {
+	r := &tickerReceiver{
+		done: make(chan struct{}),
+	}
+
+	p.mu.Lock()
+	if p.exchange.opts.SyncTickers {
+		r.ch = make(chan *exchange.Ticker)
+	} else {
+		r.ch = make(chan *exchange.Ticker, 1)
+		if p.lastTicker != nil {
+			r.ch <- p.lastTicker
+		}
+	}
+	p.tickerReceivers = append(p.tickerReceivers, r)
+	p.notifyLocked()
+	p.mu.Unlock()
+
+	stopf := sync.OnceFunc(func() {
+		p.mu.Lock()
+		p.tickerReceivers = slices.DeleteFunc(p.tickerReceivers, func(v *tickerReceiver) bool {
+			return v == r
+		})
+		p.mu.Unlock()
+		close(r.done)
+	})
+	return r.ch, stopf
+}
+
+// AddSyncReceiver declares a ticker receiver that is expected till the
+// returned function is called. When SyncTickers option is true, HandleTicker
+// waits for all declared receivers without any timeout, so that a trader job
+// can unsubscribe and resubscribe the tickers between its steps. Caller must
+// call the returned function after the trader job is complete.
+func (p *Product) AddSyncReceiver() func() {
+	p.mu.Lock()
+	p.declaredReceivers++
+	p.notifyLocked()
+	p.mu.Unlock()
+
+	return sync.OnceFunc(func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.declaredReceivers--
+		if p.declaredReceivers == 0 {
+			// Remaining receivers, if any, are all the receivers expected.
+			p.numSyncReceivers = len(p.tickerReceivers)
+		}
+		p.notifyLocked()
+	})
+}
+
+// notifyLocked wakes up the HandleTicker calls waiting for the missing ticker
+// receivers.
+func (p *Product) notifyLocked() {
+	close(p.subscribeCh)
+	p.subscribeCh = make(chan struct{})
+}
+
+// OrderUpdatesCh returns a channel that receives the order updates. When
+// SyncTickers option is true, order updates are delivered by the
+// HandleTicker method before the ticker, so receivers always see the order
+// updates in the same order as the price changes.
+func (p *Product) OrderUpdatesCh() (<-chan *exchange.Order, func()) {
+	if !p.exchange.opts.SyncTickers {
+		sub, ch, _ := p.orderTopic.Subscribe(0, true /* includeRecent */)
+		return ch, sub.Unsubscribe
+	}
+
+	r := &orderReceiver{
+		ch:   make(chan *exchange.Order),
+		done: make(chan struct{}),
+	}
+	p.mu.Lock()
+	p.orderReceivers = append(p.orderReceivers, r)
+	p.mu.Unlock()
+
+	stopf := sync.OnceFunc(func() {
+		p.mu.Lock()
+		p.orderReceivers = slices.DeleteFunc(p.orderReceivers, func(v *orderReceiver) bool {
+			return v == r
+		})
+		p.mu.Unlock()
+		close(r.done)
+	})
+	return r.ch, stopf
+}
+
+// BestBidAsk returns the best bid and ask from the followed product's order
+// book. Without a followed product, both bid and ask are the last ticker
+// price.
+func (p *Product) BestBidAsk() *exchange.BidAsk {
+	if p.feed != nil {
+		return p.feed.BestBidAsk()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lastTicker == nil {
+		return nil
+	}
+	return &exchange.BidAsk{
+		Timestamp: p.lastTicker.Timestamp,
+		BidPrice:  p.lastTicker.Price,
+		AskPrice:  p.lastTicker.Price,
+	}
+}
+
+func (p *Product) LimitBuy(ctx context.Context, clientOrderID string, size, price decimal.Decimal, opts *exchange.LimitOptions) (exchange.OrderID, error) {
+	return p.limit(ctx, "BUY", clientOrderID, size, price, opts)
+}
+
+func (p *Product) LimitSell(ctx context.Context, clientOrderID string, size, price decimal.Decimal, opts *exchange.LimitOptions) (exchange.OrderID, error) {
+	return p.limit(ctx, "SELL", clientOrderID, size, price, opts)
+}
+
+func (p *Product) MarketBuy(ctx context.Context, clientOrderID string, size decimal.Decimal) (exchange.OrderID, error) {
+	return p.market(ctx, "BUY", clientOrderID, size, decimal.Zero)
+}
+
+func (p *Product) MarketSell(ctx context.Context, clientOrderID string, size decimal.Decimal) (exchange.OrderID, error) {
+	return p.market(ctx, "SELL", clientOrderID, size, decimal.Zero)
+}
+
+func (p *Product) MarketBuyFunds(ctx context.Context, clientOrderID string, funds decimal.Decimal) (exchange.OrderID, error) {
+	return p.market(ctx, "BUY", clientOrderID, decimal.Zero, funds)
+}
+
+func (p *Product) MarketSellFunds(ctx context.Context, clientOrderID string, funds decimal.Decimal) (exchange.OrderID, error) {
+	return p.market(ctx, "SELL", clientOrderID, decimal.Zero, funds)
+}
+
+func (p *Product) Get(ctx context.Context, id exchange.OrderID) (*exchange.Order, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	v, ok := p.orderMap[id]
+	if !ok {
+		return nil, fmt.Errorf("order %s is not found: %w", id, os.ErrNotExist)
+	}
+	return dupOrder(v.state), nil
+}
+
+func (p *Product) Cancel(ctx context.Context, id exchange.OrderID) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	v, ok := p.orderMap[id]
+	if !ok {
+		return fmt.Errorf("order %s is not found: %w", id, os.ErrNotExist)
+	}
+	if v.state.Done {
+		return nil
+	}
+	p.closeLocked(v, "CANCELLED")
+	return nil
+}
+
+// Edit changes the size and price of an open limit order. Order is filled
+// immediately if the new price crosses the current price.
+func (p *Product) Edit(ctx context.Context, id exchange.OrderID, size, price decimal.Decimal) error {
+	size, price, err := exchange.NormalizeLimit(&p.data, size, price)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	v, ok := p.orderMap[id]
+	if !ok {
+		return fmt.Errorf("order %s is not found: %w", id, os.ErrNotExist)
+	}
+	if v.state.Done {
+		return fmt.Errorf("order %s is already completed: %w", id, exchange.ErrEditRejected)
+	}
+	v.size, v.price = size, price
+
+	if t := p.lastTicker; t != nil {
+		if (v.state.Side == "BUY" && t.Price.LessThanOrEqual(price)) || (v.state.Side == "SELL" && t.Price.GreaterThanOrEqual(price)) {
+			p.fillLocked(v, t.Price)
+		}
+	}
+	return nil
+}
+
+// HandleTicker updates the current product price. All resting limit orders
+// that can be executed at the new price are filled at their limit price and
+// the ticker is relayed to the TickerCh receivers.
+//
+// When SyncTickers option is true, HandleTicker blocks till the ticker is
+// received by all receivers, which also implies that they have completed
+// processing the previous ticker. Resting orders are matched only after the
+// ticker is delivered, so that orders created in response to the previous
+// ticker are also considered for the match.
+func (p *Product) HandleTicker(ticker *exchange.Ticker) {
+	p.mu.Lock()
+	if p.lastTicker != nil && ticker.Timestamp.Time.Before(p.lastTicker.Timestamp.Time) {
+		p.mu.Unlock()
+		return
+	}
+	p.lastTicker = ticker
+
+	if !p.exchange.opts.SyncTickers {
+		p.matchLocked(ticker)
+		for _, r := range p.tickerReceivers {
+			// Replace the older ticker, if it was not received yet.
+			select {
+			case <-r.ch:
+			default:
+			}
+			r.ch <- ticker
+		}
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+
+	p.deliverOrders()
+	p.deliverSync(ticker)
+
+	p.mu.Lock()
+	p.matchLocked(ticker)
+	p.mu.Unlock()
+
+	p.deliverOrders()
+}
+
+// deliverOrders sends all pending order updates to the order update receivers
+// with blocking sends.
+func (p *Product) deliverOrders() {
+	for {
+		p.mu.Lock()
+		var receivers []*orderReceiver
+		var updates [][]*exchange.Order
+		for _, r := range p.orderReceivers {
+			if len(r.queue) > 0 {
+				receivers = append(receivers, r)
+				updates = append(updates, r.queue)
+				r.queue = nil
+			}
+		}
+		p.mu.Unlock()
+
+		if len(receivers) == 0 {
+			return
+		}
+
+		for i, r := range receivers {
+			for _, order := range updates[i] {
+				select {
+				case <-p.closeCh:
+					return
+				case <-r.done:
+				case r.ch <- order:
+				}
+			}
+		}
+	}
+}
+
+func (p *Product) sendOrderLocked(order *exchange.Order) {
+	if !p.exchange.opts.SyncTickers {
+		p.orderTopic.Send(order)
+		return
+	}
+	for _, r := range p.orderReceivers {
+		r.queue = append(r.queue, order)
+	}
+}
+
+// deliverSync sends the ticker to all receivers with blocking sends. Trader
+// jobs briefly unsubscribe and resubscribe the tickers when they move from one
+// step to another (ex: from a buy to a sell), so when fewer receivers are
+// found than expected, it waits for the missing receivers to come back. When
+// receivers are declared with the AddSyncReceiver method, it waits till the
+// declared receivers are back or removed; otherwise, it waits for up to
+// SyncTimeout duration.
+func (p *Product) deliverSync(ticker *exchange.Ticker) {
+	var timeoutCh <-chan time.Time
+	delivered := make(map[*tickerReceiver]bool)
+	for {
+		p.mu.Lock()
+		var pending []*tickerReceiver
+		for _, r := range p.tickerReceivers {
+			if !delivered[r] {
+				pending = append(pending, r)
+			}
+		}
+		nreceivers := len(p.tickerReceivers)
+		if nreceivers > p.numSyncReceivers {
+			p.numSyncReceivers = nreceivers
+		}
+		want := p.numSyncReceivers
+		declared := p.declaredReceivers > 0
+		if declared {
+			want = p.declaredReceivers
+		}
+		subscribeCh := p.subscribeCh
+		p.mu.Unlock()
+
+		if len(pending) == 0 {
+			if nreceivers >= want {
+				return
+			}
+			if !declared && timeoutCh == nil {
+				timeoutCh = time.After(p.exchange.opts.SyncTimeout)
+			}
+			select {
+			case <-p.closeCh:
+				return
+			case <-subscribeCh:
+				continue
+			case <-timeoutCh:
+				// Receivers are not coming back, so we shouldn't wait for them
+				// anymore.
+				p.mu.Lock()
+				p.numSyncReceivers = len(p.tickerReceivers)
+				p.mu.Unlock()
+				return
+			}
+		}
+
+		for _, r := range pending {
+			select {
+			case <-p.closeCh:
+				return
+			case <-r.done:
+			case r.ch <- ticker:
+				delivered[r] = true
+			}
+		}
+	}
+}
+
+func (p *Product) matchLocked(ticker *exchange.Ticker) {
+	for _, v := range p.orderMap {
+		if v.state.Done {
+			continue
+		}
+		if !v.endTime.IsZero() && !ticker.Timestamp.Time.Before(v.endTime) {
+			p.closeLocked(v, "EXPIRED")
+			continue
+		}
+		if v.state.Side == "BUY" && ticker.Price.LessThanOrEqual(v.price) {
+			p.fillLocked(v, v.price)
+			continue
+		}
+		if v.state.Side == "SELL" && ticker.Price.GreaterThanOrEqual(v.price) {
+			p.fillLocked(v, v.price)
+			continue
+		}
+	}
+}
+
+func (p *Product) limit(ctx context.Context, side, clientOrderID string, size, price decimal.Decimal, opts *exchange.LimitOptions) (exchange.OrderID, error) {
+	if opts == nil {
+		opts = new(exchange.LimitOptions)
+	}
+	if err := opts.Check(); err != nil {
+		return "", fmt.Errorf("invalid limit order options: %w", errors.Join(err, os.ErrInvalid))
+	}
+	size, price, err := exchange.NormalizeLimit(&p.data, size, price)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Check if this is a retry request for the clientOrderID.
+	if id, ok := p.clientIDMap[clientOrderID]; ok {
+		p.sendOrderLocked(dupOrder(p.orderMap[id].state))
+		return id, nil
+	}
+
+	crosses := false
+	if p.lastTicker != nil {
+		if side == "BUY" && p.lastTicker.Price.LessThanOrEqual(price) {
+			crosses = true
+		}
+		if side == "SELL" && p.lastTicker.Price.GreaterThanOrEqual(price) {
+			crosses = true
+		}
+	}
+	if crosses && opts.PostOnly {
+		return "", fmt.Errorf("limit price %s crosses the current price %s: %w", price, p.lastTicker.Price, exchange.ErrPostOnlyRejected)
+	}
+
+	id := exchange.OrderID(uuid.New().String())
+	v := &order{
+		size:  size,
+		price: price,
+		state: &exchange.Order{
+			OrderID:       id,
+			ClientOrderID: clientOrderID,
+			Side:          side,
+			CreateTime:    exchange.RemoteTime{Time: p.nowLocked()},
+			Status:        "OPEN",
+		},
+	}
+	if opts.TimeInForce == "GTD" {
+		v.endTime = opts.EndTime
+	}
+	p.orderMap[id] = v
+	p.clientIDMap[clientOrderID] = id
+	p.exchange.addOrder(id, p)
+	p.sendOrderLocked(dupOrder(v.state))
+
+	// Limit orders that cross the current price are executed immediately at the
+	// current price. IOC and FOK orders are cancelled if they cannot be executed
+	// immediately; orders are always filled completely here, so they behave the
+	// same.
+	if crosses {
+		p.fillLocked(v, p.lastTicker.Price)
+	} else if opts.TimeInForce == "IOC" || opts.TimeInForce == "FOK" {
+		p.closeLocked(v, "CANCELLED")
+	}
+	return id, nil
+}
+
+// closeLocked completes an unfilled order with the given status.
+func (p *Product) closeLocked(v *order, status string) {
+	v.state.Status = status
+	v.state.Done = true
+	v.state.DoneReason = status
+	v.state.FinishTime = exchange.RemoteTime{Time: p.nowLocked()}
+	p.sendOrderLocked(dupOrder(v.state))
+}
+
+// market creates an order that is filled immediately at the latest ticker
+// price. Order size is given as base size or as quote funds, but not both.
+func (p *Product) market(ctx context.Context, side, clientOrderID string, size, funds decimal.Decimal) (exchange.OrderID, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Check if this is a retry request for the clientOrderID.
+	if id, ok := p.clientIDMap[clientOrderID]; ok {
+		p.sendOrderLocked(dupOrder(p.orderMap[id].state))
+		return id, nil
+	}
+
+	if p.lastTicker == nil {
+		return "", fmt.Errorf("market price is not known yet: %w", os.ErrInvalid)
+	}
+	price := p.lastTicker.Price
+
+	if size.IsZero() {
+		f, err := exchange.NormalizeFunds(&p.data, funds)
+		if err != nil {
+			return "", err
+		}
+		size = f.Div(price)
+	}
+	size, err := exchange.NormalizeSize(&p.data, size)
+	if err != nil {
+		return "", err
+	}
+
+	id := exchange.OrderID(uuid.New().String())
+	v := &order{
+		size:  size,
+		price: price,
+		state: &exchange.Order{
+			OrderID:       id,
+			ClientOrderID: clientOrderID,
+			Side:          side,
+			CreateTime:    exchange.RemoteTime{Time: p.nowLocked()},
+			Status:        "OPEN",
+		},
+	}
+	p.orderMap[id] = v
+	p.clientIDMap[clientOrderID] = id
+	p.exchange.addOrder(id, p)
+	p.sendOrderLocked(dupOrder(v.state))
+	p.fillLocked(v, price)
+	return id, nil
+}
+
+func (p *Product) fillLocked(v *order, price decimal.Decimal) {
+	value := v.size.Mul(price)
+	fee := value.Mul(decimal.NewFromFloat(p.exchange.opts.FeePct)).Div(decimal.NewFromInt(100))
+
+	v.state.FilledSize = v.size
+	v.state.FilledPrice = price
+	v.state.Fee = fee
+	v.state.Status = "FILLED"
+	v.state.Done = true
+	v.state.FinishTime = exchange.RemoteTime{Time: p.nowLocked()}
+	p.sendOrderLocked(dupOrder(v.state))
+}
+
+// nowLocked returns the timestamp of the latest ticker, so that order
+// timestamps follow the price feed, which may be a replay of the past.
+func (p *Product) nowLocked() time.Time {
+	if p.lastTicker != nil {
+		return p.lastTicker.Timestamp.Time
+	}
+	return p.exchange.opts.Clock.Now()
+}
+
+func (p *Product) productData() *gobs.Product {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data := p.data
+	if p.lastTicker != nil {
+		data.Price = p.lastTicker.Price
+	}
+	return &data
+}
+
+func dupOrder(v *exchange.Order) *exchange.Order {
+	tmp := new(exchange.Order)
+	*tmp = *v
+	return tmp
+}
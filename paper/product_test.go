@@ -0,0 +1,336 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package paper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/shopspring/decimal"
+)
+
+func TestLimitOrders(t *testing.T) {
+	ctx := context.Background()
+
+	ex := New(nil, &Options{FeePct: 0.5})
+	defer ex.Close()
+
+	p, err := ex.AddProduct(&gobs.Product{
+		ProductID:      "BTC-USD",
+		BaseMinSize:    decimal.RequireFromString("0.0001"),
+		QuoteIncrement: decimal.RequireFromString("0.01"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tick := func(price string) {
+		now = now.Add(time.Second)
+		p.HandleTicker(&exchange.Ticker{
+			Timestamp: exchange.RemoteTime{Time: now},
+			Price:     decimal.RequireFromString(price),
+		})
+	}
+	tick("100")
+
+	size := decimal.NewFromInt(2)
+	buyID, err := p.LimitBuy(ctx, "buy-1", size, decimal.NewFromInt(90), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sellID, err := p.LimitSell(ctx, "sell-1", size, decimal.NewFromInt(120), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Retries with the same client order id must return the same order.
+	if id, err := p.LimitBuy(ctx, "buy-1", size, decimal.NewFromInt(90), nil); err != nil || id != buyID {
+		t.Fatalf("want %s, got %s (err %v)", buyID, id, err)
+	}
+
+	tick("95")
+	if order, _ := p.Get(ctx, buyID); order.Done {
+		t.Fatalf("buy order must not be filled at price 95")
+	}
+
+	tick("89.5")
+	buy, err := ex.GetOrder(ctx, buyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !buy.Done || buy.Status != "FILLED" {
+		t.Fatalf("want filled buy order, got %v", buy)
+	}
+	if !buy.FilledPrice.Equal(decimal.NewFromInt(90)) {
+		t.Fatalf("want filled price 90, got %s", buy.FilledPrice)
+	}
+	if want := decimal.RequireFromString("0.9"); !buy.Fee.Equal(want) {
+		t.Fatalf("want fee %s, got %s", want, buy.Fee)
+	}
+
+	if err := p.Cancel(ctx, sellID); err != nil {
+		t.Fatal(err)
+	}
+	tick("130")
+	sell, err := p.Get(ctx, sellID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sell.Done || sell.Status != "CANCELLED" || !sell.FilledSize.IsZero() {
+		t.Fatalf("want cancelled sell order, got %v", sell)
+	}
+
+	// Limit orders crossing the current price are filled immediately.
+	id, err := p.LimitSell(ctx, "sell-2", size, decimal.NewFromInt(125), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order, _ := p.Get(ctx, id); !order.Done || !order.FilledPrice.Equal(decimal.NewFromInt(130)) {
+		t.Fatalf("want sell order filled at 130, got %v", order)
+	}
+}
+
+func TestMarketOrders(t *testing.T) {
+	ctx := context.Background()
+
+	ex := New(nil, &Options{FeePct: 0.5})
+	defer ex.Close()
+
+	p, err := ex.AddProduct(&gobs.Product{
+		ProductID:     "BTC-USD",
+		BaseMinSize:   decimal.RequireFromString("0.0001"),
+		BaseIncrement: decimal.RequireFromString("0.0001"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Market orders need a known price.
+	if _, err := p.MarketBuy(ctx, "buy-0", decimal.NewFromInt(1)); err == nil {
+		t.Fatalf("want error for market order without a ticker")
+	}
+
+	p.HandleTicker(&exchange.Ticker{
+		Timestamp: exchange.RemoteTime{Time: time.Now()},
+		Price:     decimal.NewFromInt(300),
+	})
+
+	buyID, err := p.MarketBuy(ctx, "buy-1", decimal.NewFromInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order, _ := p.Get(ctx, buyID); !order.Done || !order.FilledSize.Equal(decimal.NewFromInt(2)) || !order.FilledPrice.Equal(decimal.NewFromInt(300)) {
+		t.Fatalf("want buy order filled for size 2 at 300, got %v", order)
+	}
+
+	sellID, err := p.MarketSellFunds(ctx, "sell-1", decimal.NewFromInt(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order, _ := p.Get(ctx, sellID); !order.Done || !order.FilledSize.Equal(decimal.RequireFromString("0.3333")) {
+		t.Fatalf("want sell order filled for size 0.3333, got %v", order)
+	}
+}
+
+func TestNoFees(t *testing.T) {
+	ctx := context.Background()
+
+	ex := New(nil, &Options{NoFees: true})
+	defer ex.Close()
+
+	p, err := ex.AddProduct(&gobs.Product{
+		ProductID:     "BTC-USD",
+		BaseIncrement: decimal.RequireFromString("0.0001"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.HandleTicker(&exchange.Ticker{
+		Timestamp: exchange.RemoteTime{Time: time.Now()},
+		Price:     decimal.NewFromInt(300),
+	})
+
+	buyID, err := p.MarketBuy(ctx, "buy-1", decimal.NewFromInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order, _ := p.Get(ctx, buyID); !order.Done || !order.Fee.IsZero() {
+		t.Fatalf("want buy order filled without any fee, got %v", order)
+	}
+
+	fees, err := ex.FeeSchedule(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fees.MakerFeePct.IsZero() || !fees.TakerFeePct.IsZero() {
+		t.Fatalf("want zero fee rates, got %s and %s", fees.MakerFeePct, fees.TakerFeePct)
+	}
+}
+
+func TestLimitOrderOptions(t *testing.T) {
+	ctx := context.Background()
+
+	ex := New(nil, &Options{FeePct: 0.5})
+	defer ex.Close()
+
+	p, err := ex.AddProduct(&gobs.Product{
+		ProductID:   "BTC-USD",
+		BaseMinSize: decimal.RequireFromString("0.0001"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tick := func(price string) {
+		now = now.Add(time.Minute)
+		p.HandleTicker(&exchange.Ticker{
+			Timestamp: exchange.RemoteTime{Time: now},
+			Price:     decimal.RequireFromString(price),
+		})
+	}
+	tick("100")
+
+	size := decimal.NewFromInt(1)
+	postOnly := &exchange.LimitOptions{PostOnly: true}
+	if _, err := p.LimitBuy(ctx, "buy-1", size, decimal.NewFromInt(101), postOnly); !errors.Is(err, exchange.ErrPostOnlyRejected) {
+		t.Fatalf("want ErrPostOnlyRejected, got %v", err)
+	}
+	if _, err := p.LimitBuy(ctx, "buy-1", size, decimal.NewFromInt(99), postOnly); err != nil {
+		t.Fatal(err)
+	}
+
+	ioc := &exchange.LimitOptions{TimeInForce: "IOC"}
+	id, err := p.LimitSell(ctx, "sell-1", size, decimal.NewFromInt(105), ioc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order, _ := p.Get(ctx, id); !order.Done || order.Status != "CANCELLED" {
+		t.Fatalf("want cancelled ioc order, got %v", order)
+	}
+
+	gtd := &exchange.LimitOptions{TimeInForce: "GTD", EndTime: now.Add(2 * time.Minute)}
+	id, err = p.LimitSell(ctx, "sell-2", size, decimal.NewFromInt(105), gtd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tick("101")
+	if order, _ := p.Get(ctx, id); order.Done {
+		t.Fatalf("gtd order must not expire before the end time")
+	}
+	tick("102")
+	if order, _ := p.Get(ctx, id); !order.Done || order.Status != "EXPIRED" {
+		t.Fatalf("want expired gtd order, got %v", order)
+	}
+}
+
+func TestEditOrder(t *testing.T) {
+	ctx := context.Background()
+
+	ex := New(nil, &Options{FeePct: 0.5})
+	defer ex.Close()
+
+	p, err := ex.AddProduct(&gobs.Product{
+		ProductID:      "BTC-USD",
+		BaseMinSize:    decimal.RequireFromString("0.0001"),
+		QuoteIncrement: decimal.RequireFromString("0.01"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tick := func(price string) {
+		now = now.Add(time.Second)
+		p.HandleTicker(&exchange.Ticker{
+			Timestamp: exchange.RemoteTime{Time: now},
+			Price:     decimal.RequireFromString(price),
+		})
+	}
+	tick("100")
+
+	buyID, err := p.LimitBuy(ctx, "buy-1", decimal.NewFromInt(2), decimal.NewFromInt(90), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Edit(ctx, buyID, decimal.NewFromInt(1), decimal.NewFromInt(95)); err != nil {
+		t.Fatal(err)
+	}
+
+	tick("94")
+	buy, err := p.Get(ctx, buyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !buy.Done || !buy.FilledSize.Equal(decimal.NewFromInt(1)) || !buy.FilledPrice.Equal(decimal.NewFromInt(95)) {
+		t.Fatalf("want buy order filled with the edited size and price, got %v", buy)
+	}
+
+	if err := p.Edit(ctx, buyID, decimal.NewFromInt(2), decimal.NewFromInt(90)); !errors.Is(err, exchange.ErrEditRejected) {
+		t.Fatalf("want ErrEditRejected for a completed order, got %v", err)
+	}
+}
+
+func TestSyncReceivers(t *testing.T) {
+	// Timeout is too small to wait for a receiver that is slow to resubscribe,
+	// so the declared receiver must be waited for without the timeout.
+	ex := New(nil, &Options{SyncTickers: true, SyncTimeout: time.Millisecond})
+	defer ex.Close()
+
+	p, err := ex.AddProduct(&gobs.Product{ProductID: "BTC-USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tick := func(price int64) {
+		now = now.Add(time.Second)
+		p.HandleTicker(&exchange.Ticker{
+			Timestamp: exchange.RemoteTime{Time: now},
+			Price:     decimal.NewFromInt(price),
+		})
+	}
+
+	removeReceiver := p.AddSyncReceiver()
+	receivedCh := make(chan int64, 10)
+	go func() {
+		defer removeReceiver()
+
+		// Receive the first ticker and resubscribe after a delay, like a trader
+		// job moving from one step to another.
+		ch, stop := p.TickerCh()
+		receivedCh <- (<-ch).Price.IntPart()
+		stop()
+
+		time.Sleep(100 * time.Millisecond)
+		ch, stop = p.TickerCh()
+		receivedCh <- (<-ch).Price.IntPart()
+		stop()
+	}()
+
+	tick(100)
+	tick(101)
+	if got := <-receivedCh; got != 100 {
+		t.Fatalf("want first ticker 100, got %d", got)
+	}
+	if got := <-receivedCh; got != 101 {
+		t.Fatalf("want second ticker 101 after resubscribe, got %d", got)
+	}
+
+	// Ticker must not block after the declared receiver is removed.
+	doneCh := make(chan struct{})
+	go func() {
+		tick(102)
+		close(doneCh)
+	}()
+	select {
+	case <-doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ticker is blocked after the receiver is removed")
+	}
+}
@@ -5,12 +5,13 @@ package server
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 
 	"github.com/bvk/tradebot/api"
 	"github.com/bvk/tradebot/exchange"
-	"github.com/bvk/tradebot/gobs"
+	"github.com/google/uuid"
 )
 
 func (s *Server) doExchangeGetOrder(ctx context.Context, req *api.ExchangeGetOrderRequest) (*api.ExchangeGetOrderResponse, error) {
@@ -23,19 +24,7 @@ func (s *Server) doExchangeGetOrder(ctx context.Context, req *api.ExchangeGetOrd
 		return &api.ExchangeGetOrderResponse{Error: err.Error()}, nil
 	}
 	resp := &api.ExchangeGetOrderResponse{
-		Order: &gobs.Order{
-			ServerOrderID: string(order.OrderID),
-			ClientOrderID: order.ClientOrderID,
-			Side:          order.Side,
-			Status:        order.Status,
-			CreateTime:    gobs.RemoteTime{Time: order.CreateTime.Time},
-			FinishTime:    gobs.RemoteTime{Time: order.FinishTime.Time},
-			FilledFee:     order.Fee,
-			FilledSize:    order.FilledSize,
-			FilledPrice:   order.FilledPrice,
-			Done:          order.Done,
-			DoneReason:    order.DoneReason,
-		},
+		Order: exchange.GobOrder(order),
 	}
 	return resp, nil
 }
@@ -49,5 +38,107 @@ func (s *Server) doGetProduct(ctx context.Context, req *api.ExchangeGetProductRe
 	if err != nil {
 		return &api.ExchangeGetProductResponse{Error: err.Error()}, nil
 	}
-	return &api.ExchangeGetProductResponse{Product: product}, nil
+	resp := &api.ExchangeGetProductResponse{Product: product}
+
+	s.mu.Lock()
+	if p, ok := s.exProductsMap[strings.ToLower(req.ExchangeName)][req.ProductID]; ok {
+		resp.BestBidAsk = p.BestBidAsk()
+	}
+	s.mu.Unlock()
+	return resp, nil
+}
+
+func (s *Server) doExchangeMarket(ctx context.Context, req *api.ExchangeMarketRequest) (_ *api.ExchangeMarketResponse, status error) {
+	defer func() {
+		if status != nil {
+			slog.ErrorContext(ctx, "market request has failed", "error", status)
+		}
+	}()
+
+	if err := req.Check(); err != nil {
+		return nil, fmt.Errorf("invalid market request: %w", err)
+	}
+
+	product, err := s.getProduct(ctx, req.ExchangeName, req.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	clientOrderID := req.ClientOrderID
+	if len(clientOrderID) == 0 {
+		clientOrderID = uuid.New().String()
+	}
+
+	var orderID exchange.OrderID
+	switch {
+	case req.Side == "BUY" && !req.Size.IsZero():
+		orderID, err = product.MarketBuy(ctx, clientOrderID, req.Size)
+	case req.Side == "BUY":
+		orderID, err = product.MarketBuyFunds(ctx, clientOrderID, req.Funds)
+	case !req.Size.IsZero():
+		orderID, err = product.MarketSell(ctx, clientOrderID, req.Size)
+	default:
+		orderID, err = product.MarketSellFunds(ctx, clientOrderID, req.Funds)
+	}
+	if err != nil {
+		return &api.ExchangeMarketResponse{Error: err.Error(), ClientOrderID: clientOrderID}, nil
+	}
+	resp := &api.ExchangeMarketResponse{
+		ClientOrderID: clientOrderID,
+		OrderID:       string(orderID),
+	}
+	return resp, nil
+}
+
+func (s *Server) doExchangeBalances(ctx context.Context, req *api.ExchangeBalancesRequest) (*api.ExchangeBalancesResponse, error) {
+	ex, ok := s.exchangeMap[strings.ToLower(req.ExchangeName)]
+	if !ok {
+		return nil, fmt.Errorf("no exchange with name %q: %w", req.ExchangeName, os.ErrNotExist)
+	}
+	accounts, err := ex.Balances(ctx)
+	if err != nil {
+		return &api.ExchangeBalancesResponse{Error: err.Error()}, nil
+	}
+	return &api.ExchangeBalancesResponse{Accounts: accounts}, nil
+}
+
+func (s *Server) doExchangeFeeSchedule(ctx context.Context, req *api.ExchangeFeeScheduleRequest) (*api.ExchangeFeeScheduleResponse, error) {
+	ex, ok := s.exchangeMap[strings.ToLower(req.ExchangeName)]
+	if !ok {
+		return nil, fmt.Errorf("no exchange with name %q: %w", req.ExchangeName, os.ErrNotExist)
+	}
+	fees, err := ex.FeeSchedule(ctx)
+	if err != nil {
+		return &api.ExchangeFeeScheduleResponse{Error: err.Error()}, nil
+	}
+	return &api.ExchangeFeeScheduleResponse{FeeSchedule: fees}, nil
+}
+
+func (s *Server) doExchangeStats(ctx context.Context, req *api.ExchangeStatsRequest) (*api.ExchangeStatsResponse, error) {
+	ex, ok := s.exchangeMap[strings.ToLower(req.ExchangeName)]
+	if !ok {
+		return nil, fmt.Errorf("no exchange with name %q: %w", req.ExchangeName, os.ErrNotExist)
+	}
+	reporter, ok := ex.(exchange.StatsReporter)
+	if !ok {
+		return &api.ExchangeStatsResponse{Error: fmt.Sprintf("exchange %q doesn't report any stats", req.ExchangeName)}, nil
+	}
+
+	stats := reporter.Stats()
+	resp := &api.ExchangeStatsResponse{
+		NumGaps:    stats.NumGaps,
+		NumResyncs: stats.NumResyncs,
+	}
+	for _, v := range stats.Throttles {
+		resp.Throttles = append(resp.Throttles, &api.ExchangeThrottle{
+			Name:         v.Name,
+			Limit:        v.Limit,
+			Burst:        v.Burst,
+			Tokens:       v.Tokens,
+			NumRequests:  v.NumRequests,
+			NumThrottled: v.NumThrottled,
+			BackoffUntil: v.BackoffUntil,
+		})
+	}
+	return resp, nil
 }
@@ -0,0 +1,141 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/limiter"
+	"github.com/bvk/tradebot/trader"
+	"github.com/bvk/tradebot/trailer"
+	"github.com/shopspring/decimal"
+)
+
+// feePct returns the current maker fee percentage for the exchange. It
+// returns the default fee percentage if the fee schedule is not available.
+func (s *Server) feePct(ctx context.Context, exchangeName string) float64 {
+	ex, ok := s.exchangeMap[strings.ToLower(exchangeName)]
+	if !ok {
+		return exchange.DefaultFeePct
+	}
+	fees, err := ex.FeeSchedule(ctx)
+	if err != nil {
+		log.Printf("could not fetch fee schedule for exchange %q (using default %.2f%%): %v", exchangeName, exchange.DefaultFeePct, err)
+		return exchange.DefaultFeePct
+	}
+	return fees.MakerFeePct.InexactFloat64()
+}
+
+// checkFunds verifies that the exchange account has enough available balance
+// for a new job that needs the given quote and base currency amounts. Funds on
+// hold for the resting orders are counted as part of the balance because they
+// are already included in the reservations of the running jobs on the same
+// exchange. Jobs on exchanges that cannot report balances are not checked.
+func (s *Server) checkFunds(ctx context.Context, exchangeName, productID string, quote, base decimal.Decimal) error {
+	exchangeName = strings.ToLower(exchangeName)
+	ex, ok := s.exchangeMap[exchangeName]
+	if !ok {
+		return fmt.Errorf("exchange with name %q not found: %w", exchangeName, os.ErrNotExist)
+	}
+
+	accounts, err := ex.Balances(ctx)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil
+		}
+		return fmt.Errorf("could not fetch account balances: %w", err)
+	}
+
+	product, err := ex.GetProduct(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("could not fetch product %q info: %w", productID, err)
+	}
+
+	reservedQuote, reservedBase, err := s.reservedFunds(ctx, ex, product)
+	if err != nil {
+		return err
+	}
+
+	available := func(currencyID string) decimal.Decimal {
+		var sum decimal.Decimal
+		for _, a := range accounts {
+			if a.CurrencyID == currencyID {
+				sum = sum.Add(a.Available).Add(a.Hold)
+			}
+		}
+		return sum
+	}
+
+	if quote.IsPositive() {
+		if v := available(product.QuoteCurrencyID).Sub(reservedQuote); v.LessThan(quote) {
+			return fmt.Errorf("available %s balance %s (after %s reserved by running jobs) is less than the required %s: %w", product.QuoteCurrencyID, v.StringFixed(3), reservedQuote.StringFixed(3), quote.StringFixed(3), os.ErrInvalid)
+		}
+	}
+	if base.IsPositive() {
+		if v := available(product.BaseCurrencyID).Sub(reservedBase); v.LessThan(base) {
+			return fmt.Errorf("available %s balance %s (after %s reserved by running jobs) is less than the required %s: %w", product.BaseCurrencyID, v, reservedBase, base, os.ErrInvalid)
+		}
+	}
+	return nil
+}
+
+// reservedFunds returns the quote and base currency amounts of the given
+// product that are reserved by the running jobs on the exchange. Sell jobs
+// reserve their pending size in the base currency, buy limiters reserve the
+// value of their unfilled size and all other jobs reserve their budget in the
+// quote currency.
+func (s *Server) reservedFunds(ctx context.Context, ex exchange.Exchange, product *gobs.Product) (quote, base decimal.Decimal, err error) {
+	feePct := s.feePct(ctx, ex.ExchangeName())
+	productMap := map[string]*gobs.Product{product.ProductID: product}
+
+	var traders []trader.Trader
+	s.jobMap.Range(func(_ string, v trader.Trader) bool {
+		if strings.EqualFold(v.ExchangeName(), ex.ExchangeName()) {
+			traders = append(traders, v)
+		}
+		return true
+	})
+
+	for _, v := range traders {
+		p, ok := productMap[v.ProductID()]
+		if !ok {
+			p, err = ex.GetProduct(ctx, v.ProductID())
+			if err != nil {
+				return decimal.Zero, decimal.Zero, fmt.Errorf("could not fetch product %q info: %w", v.ProductID(), err)
+			}
+			productMap[v.ProductID()] = p
+		}
+
+		switch t := v.(type) {
+		case *limiter.Limiter:
+			if t.IsSell() {
+				if p.BaseCurrencyID == product.BaseCurrencyID {
+					base = base.Add(t.PendingSize())
+				}
+				continue
+			}
+			if p.QuoteCurrencyID == product.QuoteCurrencyID {
+				pending := t.Point()
+				pending.Size = t.PendingSize()
+				quote = quote.Add(pending.Value()).Add(pending.FeeAt(feePct))
+			}
+			continue
+		case *trailer.Trailer:
+			if p.BaseCurrencyID == product.BaseCurrencyID {
+				base = base.Add(t.PendingSize())
+			}
+			continue
+		}
+		if p.QuoteCurrencyID == product.QuoteCurrencyID {
+			quote = quote.Add(v.BudgetAt(feePct))
+		}
+	}
+	return quote, base, nil
+}
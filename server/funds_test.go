@@ -0,0 +1,72 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/limiter"
+	"github.com/bvk/tradebot/point"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type fundsExchange struct {
+	exchange.Exchange
+
+	accounts []*gobs.Account
+}
+
+func (ex *fundsExchange) ExchangeName() string {
+	return "coinbase"
+}
+
+func (ex *fundsExchange) GetProduct(ctx context.Context, id string) (*gobs.Product, error) {
+	return &gobs.Product{ProductID: id, BaseCurrencyID: "BCH", QuoteCurrencyID: "USD"}, nil
+}
+
+func (ex *fundsExchange) Balances(ctx context.Context) ([]*gobs.Account, error) {
+	return ex.accounts, nil
+}
+
+func (ex *fundsExchange) FeeSchedule(ctx context.Context) (*gobs.FeeSchedule, error) {
+	pct := decimal.NewFromFloat(0.25)
+	return &gobs.FeeSchedule{MakerFeePct: pct, TakerFeePct: pct}, nil
+}
+
+func TestCheckFundsWithRestingOrder(t *testing.T) {
+	ctx := context.Background()
+
+	// A running buy limiter for 1 BCH at $100 has a resting order, so the
+	// exchange holds $100.25 for it out of the $150.25 total balance.
+	ex := &fundsExchange{
+		accounts: []*gobs.Account{{
+			CurrencyID: "USD",
+			Available:  decimal.NewFromInt(50),
+			Hold:       decimal.RequireFromString("100.25"),
+		}},
+	}
+	s := &Server{exchangeMap: map[string]exchange.Exchange{"coinbase": ex}}
+
+	limit, err := limiter.New(uuid.New().String(), "coinbase", "BCH-USD", &point.Point{
+		Size:   decimal.NewFromInt(1),
+		Price:  decimal.NewFromInt(100),
+		Cancel: decimal.NewFromInt(110),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.jobMap.Store(limit.UID(), limit)
+
+	if err := s.checkFunds(ctx, "coinbase", "BCH-USD", decimal.NewFromInt(50), decimal.Zero); err != nil {
+		t.Fatalf("new job within the available balance: want success, got %v", err)
+	}
+	if err := s.checkFunds(ctx, "coinbase", "BCH-USD", decimal.NewFromInt(51), decimal.Zero); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("new job over the available balance: want os.ErrInvalid, got %v", err)
+	}
+}
@@ -10,10 +10,12 @@ import (
 	"path"
 	"strings"
 
+	"github.com/bvk/tradebot/dca"
 	"github.com/bvk/tradebot/limiter"
 	"github.com/bvk/tradebot/looper"
 	"github.com/bvk/tradebot/namer"
 	"github.com/bvk/tradebot/trader"
+	"github.com/bvk/tradebot/trailer"
 	"github.com/bvk/tradebot/waller"
 	"github.com/bvkgo/kv"
 	"github.com/google/uuid"
@@ -70,6 +72,18 @@ func LoadTraders(ctx context.Context, r kv.Reader) ([]trader.Trader, error) {
 	}
 	traders = append(traders, wallers...)
 
+	dcas, err := load(ctx, r, dca.DefaultKeyspace, dca.Load)
+	if err != nil {
+		return nil, fmt.Errorf("could not load all existing dcas: %w", err)
+	}
+	traders = append(traders, dcas...)
+
+	trailers, err := load(ctx, r, trailer.DefaultKeyspace, trailer.Load)
+	if err != nil {
+		return nil, fmt.Errorf("could not load all existing trailers: %w", err)
+	}
+	traders = append(traders, trailers...)
+
 	return traders, nil
 }
 
@@ -85,6 +99,8 @@ func Load(ctx context.Context, r kv.Reader, uid, typename string) (trader.Trader
 			{limiter.DefaultKeyspace, "limiter"},
 			{looper.DefaultKeyspace, "looper"},
 			{waller.DefaultKeyspace, "waller"},
+			{dca.DefaultKeyspace, "dca"},
+			{trailer.DefaultKeyspace, "trailer"},
 		}
 		for _, ks := range kss {
 			key := path.Join(ks[0], uid)
@@ -102,6 +118,10 @@ func Load(ctx context.Context, r kv.Reader, uid, typename string) (trader.Trader
 		return looper.Load(ctx, uid, r)
 	case strings.EqualFold(typename, "waller"):
 		return waller.Load(ctx, uid, r)
+	case strings.EqualFold(typename, "dca"):
+		return dca.Load(ctx, uid, r)
+	case strings.EqualFold(typename, "trailer"):
+		return trailer.Load(ctx, uid, r)
 	}
 
 	return nil, fmt.Errorf("unsupported trader type %q", typename)
@@ -12,20 +12,37 @@ type Options struct {
 	// NoResume when true, will NOT resume the trade jobs automatically.
 	NoResume bool
 
-	// NoFetchCandles when true, will disable periodic fetch of product candles data.
-	NoFetchCandles bool
-
-	// Max time latency for fetching the server time from coinbase.
-	MaxFetchTimeLatency time.Duration
+	// PaperTrading when true, replaces the exchanges with simulated exchanges
+	// that use the real exchanges only for the price feed, so that orders are
+	// never placed on the real exchanges.
+	PaperTrading bool
+
+	// PaperTradingFeePct is the fee percentage charged by the simulated
+	// exchanges when PaperTrading is true. Zero value is replaced with the
+	// default fee percentage unless PaperTradingNoFees is true.
+	PaperTradingFeePct float64
+	PaperTradingNoFees bool
+
+	// DataDir and QuoteCurrencies are passed to the exchanges as the exchange
+	// options with the same names.
+	DataDir         string
+	QuoteCurrencies []string
+
+	// ExchangeParams holds the exchange specific parameters for each exchange
+	// name, which are passed to the exchanges as the Params option.
+	ExchangeParams map[string]map[string]string
+
+	// EnableProductIDs holds the product ids to enable for trading in addition
+	// to the already enabled products. Product ids can be prefixed with an
+	// exchange name and a colon (eg: "binance:BTC-USDT"); ids without the
+	// prefix refer to the coinbase products.
+	EnableProductIDs []string
 
 	// Max timeout for http requests.
 	MaxHttpClientTimeout time.Duration
 }
 
 func (v *Options) setDefaults() {
-	if v.MaxFetchTimeLatency == 0 {
-		v.MaxFetchTimeLatency = time.Second
-	}
 	if v.MaxHttpClientTimeout == 0 {
 		v.MaxHttpClientTimeout = 10 * time.Second
 	}
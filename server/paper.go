@@ -0,0 +1,55 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bvk/tradebot/kvutil"
+	"github.com/bvkgo/kv"
+)
+
+// paperTradingKey marks the databases used for paper trading. Simulated
+// order ids and fills must never be saved over the real job states, so a
+// database is used either for paper trading or for live trading, but never
+// both.
+const paperTradingKey = "/server/paper-trading"
+
+// checkTradingMode verifies that the database is not used for live and
+// paper trading at the same time. Databases without any jobs are marked for
+// paper trading when paper is true.
+func checkTradingMode(ctx context.Context, db kv.Database, paper bool) error {
+	check := func(ctx context.Context, rw kv.ReadWriter) error {
+		_, err := kvutil.GetString[string](ctx, rw, paperTradingKey)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("could not load paper trading marker: %w", err)
+		}
+		marked := err == nil
+
+		if !paper {
+			if marked {
+				return fmt.Errorf("database has paper trading jobs; use a separate data directory for live trading: %w", os.ErrInvalid)
+			}
+			return nil
+		}
+		if marked {
+			return nil
+		}
+
+		traders, err := LoadTraders(ctx, rw)
+		if err != nil {
+			return err
+		}
+		if len(traders) > 0 {
+			return fmt.Errorf("database has %d live trading jobs; use a separate data directory for paper trading: %w", len(traders), os.ErrInvalid)
+		}
+		if err := kvutil.SetString(ctx, rw, paperTradingKey, "true"); err != nil {
+			return fmt.Errorf("could not save paper trading marker: %w", err)
+		}
+		return nil
+	}
+	return kv.WithReadWriter(ctx, db, check)
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bvk/tradebot/limiter"
+	"github.com/bvk/tradebot/point"
+	"github.com/bvkgo/kv"
+	"github.com/bvkgo/kv/kvmemdb"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestPaperTradingOverLiveJobs(t *testing.T) {
+	ctx := context.Background()
+
+	db := kvmemdb.New()
+	limit, err := limiter.New(uuid.New().String(), "coinbase", "BCH-USD", &point.Point{
+		Size:   decimal.NewFromInt(1),
+		Price:  decimal.NewFromInt(100),
+		Cancel: decimal.NewFromInt(110),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.WithReadWriter(ctx, db, limit.Save); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(ctx, &Secrets{}, db, &Options{PaperTrading: true}); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("paper trading over live jobs: want os.ErrInvalid, got %v", err)
+	}
+	if err := checkTradingMode(ctx, db, true); err == nil {
+		t.Fatalf("paper trading over live jobs: want an error")
+	}
+	if err := checkTradingMode(ctx, db, false); err != nil {
+		t.Fatalf("live trading over live jobs: want success, got %v", err)
+	}
+
+	paperDB := kvmemdb.New()
+	if err := checkTradingMode(ctx, paperDB, true); err != nil {
+		t.Fatalf("paper trading over an empty db: want success, got %v", err)
+	}
+	if err := checkTradingMode(ctx, paperDB, true); err != nil {
+		t.Fatalf("paper trading over a paper db: want success, got %v", err)
+	}
+	if err := checkTradingMode(ctx, paperDB, false); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("live trading over a paper db: want os.ErrInvalid, got %v", err)
+	}
+}
@@ -5,14 +5,21 @@ package server
 import (
 	"encoding/json"
 	"os"
+	"strings"
 
 	"github.com/bvk/tradebot/coinbase"
+	"github.com/bvk/tradebot/exchange"
 	"github.com/bvk/tradebot/pushover"
 )
 
 type Secrets struct {
 	Coinbase *coinbase.Credentials
 	Pushover *pushover.Keys
+
+	// Exchanges holds the credentials for the exchanges in the exchange
+	// registry indexed by the exchange name. Coinbase credentials can be
+	// given either here or with the Coinbase field.
+	Exchanges map[string]*exchange.Credentials `json:",omitempty"`
 }
 
 func SecretsFromFile(fpath string) (*Secrets, error) {
@@ -26,3 +33,19 @@ func SecretsFromFile(fpath string) (*Secrets, error) {
 	}
 	return s, nil
 }
+
+// ExchangeCredentials returns the credentials for all exchanges indexed by
+// the lower-case exchange names.
+func (s *Secrets) ExchangeCredentials() map[string]*exchange.Credentials {
+	cmap := make(map[string]*exchange.Credentials)
+	for name, creds := range s.Exchanges {
+		if creds != nil {
+			cmap[strings.ToLower(name)] = creds
+		}
+	}
+	if s.Coinbase != nil {
+		key, secret := s.Coinbase.KeySecret()
+		cmap["coinbase"] = &exchange.Credentials{Key: key, Secret: secret}
+	}
+	return cmap
+}
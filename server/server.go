@@ -18,20 +18,25 @@ import (
 	"time"
 
 	"github.com/bvk/tradebot/api"
-	"github.com/bvk/tradebot/coinbase"
+	_ "github.com/bvk/tradebot/binance"
+	"github.com/bvk/tradebot/clock"
 	"github.com/bvk/tradebot/ctxutil"
+	"github.com/bvk/tradebot/dca"
 	"github.com/bvk/tradebot/exchange"
 	"github.com/bvk/tradebot/gobs"
 	"github.com/bvk/tradebot/job"
 	"github.com/bvk/tradebot/kvutil"
 	"github.com/bvk/tradebot/limiter"
 	"github.com/bvk/tradebot/looper"
+	"github.com/bvk/tradebot/paper"
 	"github.com/bvk/tradebot/pushover"
 	"github.com/bvk/tradebot/syncmap"
 	"github.com/bvk/tradebot/trader"
+	"github.com/bvk/tradebot/trailer"
 	"github.com/bvk/tradebot/waller"
 	"github.com/bvkgo/kv"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 const (
@@ -74,7 +79,29 @@ func New(newctx context.Context, secrets *Secrets, db kv.Database, opts *Options
 	}
 	opts.setDefaults()
 
-	exchangeMap := make(map[string]exchange.Exchange)
+	var pushoverClient *pushover.Client
+	if secrets.Pushover != nil {
+		client, err := pushover.New(secrets.Pushover)
+		if err != nil {
+			return nil, fmt.Errorf("could not create pushover client: %w", err)
+		}
+		pushoverClient = client
+	}
+
+	if err := checkTradingMode(newctx, db, opts.PaperTrading); err != nil {
+		return nil, err
+	}
+
+	eopts := &exchange.Options{
+		DataDir:           opts.DataDir,
+		HttpClientTimeout: opts.MaxHttpClientTimeout,
+		Messenger:         pushoverMessenger{pushoverClient},
+		QuoteCurrencies:   opts.QuoteCurrencies,
+	}
+	exchangeMap, err := newExchanges(newctx, db, secrets, eopts, opts.ExchangeParams)
+	if err != nil {
+		return nil, err
+	}
 	defer func() {
 		if status != nil {
 			for _, exch := range exchangeMap {
@@ -83,30 +110,18 @@ func New(newctx context.Context, secrets *Secrets, db kv.Database, opts *Options
 		}
 	}()
 
-	var coinbaseClient *coinbase.Exchange
-	if secrets.Coinbase != nil {
-		cbopts := &coinbase.Options{
-			MaxFetchTimeLatency: opts.MaxFetchTimeLatency,
-			HttpClientTimeout:   opts.MaxHttpClientTimeout,
+	if opts.PaperTrading {
+		if len(exchangeMap) == 0 {
+			return nil, fmt.Errorf("paper trading requires exchange credentials for the price feed: %w", os.ErrInvalid)
 		}
-		if opts.NoFetchCandles {
-			cbopts.FetchCandlesInterval = -1
-		}
-		client, err := coinbase.New(newctx, db, secrets.Coinbase.Key, secrets.Coinbase.Secret, cbopts)
-		if err != nil {
-			return nil, fmt.Errorf("could not create coinbase client: %w", err)
-		}
-		coinbaseClient = client
-	}
-	exchangeMap["coinbase"] = coinbaseClient
-
-	var pushoverClient *pushover.Client
-	if secrets.Pushover != nil {
-		client, err := pushover.New(secrets.Pushover)
-		if err != nil {
-			return nil, fmt.Errorf("could not create pushover client: %w", err)
+		for name, client := range exchangeMap {
+			popts := &paper.Options{
+				FeePct: opts.PaperTradingFeePct,
+				NoFees: opts.PaperTradingNoFees,
+			}
+			exchangeMap[name] = paper.New(client, popts)
 		}
-		pushoverClient = client
+		log.Printf("paper trading is enabled; orders will not be placed on the real exchanges")
 	}
 
 	state, err := kvutil.GetDB[gobs.ServerState](newctx, db, serverStateKey)
@@ -148,6 +163,22 @@ func New(newctx context.Context, secrets *Secrets, db kv.Database, opts *Options
 			},
 		}
 	}
+	for name := range t.exchangeMap {
+		if _, ok := t.state.ExchangeMap[name]; !ok {
+			t.state.ExchangeMap[name] = new(gobs.ServerExchangeState)
+		}
+	}
+	for _, v := range opts.EnableProductIDs {
+		name, pid, ok := strings.Cut(v, ":")
+		if !ok {
+			name, pid = "coinbase", v
+		}
+		if estate, ok := t.state.ExchangeMap[strings.ToLower(name)]; ok {
+			if !slices.Contains(estate.EnabledProductIDs, pid) {
+				estate.EnabledProductIDs = append(estate.EnabledProductIDs, pid)
+			}
+		}
+	}
 	if err := t.loadProducts(newctx); err != nil {
 		return nil, fmt.Errorf("could not load default products: %w", err)
 	}
@@ -164,16 +195,48 @@ func New(newctx context.Context, secrets *Secrets, db kv.Database, opts *Options
 	t.handlerMap[api.LimitPath] = httpPostJSONHandler(t.doLimit)
 	t.handlerMap[api.LoopPath] = httpPostJSONHandler(t.doLoop)
 	t.handlerMap[api.WallPath] = httpPostJSONHandler(t.doWall)
+	t.handlerMap[api.DCAPath] = httpPostJSONHandler(t.doDCA)
+	t.handlerMap[api.TrailPath] = httpPostJSONHandler(t.doTrail)
 
 	t.handlerMap[api.ExchangeGetOrderPath] = httpPostJSONHandler(t.doExchangeGetOrder)
 	t.handlerMap[api.ExchangeGetProductPath] = httpPostJSONHandler(t.doGetProduct)
+	t.handlerMap[api.ExchangeMarketPath] = httpPostJSONHandler(t.doExchangeMarket)
+	t.handlerMap[api.ExchangeBalancesPath] = httpPostJSONHandler(t.doExchangeBalances)
+	t.handlerMap[api.ExchangeFeeSchedulePath] = httpPostJSONHandler(t.doExchangeFeeSchedule)
+	t.handlerMap[api.ExchangeStatsPath] = httpPostJSONHandler(t.doExchangeStats)
 
 	for _, ex := range t.exchangeMap {
-		limiter.RunBackgroundTasks(&t.cg, t.db, ex)
+		limiter.RunBackgroundTasks(&t.cg, t.db, ex, clock.System)
 	}
 	return t, nil
 }
 
+// newExchanges creates the clients for all exchanges with the credentials.
+// Exchange specific parameters are looked up by the exchange names in the
+// params map. Clients that are already created are closed when an exchange
+// cannot be created.
+func newExchanges(ctx context.Context, db kv.Database, secrets *Secrets, opts *exchange.Options, params map[string]map[string]string) (_ map[string]exchange.Exchange, status error) {
+	exchangeMap := make(map[string]exchange.Exchange)
+	defer func() {
+		if status != nil {
+			for _, exch := range exchangeMap {
+				exch.Close()
+			}
+		}
+	}()
+
+	for name, creds := range secrets.ExchangeCredentials() {
+		eopts := *opts
+		eopts.Params = params[name]
+		client, err := exchange.New(ctx, name, db, creds, &eopts)
+		if err != nil {
+			return nil, fmt.Errorf("could not create %s client: %w", name, err)
+		}
+		exchangeMap[name] = client
+	}
+	return exchangeMap, nil
+}
+
 func (s *Server) Close() error {
 	s.cg.Close()
 
@@ -197,12 +260,23 @@ func (s *Server) Runtime(product exchange.Product) *trader.Runtime {
 		Database:  s.db,
 		Product:   product,
 		Messenger: s,
+		Clock:     clock.System,
 	}
 }
 
 func (s *Server) SendMessage(ctx context.Context, at time.Time, msgfmt string, args ...interface{}) {
-	if s.pushoverClient != nil {
-		if err := s.pushoverClient.SendMessage(ctx, at, fmt.Sprintf(msgfmt, args...)); err != nil {
+	pushoverMessenger{s.pushoverClient}.SendMessage(ctx, at, msgfmt, args...)
+}
+
+// pushoverMessenger implements trader.Messenger interface using an optional
+// pushover client.
+type pushoverMessenger struct {
+	client *pushover.Client
+}
+
+func (m pushoverMessenger) SendMessage(ctx context.Context, at time.Time, msgfmt string, args ...interface{}) {
+	if m.client != nil {
+		if err := m.client.SendMessage(ctx, at, fmt.Sprintf(msgfmt, args...)); err != nil {
 			log.Printf("warning: could not send pushover message (ignored): %v", err)
 		}
 	}
@@ -449,6 +523,18 @@ func (s *Server) doLimit(ctx context.Context, req *api.LimitRequest) (_ *api.Lim
 		return nil, err
 	}
 
+	var quote, base decimal.Decimal
+	if limit.IsBuy() {
+		quote = limit.BudgetAt(s.feePct(ctx, req.ExchangeName))
+	} else {
+		base = req.Point.Size
+	}
+	if !req.SkipFundsCheck {
+		if err := s.checkFunds(ctx, req.ExchangeName, req.ProductID, quote, base); err != nil {
+			return nil, err
+		}
+	}
+
 	start := func(ctx context.Context, rw kv.ReadWriter) error {
 		if err := limit.Save(ctx, rw); err != nil {
 			return fmt.Errorf("could not save new limiter: %v", err)
@@ -492,6 +578,12 @@ func (s *Server) doLoop(ctx context.Context, req *api.LoopRequest) (_ *api.LoopR
 		return nil, err
 	}
 
+	if !req.SkipFundsCheck {
+		if err := s.checkFunds(ctx, req.ExchangeName, req.ProductID, loop.BudgetAt(s.feePct(ctx, req.ExchangeName)), decimal.Zero); err != nil {
+			return nil, err
+		}
+	}
+
 	start := func(ctx context.Context, rw kv.ReadWriter) error {
 		if err := loop.Save(ctx, rw); err != nil {
 			return fmt.Errorf("could not save new looper: %v", err)
@@ -535,6 +627,12 @@ func (s *Server) doWall(ctx context.Context, req *api.WallRequest) (_ *api.WallR
 		return nil, err
 	}
 
+	if !req.SkipFundsCheck {
+		if err := s.checkFunds(ctx, req.ExchangeName, req.ProductID, wall.BudgetAt(s.feePct(ctx, req.ExchangeName)), decimal.Zero); err != nil {
+			return nil, err
+		}
+	}
+
 	start := func(ctx context.Context, rw kv.ReadWriter) error {
 		if err := wall.Save(ctx, rw); err != nil {
 			return fmt.Errorf("could not save new waller: %v", err)
@@ -556,3 +654,109 @@ func (s *Server) doWall(ctx context.Context, req *api.WallRequest) (_ *api.WallR
 	}
 	return resp, nil
 }
+
+func (s *Server) doDCA(ctx context.Context, req *api.DCARequest) (_ *api.DCAResponse, status error) {
+	defer func() {
+		if status != nil {
+			slog.ErrorContext(ctx, "dca has failed", "error", status)
+		}
+	}()
+
+	if err := req.Check(); err != nil {
+		return nil, fmt.Errorf("invalid dca request: %w", err)
+	}
+
+	if _, err := s.getProduct(ctx, req.ExchangeName, req.ProductID); err != nil {
+		return nil, err
+	}
+
+	uid := uuid.New().String()
+	buyer, err := dca.New(uid, req.ExchangeName, req.ProductID, req.Amount, req.Size, req.Interval, req.Ceiling)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.SkipFundsCheck {
+		if err := s.checkFunds(ctx, req.ExchangeName, req.ProductID, buyer.BudgetAt(s.feePct(ctx, req.ExchangeName)), decimal.Zero); err != nil {
+			return nil, err
+		}
+	}
+
+	start := func(ctx context.Context, rw kv.ReadWriter) error {
+		if err := buyer.Save(ctx, rw); err != nil {
+			return fmt.Errorf("could not save new dca: %v", err)
+		}
+		if err := s.runner.Add(ctx, rw, uid, "DCA"); err != nil {
+			return fmt.Errorf("could not add new dca as a job: %w", err)
+		}
+		if _, err := s.runner.Resume(ctx, rw, uid, s.makeJobFunc(buyer), s.cg.Context()); err != nil {
+			return fmt.Errorf("could not resume new dca job: %w", err)
+		}
+		return nil
+	}
+	if err := kv.WithReadWriter(ctx, s.db, start); err != nil {
+		return nil, err
+	}
+
+	resp := &api.DCAResponse{
+		UID: uid,
+	}
+	return resp, nil
+}
+
+func (s *Server) doTrail(ctx context.Context, req *api.TrailRequest) (_ *api.TrailResponse, status error) {
+	defer func() {
+		if status != nil {
+			slog.ErrorContext(ctx, "trail has failed", "error", status)
+		}
+	}()
+
+	if err := req.Check(); err != nil {
+		return nil, fmt.Errorf("invalid trail request: %w", err)
+	}
+
+	if _, err := s.getProduct(ctx, req.ExchangeName, req.ProductID); err != nil {
+		return nil, err
+	}
+
+	trail := &trailer.Trail{
+		Size:            req.Size,
+		TrailAmount:     req.TrailAmount,
+		TrailPct:        req.TrailPct,
+		OrderType:       req.OrderType,
+		LimitOffset:     req.LimitOffset,
+		ActivationPrice: req.ActivationPrice,
+	}
+	uid := uuid.New().String()
+	seller, err := trailer.New(uid, req.ExchangeName, req.ProductID, trail)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.SkipFundsCheck {
+		if err := s.checkFunds(ctx, req.ExchangeName, req.ProductID, decimal.Zero, req.Size); err != nil {
+			return nil, err
+		}
+	}
+
+	start := func(ctx context.Context, rw kv.ReadWriter) error {
+		if err := seller.Save(ctx, rw); err != nil {
+			return fmt.Errorf("could not save new trailer: %v", err)
+		}
+		if err := s.runner.Add(ctx, rw, uid, "Trailer"); err != nil {
+			return fmt.Errorf("could not add new trailer as a job: %w", err)
+		}
+		if _, err := s.runner.Resume(ctx, rw, uid, s.makeJobFunc(seller), s.cg.Context()); err != nil {
+			return fmt.Errorf("could not resume new trailer job: %w", err)
+		}
+		return nil
+	}
+	if err := kv.WithReadWriter(ctx, s.db, start); err != nil {
+		return nil, err
+	}
+
+	resp := &api.TrailResponse{
+		UID: uid,
+	}
+	return resp, nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package cmdutil
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bvk/tradebot/backtest"
+	"github.com/bvk/tradebot/coinbase"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/timerange"
+	"github.com/bvk/tradebot/trader"
+	"github.com/bvkgo/kv"
+	"github.com/shopspring/decimal"
+)
+
+type BacktestFlags struct {
+	beginTime string
+	endTime   string
+
+	feePct    float64
+	feePctSet bool
+
+	verbose bool
+}
+
+func (f *BacktestFlags) SetFlags(fset *flag.FlagSet) {
+	fset.StringVar(&f.beginTime, "begin-time", "", "begin time for the historical candles (default: all)")
+	fset.StringVar(&f.endTime, "end-time", "", "end time for the historical candles (default: now)")
+	fset.Func("backtest-fee-pct", "fee percentage charged by the simulated exchange (default: saved maker fee)", func(v string) error {
+		pct, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		f.feePct, f.feePctSet = pct, true
+		return nil
+	})
+	fset.BoolVar(&f.verbose, "verbose", false, "when true, prints the trader job logs")
+}
+
+func parseTime(now time.Time, s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(d), nil
+	}
+	if v, err := time.Parse("2006-01-02", s); err == nil {
+		return v, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// Backtest replays the historical candles saved in the database through the
+// trader job on a simulated exchange and prints it's trade summary.
+func (f *BacktestFlags) Backtest(ctx context.Context, db kv.Database, job trader.Trader) error {
+	now := time.Now()
+
+	var begin, end time.Time
+	if len(f.beginTime) > 0 {
+		v, err := parseTime(now, f.beginTime)
+		if err != nil {
+			return fmt.Errorf("could not parse begin time: %w", err)
+		}
+		begin = v
+	}
+	end = now
+	if len(f.endTime) > 0 {
+		v, err := parseTime(now, f.endTime)
+		if err != nil {
+			return fmt.Errorf("could not parse end time: %w", err)
+		}
+		end = v
+	}
+
+	datastore := coinbase.NewDatastore(db)
+	product, err := datastore.LoadProduct(ctx, job.ProductID())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("could not load product %q: %w", job.ProductID(), err)
+		}
+		log.Printf("product %q information is not found (using the defaults)", job.ProductID())
+		product = &gobs.Product{ProductID: job.ProductID()}
+	}
+
+	feePct := f.feePct
+	if !f.feePctSet {
+		feePct = LoadFeeSchedule(ctx, db).MakerFeePct.InexactFloat64()
+	}
+
+	if !f.verbose {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+
+	opts := &backtest.Options{
+		FeePct: feePct,
+		NoFees: feePct == 0,
+	}
+	period, err := backtest.Run(ctx, job, product, datastore, begin, end, opts)
+	if err != nil {
+		return err
+	}
+
+	type Statuser interface {
+		Status(*timerange.Range) *trader.Status
+	}
+	statuser, ok := job.(Statuser)
+	if !ok {
+		fmt.Printf("Num Actions: %d\n", len(job.Actions()))
+		return nil
+	}
+	s := statuser.Status(period)
+
+	fmt.Printf("Begin Time: %s\n", period.Begin.Format(time.RFC3339))
+	fmt.Printf("End Time: %s\n", period.End.Format(time.RFC3339))
+	fmt.Printf("Num Days: %s\n", s.NumDays().StringFixed(2))
+	fmt.Printf("Num Buys: %d\n", s.NumBuys)
+	fmt.Printf("Num Sells: %d\n", s.NumSells)
+
+	fmt.Println()
+	fmt.Printf("Fees: %s\n", s.Fees().StringFixed(3))
+	fmt.Printf("Sold: %s\n", s.Sold().StringFixed(3))
+	fmt.Printf("Bought: %s\n", s.Bought().StringFixed(3))
+	fmt.Printf("Unsold Value: %s\n", s.UnsoldValue.StringFixed(3))
+	fmt.Printf("Unsold Size: %s\n", s.UnsoldSize.StringFixed(3))
+
+	fmt.Println()
+	fmt.Printf("Profit: %s\n", s.Profit().StringFixed(3))
+	fmt.Printf("Per day (average): %s\n", s.ProfitPerDay().StringFixed(3))
+	fmt.Printf("Per year (projected): %s\n", s.ProfitPerDay().Mul(decimal.NewFromInt(365)).StringFixed(3))
+
+	fmt.Println()
+	fmt.Printf("Budget: %s\n", s.Budget.StringFixed(3))
+	fmt.Printf("Return Rate: %s%%\n", s.ReturnRate().StringFixed(3))
+	fmt.Printf("Annual Return Rate: %s%%\n", s.AnnualReturnRate().StringFixed(3))
+	return nil
+}
@@ -56,6 +56,12 @@ func (f *DBFlags) SetFlags(fset *flag.FlagSet) {
 	fset.StringVar(&f.backupAfter, "backup-after", "", "Path to a file to receive db backup after cmd is run")
 }
 
+// IsRemote returns true when the database is accessed through the server
+// api, in which case, the server api can also be used for other requests.
+func (f *DBFlags) IsRemote() bool {
+	return len(f.fromBackup) == 0 && len(f.dataDir) == 0
+}
+
 func (f *DBFlags) dbCloser(db kv.Database, c io.Closer) func() {
 	return func() {
 		if len(f.backupAfter) != 0 {
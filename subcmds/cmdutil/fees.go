@@ -0,0 +1,45 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package cmdutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/bvk/tradebot/coinbase"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvkgo/kv"
+	"github.com/shopspring/decimal"
+)
+
+// LoadFeeSchedule returns the exchange fee schedule saved in the database. It
+// returns the default fee percentage for both maker and taker fees when the
+// fee schedule was never fetched from the exchange.
+func LoadFeeSchedule(ctx context.Context, db kv.Database) *gobs.FeeSchedule {
+	fees, err := LoadExchangeFeeSchedule(ctx, db, "coinbase")
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("could not load fee schedule (using the defaults): %v", err)
+		}
+		return &gobs.FeeSchedule{
+			MakerFeePct: decimal.NewFromFloat(exchange.DefaultFeePct),
+			TakerFeePct: decimal.NewFromFloat(exchange.DefaultFeePct),
+		}
+	}
+	return fees
+}
+
+// LoadExchangeFeeSchedule returns the fee schedule saved in the database for
+// the named exchange. It returns os.ErrNotExist when the exchange doesn't save
+// it's fee schedule or when it was never fetched from the exchange.
+func LoadExchangeFeeSchedule(ctx context.Context, db kv.Database, exchangeName string) (*gobs.FeeSchedule, error) {
+	if !strings.EqualFold(exchangeName, "coinbase") {
+		return nil, fmt.Errorf("fee schedule for exchange %q is not saved: %w", exchangeName, os.ErrNotExist)
+	}
+	return coinbase.NewDatastore(db).LoadFeeSchedule(ctx)
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/coinbase"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+)
+
+type PrintCandles struct {
+	cmdutil.DBFlags
+
+	beginDate, endDate string
+
+	productID string
+
+	granularity string
+
+	format string
+
+	rollup bool
+}
+
+func (c *PrintCandles) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("print", flag.ContinueOnError)
+	c.DBFlags.SetFlags(fset)
+	fset.StringVar(&c.productID, "product-id", "", "product id")
+	fset.StringVar(&c.beginDate, "begin-date", "", "date of start day in YYYY-MM-DD format")
+	fset.StringVar(&c.endDate, "end-date", "", "date of stop day in YYYY-MM-DD format")
+	fset.StringVar(&c.granularity, "granularity", "1m", "one of 1m|5m|15m|1h|1d")
+	fset.StringVar(&c.format, "format", "text", "one of text|csv|json")
+	fset.BoolVar(&c.rollup, "rollup", false, "recompute coarser candles from the minute candles before printing")
+	return fset, cli.CmdFunc(c.run)
+}
+
+func (c *PrintCandles) Synopsis() string {
+	return "Prints or exports candles saved in the datastore"
+}
+
+func (c *PrintCandles) CommandHelp() string {
+	return `
+
+Command "print" prints the candles saved in the datastore for a product in
+the given time range. Minute candles are synced from coinbase and coarser
+candles (5m, 15m, 1h and 1d) are computed from them when they are saved.
+
+Candles saved before coarser candles were supported can be rolled up with the
+-rollup flag.
+
+Output can be printed as text or exported in CSV or JSON formats.
+
+`
+}
+
+func (c *PrintCandles) run(ctx context.Context, args []string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	formats := []string{"text", "csv", "json"}
+	if !slices.Contains(formats, c.format) {
+		return fmt.Errorf("format must be one of %v", formats)
+	}
+
+	granularity, err := parseGranularity(c.granularity)
+	if err != nil {
+		return err
+	}
+
+	if c.productID == "" {
+		return fmt.Errorf("product id cannot be empty")
+	}
+
+	if len(c.beginDate) == 0 {
+		return errors.New("begin-date argument is required")
+	}
+	begin, err := time.Parse("2006-01-02", c.beginDate)
+	if err != nil {
+		return fmt.Errorf("could not parse date argument: %w", err)
+	}
+	end := time.Now()
+	if len(c.endDate) > 0 {
+		v, err := time.Parse("2006-01-02", c.endDate)
+		if err != nil {
+			return fmt.Errorf("could not parse end date argument: %w", err)
+		}
+		end = v
+	}
+
+	db, closer, err := c.DBFlags.GetDatabase(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create database client: %w", err)
+	}
+	defer closer()
+
+	ds := coinbase.NewDatastore(db)
+
+	if c.rollup {
+		if err := ds.RollupCandles(ctx, c.productID, begin, end); err != nil {
+			return fmt.Errorf("could not rollup candles: %w", err)
+		}
+	}
+
+	var candles []*gobs.Candle
+	collect := func(v *gobs.Candle) error {
+		candles = append(candles, v)
+		return nil
+	}
+	if err := ds.ScanCandlesWithGranularity(ctx, c.productID, granularity, begin, end, collect); err != nil {
+		return fmt.Errorf("could not scan candles: %w", err)
+	}
+
+	switch c.format {
+	case "json":
+		js, err := json.MarshalIndent(candles, "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not json-marshal candles: %w", err)
+		}
+		fmt.Printf("%s\n", js)
+
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"time", "open", "high", "low", "close", "volume"})
+		for _, v := range candles {
+			w.Write([]string{
+				v.StartTime.UTC().Format(time.RFC3339),
+				v.Open.String(),
+				v.High.String(),
+				v.Low.String(),
+				v.Close.String(),
+				v.Volume.String(),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return fmt.Errorf("could not write csv data: %w", err)
+		}
+
+	default:
+		for _, v := range candles {
+			fmt.Printf("%s open=%s high=%s low=%s close=%s volume=%s\n",
+				v.StartTime.Format(time.DateTime), v.Open.StringFixed(2), v.High.StringFixed(2),
+				v.Low.StringFixed(2), v.Close.StringFixed(2), v.Volume.String())
+		}
+	}
+	return nil
+}
+
+func parseGranularity(s string) (time.Duration, error) {
+	d := 24 * time.Hour
+	if s != "1d" {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse granularity %q: %w", s, err)
+		}
+		d = v
+	}
+	if !slices.Contains(coinbase.CandleGranularities(), d) {
+		return 0, fmt.Errorf("granularity must be one of 1m|5m|15m|1h|1d")
+	}
+	return d, nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/coinbase"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+)
+
+type GC struct {
+	cmdutil.DBFlags
+
+	retentionDays int
+}
+
+func (c *GC) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("gc", flag.ContinueOnError)
+	c.DBFlags.SetFlags(fset)
+	fset.IntVar(&c.retentionDays, "retention-days", 7, "number of days to keep the cancelled orders with zero filled size")
+	return fset, cli.CmdFunc(c.run)
+}
+
+func (c *GC) Synopsis() string {
+	return "Removes old cancelled orders from the datastore"
+}
+
+func (c *GC) CommandHelp() string {
+	return `
+
+Command "gc" removes the cancelled orders with zero filled size that are older
+than the retention period from the datastore. Orders with non-zero filled size
+are never removed.
+
+Trading server also removes the old cancelled orders periodically as per it's
+retention period, so this command is only necessary to compact the datastore
+with a different retention period.
+
+`
+}
+
+func (c *GC) run(ctx context.Context, args []string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if c.retentionDays < 0 {
+		return fmt.Errorf("retention days cannot be negative")
+	}
+
+	db, closer, err := c.DBFlags.GetDatabase(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create database client: %w", err)
+	}
+	defer closer()
+
+	ds := coinbase.NewDatastore(db)
+	before := time.Now().AddDate(0, 0, -c.retentionDays)
+	n, err := ds.CompactCancelled(ctx, before)
+	if err != nil {
+		return fmt.Errorf("could not compact cancelled orders: %w", err)
+	}
+	fmt.Printf("Removed %d cancelled orders created before %s\n", n, before.Format(time.DateTime))
+	return nil
+}
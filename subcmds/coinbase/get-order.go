@@ -47,7 +47,8 @@ func (c *GetOrder) run(ctx context.Context, args []string) error {
 	db := kvmemdb.New()
 
 	opts := coinbase.SubcommandOptions()
-	cb, err := coinbase.New(ctx, db, secrets.Coinbase.Key, secrets.Coinbase.Secret, opts)
+	key, secret := secrets.Coinbase.KeySecret()
+	cb, err := coinbase.New(ctx, db, key, secret, opts)
 	if err != nil {
 		return fmt.Errorf("could not create coinbase client: %w", err)
 	}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/coinbase"
+	"github.com/bvk/tradebot/server"
+	"github.com/bvkgo/kv/kvmemdb"
+)
+
+type Replay struct {
+	secretsPath string
+}
+
+func (c *Replay) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fset.StringVar(&c.secretsPath, "secrets-file", "", "path to credentials file")
+	return fset, cli.CmdFunc(c.run)
+}
+
+func (c *Replay) Synopsis() string {
+	return "Replays the saved websocket messages and reports the sequence gaps"
+}
+
+func (c *Replay) CommandHelp() string {
+	return `
+
+Command "replay" reads the raw websocket message logs saved through the
+websocket log directory option and dispatches the messages to a new exchange
+client in the same order as they were received.
+
+Message sequence numbers are verified the same way as the live messages, so
+the lost messages and the reconnects in the logs are reported as gaps. Log
+files are replayed in the order given on the command-line.
+
+`
+}
+
+func (c *Replay) run(ctx context.Context, args []string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(args) == 0 {
+		return fmt.Errorf("no websocket log file arguments")
+	}
+
+	if len(c.secretsPath) == 0 {
+		return fmt.Errorf("secrets file must be specified")
+	}
+	secrets, err := server.SecretsFromFile(c.secretsPath)
+	if err != nil {
+		return err
+	}
+
+	opts := coinbase.SubcommandOptions()
+	key, secret := secrets.Coinbase.KeySecret()
+	cb, err := coinbase.New(ctx, kvmemdb.New(), key, secret, opts)
+	if err != nil {
+		return fmt.Errorf("could not create coinbase client: %w", err)
+	}
+	defer cb.Close()
+
+	for _, file := range args {
+		before, _ := cb.FeedStats()
+		if err := replayFile(ctx, cb, file); err != nil {
+			return err
+		}
+		after, _ := cb.FeedStats()
+		fmt.Printf("%s: %d gaps\n", file, after-before)
+	}
+	return nil
+}
+
+func replayFile(ctx context.Context, cb *coinbase.Exchange, file string) error {
+	fp, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("could not open websocket log file: %w", err)
+	}
+	defer fp.Close()
+
+	if err := cb.ReplayMessages(ctx, fp); err != nil {
+		return fmt.Errorf("could not replay file %q: %w", file, err)
+	}
+	return nil
+}
@@ -78,7 +78,8 @@ func (c *Sync) run(ctx context.Context, args []string) error {
 	defer closer()
 
 	opts := coinbase.SubcommandOptions()
-	exchange, err := coinbase.New(ctx, db, secrets.Coinbase.Key, secrets.Coinbase.Secret, opts)
+	key, secret := secrets.Coinbase.KeySecret()
+	exchange, err := coinbase.New(ctx, db, key, secret, opts)
 	if err != nil {
 		return fmt.Errorf("could not create coinbase client: %w", err)
 	}
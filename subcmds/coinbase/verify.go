@@ -0,0 +1,156 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package coinbase
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/coinbase"
+	"github.com/bvk/tradebot/server"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+)
+
+type VerifyCandles struct {
+	cmdutil.DBFlags
+
+	secretsPath string
+
+	productID string
+
+	begin, end string
+
+	fix bool
+}
+
+func (c *VerifyCandles) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("verify", flag.ContinueOnError)
+	c.DBFlags.SetFlags(fset)
+	fset.StringVar(&c.productID, "product-id", "", "product id")
+	fset.StringVar(&c.begin, "begin", "", "begin time in YYYY-MM-DD or RFC3339 format")
+	fset.StringVar(&c.end, "end", "", "end time in YYYY-MM-DD or RFC3339 format (defaults to now)")
+	fset.StringVar(&c.secretsPath, "secrets-file", "", "path to credentials file (required with -fix)")
+	fset.BoolVar(&c.fix, "fix", false, "refetch the missing candles from coinbase")
+	return fset, cli.CmdFunc(c.run)
+}
+
+func (c *VerifyCandles) Synopsis() string {
+	return "Reports missing or duplicate minute candles in the datastore"
+}
+
+func (c *VerifyCandles) CommandHelp() string {
+	return `
+
+Command "verify" scans the minute candles saved in the datastore for a product
+and reports the missing minutes and duplicate candles in the given time range.
+
+With the -fix flag, missing time ranges are refetched from coinbase and saved
+to the datastore. Coinbase doesn't return candles for the minutes without any
+trades, so some gaps may remain for products with low trading volume.
+
+`
+}
+
+func (c *VerifyCandles) run(ctx context.Context, args []string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if c.productID == "" {
+		return fmt.Errorf("product id cannot be empty")
+	}
+	if len(c.begin) == 0 {
+		return fmt.Errorf("begin argument is required")
+	}
+	begin, err := parseTime(c.begin)
+	if err != nil {
+		return fmt.Errorf("could not parse begin argument: %w", err)
+	}
+	end := time.Now()
+	if len(c.end) > 0 {
+		v, err := parseTime(c.end)
+		if err != nil {
+			return fmt.Errorf("could not parse end argument: %w", err)
+		}
+		end = v
+	}
+	if !begin.Before(end) {
+		return fmt.Errorf("begin time must be before the end time")
+	}
+
+	var secrets *server.Secrets
+	if c.fix {
+		if len(c.secretsPath) == 0 {
+			return fmt.Errorf("secrets file is required to fix the candles")
+		}
+		v, err := server.SecretsFromFile(c.secretsPath)
+		if err != nil {
+			return fmt.Errorf("could not load secrets: %w", err)
+		}
+		if v.Coinbase == nil {
+			return fmt.Errorf("coinbase credentials are missing")
+		}
+		secrets = v
+	}
+
+	db, closer, err := c.DBFlags.GetDatabase(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create database client: %w", err)
+	}
+	defer closer()
+
+	ds := coinbase.NewDatastore(db)
+	report, err := ds.VerifyCandles(ctx, c.productID, begin, end)
+	if err != nil {
+		return fmt.Errorf("could not verify candles: %w", err)
+	}
+	printCandlesReport(report)
+
+	if !c.fix || len(report.Missing) == 0 {
+		return nil
+	}
+
+	key, secret := secrets.Coinbase.KeySecret()
+	exchange, err := coinbase.New(ctx, db, key, secret, coinbase.SubcommandOptions())
+	if err != nil {
+		return fmt.Errorf("could not create coinbase client: %w", err)
+	}
+	defer exchange.Close()
+
+	if err := exchange.BackfillCandles(ctx, c.productID, report.Missing); err != nil {
+		return fmt.Errorf("could not backfill missing candles: %w", err)
+	}
+
+	after, err := ds.VerifyCandles(ctx, c.productID, begin, end)
+	if err != nil {
+		return fmt.Errorf("could not verify candles after the backfill: %w", err)
+	}
+	fmt.Printf("Fetched %d missing candles; %d minutes are still missing\n", after.NumCandles-report.NumCandles, after.NumMissing())
+	return nil
+}
+
+func printCandlesReport(r *coinbase.CandlesReport) {
+	fmt.Printf("Product: %s\n", r.ProductID)
+	fmt.Printf("Time range: %s - %s\n", r.Begin.Format(time.DateTime), r.End.Format(time.DateTime))
+	fmt.Printf("Candles: %d\n", r.NumCandles)
+	fmt.Printf("Missing minutes: %d (in %d gaps)\n", r.NumMissing(), len(r.Missing))
+	for _, g := range r.Missing {
+		fmt.Printf("  %s - %s (%d minutes)\n", g.Begin.Format(time.DateTime), g.End.Format(time.DateTime), g.NumMinutes())
+	}
+	fmt.Printf("Duplicates: %d\n", len(r.Duplicates))
+	for _, d := range r.Duplicates {
+		fmt.Printf("  %s\n", d.Format(time.DateTime))
+	}
+}
+
+func parseTime(s string) (time.Time, error) {
+	if v, err := time.Parse("2006-01-02", s); err == nil {
+		return v, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package dca
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/bvk/tradebot/api"
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+	"github.com/shopspring/decimal"
+)
+
+type Add struct {
+	cmdutil.ClientFlags
+
+	product  string
+	exchange string
+
+	skipFundsCheck bool
+
+	amount   float64
+	size     float64
+	interval time.Duration
+	ceiling  float64
+}
+
+func (c *Add) check() error {
+	if len(c.product) == 0 {
+		return fmt.Errorf("product name cannot be empty")
+	}
+	if len(c.exchange) == 0 {
+		return fmt.Errorf("exchange name cannot be empty")
+	}
+
+	if c.amount < 0 || c.size < 0 {
+		return fmt.Errorf("amount or size cannot be negative")
+	}
+	if (c.amount == 0) == (c.size == 0) {
+		return fmt.Errorf("exactly one of amount or size must be given")
+	}
+	if c.interval <= 0 {
+		return fmt.Errorf("interval cannot be zero or negative")
+	}
+	if c.ceiling < 0 {
+		return fmt.Errorf("ceiling price cannot be negative")
+	}
+	return nil
+}
+
+func (c *Add) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("this command takes no arguments")
+	}
+	if err := c.check(); err != nil {
+		return err
+	}
+
+	req := &api.DCARequest{
+		ProductID:      c.product,
+		ExchangeName:   c.exchange,
+		SkipFundsCheck: c.skipFundsCheck,
+		Amount:         decimal.NewFromFloat(c.amount),
+		Size:           decimal.NewFromFloat(c.size),
+		Interval:       c.interval,
+		Ceiling:        decimal.NewFromFloat(c.ceiling),
+	}
+	resp, err := cmdutil.Post[api.DCAResponse](ctx, &c.ClientFlags, api.DCAPath, req)
+	if err != nil {
+		return err
+	}
+	jsdata, _ := json.MarshalIndent(resp, "", "  ")
+	fmt.Printf("%s\n", jsdata)
+	return nil
+}
+
+func (c *Add) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("add", flag.ContinueOnError)
+	c.ClientFlags.SetFlags(fset)
+	fset.Float64Var(&c.amount, "amount", 0, "value in quote currency to buy every interval")
+	fset.Float64Var(&c.size, "size", 0, "asset size to buy every interval")
+	fset.DurationVar(&c.interval, "interval", 0, "time interval between the buys")
+	fset.Float64Var(&c.ceiling, "ceiling", 0, "when non-zero, due buys are deferred at or above this price")
+	fset.StringVar(&c.product, "product", "", "product id for the trade")
+	fset.StringVar(&c.exchange, "exchange", "coinbase", "exchange name for the product")
+	fset.BoolVar(&c.skipFundsCheck, "skip-funds-check", false, "when true, job is created without checking the available balance")
+	return fset, cli.CmdFunc(c.Run)
+}
+
+func (c *Add) Synopsis() string {
+	return "Creates a new recurring buy job"
+}
+
+func (c *Add) CommandHelp() string {
+	return `
+
+Command "add" creates a new dollar-cost-averaging job that buys a fixed value
+(with -amount flag) or a fixed size (with -size flag) of the product with a
+market order every interval.
+
+When the -ceiling flag is given, a buy that is due is delayed till the ticker
+price drops below the ceiling price. Next buy is due after the interval from
+the last buy.
+
+Buys can be paused with the "hold" job option and the buy value or size can be
+updated with the "amount" or "size" job options. Ceiling price can be updated
+with the "ceiling" job option; a zero value removes the ceiling.
+
+`
+}
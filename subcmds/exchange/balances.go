@@ -0,0 +1,64 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package exchange
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/bvk/tradebot/api"
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+)
+
+type Balances struct {
+	cmdutil.ClientFlags
+
+	name string
+}
+
+func (c *Balances) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("balances", flag.ContinueOnError)
+	c.ClientFlags.SetFlags(fset)
+	fset.StringVar(&c.name, "name", "coinbase", "name of the exchange")
+	return fset, cli.CmdFunc(c.run)
+}
+
+func (c *Balances) run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("this command takes no arguments")
+	}
+
+	req := &api.ExchangeBalancesRequest{
+		ExchangeName: c.name,
+	}
+	resp, err := cmdutil.Post[api.ExchangeBalancesResponse](ctx, &c.ClientFlags, api.ExchangeBalancesPath, req)
+	if err != nil {
+		return fmt.Errorf("POST request to balances failed: %w", err)
+	}
+	if len(resp.Error) != 0 {
+		return errors.New(resp.Error)
+	}
+
+	accounts := resp.Accounts
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Name < accounts[j].Name
+	})
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Name\tCurrency\tAvailable\tHold\tTotal\t\n")
+	for _, a := range accounts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", a.Name, a.CurrencyID, a.Available.StringFixed(3), a.Hold.StringFixed(3), a.Available.Add(a.Hold).StringFixed(3))
+	}
+	tw.Flush()
+	return nil
+}
+
+func (c *Balances) Synopsis() string {
+	return "Prints the account balances in the exchange"
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package exchange
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/bvk/tradebot/api"
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+)
+
+type Fees struct {
+	cmdutil.ClientFlags
+
+	name string
+}
+
+func (c *Fees) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("fees", flag.ContinueOnError)
+	c.ClientFlags.SetFlags(fset)
+	fset.StringVar(&c.name, "name", "coinbase", "name of the exchange")
+	return fset, cli.CmdFunc(c.run)
+}
+
+func (c *Fees) run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("this command takes no arguments")
+	}
+
+	req := &api.ExchangeFeeScheduleRequest{
+		ExchangeName: c.name,
+	}
+	resp, err := cmdutil.Post[api.ExchangeFeeScheduleResponse](ctx, &c.ClientFlags, api.ExchangeFeeSchedulePath, req)
+	if err != nil {
+		return fmt.Errorf("POST request to fee-schedule failed: %w", err)
+	}
+	if len(resp.Error) != 0 {
+		return errors.New(resp.Error)
+	}
+
+	fees := resp.FeeSchedule
+	fmt.Printf("Tier: %s\n", fees.TierName)
+	fmt.Printf("Maker Fee Pct: %s%%\n", fees.MakerFeePct.StringFixed(3))
+	fmt.Printf("Taker Fee Pct: %s%%\n", fees.TakerFeePct.StringFixed(3))
+	fmt.Printf("Updated At: %s\n", fees.Timestamp.Format(time.RFC3339))
+	return nil
+}
+
+func (c *Fees) Synopsis() string {
+	return "Prints the current maker and taker fee rates in the exchange"
+}
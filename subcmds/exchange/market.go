@@ -0,0 +1,84 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/bvk/tradebot/api"
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+	"github.com/shopspring/decimal"
+)
+
+type Market struct {
+	cmdutil.ClientFlags
+
+	name    string
+	product string
+
+	side  string
+	size  float64
+	funds float64
+
+	clientOrderID string
+}
+
+func (c *Market) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("market", flag.ContinueOnError)
+	c.ClientFlags.SetFlags(fset)
+	fset.StringVar(&c.name, "name", "coinbase", "name of the exchange")
+	fset.StringVar(&c.product, "product", "", "product id for the order")
+	fset.StringVar(&c.side, "side", "", "must be one of BUY or SELL")
+	fset.Float64Var(&c.size, "size", 0, "order size in the base currency")
+	fset.Float64Var(&c.funds, "funds", 0, "order size as funds in the quote currency")
+	fset.StringVar(&c.clientOrderID, "client-order-id", "", "optional client order id for the order")
+	return fset, cli.CmdFunc(c.run)
+}
+
+func (c *Market) run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("this command takes no arguments")
+	}
+
+	req := &api.ExchangeMarketRequest{
+		ExchangeName:  c.name,
+		ProductID:     c.product,
+		ClientOrderID: c.clientOrderID,
+		Side:          strings.ToUpper(c.side),
+		Size:          decimal.NewFromFloat(c.size),
+		Funds:         decimal.NewFromFloat(c.funds),
+	}
+	if err := req.Check(); err != nil {
+		return err
+	}
+	resp, err := cmdutil.Post[api.ExchangeMarketResponse](ctx, &c.ClientFlags, api.ExchangeMarketPath, req)
+	if err != nil {
+		return fmt.Errorf("POST request to market failed: %w", err)
+	}
+	jsdata, _ := json.MarshalIndent(resp, "", "  ")
+	fmt.Printf("%s\n", jsdata)
+	return nil
+}
+
+func (c *Market) Synopsis() string {
+	return "Creates a market buy or sell order"
+}
+
+func (c *Market) CommandHelp() string {
+	return `
+
+Command "market" creates a market order that is executed immediately at the
+best available price. Order size is given with either the -size flag in the
+base currency or the -funds flag in the quote currency.
+
+Market orders are not tracked by any trader job, so they are not included in
+the status summary. This command is mainly useful to exit a position quickly,
+for example, to liquidate the unsold inventory of a canceled job.
+
+`
+}
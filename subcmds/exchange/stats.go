@@ -0,0 +1,67 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package exchange
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bvk/tradebot/api"
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+)
+
+type Stats struct {
+	cmdutil.ClientFlags
+
+	name string
+}
+
+func (c *Stats) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("stats", flag.ContinueOnError)
+	c.ClientFlags.SetFlags(fset)
+	fset.StringVar(&c.name, "name", "coinbase", "name of the exchange")
+	return fset, cli.CmdFunc(c.run)
+}
+
+func (c *Stats) run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("this command takes no arguments")
+	}
+
+	req := &api.ExchangeStatsRequest{
+		ExchangeName: c.name,
+	}
+	resp, err := cmdutil.Post[api.ExchangeStatsResponse](ctx, &c.ClientFlags, api.ExchangeStatsPath, req)
+	if err != nil {
+		return fmt.Errorf("POST request to stats failed: %w", err)
+	}
+	if len(resp.Error) != 0 {
+		return errors.New(resp.Error)
+	}
+
+	fmt.Printf("Websocket Gaps: %d\n", resp.NumGaps)
+	fmt.Printf("Order Resyncs: %d\n", resp.NumResyncs)
+	fmt.Println()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Name\tLimit\tBurst\tTokens\tRequests\tThrottled\tBackoff Until\t\n")
+	for _, v := range resp.Throttles {
+		backoff := "-"
+		if !v.BackoffUntil.IsZero() {
+			backoff = v.BackoffUntil.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%.2f\t%d\t%.2f\t%d\t%d\t%s\t\n", v.Name, v.Limit, v.Burst, v.Tokens, v.NumRequests, v.NumThrottled, backoff)
+	}
+	tw.Flush()
+	return nil
+}
+
+func (c *Stats) Synopsis() string {
+	return "Prints the websocket feed and api throttling stats of the exchange"
+}
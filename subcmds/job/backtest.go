@@ -0,0 +1,101 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package job
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/dca"
+	"github.com/bvk/tradebot/limiter"
+	"github.com/bvk/tradebot/looper"
+	"github.com/bvk/tradebot/namer"
+	"github.com/bvk/tradebot/server"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+	"github.com/bvk/tradebot/trader"
+	"github.com/bvk/tradebot/trailer"
+	"github.com/bvk/tradebot/waller"
+	"github.com/bvkgo/kv"
+	"github.com/google/uuid"
+)
+
+type Backtest struct {
+	cmdutil.DBFlags
+	cmdutil.BacktestFlags
+}
+
+func (c *Backtest) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	c.DBFlags.SetFlags(fset)
+	c.BacktestFlags.SetFlags(fset)
+	return fset, cli.CmdFunc(c.run)
+}
+
+func (c *Backtest) run(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("this command takes one (job-name or uid) argument")
+	}
+	jobArg := args[0]
+
+	db, closer, err := c.DBFlags.GetDatabase(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create db instance: %w", err)
+	}
+	defer closer()
+
+	var fresh trader.Trader
+	loader := func(ctx context.Context, r kv.Reader) error {
+		_, uid, typename, err := namer.Resolve(ctx, r, jobArg)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("could not resolve job argument %q: %w", jobArg, err)
+			}
+			// Assume jobArg is an uid.
+			uid = jobArg
+		}
+
+		job, err := server.Load(ctx, r, uid, typename)
+		if err != nil {
+			return fmt.Errorf("could not load job with uid %q: %w", uid, err)
+		}
+		v, err := newCopy(job)
+		if err != nil {
+			return fmt.Errorf("could not create a copy of job %q: %w", uid, err)
+		}
+		fresh = v
+		return nil
+	}
+	if err := kv.WithReader(ctx, db, loader); err != nil {
+		return err
+	}
+
+	return c.BacktestFlags.Backtest(ctx, db, fresh)
+}
+
+// newCopy returns a new job with the same trade parameters as the input job,
+// but without any of it's orders history.
+func newCopy(job trader.Trader) (trader.Trader, error) {
+	uid := uuid.New().String()
+	switch v := job.(type) {
+	case *limiter.Limiter:
+		return limiter.New(uid, v.ExchangeName(), v.ProductID(), v.Point())
+	case *looper.Looper:
+		pair := v.Pair()
+		return looper.New(uid, v.ExchangeName(), v.ProductID(), &pair.Buy, &pair.Sell)
+	case *waller.Waller:
+		return waller.New(uid, v.ExchangeName(), v.ProductID(), v.Pairs())
+	case *dca.DCA:
+		return dca.New(uid, v.ExchangeName(), v.ProductID(), v.Amount(), v.Size(), v.Interval(), v.Ceiling())
+	case *trailer.Trailer:
+		return trailer.New(uid, v.ExchangeName(), v.ProductID(), v.Trail())
+	}
+	return nil, fmt.Errorf("unsupported job type %T: %w", job, os.ErrInvalid)
+}
+
+func (c *Backtest) Synopsis() string {
+	return "Simulates a trading job over historical candles"
+}
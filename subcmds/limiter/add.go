@@ -21,6 +21,8 @@ type Add struct {
 	product  string
 	exchange string
 
+	skipFundsCheck bool
+
 	side         string
 	size         float64
 	price        float64
@@ -76,8 +78,9 @@ func (c *Add) Run(ctx context.Context, args []string) error {
 	}
 
 	req := &api.LimitRequest{
-		ProductID:    c.product,
-		ExchangeName: c.exchange,
+		ProductID:      c.product,
+		ExchangeName:   c.exchange,
+		SkipFundsCheck: c.skipFundsCheck,
 		Point: &point.Point{
 			Size:   decimal.NewFromFloat(c.size),
 			Price:  decimal.NewFromFloat(c.price),
@@ -102,6 +105,7 @@ func (c *Add) Command() (*flag.FlagSet, cli.CmdFunc) {
 	fset.Float64Var(&c.cancelOffset, "cancel-offset", 0, "cancel-price offset for the trade")
 	fset.StringVar(&c.product, "product", "", "product id for the trade")
 	fset.StringVar(&c.exchange, "exchange", "coinbase", "exchange name for the product")
+	fset.BoolVar(&c.skipFundsCheck, "skip-funds-check", false, "when true, job is created without checking the available balance")
 	return fset, cli.CmdFunc(c.Run)
 }
 
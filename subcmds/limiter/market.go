@@ -0,0 +1,92 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/bvk/tradebot/api"
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+	"github.com/shopspring/decimal"
+)
+
+type Market struct {
+	cmdutil.ClientFlags
+
+	product  string
+	exchange string
+
+	side  string
+	size  float64
+	funds float64
+
+	clientOrderID string
+}
+
+func (c *Market) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("this command takes no arguments")
+	}
+
+	req := &api.ExchangeMarketRequest{
+		ExchangeName:  c.exchange,
+		ProductID:     c.product,
+		ClientOrderID: c.clientOrderID,
+		Side:          strings.ToUpper(c.side),
+		Size:          decimal.NewFromFloat(c.size),
+		Funds:         decimal.NewFromFloat(c.funds),
+	}
+	if err := req.Check(); err != nil {
+		return err
+	}
+	resp, err := cmdutil.Post[api.ExchangeMarketResponse](ctx, &c.ClientFlags, api.ExchangeMarketPath, req)
+	if err != nil {
+		return err
+	}
+	if len(resp.Error) != 0 {
+		return errors.New(resp.Error)
+	}
+	jsdata, _ := json.MarshalIndent(resp, "", "  ")
+	fmt.Printf("%s\n", jsdata)
+	return nil
+}
+
+func (c *Market) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("market", flag.ContinueOnError)
+	c.ClientFlags.SetFlags(fset)
+	fset.Float64Var(&c.size, "size", 0, "asset size for the trade")
+	fset.Float64Var(&c.funds, "funds", 0, "trade size as funds in the quote currency")
+	fset.StringVar(&c.side, "side", "", "must be one of BUY or SELL")
+	fset.StringVar(&c.product, "product", "", "product id for the trade")
+	fset.StringVar(&c.exchange, "exchange", "coinbase", "exchange name for the product")
+	fset.StringVar(&c.clientOrderID, "client-order-id", "", "optional client order id for the trade")
+	return fset, cli.CmdFunc(c.Run)
+}
+
+func (c *Market) Synopsis() string {
+	return "Creates a market buy/sell order"
+}
+
+func (c *Market) CommandHelp() string {
+	return `
+
+Command "market" places a market buy or sell order that is executed
+immediately at the best available price. Trade size is given with either the
+-size flag in the base currency or the -funds flag in the quote currency.
+
+Market sells with the -funds flag are converted to the base size at the
+current market price, because exchanges accept the quote currency funds only
+for the market buys.
+
+Unlike the limit orders created with the "add" command, market orders are
+not tracked by a job, so they are not included in the status summary. Retries
+with the same -client-order-id flag do not create duplicate orders.
+
+`
+}
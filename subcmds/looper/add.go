@@ -21,6 +21,8 @@ type Add struct {
 	product  string
 	exchange string
 
+	skipFundsCheck bool
+
 	buySize         float64
 	buyPrice        float64
 	buyCancelOffset float64
@@ -67,8 +69,9 @@ func (c *Add) Run(ctx context.Context, args []string) error {
 	}
 
 	req := &api.LoopRequest{
-		ProductID:    c.product,
-		ExchangeName: c.exchange,
+		ProductID:      c.product,
+		ExchangeName:   c.exchange,
+		SkipFundsCheck: c.skipFundsCheck,
 		Buy: &point.Point{
 			Size:   decimal.NewFromFloat(c.buySize),
 			Price:  decimal.NewFromFloat(c.buyPrice),
@@ -94,6 +97,7 @@ func (c *Add) Command() (*flag.FlagSet, cli.CmdFunc) {
 	c.ClientFlags.SetFlags(fset)
 	fset.StringVar(&c.product, "product", "", "product id for the trade")
 	fset.StringVar(&c.exchange, "exchange", "coinbase", "exchange name for the product")
+	fset.BoolVar(&c.skipFundsCheck, "skip-funds-check", false, "when true, job is created without checking the available balance")
 	fset.Float64Var(&c.buySize, "buy-size", 0, "buy-size for the trade")
 	fset.Float64Var(&c.buyPrice, "buy-price", 0, "limit buy-price for the trade")
 	fset.Float64Var(&c.buyCancelOffset, "buy-cancel-offset", 0, "buy-cancel price offset for the trade")
@@ -65,6 +65,8 @@ func (c *List) Run(ctx context.Context, args []string) error {
 	}
 	defer closer()
 
+	feePct := cmdutil.LoadFeeSchedule(ctx, db).MakerFeePct.InexactFloat64()
+
 	lister := func(ctx context.Context, r kv.Reader, k string, v *gobs.LooperState) error {
 		if keyRe != nil && !keyRe.MatchString(k) {
 			return nil
@@ -85,6 +87,7 @@ func (c *List) Run(ctx context.Context, args []string) error {
 				log.Printf("looper at %q has nil status", k)
 				return nil
 			}
+			status.Budget = t.BudgetAt(feePct)
 			value = status
 		}
 
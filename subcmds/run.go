@@ -16,6 +16,8 @@ import (
 	"os/signal"
 	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -46,6 +48,20 @@ type Run struct {
 	maxFetchTimeLatency  time.Duration
 	maxHttpClientTimeout time.Duration
 
+	paperTrading       bool
+	paperTradingFeePct float64
+
+	quoteCurrencies string
+	priceAliases    string
+	enableProducts  string
+
+	recordTickers   bool
+	tickerRetention time.Duration
+
+	websocketLogDir string
+
+	cancelledOrderRetention time.Duration
+
 	secretsPath string
 	dataDir     string
 }
@@ -61,6 +77,15 @@ func (c *Run) Command() (*flag.FlagSet, cli.CmdFunc) {
 	fset.BoolVar(&c.noFetchCandles, "no-fetch-candles", true, "when true, candle data is not saved in the datastore")
 	fset.DurationVar(&c.maxFetchTimeLatency, "max-fetch-time-latency", 0, "max latency for fetch-time operation in finding time difference")
 	fset.DurationVar(&c.maxHttpClientTimeout, "max-http-client-timeout", 30*time.Second, "default max timeout for http requests")
+	fset.BoolVar(&c.paperTrading, "paper-trading", false, "when true, orders are simulated and never placed on the exchange")
+	fset.Float64Var(&c.paperTradingFeePct, "paper-trading-fee-pct", 0.25, "fee percentage charged on simulated orders")
+	fset.StringVar(&c.quoteCurrencies, "quote-currencies", "USD,USDC", "comma separated list of quote currencies for the supported products")
+	fset.StringVar(&c.priceAliases, "price-aliases", "", "comma separated list of product=source pairs where product uses the source product prices (default: X-USDC=X-USD)")
+	fset.StringVar(&c.enableProducts, "enable-products", "", "comma separated list of additional product ids to enable for trading; ids can be prefixed with an exchange name as in binance:BTC-USDT")
+	fset.BoolVar(&c.recordTickers, "record-tickers", false, "when true, every ticker of the watched products is saved in the datastore")
+	fset.DurationVar(&c.tickerRetention, "ticker-retention", 7*24*time.Hour, "max age of the recorded tickers; negative value keeps them forever")
+	fset.StringVar(&c.websocketLogDir, "websocket-log-dir", "", "when non-empty, raw websocket messages are saved in this directory for replaying; relative paths are resolved against the data directory")
+	fset.DurationVar(&c.cancelledOrderRetention, "cancelled-order-retention", 7*24*time.Hour, "max age of the cancelled orders with zero filled size in the datastore; negative value keeps them forever")
 	fset.StringVar(&c.secretsPath, "secrets-file", "", "path to credentials file")
 	fset.StringVar(&c.dataDir, "data-dir", "", "path to the data directory")
 	return fset, cli.CmdFunc(c.run)
@@ -89,9 +114,31 @@ JSON format. A example secrets file format is given below:
         }
     }
 
+Credentials for the other supported exchanges (eg: binance) are given under
+the "exchanges" object with the exchange name as the key:
+
+    {
+        "exchanges":{
+            "binance":{
+                "key":"333333333",
+                "secret":"4444444444"
+            }
+        }
+    }
+
 Users should consult the exchange specific documentation to learn how to create
 the API keys.
 
+PAPER TRADING
+
+When the -paper-trading flag is true, all jobs run against a simulated
+exchange. Exchange is still used for the product information and the price
+feed, but limit orders are matched in memory against the ticker prices and are
+never placed on the exchange. Simulated orders must never be mixed with the
+real orders, so paper trading requires a separate -data-dir. Trader refuses to
+start in paper trading mode when the data directory has live trading jobs and
+in live trading mode when the data directory was used for paper trading.
+
 `
 }
 
@@ -160,7 +207,7 @@ func (c *Run) run(ctx context.Context, args []string) error {
 		return false, nil
 	}
 
-	logger, err := logdir.New(dataDir, "tradebot")
+	logger, err := logdir.New(dataDir, "tradebot", 0, nil)
 	if err != nil {
 		return fmt.Errorf("could not create logger: %w", err)
 	}
@@ -243,11 +290,31 @@ func (c *Run) run(ctx context.Context, args []string) error {
 	s.AddHandler("/db/", http.StripPrefix("/db", kvhttp.Handler(db)))
 
 	// Start other services.
+	coinbaseParams := map[string]string{
+		"no-fetch-candles":          strconv.FormatBool(c.noFetchCandles),
+		"record-tickers":            strconv.FormatBool(c.recordTickers),
+		"ticker-retention":          c.tickerRetention.String(),
+		"cancelled-order-retention": c.cancelledOrderRetention.String(),
+	}
+	if c.maxFetchTimeLatency != 0 {
+		coinbaseParams["max-fetch-time-latency"] = c.maxFetchTimeLatency.String()
+	}
+	if len(c.priceAliases) > 0 {
+		coinbaseParams["price-aliases"] = c.priceAliases
+	}
+	if len(c.websocketLogDir) > 0 {
+		coinbaseParams["websocket-log-dir"] = c.websocketLogDir
+	}
 	topts := &server.Options{
+		DataDir:              dataDir,
+		QuoteCurrencies:      splitList(c.quoteCurrencies),
+		ExchangeParams:       map[string]map[string]string{"coinbase": coinbaseParams},
+		EnableProductIDs:     splitList(c.enableProducts),
 		NoResume:             c.noResume,
-		NoFetchCandles:       c.noFetchCandles,
-		MaxFetchTimeLatency:  c.maxFetchTimeLatency,
 		MaxHttpClientTimeout: c.maxHttpClientTimeout,
+		PaperTrading:         c.paperTrading,
+		PaperTradingFeePct:   c.paperTradingFeePct,
+		PaperTradingNoFees:   c.paperTradingFeePct == 0,
 	}
 	trader, err := server.New(ctx, secrets, db, topts)
 	if err != nil {
@@ -290,3 +357,14 @@ func (c *Run) run(ctx context.Context, args []string) error {
 func isGoodKey(k string) bool {
 	return path.IsAbs(k) && k == path.Clean(k)
 }
+
+// splitList returns the non-empty items from a comma separated list.
+func splitList(s string) []string {
+	var vs []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
@@ -15,8 +15,11 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"github.com/bvk/tradebot/api"
 	"github.com/bvk/tradebot/cli"
 	"github.com/bvk/tradebot/coinbase"
+	"github.com/bvk/tradebot/dca"
+	"github.com/bvk/tradebot/gobs"
 	"github.com/bvk/tradebot/job"
 	"github.com/bvk/tradebot/limiter"
 	"github.com/bvk/tradebot/looper"
@@ -25,6 +28,7 @@ import (
 	"github.com/bvk/tradebot/subcmds/cmdutil"
 	"github.com/bvk/tradebot/timerange"
 	"github.com/bvk/tradebot/trader"
+	"github.com/bvk/tradebot/trailer"
 	"github.com/bvk/tradebot/waller"
 	"github.com/bvkgo/kv"
 	"github.com/shopspring/decimal"
@@ -72,6 +76,7 @@ func (c *Status) run(ctx context.Context, args []string) error {
 		}
 		priceMap = make(map[string]decimal.Decimal)
 	}
+	fees := cmdutil.LoadFeeSchedule(ctx, db)
 
 	var assets []string
 	holdMap := make(map[string]decimal.Decimal)
@@ -117,6 +122,8 @@ func (c *Status) run(ctx context.Context, args []string) error {
 			uid = strings.TrimPrefix(uid, limiter.DefaultKeyspace)
 			uid = strings.TrimPrefix(uid, looper.DefaultKeyspace)
 			uid = strings.TrimPrefix(uid, waller.DefaultKeyspace)
+			uid = strings.TrimPrefix(uid, dca.DefaultKeyspace)
+			uid = strings.TrimPrefix(uid, trailer.DefaultKeyspace)
 			name := uid
 			if v, _, _, err := namer.Resolve(ctx, r, uid); err == nil {
 				name = v
@@ -175,22 +182,51 @@ func (c *Status) run(ctx context.Context, args []string) error {
 		return jobs[i].ProductID() < jobs[j].ProductID()
 	})
 
+	// Jobs report the budget at the default or the past effective fee
+	// percentage, so we adjust them for the current maker fee of their
+	// exchange. Budgets of the jobs on exchanges without a saved fee schedule
+	// are left at the job's own fee percentage.
+	feesMap := make(map[string]*gobs.FeeSchedule)
+	exchangeFees := func(name string) *gobs.FeeSchedule {
+		name = strings.ToLower(name)
+		if v, ok := feesMap[name]; ok {
+			return v
+		}
+		v, err := cmdutil.LoadExchangeFeeSchedule(ctx, db, name)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("could not load fee schedule for exchange %q (ignored): %v", name, err)
+		}
+		feesMap[name] = v
+		return v
+	}
+
 	var statuses []*trader.Status
 	for _, j := range jobs {
 		if v, ok := j.(Statuser); ok {
 			if s := v.Status(&period); s != nil {
+				if fees := exchangeFees(j.ExchangeName()); fees != nil {
+					s.Budget = j.BudgetAt(fees.MakerFeePct.InexactFloat64())
+				}
 				statuses = append(statuses, s)
 			}
 		}
 	}
 
-	sum := trader.Summarize(statuses)
-	var curUnsoldValue decimal.Decimal
+	// Values in different quote currencies cannot be added, so statuses are
+	// summarized separately for each quote currency.
+	sumMap := trader.SummarizeByQuote(statuses)
+	curUnsoldValueMap := make(map[string]decimal.Decimal)
 	for _, s := range statuses {
 		if p, ok := priceMap[s.ProductID]; ok {
-			curUnsoldValue = curUnsoldValue.Add(s.UnsoldSize.Mul(p))
+			quote := s.QuoteCurrency()
+			curUnsoldValueMap[quote] = curUnsoldValueMap[quote].Add(s.UnsoldSize.Mul(p))
 		}
 	}
+	var quotes []string
+	for quote := range sumMap {
+		quotes = append(quotes, quote)
+	}
+	sort.Strings(quotes)
 
 	var runningStatuses []*trader.Status
 	for _, s := range statuses {
@@ -198,40 +234,19 @@ func (c *Status) run(ctx context.Context, args []string) error {
 			runningStatuses = append(runningStatuses, s)
 		}
 	}
-	runningSum := trader.Summarize(runningStatuses)
-
-	var (
-		d30  = decimal.NewFromInt(30)
-		d100 = decimal.NewFromInt(100)
-		d365 = decimal.NewFromInt(365)
-	)
+	runningSumMap := trader.SummarizeByQuote(runningStatuses)
 
 	if period.IsZero() {
-		fmt.Printf("Num Days: %s\n", sum.NumDays().StringFixed(2))
-		fmt.Printf("Num Buys: %d\n", sum.NumBuys)
-		fmt.Printf("Num Sells: %d\n", sum.NumSells)
-
-		fmt.Println()
-		fmt.Printf("Fees: %s\n", sum.Fees().StringFixed(3))
-		fmt.Printf("Sold: %s\n", sum.Sold().StringFixed(3))
-		fmt.Printf("Bought: %s\n", sum.Bought().StringFixed(3))
-		fmt.Printf("Effective Fee Pct: %s%%\n", sum.FeePct().StringFixed(3))
-
-		fmt.Println()
-		fmt.Printf("Lockin Position: %s\n", curUnsoldValue.Sub(sum.UnsoldValue).StringFixed(3))
-		fmt.Printf("Lockin at Buy Price: %s\n", sum.UnsoldValue.StringFixed(3))
-		fmt.Printf("Lockin at Current Price: %s\n", curUnsoldValue.StringFixed(3))
+		fmt.Printf("Current Maker Fee Pct: %s%%\n", fees.MakerFeePct.StringFixed(3))
+		fmt.Printf("Current Taker Fee Pct: %s%%\n", fees.TakerFeePct.StringFixed(3))
 
-		fmt.Println()
-		fmt.Printf("Profit: %s\n", sum.Profit().StringFixed(3))
-		fmt.Printf("Per day (average): %s\n", sum.ProfitPerDay().StringFixed(3))
-		fmt.Printf("Per month (projected): %s\n", sum.ProfitPerDay().Mul(d30).StringFixed(3))
-		fmt.Printf("Per year (projected): %s\n", sum.ProfitPerDay().Mul(d365).StringFixed(3))
-
-		fmt.Println()
-		fmt.Printf("Budget: %s\n", runningSum.Budget.StringFixed(3))
-		fmt.Printf("Return Rate: %s%%\n", runningSum.ReturnRate().StringFixed(3))
-		fmt.Printf("Annual Return Rate: %s%%\n", runningSum.AnnualReturnRate().StringFixed(3))
+		for _, quote := range quotes {
+			runningSum, ok := runningSumMap[quote]
+			if !ok {
+				runningSum = new(trader.Summary)
+			}
+			c.printSummary(quote, sumMap[quote], runningSum, curUnsoldValueMap[quote])
+		}
 	}
 
 	emptystrings := func(n int) (vs []any) {
@@ -241,39 +256,12 @@ func (c *Status) run(ctx context.Context, args []string) error {
 		return vs
 	}
 
-	if p := sum.Profit(); p.IsPositive() && period.IsZero() {
-		fmt.Println()
-		unsold, _ := sum.UnsoldValue.Float64()
-		amounts := []float64{unsold, 50000, 100000, 200000, 250000, 500000}
-		if c.budget != 0 {
-			amounts = append([]float64{c.budget}, amounts...)
-		}
-		amounts = slices.DeleteFunc(amounts, func(v float64) bool { return v == 0 })
-
-		fmtstr := strings.Repeat("%s\t", len(amounts)+1)
-		amts := []any{"Amounts"}
-		covered := []any{"Covered"}
-		projected := []any{"Projected"}
-		for _, amount := range amounts {
-			c := sum.Profit().Mul(d100).Div(decimal.NewFromFloat(amount))
-			p := sum.ProfitPerDay().Mul(d365).Mul(d100).Div(decimal.NewFromFloat(amount))
-			amts = append(amts, fmt.Sprintf("%.02f", amount))
-			covered = append(covered, fmt.Sprintf("%s%%", c.StringFixed(3)))
-			projected = append(projected, fmt.Sprintf("%s%%", p.StringFixed(3)))
-		}
-		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
-		fmt.Fprintf(tw, fmtstr+"\n", amts...)
-		fmt.Fprintf(tw, fmtstr+"\n", covered...)
-		fmt.Fprintf(tw, fmtstr+"\n", projected...)
-		tw.Flush()
-	}
-
 	if len(availMap) > 0 && period.IsZero() {
 		fmt.Println()
 		ids := []any{""}
 		avails := []any{"Available"}
 		holds := []any{"Hold"}
-		prices := []any{"Price"}
+		prices := []any{"USD Price"}
 		totals := []any{"Total"}
 		for _, a := range assets {
 			currency := currencyMap[a]
@@ -299,6 +287,10 @@ func (c *Status) run(ctx context.Context, args []string) error {
 		tw.Flush()
 	}
 
+	if c.DBFlags.IsRemote() && period.IsZero() && len(statuses) > 0 {
+		c.printSpreads(ctx, statuses)
+	}
+
 	if len(statuses) > 0 {
 		order := []string{"RUNNING", "PAUSED", "COMPLETED", "FAILED", "CANCELED"}
 		sort.Slice(statuses, func(i, j int) bool {
@@ -322,3 +314,119 @@ func (c *Status) run(ctx context.Context, args []string) error {
 	}
 	return nil
 }
+
+// printSummary prints the summary of all jobs trading in the given quote
+// currency.
+func (c *Status) printSummary(quote string, sum, runningSum *trader.Summary, curUnsoldValue decimal.Decimal) {
+	var (
+		d30  = decimal.NewFromInt(30)
+		d100 = decimal.NewFromInt(100)
+		d365 = decimal.NewFromInt(365)
+	)
+
+	fmt.Println()
+	fmt.Printf("Quote Currency: %s\n", quote)
+	fmt.Printf("Num Days: %s\n", sum.NumDays().StringFixed(2))
+	fmt.Printf("Num Buys: %d\n", sum.NumBuys)
+	fmt.Printf("Num Sells: %d\n", sum.NumSells)
+
+	fmt.Println()
+	fmt.Printf("Fees: %s\n", sum.Fees().StringFixed(3))
+	fmt.Printf("Sold: %s\n", sum.Sold().StringFixed(3))
+	fmt.Printf("Bought: %s\n", sum.Bought().StringFixed(3))
+	fmt.Printf("Effective Fee Pct: %s%%\n", sum.FeePct().StringFixed(3))
+
+	fmt.Println()
+	fmt.Printf("Lockin Position: %s\n", curUnsoldValue.Sub(sum.UnsoldValue).StringFixed(3))
+	fmt.Printf("Lockin at Buy Price: %s\n", sum.UnsoldValue.StringFixed(3))
+	fmt.Printf("Lockin at Current Price: %s\n", curUnsoldValue.StringFixed(3))
+
+	fmt.Println()
+	fmt.Printf("Profit: %s\n", sum.Profit().StringFixed(3))
+	fmt.Printf("Per day (average): %s\n", sum.ProfitPerDay().StringFixed(3))
+	fmt.Printf("Per month (projected): %s\n", sum.ProfitPerDay().Mul(d30).StringFixed(3))
+	fmt.Printf("Per year (projected): %s\n", sum.ProfitPerDay().Mul(d365).StringFixed(3))
+
+	fmt.Println()
+	fmt.Printf("Budget: %s\n", runningSum.Budget.StringFixed(3))
+	fmt.Printf("Return Rate: %s%%\n", runningSum.ReturnRate().StringFixed(3))
+	fmt.Printf("Annual Return Rate: %s%%\n", runningSum.AnnualReturnRate().StringFixed(3))
+
+	if !sum.Profit().IsPositive() {
+		return
+	}
+
+	fmt.Println()
+	unsold, _ := sum.UnsoldValue.Float64()
+	amounts := []float64{unsold}
+	// Fixed amounts are in dollars, so they are only meaningful for the
+	// dollar quote currencies.
+	if quote == "USD" || quote == "USDC" {
+		amounts = append(amounts, 50000, 100000, 200000, 250000, 500000)
+	}
+	if c.budget != 0 {
+		amounts = append([]float64{c.budget}, amounts...)
+	}
+	amounts = slices.DeleteFunc(amounts, func(v float64) bool { return v == 0 })
+
+	fmtstr := strings.Repeat("%s\t", len(amounts)+1)
+	amts := []any{fmt.Sprintf("Amounts (%s)", quote)}
+	covered := []any{"Covered"}
+	projected := []any{"Projected"}
+	for _, amount := range amounts {
+		c := sum.Profit().Mul(d100).Div(decimal.NewFromFloat(amount))
+		p := sum.ProfitPerDay().Mul(d365).Mul(d100).Div(decimal.NewFromFloat(amount))
+		amts = append(amts, fmt.Sprintf("%.02f", amount))
+		covered = append(covered, fmt.Sprintf("%s%%", c.StringFixed(3)))
+		projected = append(projected, fmt.Sprintf("%s%%", p.StringFixed(3)))
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, fmtstr+"\n", amts...)
+	fmt.Fprintf(tw, fmtstr+"\n", covered...)
+	fmt.Fprintf(tw, fmtstr+"\n", projected...)
+	tw.Flush()
+}
+
+// printSpreads prints the best bid and ask prices and the spread for the
+// products of the jobs from the server's live order books.
+func (c *Status) printSpreads(ctx context.Context, statuses []*trader.Status) {
+	type key struct{ exchange, product string }
+	var keys []key
+	for _, s := range statuses {
+		k := key{s.ExchangeName, s.ProductID}
+		if !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+
+	var rows []string
+	for _, k := range keys {
+		req := &api.ExchangeGetProductRequest{
+			ExchangeName: k.exchange,
+			ProductID:    k.product,
+		}
+		resp, err := cmdutil.Post[api.ExchangeGetProductResponse](ctx, &c.ClientFlags, api.ExchangeGetProductPath, req)
+		if err != nil {
+			log.Printf("could not fetch best bid and ask for product %q (ignored): %v", k.product, err)
+			continue
+		}
+		if len(resp.Error) != 0 {
+			log.Printf("could not fetch best bid and ask for product %q (ignored): %s", k.product, resp.Error)
+			continue
+		}
+		if v := resp.BestBidAsk; v != nil {
+			rows = append(rows, fmt.Sprintf("%s\t%s\t%s\t%s\t\n", k.product, v.BidPrice.StringFixed(3), v.AskPrice.StringFixed(3), v.Spread().StringFixed(3)))
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Product\tBid\tAsk\tSpread\t\n")
+	for _, row := range rows {
+		fmt.Fprint(tw, row)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package trailer
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/bvk/tradebot/api"
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+	"github.com/shopspring/decimal"
+)
+
+type Add struct {
+	cmdutil.ClientFlags
+
+	product  string
+	exchange string
+
+	skipFundsCheck bool
+
+	size            float64
+	trailAmount     float64
+	trailPct        float64
+	orderType       string
+	limitOffset     float64
+	activationPrice float64
+}
+
+func (c *Add) check() error {
+	if len(c.product) == 0 {
+		return fmt.Errorf("product name cannot be empty")
+	}
+	if len(c.exchange) == 0 {
+		return fmt.Errorf("exchange name cannot be empty")
+	}
+
+	if c.size <= 0 {
+		return fmt.Errorf("size cannot be zero or negative")
+	}
+	if c.trailAmount < 0 || c.trailPct < 0 {
+		return fmt.Errorf("trail amount or percentage cannot be negative")
+	}
+	if (c.trailAmount == 0) == (c.trailPct == 0) {
+		return fmt.Errorf("exactly one of trail amount or percentage must be given")
+	}
+	if c.trailPct >= 100 {
+		return fmt.Errorf("trail percentage must be less than 100")
+	}
+	if t := strings.ToUpper(c.orderType); t != "MARKET" && t != "LIMIT" {
+		return fmt.Errorf("order type must be one of market or limit")
+	}
+	if c.limitOffset < 0 {
+		return fmt.Errorf("limit offset cannot be negative")
+	}
+	if c.activationPrice < 0 {
+		return fmt.Errorf("activation price cannot be negative")
+	}
+	return nil
+}
+
+func (c *Add) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("this command takes no arguments")
+	}
+	if err := c.check(); err != nil {
+		return err
+	}
+
+	req := &api.TrailRequest{
+		ProductID:       c.product,
+		ExchangeName:    c.exchange,
+		SkipFundsCheck:  c.skipFundsCheck,
+		Size:            decimal.NewFromFloat(c.size),
+		TrailAmount:     decimal.NewFromFloat(c.trailAmount),
+		TrailPct:        decimal.NewFromFloat(c.trailPct),
+		OrderType:       strings.ToUpper(c.orderType),
+		LimitOffset:     decimal.NewFromFloat(c.limitOffset),
+		ActivationPrice: decimal.NewFromFloat(c.activationPrice),
+	}
+	resp, err := cmdutil.Post[api.TrailResponse](ctx, &c.ClientFlags, api.TrailPath, req)
+	if err != nil {
+		return err
+	}
+	jsdata, _ := json.MarshalIndent(resp, "", "  ")
+	fmt.Printf("%s\n", jsdata)
+	return nil
+}
+
+func (c *Add) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("add", flag.ContinueOnError)
+	c.ClientFlags.SetFlags(fset)
+	fset.Float64Var(&c.size, "size", 0, "asset size to sell when the stop is triggered")
+	fset.Float64Var(&c.trailAmount, "trail-amount", 0, "retrace amount from the high price that triggers the sell")
+	fset.Float64Var(&c.trailPct, "trail-pct", 0, "retrace percentage from the high price that triggers the sell")
+	fset.StringVar(&c.orderType, "order-type", "market", "sell order type; one of market or limit")
+	fset.Float64Var(&c.limitOffset, "limit-offset", 0, "offset below the stop price for the limit sell orders")
+	fset.Float64Var(&c.activationPrice, "activation-price", 0, "when non-zero, trail starts after the price reaches this value")
+	fset.StringVar(&c.product, "product", "", "product id for the trade")
+	fset.StringVar(&c.exchange, "exchange", "coinbase", "exchange name for the product")
+	fset.BoolVar(&c.skipFundsCheck, "skip-funds-check", false, "when true, job is created without checking the available balance")
+	return fset, cli.CmdFunc(c.Run)
+}
+
+func (c *Add) Synopsis() string {
+	return "Creates a new trailing stop sell job"
+}
+
+func (c *Add) CommandHelp() string {
+	return `
+
+Command "add" creates a new trailing stop job that follows the high-water mark
+of the ticker price and sells the given size when the price retraces from the
+high by a fixed amount (with -trail-amount flag) or by a percentage (with
+-trail-pct flag).
+
+Sell is placed as a market order by default. With "-order-type=limit" flag, a
+limit order is placed at the -limit-offset below the stop price. When the
+price gaps below the limit price for more than a minute, the limit order is
+cancelled and the remaining size is sold with a market order.
+
+When the -activation-price flag is given, high-water mark is not tracked till
+the ticker price reaches the activation price, which makes the job a trailing
+take-profit instead of a trailing stop-loss.
+
+Trail parameters can be updated while the job is running with the
+"trail-amount", "trail-pct", "order-type", "limit-offset" and
+"activation-price" job options.
+
+`
+}
@@ -24,6 +24,8 @@ type Add struct {
 	exchange string
 	name     string
 
+	skipFundsCheck bool
+
 	spec Spec
 }
 
@@ -60,6 +62,15 @@ func (c *Add) Run(ctx context.Context, args []string) error {
 	if len(args) != 0 {
 		return fmt.Errorf("this command takes no arguments")
 	}
+
+	feeReq := &api.ExchangeFeeScheduleRequest{ExchangeName: c.exchange}
+	feeResp, err := cmdutil.Post[api.ExchangeFeeScheduleResponse](ctx, &c.ClientFlags, api.ExchangeFeeSchedulePath, feeReq)
+	if err != nil {
+		log.Printf("could not fetch exchange fee schedule (ignored): %v", err)
+	} else if len(feeResp.Error) == 0 && feeResp.FeeSchedule != nil {
+		c.spec.UseFeePct(feeResp.FeeSchedule.MakerFeePct.InexactFloat64())
+	}
+
 	if err := c.check(); err != nil {
 		return err
 	}
@@ -80,9 +91,10 @@ func (c *Add) Run(ctx context.Context, args []string) error {
 	}
 
 	req1 := &api.WallRequest{
-		ProductID:    c.product,
-		ExchangeName: c.exchange,
-		Pairs:        pairs,
+		ProductID:      c.product,
+		ExchangeName:   c.exchange,
+		SkipFundsCheck: c.skipFundsCheck,
+		Pairs:          pairs,
 	}
 	resp1, err := cmdutil.Post[api.WallResponse](ctx, &c.ClientFlags, api.WallPath, req1)
 	if err != nil {
@@ -110,6 +122,7 @@ func (c *Add) Command() (*flag.FlagSet, cli.CmdFunc) {
 	fset.StringVar(&c.name, "name", "", "a name for the trader job")
 	fset.StringVar(&c.product, "product", "", "product id for the trader")
 	fset.StringVar(&c.exchange, "exchange", "coinbase", "exchange name for the product")
+	fset.BoolVar(&c.skipFundsCheck, "skip-funds-check", false, "when true, job is created without checking the available balance")
 	return fset, cli.CmdFunc(c.Run)
 }
 
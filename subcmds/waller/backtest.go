@@ -0,0 +1,110 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package waller
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/coinbase"
+	"github.com/bvk/tradebot/point"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
+	"github.com/bvk/tradebot/waller"
+	"github.com/google/uuid"
+)
+
+type Backtest struct {
+	cmdutil.DBFlags
+	cmdutil.BacktestFlags
+
+	product  string
+	exchange string
+
+	spec Spec
+}
+
+func (c *Backtest) check() error {
+	if len(c.product) == 0 {
+		return fmt.Errorf("product name cannot be empty")
+	}
+	if len(c.exchange) == 0 {
+		return fmt.Errorf("exchange name cannot be empty")
+	}
+	if err := c.spec.Check(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Backtest) buySellPairs() []*point.Pair {
+	if c.spec.profitMargin > 0 {
+		return fixedProfitPairs(&c.spec)
+	}
+	if c.spec.profitMarginPct > 0 {
+		return percentProfitPairs(&c.spec)
+	}
+	return nil
+}
+
+func (c *Backtest) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("this command takes no arguments")
+	}
+
+	db, closer, err := c.DBFlags.GetDatabase(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create db instance: %w", err)
+	}
+	defer closer()
+
+	if fees, err := coinbase.NewDatastore(db).LoadFeeSchedule(ctx); err == nil {
+		c.spec.UseFeePct(fees.MakerFeePct.InexactFloat64())
+	}
+
+	if err := c.check(); err != nil {
+		return err
+	}
+
+	pairs := c.buySellPairs()
+	if pairs == nil {
+		return fmt.Errorf("could not determine buy/sell points")
+	}
+
+	w, err := waller.New(uuid.New().String(), c.exchange, c.product, pairs)
+	if err != nil {
+		return fmt.Errorf("could not create waller instance: %w", err)
+	}
+
+	return c.BacktestFlags.Backtest(ctx, db, w)
+}
+
+func (c *Backtest) Command() (*flag.FlagSet, cli.CmdFunc) {
+	fset := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	c.DBFlags.SetFlags(fset)
+	c.BacktestFlags.SetFlags(fset)
+	c.spec.SetFlags(fset)
+	fset.StringVar(&c.product, "product", "", "product id for the trader")
+	fset.StringVar(&c.exchange, "exchange", "coinbase", "exchange name for the product")
+	return fset, cli.CmdFunc(c.Run)
+}
+
+func (c *Backtest) Synopsis() string {
+	return "Simulates a waller job over historical candles"
+}
+
+func (c *Backtest) CommandHelp() string {
+	return `
+
+Command "backtest" creates a temporary waller job with the same flags as the
+"add" command and replays the historical candles saved in the database (by the
+"coinbase sync" command) for the product through it on a simulated exchange.
+Nothing is saved to the database and no orders are placed with the exchange.
+
+Each candle is expanded into a few ticker prices (open, low, high and close)
+so results are only an approximation of the real trading. Summary printed at
+the end is same as the "status" command output for the job.
+
+`
+}
@@ -64,6 +64,7 @@ func (c *Get) Run(ctx context.Context, args []string) error {
 
 	// Print the waller state in a human readable format.
 	s := wall.Status(nil)
+	s.Budget = wall.BudgetAt(cmdutil.LoadFeeSchedule(ctx, db).MakerFeePct.InexactFloat64())
 	fmt.Println("UID", s.UID)
 	fmt.Println("ProductID", s.ProductID)
 	fmt.Println("ExchangeName", s.ExchangeName)
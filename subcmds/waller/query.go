@@ -10,6 +10,7 @@ import (
 	"text/tabwriter"
 
 	"github.com/bvk/tradebot/cli"
+	"github.com/bvk/tradebot/subcmds/cmdutil"
 	"github.com/bvk/tradebot/waller"
 	"github.com/shopspring/decimal"
 )
@@ -17,12 +18,22 @@ import (
 var aprs = []float64{5, 10, 20, 30}
 
 type Query struct {
+	cmdutil.DBFlags
+
 	spec Spec
 
 	printPairs bool
 }
 
 func (c *Query) run(ctx context.Context, args []string) error {
+	db, closer, err := c.DBFlags.GetDatabase(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create db instance: %w", err)
+	}
+	defer closer()
+
+	c.spec.UseFeePct(cmdutil.LoadFeeSchedule(ctx, db).MakerFeePct.InexactFloat64())
+
 	if err := c.spec.Check(); err != nil {
 		return err
 	}
@@ -49,6 +60,7 @@ func (c *Query) run(ctx context.Context, args []string) error {
 
 func (c *Query) Command() (*flag.FlagSet, cli.CmdFunc) {
 	fset := flag.NewFlagSet("query", flag.ContinueOnError)
+	c.DBFlags.SetFlags(fset)
 	c.spec.SetFlags(fset)
 	fset.BoolVar(&c.printPairs, "print-pairs", false, "when true, prints buy-sell points")
 	return fset, cli.CmdFunc(c.run)
@@ -73,5 +85,8 @@ Users can get the following information for a waller job:
   - Number of sells required per month for returns at 5%, 10%, etc.
   - TODO: Minimum volatility required for returns at 5%, 10%, etc.
 
+Fees are computed with the maker fee from the exchange fee schedule saved in
+the database, unless the fee percentage is given explicitly with -fee-pct flag.
+
 `
 }
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"strconv"
 
+	"github.com/bvk/tradebot/exchange"
 	"github.com/bvk/tradebot/point"
 	"github.com/shopspring/decimal"
 )
@@ -15,7 +17,10 @@ import (
 var d100 = decimal.NewFromInt(100)
 
 type Spec struct {
-	feePercentage float64
+	// feePercentage holds the exchange fee percentage. It is valid only when
+	// feePercentageSet is true; otherwise, the default is used.
+	feePercentage    float64
+	feePercentageSet bool
 
 	beginPriceRange float64
 	endPriceRange   float64
@@ -45,7 +50,22 @@ func (s *Spec) SetFlags(fset *flag.FlagSet) {
 	fset.Float64Var(&s.buySize, "buy-size", 0, "asset buy-size for the trade")
 	fset.Float64Var(&s.sellSize, "sell-size", 0, "asset sell-size for the trade")
 	fset.Float64Var(&s.cancelOffsetPct, "cancel-offset-pct", 5, "cancel-at price as pct of middle of the price range")
-	fset.Float64Var(&s.feePercentage, "fee-pct", 0.25, "exchange fee percentage to adjust sell margin")
+	fset.Func("fee-pct", "exchange fee percentage to adjust sell margin (default: exchange maker fee)", func(v string) error {
+		pct, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		s.feePercentage, s.feePercentageSet = pct, true
+		return nil
+	})
+}
+
+// UseFeePct sets the exchange fee percentage to use when the fee-pct flag is
+// not given by the user.
+func (s *Spec) UseFeePct(pct float64) {
+	if !s.feePercentageSet {
+		s.feePercentage, s.feePercentageSet = pct, true
+	}
 }
 
 func (s *Spec) BuySellPairs() []*point.Pair {
@@ -53,6 +73,9 @@ func (s *Spec) BuySellPairs() []*point.Pair {
 }
 
 func (s *Spec) setDefaults() {
+	if !s.feePercentageSet {
+		s.feePercentage, s.feePercentageSet = exchange.DefaultFeePct, true
+	}
 	if s.sellSize == 0 {
 		s.sellSize = s.buySize
 	}
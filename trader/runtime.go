@@ -3,19 +3,41 @@
 package trader
 
 import (
-	"context"
 	"time"
 
+	"github.com/bvk/tradebot/clock"
 	"github.com/bvk/tradebot/exchange"
 	"github.com/bvkgo/kv"
 )
 
-type Messenger interface {
-	SendMessage(context.Context, time.Time, string, ...interface{})
-}
+// Messenger is an alias of the exchange.Messenger interface, so that the
+// exchanges and the traders can share the messengers.
+type Messenger = exchange.Messenger
 
 type Runtime struct {
 	Database  kv.Database
 	Product   exchange.Product
 	Messenger Messenger
+
+	// Clock is the time source for the job. It is the wall-clock time for the
+	// live jobs and a simulated time when jobs are run over historical data.
+	Clock clock.Clock
+}
+
+// Now returns the current time from the runtime's clock. Runtimes without a
+// clock use the wall-clock time.
+func (rt *Runtime) Now() time.Time {
+	if rt.Clock == nil {
+		return clock.System.Now()
+	}
+	return rt.Clock.Now()
+}
+
+// After waits for the duration to elapse on the runtime's clock. Runtimes
+// without a clock use the wall-clock time.
+func (rt *Runtime) After(d time.Duration) <-chan time.Time {
+	if rt.Clock == nil {
+		return clock.System.After(d)
+	}
+	return rt.Clock.After(d)
 }
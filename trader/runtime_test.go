@@ -0,0 +1,34 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/clock"
+)
+
+func TestRuntimeClock(t *testing.T) {
+	rt := new(Runtime)
+	if now := rt.Now(); time.Since(now) > time.Minute {
+		t.Fatalf("runtime without a clock: want wall-clock time, got %s", now)
+	}
+	select {
+	case <-rt.After(time.Millisecond):
+	case <-time.After(time.Minute):
+		t.Fatalf("runtime without a clock: timer did not fire")
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sim := clock.NewSimulated(start)
+	rt.Clock = sim
+	if now := rt.Now(); !now.Equal(start) {
+		t.Fatalf("runtime with a simulated clock: want %s, got %s", start, now)
+	}
+	ch := rt.After(time.Hour)
+	sim.Advance(time.Hour)
+	if v := <-ch; !v.Equal(start.Add(time.Hour)) {
+		t.Fatalf("runtime with a simulated clock: want %s, got %s", start.Add(time.Hour), v)
+	}
+}
@@ -4,6 +4,7 @@ package trader
 
 import (
 	"fmt"
+	"strings"
 )
 
 type Status struct {
@@ -17,3 +18,18 @@ type Status struct {
 func (s *Status) String() string {
 	return fmt.Sprintf("uid %s product %s bvalue %s s %s usize %s", s.UID, s.ProductID, s.BoughtSize, s.SoldSize, s.UnsoldSize)
 }
+
+// QuoteCurrency returns the quote currency of the product, which is the
+// currency for all values in the status.
+func (s *Status) QuoteCurrency() string {
+	return QuoteCurrency(s.ProductID)
+}
+
+// QuoteCurrency returns the quote currency part of a product id in the
+// BASE-QUOTE form.
+func QuoteCurrency(productID string) string {
+	if i := strings.LastIndex(productID, "-"); i >= 0 {
+		return productID[i+1:]
+	}
+	return ""
+}
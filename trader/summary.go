@@ -140,3 +140,18 @@ func Summarize(statuses []*Status) *Summary {
 	}
 	return sum
 }
+
+// SummarizeByQuote summarizes the statuses separately for each quote currency,
+// because values in different quote currencies cannot be added together.
+func SummarizeByQuote(statuses []*Status) map[string]*Summary {
+	quoteMap := make(map[string][]*Status)
+	for _, s := range statuses {
+		quote := s.QuoteCurrency()
+		quoteMap[quote] = append(quoteMap[quote], s)
+	}
+	sumMap := make(map[string]*Summary)
+	for quote, vs := range quoteMap {
+		sumMap[quote] = Summarize(vs)
+	}
+	return sumMap
+}
@@ -3,7 +3,11 @@
 package trader
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/bvk/tradebot/gobs"
+	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
@@ -73,3 +77,15 @@ func unsoldActions(buys, sells []*gobs.Action) []*gobs.Action {
 	}
 	return unsold
 }
+
+// CheckUID verifies that the trader uid starts with an uuid.
+func CheckUID(uid string) error {
+	fs := strings.Split(uid, "/")
+	if len(fs) == 0 {
+		return fmt.Errorf("uid cannot be empty")
+	}
+	if _, err := uuid.Parse(fs[0]); err != nil {
+		return fmt.Errorf("uid %q doesn't start with an uuid: %w", uid, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package trailer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// SetOption updates the trail parameters. Trail parameters can be updated
+// while the job is running and take effect from the next ticker.
+func (v *Trailer) SetOption(key, value string) error {
+	optMap := map[string]func(*Trail, string) error{
+		"trail-amount":     setTrailAmountOption,
+		"trail-pct":        setTrailPctOption,
+		"order-type":       setOrderTypeOption,
+		"limit-offset":     setLimitOffsetOption,
+		"activation-price": setActivationPriceOption,
+	}
+	handler, ok := optMap[key]
+	if !ok {
+		return fmt.Errorf("invalid option key %q", key)
+	}
+
+	trail := v.Trail()
+	if err := handler(trail, value); err != nil {
+		return err
+	}
+	if err := trail.Check(); err != nil {
+		return fmt.Errorf("%v: invalid %s option value %q: %w", v.uid, key, value, err)
+	}
+	v.trail.Store(trail)
+
+	v.optionMap[key] = value
+	// Trail amount and percentage options replace each other.
+	switch key {
+	case "trail-amount":
+		delete(v.optionMap, "trail-pct")
+	case "trail-pct":
+		delete(v.optionMap, "trail-amount")
+	}
+	return nil
+}
+
+func setTrailAmountOption(t *Trail, arg string) error {
+	amount, err := decimal.NewFromString(arg)
+	if err != nil {
+		return err
+	}
+	t.TrailAmount, t.TrailPct = amount, decimal.Zero
+	return nil
+}
+
+func setTrailPctOption(t *Trail, arg string) error {
+	pct, err := decimal.NewFromString(arg)
+	if err != nil {
+		return err
+	}
+	t.TrailPct, t.TrailAmount = pct, decimal.Zero
+	return nil
+}
+
+func setOrderTypeOption(t *Trail, arg string) error {
+	t.OrderType = strings.ToUpper(arg)
+	return nil
+}
+
+func setLimitOffsetOption(t *Trail, arg string) error {
+	offset, err := decimal.NewFromString(arg)
+	if err != nil {
+		return err
+	}
+	t.LimitOffset = offset
+	return nil
+}
+
+func setActivationPriceOption(t *Trail, arg string) error {
+	price, err := decimal.NewFromString(arg)
+	if err != nil {
+		return err
+	}
+	t.ActivationPrice = price
+	return nil
+}
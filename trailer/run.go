@@ -0,0 +1,258 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package trailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/trader"
+	"github.com/bvkgo/kv"
+	"github.com/shopspring/decimal"
+)
+
+// gapTimeout is the max duration an active limit sell order is kept open
+// while the ticker price stays below its limit price. The order is cancelled
+// after the timeout and the remaining size is sold with a market order.
+const gapTimeout = time.Minute
+
+func (v *Trailer) Refresh(ctx context.Context, rt *trader.Runtime) error {
+	v.runtimeLock.Lock()
+	defer v.runtimeLock.Unlock()
+
+	if _, err := v.fetchOrderMap(ctx, rt.Product); err != nil {
+		return fmt.Errorf("could not refresh trailer state: %w", err)
+	}
+	return nil
+}
+
+func (v *Trailer) Run(ctx context.Context, rt *trader.Runtime) error {
+	v.runtimeLock.Lock()
+	defer v.runtimeLock.Unlock()
+
+	log.Printf("%s: started trailer job", v.uid)
+	if rt.Product.ProductID() != v.productID {
+		return os.ErrInvalid
+	}
+	nupdated, err := v.fetchOrderMap(ctx, rt.Product)
+	if err != nil {
+		log.Printf("%s: could not refresh/fetch order map: %v", v.uid, err)
+		return err
+	}
+
+	var activeOrderID exchange.OrderID
+	for id, order := range v.dupOrderMap() {
+		if !order.Done {
+			activeOrderID = id
+			log.Printf("%s: reusing existing order %s as the active order", v.uid, activeOrderID)
+		}
+	}
+
+	if activeOrderID == "" && v.sellSize(rt.Product).IsZero() {
+		if nupdated != 0 {
+			_ = kv.WithReadWriter(ctx, rt.Database, v.Save)
+		}
+		log.Printf("%s: trailer is complete cause pending size %s cannot be sold", v.uid, v.PendingSize())
+		return nil
+	}
+
+	dirty := 0
+	flushCh := rt.After(time.Minute)
+
+	localCtx := context.Background()
+
+	tickerCh, stopTickers := rt.Product.TickerCh()
+	defer stopTickers()
+
+	orderUpdatesCh, stopUpdates := rt.Product.OrderUpdatesCh()
+	defer stopUpdates()
+
+	// Sell order creation is retried with an exponential backoff when it
+	// fails, so that a temporary exchange failure doesn't stop the job.
+	var retryAt time.Time
+	var backoff time.Duration
+
+	// activeLimit is the limit price of the active limit sell order and
+	// gapTime is the time when the ticker price has dropped below it. When the
+	// price gaps below the limit for too long, the active order is cancelled
+	// and the remaining size is sold with market orders.
+	var activeLimit decimal.Decimal
+	var gapTime time.Time
+	marketOnly := false
+
+	for activeOrderID != "" || !v.sellSize(rt.Product).IsZero() {
+		select {
+		case <-ctx.Done():
+			if activeOrderID != "" {
+				log.Printf("%s: canceling active sell order %v (%v)", v.uid, activeOrderID, context.Cause(ctx))
+				if err := rt.Product.Cancel(localCtx, activeOrderID); err != nil {
+					return err
+				}
+				dirty++
+			}
+			if err := kv.WithReadWriter(localCtx, rt.Database, v.Save); err != nil {
+				log.Printf("%s: dirty trailer state could not be saved to the database (will retry): %v", v.uid, err)
+			}
+			return context.Cause(ctx)
+
+		case <-flushCh:
+			if dirty > 0 {
+				if err := kv.WithReadWriter(ctx, rt.Database, v.Save); err != nil {
+					log.Printf("%s: dirty trailer state could not be saved to the database (will retry): %v", v.uid, err)
+				} else {
+					dirty = 0
+				}
+			}
+			flushCh = rt.After(time.Minute)
+
+		case order := <-orderUpdatesCh:
+			if _, ok := v.orderMap.Load(order.OrderID); !ok {
+				continue
+			}
+			dirty++
+			v.updateOrderMap(order)
+			if order.Done && order.OrderID == activeOrderID {
+				log.Printf("%s: sell order %s is completed with status %q (DoneReason %q)", v.uid, activeOrderID, order.Status, order.DoneReason)
+				activeOrderID = ""
+				activeLimit, gapTime = decimal.Zero, time.Time{}
+				// High price is not reset, so remaining size, if any, is sold at the
+				// next ticker below the same stop price.
+			}
+
+		case ticker := <-tickerCh:
+			if activeOrderID != "" {
+				if activeLimit.IsZero() || ticker.Price.GreaterThanOrEqual(activeLimit) {
+					gapTime = time.Time{}
+					continue
+				}
+				now := rt.Now()
+				if gapTime.IsZero() {
+					gapTime = now
+				}
+				if now.Sub(gapTime) < gapTimeout {
+					continue
+				}
+				log.Printf("%s: canceling limit sell order %s at %s cause ticker price %s is below the limit since %s", v.uid, activeOrderID, activeLimit, ticker.Price, gapTime)
+				if err := rt.Product.Cancel(localCtx, activeOrderID); err != nil {
+					log.Printf("%s: could not cancel limit sell order %s (will retry): %v", v.uid, activeOrderID, err)
+					continue
+				}
+				activeLimit, marketOnly = decimal.Zero, true
+				continue
+			}
+
+			trail := v.trail.Load()
+			high := v.HighPrice()
+			if high.IsZero() {
+				if p := trail.ActivationPrice; p.IsPositive() && ticker.Price.LessThan(p) {
+					continue
+				}
+				log.Printf("%s: trail is activated at ticker price %s", v.uid, ticker.Price)
+			}
+			if ticker.Price.GreaterThan(high) {
+				high = ticker.Price
+				v.setHighPrice(high)
+				dirty++
+			}
+
+			stop := trail.StopPrice(high)
+			if ticker.Price.GreaterThan(stop) {
+				continue
+			}
+			if rt.Now().Before(retryAt) {
+				continue
+			}
+
+			orderType, limit := trail.OrderType, trail.LimitPrice(stop)
+			if orderType == "LIMIT" && (marketOnly || !limit.IsPositive()) {
+				orderType, limit = "MARKET", decimal.Zero
+			}
+			id, err := v.sell(localCtx, rt, orderType, limit)
+			if err != nil {
+				backoff = min(max(2*backoff, time.Second), time.Minute)
+				retryAt = rt.Now().Add(backoff)
+				log.Printf("%s: could not create the sell order (will retry after %s): %v", v.uid, backoff, err)
+				continue
+			}
+			backoff = 0
+			dirty++
+			if order, ok := v.orderMap.Load(id); ok && !order.Done {
+				activeOrderID, activeLimit = id, limit
+			}
+			if rt.Messenger != nil {
+				rt.Messenger.SendMessage(localCtx, rt.Now(), "Trailing stop for %s is triggered at price %s (high %s, stop %s).", v.productID, ticker.Price, high, stop)
+			}
+		}
+	}
+
+	if err := kv.WithReadWriter(localCtx, rt.Database, v.Save); err != nil {
+		log.Printf("%s: trailer state could not be saved to the database: %v", v.uid, err)
+		return err
+	}
+	log.Printf("%s: trailer is complete with filled size %s", v.uid, v.FilledSize())
+	return nil
+}
+
+// sellSize returns the size for the next sell order rounded down to the
+// product's base increment. It returns zero when the pending size is too
+// small to sell.
+func (v *Trailer) sellSize(product exchange.Product) decimal.Decimal {
+	return exchange.TradableSize(product, v.PendingSize())
+}
+
+// sell creates a market order or a limit order at the given limit price for
+// the pending size.
+func (v *Trailer) sell(ctx context.Context, rt *trader.Runtime, orderType string, limit decimal.Decimal) (exchange.OrderID, error) {
+	offset := v.idgen.Offset()
+	clientOrderID := v.idgen.NextID()
+
+	size := v.sellSize(rt.Product)
+
+	var err error
+	var orderID exchange.OrderID
+	if orderType == "LIMIT" {
+		orderID, err = rt.Product.LimitSell(ctx, clientOrderID.String(), size, limit, nil)
+	} else {
+		orderID, err = rt.Product.MarketSell(ctx, clientOrderID.String(), size)
+	}
+	if err != nil {
+		v.idgen.RevertID()
+		log.Printf("%s: create %s sell order with client-order-id %s (%d reverted) has failed: %v", v.uid, orderType, clientOrderID, offset, err)
+		return "", err
+	}
+
+	// Order updates may be received before the order is added to the order
+	// map, so order state is fetched explicitly.
+	order := &exchange.Order{
+		OrderID:       orderID,
+		ClientOrderID: clientOrderID.String(),
+		Side:          "SELL",
+	}
+	if o, err := rt.Product.Get(ctx, orderID); err == nil {
+		order = o
+	}
+	v.orderMap.Store(orderID, order)
+
+	log.Printf("%s: created a new %s sell order %s for size %s with client-order-id %s (%d)", v.uid, orderType, orderID, size, clientOrderID, offset)
+	return orderID, nil
+}
+
+func (v *Trailer) fetchOrderMap(ctx context.Context, product exchange.Product) (nupdated int, status error) {
+	for id, order := range v.dupOrderMap() {
+		if order.Done {
+			continue
+		}
+		norder, err := product.Get(ctx, id)
+		if err != nil {
+			log.Printf("%s: could not fetch order with id %s: %v", v.uid, id, err)
+			return nupdated, err
+		}
+		v.orderMap.Store(id, norder)
+		nupdated++
+	}
+	return nupdated, nil
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package trailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/idgen"
+	"github.com/bvk/tradebot/kvutil"
+	"github.com/bvk/tradebot/syncmap"
+	"github.com/bvk/tradebot/trader"
+	"github.com/bvkgo/kv"
+	"github.com/shopspring/decimal"
+)
+
+const DefaultKeyspace = "/trailers/"
+
+// Trail holds the parameters for a trailing stop sell.
+type Trail struct {
+	// Size is the asset size to sell when the stop is triggered.
+	Size decimal.Decimal
+
+	// TrailAmount is the retrace amount in quote currency and TrailPct is the
+	// retrace percentage from the high price that triggers the sell. Only one
+	// of them must be non-zero.
+	TrailAmount decimal.Decimal
+	TrailPct    decimal.Decimal
+
+	// OrderType is one of MARKET or LIMIT. LIMIT orders are created at
+	// LimitOffset below the stop price.
+	OrderType   string
+	LimitOffset decimal.Decimal
+
+	// ActivationPrice when non-zero, delays following the ticker till the
+	// price reaches the activation price, which makes it a trailing
+	// take-profit.
+	ActivationPrice decimal.Decimal
+}
+
+func (t *Trail) Check() error {
+	if !t.Size.IsPositive() {
+		return fmt.Errorf("size must be positive")
+	}
+	if t.TrailAmount.IsNegative() || t.TrailPct.IsNegative() {
+		return fmt.Errorf("trail amount or percentage cannot be negative")
+	}
+	if t.TrailAmount.IsZero() == t.TrailPct.IsZero() {
+		return fmt.Errorf("exactly one of trail amount or percentage must be set")
+	}
+	if t.TrailPct.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+		return fmt.Errorf("trail percentage must be less than 100")
+	}
+	if t.OrderType != "MARKET" && t.OrderType != "LIMIT" {
+		return fmt.Errorf("order type must be one of MARKET or LIMIT")
+	}
+	if t.LimitOffset.IsNegative() {
+		return fmt.Errorf("limit offset cannot be negative")
+	}
+	if t.ActivationPrice.IsNegative() {
+		return fmt.Errorf("activation price cannot be negative")
+	}
+	if t.OrderType == "LIMIT" && t.ActivationPrice.IsPositive() && !t.LimitPrice(t.StopPrice(t.ActivationPrice)).IsPositive() {
+		return fmt.Errorf("limit offset must be less than the stop price at the activation price")
+	}
+	return nil
+}
+
+// StopPrice returns the price at or below which the sell is triggered for
+// the given high price.
+func (t *Trail) StopPrice(high decimal.Decimal) decimal.Decimal {
+	if t.TrailAmount.IsPositive() {
+		return high.Sub(t.TrailAmount)
+	}
+	return high.Sub(high.Mul(t.TrailPct).Div(decimal.NewFromInt(100)))
+}
+
+// LimitPrice returns the limit sell price for the given stop price. Returned
+// price is not positive when the limit offset is at or above the stop price.
+func (t *Trail) LimitPrice(stop decimal.Decimal) decimal.Decimal {
+	return stop.Sub(t.LimitOffset)
+}
+
+// Trailer is a trader that follows the high-water mark of the ticker price
+// and sells when the price retraces by the configured trail amount or
+// percentage.
+type Trailer struct {
+	runtimeLock sync.Mutex
+
+	productID    string
+	exchangeName string
+
+	uid string
+
+	idgen *idgen.Generator
+
+	// trail holds the trail parameters. It is replaced as a whole when the
+	// job options are updated while the job is running.
+	trail atomic.Pointer[Trail]
+
+	// highPrice is the high-water mark of the ticker price. It is zero till
+	// the trail is activated.
+	highPrice atomic.Pointer[decimal.Decimal]
+
+	// orderMap holds all orders created by the job. It is used by Run and Save
+	// methods, so it needs to be thread-safe.
+	orderMap syncmap.Map[exchange.OrderID, *exchange.Order]
+
+	optionMap map[string]string
+}
+
+var _ trader.Trader = &Trailer{}
+
+// New creates a trailing stop sell job with the given trail parameters.
+func New(uid, exchangeName, productID string, trail *Trail) (*Trailer, error) {
+	v := &Trailer{
+		productID:    productID,
+		exchangeName: exchangeName,
+		uid:          uid,
+		idgen:        idgen.New(uid, 0),
+		optionMap:    make(map[string]string),
+	}
+	t := *trail
+	v.trail.Store(&t)
+	if err := v.check(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *Trailer) check() error {
+	if len(v.uid) == 0 {
+		return fmt.Errorf("trailer uid is empty")
+	}
+	if err := v.trail.Load().Check(); err != nil {
+		return fmt.Errorf("invalid trail parameters: %w", err)
+	}
+	return nil
+}
+
+func (v *Trailer) String() string {
+	return "trailer:" + v.uid
+}
+
+func (v *Trailer) UID() string {
+	return v.uid
+}
+
+func (v *Trailer) ProductID() string {
+	return v.productID
+}
+
+func (v *Trailer) ExchangeName() string {
+	return v.exchangeName
+}
+
+// HighPrice returns the high-water mark of the ticker price. It returns zero
+// if the trail is not activated yet.
+func (v *Trailer) HighPrice() decimal.Decimal {
+	if p := v.highPrice.Load(); p != nil {
+		return *p
+	}
+	return decimal.Zero
+}
+
+func (v *Trailer) setHighPrice(price decimal.Decimal) {
+	v.highPrice.Store(&price)
+}
+
+// Trail returns a copy of the current trail parameters.
+func (v *Trailer) Trail() *Trail {
+	t := *v.trail.Load()
+	return &t
+}
+
+// BudgetAt returns the estimated value of the sell at the activation price or
+// the high price.
+func (v *Trailer) BudgetAt(feePct float64) decimal.Decimal {
+	t := v.trail.Load()
+	price := decimal.Max(t.ActivationPrice, v.HighPrice())
+	value := t.Size.Mul(price)
+	return value.Add(value.Mul(decimal.NewFromFloat(feePct)).Div(decimal.NewFromInt(100)))
+}
+
+func (v *Trailer) dupOrderMap() map[exchange.OrderID]*exchange.Order {
+	dup := make(map[exchange.OrderID]*exchange.Order)
+	v.orderMap.Range(func(id exchange.OrderID, order *exchange.Order) bool {
+		dup[id] = order
+		return true
+	})
+	return dup
+}
+
+func (v *Trailer) updateOrderMap(order *exchange.Order) {
+	if _, ok := v.orderMap.Load(order.OrderID); ok {
+		v.orderMap.Store(order.OrderID, order)
+	}
+}
+
+func (v *Trailer) Fees() decimal.Decimal {
+	var sum decimal.Decimal
+	for _, order := range v.dupOrderMap() {
+		sum = sum.Add(order.Fee)
+	}
+	return sum
+}
+
+func (v *Trailer) FilledSize() decimal.Decimal {
+	var filled decimal.Decimal
+	for _, order := range v.dupOrderMap() {
+		filled = filled.Add(order.FilledSize)
+	}
+	return filled
+}
+
+func (v *Trailer) FilledValue() decimal.Decimal {
+	var value decimal.Decimal
+	for _, order := range v.dupOrderMap() {
+		value = value.Add(order.FilledSize.Mul(order.FilledPrice))
+	}
+	return value
+}
+
+func (v *Trailer) PendingSize() decimal.Decimal {
+	size := v.trail.Load().Size.Sub(v.FilledSize())
+	if size.LessThanOrEqual(decimal.Zero) {
+		return decimal.Zero
+	}
+	return size
+}
+
+// Actions returns a single sell action with all filled orders.
+func (v *Trailer) Actions() []*gobs.Action {
+	var orders []*gobs.Order
+	for _, order := range v.dupOrderMap() {
+		if order.Done && !order.FilledSize.IsZero() {
+			orders = append(orders, exchange.GobOrder(order))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].CreateTime.Before(orders[j].CreateTime.Time)
+	})
+	if len(orders) == 0 {
+		return nil
+	}
+	point := gobs.Point{
+		Size:  exchange.FilledSize(orders),
+		Price: exchange.AvgPrice(orders),
+	}
+	return []*gobs.Action{{UID: v.uid, PairingKey: v.uid, Point: point, Orders: orders}}
+}
+
+func (v *Trailer) Save(ctx context.Context, rw kv.ReadWriter) error {
+	t := v.trail.Load()
+	gv := &gobs.TrailerState{
+		V1: &gobs.TrailerStateV1{
+			ProductID:        v.productID,
+			ExchangeName:     v.exchangeName,
+			ClientIDSeed:     v.idgen.Seed(),
+			ClientIDOffset:   v.idgen.Offset(),
+			Size:             t.Size,
+			TrailAmount:      t.TrailAmount,
+			TrailPct:         t.TrailPct,
+			OrderType:        t.OrderType,
+			LimitOffset:      t.LimitOffset,
+			ActivationPrice:  t.ActivationPrice,
+			HighPrice:        v.HighPrice(),
+			ServerIDOrderMap: make(map[string]*gobs.Order),
+			Options:          v.optionMap,
+		},
+	}
+	for k, v := range v.dupOrderMap() {
+		gv.V1.ServerIDOrderMap[string(k)] = exchange.GobOrder(v)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gv); err != nil {
+		return fmt.Errorf("could not encode trailer state: %w", err)
+	}
+	key := path.Join(DefaultKeyspace, v.uid)
+	if err := rw.Set(ctx, key, &buf); err != nil {
+		return fmt.Errorf("could not save trailer state: %w", err)
+	}
+	return nil
+}
+
+func Load(ctx context.Context, uid string, r kv.Reader) (*Trailer, error) {
+	if err := trader.CheckUID(uid); err != nil {
+		return nil, err
+	}
+	key := path.Join(DefaultKeyspace, uid)
+	gv, err := kvutil.Get[gobs.TrailerState](ctx, r, key)
+	if err != nil {
+		return nil, fmt.Errorf("could not load trailer state: %w", err)
+	}
+	seed := uid
+	if len(gv.V1.ClientIDSeed) > 0 {
+		seed = gv.V1.ClientIDSeed
+	}
+	v := &Trailer{
+		uid:          uid,
+		productID:    gv.V1.ProductID,
+		exchangeName: gv.V1.ExchangeName,
+		idgen:        idgen.New(seed, gv.V1.ClientIDOffset),
+		optionMap:    make(map[string]string),
+	}
+	v.setHighPrice(gv.V1.HighPrice)
+	v.trail.Store(&Trail{
+		Size:            gv.V1.Size,
+		TrailAmount:     gv.V1.TrailAmount,
+		TrailPct:        gv.V1.TrailPct,
+		OrderType:       gv.V1.OrderType,
+		LimitOffset:     gv.V1.LimitOffset,
+		ActivationPrice: gv.V1.ActivationPrice,
+	})
+	for kk, vv := range gv.V1.ServerIDOrderMap {
+		v.orderMap.Store(exchange.OrderID(kk), exchange.OrderFromGob(vv))
+	}
+	if err := v.check(); err != nil {
+		return nil, err
+	}
+	for opt, val := range gv.V1.Options {
+		if err := v.SetOption(opt, val); err != nil {
+			return nil, fmt.Errorf("could not set options: %v", err)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2024 BVK Chaitanya
+
+package trailer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bvk/tradebot/clock"
+	"github.com/bvk/tradebot/exchange"
+	"github.com/bvk/tradebot/gobs"
+	"github.com/bvk/tradebot/paper"
+	"github.com/bvk/tradebot/trader"
+	"github.com/bvkgo/kv"
+	"github.com/bvkgo/kv/kvmemdb"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestTrailer(t *testing.T) {
+	type testCase struct {
+		trail     *Trail
+		prices    []int64
+		wantPrice decimal.Decimal
+	}
+
+	testCases := map[string]testCase{
+		"market": {
+			trail: &Trail{
+				Size:        decimal.NewFromInt(2),
+				TrailAmount: decimal.NewFromInt(5),
+				OrderType:   "MARKET",
+			},
+			// Stop follows the high to 115 and sells at the first price below it.
+			prices:    []int64{100, 110, 120, 116, 118, 114},
+			wantPrice: decimal.NewFromInt(114),
+		},
+		"limit": {
+			trail: &Trail{
+				Size:            decimal.NewFromInt(2),
+				TrailPct:        decimal.NewFromInt(10),
+				OrderType:       "LIMIT",
+				LimitOffset:     decimal.NewFromInt(1),
+				ActivationPrice: decimal.NewFromInt(100),
+			},
+			// Prices below the activation price must not trigger the sell. Stop is
+			// at 90 after activation and the limit sell is placed at 89.
+			prices:    []int64{90, 80, 100, 95, 89},
+			wantPrice: decimal.NewFromInt(89),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			ex := paper.New(nil, &paper.Options{SyncTickers: true})
+			defer ex.Close()
+
+			product, err := ex.AddProduct(&gobs.Product{
+				ProductID:     "BCH-USD",
+				BaseIncrement: decimal.RequireFromString("0.001"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			uid := uuid.New().String()
+			seller, err := New(uid, "coinbase", "BCH-USD", tc.trail)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db := kvmemdb.New()
+			sim := clock.NewSimulated(time.Now())
+			rt := &trader.Runtime{
+				Database: db,
+				Product:  product,
+				Clock:    sim,
+			}
+
+			errCh := make(chan error, 1)
+			removeReceiver := product.AddSyncReceiver()
+			go func() {
+				err := seller.Run(ctx, rt)
+				removeReceiver()
+				errCh <- err
+			}()
+
+			for i, p := range tc.prices {
+				if i == len(tc.prices)-1 && len(seller.Actions()) != 0 {
+					t.Fatalf("want no sells before the stop price, got %d", len(seller.Actions()))
+				}
+				product.HandleTicker(&exchange.Ticker{
+					Timestamp: exchange.RemoteTime{Time: sim.Now()},
+					Price:     decimal.NewFromInt(p),
+				})
+			}
+			// Job completes when the order for the whole size is filled.
+			select {
+			case <-ctx.Done():
+				t.Fatalf("timed out waiting for the sell")
+			case err := <-errCh:
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := seller.SetOption("trail-pct", "3"); err != nil {
+				t.Fatal(err)
+			}
+			if err := seller.SetOption("order-type", "bad"); err == nil {
+				t.Fatalf("want invalid order-type option to fail")
+			}
+			if err := kv.WithReadWriter(ctx, db, seller.Save); err != nil {
+				t.Fatal(err)
+			}
+
+			var loaded *Trailer
+			load := func(ctx context.Context, r kv.Reader) (err error) {
+				loaded, err = Load(ctx, uid, r)
+				return err
+			}
+			if err := kv.WithReader(ctx, db, load); err != nil {
+				t.Fatal(err)
+			}
+			actions := loaded.Actions()
+			if len(actions) != 1 {
+				t.Fatalf("want 1 sell action, got %d", len(actions))
+			}
+			if a := actions[0]; !a.Point.Size.Equal(tc.trail.Size) || !a.Point.Price.Equal(tc.wantPrice) {
+				t.Fatalf("want sell of size %s at price %s, got %s at %s", tc.trail.Size, tc.wantPrice, a.Point.Size, a.Point.Price)
+			}
+			if trail := loaded.Trail(); !trail.TrailPct.Equal(decimal.NewFromInt(3)) || !trail.TrailAmount.IsZero() {
+				t.Fatalf("want trail-pct option after load, got amount %s pct %s", trail.TrailAmount, trail.TrailPct)
+			}
+		})
+	}
+}
+
+func TestTrailerLimitGapDown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ex := paper.New(nil, &paper.Options{SyncTickers: true})
+	defer ex.Close()
+
+	product, err := ex.AddProduct(&gobs.Product{
+		ProductID:     "BCH-USD",
+		BaseIncrement: decimal.RequireFromString("0.001"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trail := &Trail{
+		Size:        decimal.NewFromInt(2),
+		TrailAmount: decimal.NewFromInt(5),
+		OrderType:   "LIMIT",
+		LimitOffset: decimal.NewFromInt(1),
+	}
+	seller, err := New(uuid.New().String(), "coinbase", "BCH-USD", trail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim := clock.NewSimulated(time.Now())
+	rt := &trader.Runtime{
+		Database: kvmemdb.New(),
+		Product:  product,
+		Clock:    sim,
+	}
+
+	errCh := make(chan error, 1)
+	removeReceiver := product.AddSyncReceiver()
+	go func() {
+		err := seller.Run(ctx, rt)
+		removeReceiver()
+		errCh <- err
+	}()
+
+	// Stop is at 105 and the limit sell at 104 is placed when price gaps down
+	// to 95. Limit order is never filled, so it must be replaced by a market
+	// sell after the gap timeout.
+	prices := []int64{100, 110, 95, 95, 95, 95, 95, 95, 95, 95}
+	for _, p := range prices {
+		product.HandleTicker(&exchange.Ticker{
+			Timestamp: exchange.RemoteTime{Time: sim.Now()},
+			Price:     decimal.NewFromInt(p),
+		})
+		sim.Advance(30 * time.Second)
+	}
+	select {
+	case <-ctx.Done():
+		t.Fatalf("timed out waiting for the sell")
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	actions := seller.Actions()
+	if len(actions) != 1 {
+		t.Fatalf("want 1 sell action, got %d", len(actions))
+	}
+	if a := actions[0]; !a.Point.Size.Equal(trail.Size) || !a.Point.Price.Equal(decimal.NewFromInt(95)) {
+		t.Fatalf("want sell of size %s at price 95, got %s at %s", trail.Size, a.Point.Size, a.Point.Price)
+	}
+	if high := seller.HighPrice(); !high.Equal(decimal.NewFromInt(110)) {
+		t.Fatalf("want high price to be retained at 110, got %s", high)
+	}
+}
+
+func TestTrailLimitOffsetCheck(t *testing.T) {
+	trail := &Trail{
+		Size:            decimal.NewFromInt(1),
+		TrailAmount:     decimal.NewFromInt(5),
+		OrderType:       "LIMIT",
+		LimitOffset:     decimal.NewFromInt(5),
+		ActivationPrice: decimal.NewFromInt(10),
+	}
+	if err := trail.Check(); err == nil {
+		t.Fatalf("want limit offset at or above the stop price to fail")
+	}
+	trail.LimitOffset = decimal.NewFromInt(4)
+	if err := trail.Check(); err != nil {
+		t.Fatal(err)
+	}
+}
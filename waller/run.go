@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/bvk/tradebot/clock"
 	"github.com/bvk/tradebot/trader"
 )
 
@@ -44,7 +45,7 @@ func (w *Waller) Run(ctx context.Context, rt *trader.Runtime) error {
 				if err := loop.Run(ctx, rt); err != nil {
 					if ctx.Err() == nil {
 						log.Printf("wall-looper %v has failed (retry): %v", loop, err)
-						time.Sleep(time.Second)
+						clock.Sleep(ctx, rt, time.Second)
 					}
 				}
 			}
@@ -16,7 +16,6 @@ import (
 	"github.com/bvk/tradebot/point"
 	"github.com/bvk/tradebot/trader"
 	"github.com/bvkgo/kv"
-	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
@@ -177,17 +176,6 @@ func (w *Waller) Save(ctx context.Context, rw kv.ReadWriter) error {
 	return nil
 }
 
-func checkUID(uid string) error {
-	fs := strings.Split(uid, "/")
-	if len(fs) == 0 {
-		return fmt.Errorf("uid cannot be empty")
-	}
-	if _, err := uuid.Parse(fs[0]); err != nil {
-		return fmt.Errorf("uid %q doesn't start with an uuid: %w", uid, err)
-	}
-	return nil
-}
-
 func cleanUID(uid string) string {
 	uid = strings.TrimPrefix(uid, "/wallers/")
 	uid = strings.TrimPrefix(uid, "/limiters/")
@@ -196,7 +184,7 @@ func cleanUID(uid string) string {
 }
 
 func Load(ctx context.Context, uid string, r kv.Reader) (*Waller, error) {
-	if err := checkUID(uid); err != nil {
+	if err := trader.CheckUID(uid); err != nil {
 		return nil, err
 	}
 	key := path.Join(DefaultKeyspace, uid)